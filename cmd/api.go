@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bdmorin/gristle/gristtools"
+	"github.com/spf13/cobra"
+)
+
+var apiData string
+
+var apiCmd = &cobra.Command{
+	Use:   "api <method> <path>",
+	Short: "Send a request to any endpoint of the Grist API",
+	Long: `Send an authenticated request to the configured Grist server and print the
+response, for endpoints gristle has no command for. The path is relative to
+the API root, e.g. "docs/<doc-id>/tables".
+
+The request body is given with --data, read from a file with --data @file,
+or from the standard input with --data @-.`,
+	Example: `  gristle api GET orgs
+  gristle api GET docs/<doc-id>/tables/Table1/records
+  gristle api POST docs/<doc-id>/tables/Table1/records --data @records.json`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		body, err := readAPIData(apiData)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !gristtools.CallAPI(args[0], args[1], body) {
+			os.Exit(1)
+		}
+	},
+}
+
+// readAPIData returns the request body given with --data: the value itself,
+// the content of a file if it starts with "@", or the standard input for "@-"
+func readAPIData(data string) (string, error) {
+	path, isFile := strings.CutPrefix(data, "@")
+	if !isFile {
+		return data, nil
+	}
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		// #nosec G304 - the file is chosen by the user
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read the request body: %w", err)
+	}
+	return string(content), nil
+}
+
+func init() {
+	rootCmd.AddCommand(apiCmd)
+
+	apiCmd.Flags().StringVarP(&apiData, "data", "d", "", "Request body, @file to read it from a file or @- from stdin")
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bdmorin/gristle/gristtools"
+	"github.com/spf13/cobra"
+)
+
+var attachmentsPruneDryRun bool
+
+var docAttachmentsCmd = &cobra.Command{
+	Use:   "attachments",
+	Short: "Manage document attachments",
+	Long:  `Commands for listing, uploading and downloading the attachments of a Grist document.`,
+}
+
+var docAttachmentsListCmd = &cobra.Command{
+	Use:   "list <doc-id>",
+	Short: "List the attachments of a document",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayAttachments(resolveDoc(args[0]))
+	},
+}
+
+var docAttachmentsUploadCmd = &cobra.Command{
+	Use:   "upload <doc-id> <file>...",
+	Short: "Upload files as attachments of a document",
+	Long: `Upload one or more files as attachments of a document and print the ID
+of each new attachment, to be used in Attachments columns.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.UploadAttachments(resolveDoc(args[0]), args[1:]) {
+			os.Exit(1)
+		}
+	},
+}
+
+var docAttachmentsDownloadCmd = &cobra.Command{
+	Use:   "download <doc-id> <attachment-id> [path]",
+	Short: "Download an attachment of a document",
+	Long: `Download an attachment to path, or to its original file name in the
+current directory when path is omitted.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		attachmentID, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid attachment ID: %s\n", args[1])
+			os.Exit(1)
+		}
+		destPath := ""
+		if len(args) == 3 {
+			destPath = args[2]
+		}
+		if !gristtools.DownloadAttachment(resolveDoc(args[0]), attachmentID, destPath) {
+			os.Exit(1)
+		}
+	},
+}
+
+var docAttachmentsPruneCmd = &cobra.Command{
+	Use:   "prune <doc-id>",
+	Short: "Delete the attachments no longer used by a document",
+	Long: `List the attachments not referenced by any cell, with the space they
+take, then delete them after confirmation. With --dry-run, only report
+the reclaimable space.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.PruneAttachments(resolveDoc(args[0]), attachmentsPruneDryRun) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	docCmd.AddCommand(docAttachmentsCmd)
+	docAttachmentsCmd.AddCommand(docAttachmentsListCmd)
+	docAttachmentsCmd.AddCommand(docAttachmentsUploadCmd)
+	docAttachmentsCmd.AddCommand(docAttachmentsDownloadCmd)
+	docAttachmentsCmd.AddCommand(docAttachmentsPruneCmd)
+
+	docAttachmentsPruneCmd.Flags().BoolVar(&attachmentsPruneDryRun, "dry-run", false, "Report unused attachments without deleting them")
+}
@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bdmorin/gristle/gristapi"
+	"github.com/spf13/cobra"
+)
+
+// Shell completion of organization, workspace, document and table IDs
+// Each completion runs a new process, so the resources fetched from the API
+// are cached in a file for a short time, to keep completion responsive
+
+// Lifetime of the completion cache
+const completionCacheTTL = time.Minute
+
+// Timeout of the API requests sent during completion
+const completionTimeout = 5 * time.Second
+
+// A completion candidate: an ID described by the name of the resource
+type completionItem struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Info string `json:"info,omitempty"`
+}
+
+type completionEntry struct {
+	Expires time.Time        `json:"expires"`
+	Items   []completionItem `json:"items"`
+}
+
+// Cached candidates by kind ("orgs", "workspaces", "docs", "tables/<doc>"),
+// for one server
+type completionCache struct {
+	Server  string                     `json:"server"`
+	Entries map[string]completionEntry `json:"entries"`
+}
+
+// completionCachePath returns the path of the cache file, "" if there is no
+// cache directory
+func completionCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gristle", "completion.json")
+}
+
+// cachedCompletions returns the candidates of key from the cache, calling
+// fetch and caching its result if they are missing or expired
+func cachedCompletions(key string, fetch func() []completionItem) []completionItem {
+	path := completionCachePath()
+	server := os.Getenv("GRIST_URL")
+	cache := completionCache{}
+	if path != "" {
+		// #nosec G304 - path is in the user cache directory
+		if data, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(data, &cache)
+		}
+	}
+	if cache.Server != server || cache.Entries == nil {
+		cache = completionCache{Server: server, Entries: map[string]completionEntry{}}
+	}
+	if entry, found := cache.Entries[key]; found && time.Now().Before(entry.Expires) {
+		return entry.Items
+	}
+
+	gristapi.SetTimeout(completionTimeout)
+	items := fetch()
+	if path == "" || len(items) == 0 {
+		return items
+	}
+	now := time.Now()
+	for k, entry := range cache.Entries {
+		if now.After(entry.Expires) {
+			delete(cache.Entries, k)
+		}
+	}
+	cache.Entries[key] = completionEntry{Expires: now.Add(completionCacheTTL), Items: items}
+	if data, err := json.Marshal(cache); err == nil && os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+		_ = os.WriteFile(path, data, 0o600)
+	}
+	return items
+}
+
+// completions formats candidates as "id<TAB>description"
+func completions(items []completionItem) []cobra.Completion {
+	result := make([]cobra.Completion, len(items))
+	for i, item := range items {
+		description := item.Name
+		if item.Info != "" {
+			description += " (" + item.Info + ")"
+		}
+		result[i] = cobra.CompletionWithDesc(item.Id, description)
+	}
+	return result
+}
+
+func orgCompletions() []completionItem {
+	return cachedCompletions("orgs", func() []completionItem {
+		items := []completionItem{}
+		for _, org := range gristapi.GetOrgs() {
+			items = append(items, completionItem{Id: strconv.Itoa(org.Id), Name: org.Name, Info: org.Domain})
+		}
+		return items
+	})
+}
+
+func workspaceCompletions() []completionItem {
+	return cachedCompletions("workspaces", func() []completionItem {
+		items := []completionItem{}
+		gristapi.WalkWorkspaces(func(org gristapi.Org, ws gristapi.Workspace) {
+			items = append(items, completionItem{Id: strconv.Itoa(ws.Id), Name: ws.Name, Info: org.Name})
+		})
+		return items
+	})
+}
+
+func docCompletions() []completionItem {
+	return cachedCompletions("docs", func() []completionItem {
+		items := []completionItem{}
+		gristapi.WalkWorkspaces(func(org gristapi.Org, ws gristapi.Workspace) {
+			for _, doc := range ws.Docs {
+				items = append(items, completionItem{Id: doc.Id, Name: doc.Name, Info: ws.Name})
+			}
+		})
+		return items
+	})
+}
+
+// tableCompletions returns the tables of a document given by ID or by a
+// name known from the completion of documents
+func tableCompletions(docRef string) []completionItem {
+	docId := docRef
+	for _, doc := range docCompletions() {
+		if doc.Id != docRef && strings.EqualFold(doc.Name, docRef) {
+			docId = doc.Id
+		}
+	}
+	return cachedCompletions("tables/"+docId, func() []completionItem {
+		items := []completionItem{}
+		for _, table := range gristapi.GetDocTables(docId).Tables {
+			items = append(items, completionItem{Id: table.Id})
+		}
+		return items
+	})
+}
+
+func completeOrgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return completions(orgCompletions()), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeWorkspaces(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return completions(workspaceCompletions()), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeDocs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return completions(docCompletions()), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTables completes the tables of the document given as first argument
+func completeTables(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completions(tableCompletions(args[0])), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFiles leaves the completion of the argument to the shell
+func completeFiles(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
+// completeArgs completes each positional argument with its completion
+// function; the last one applies to the following arguments
+func completeArgs(completers ...cobra.CompletionFunc) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if len(completers) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		completer := completers[min(len(args), len(completers)-1)]
+		return completer(cmd, args, toComplete)
+	}
+}
+
+// noMoreArgs stops the completion after the last positional argument
+func noMoreArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{
+		docGetCmd, docHistoryCmd, docReloadCmd, docAccessCmd, docACLCmd, docWebhooksCmd, docBiggestTablesCmd,
+		docAttachmentsListCmd, docAttachmentsPruneCmd, deleteDocCmd, tableListCmd, webhookStatusCmd,
+	} {
+		cmd.ValidArgsFunction = completeArgs(completeDocs, noMoreArgs)
+	}
+	for _, cmd := range []*cobra.Command{docDiffCmd, docCompareDataCmd} {
+		cmd.ValidArgsFunction = completeArgs(completeDocs, completeDocs, noMoreArgs)
+	}
+	docBackupCmd.ValidArgsFunction = completeArgs(completeDocs, completeFiles, noMoreArgs)
+	docImportCmd.ValidArgsFunction = completeArgs(completeWorkspaces, completeFiles, noMoreArgs)
+	docAttachmentsUploadCmd.ValidArgsFunction = completeArgs(completeDocs, completeFiles)
+	docAttachmentsDownloadCmd.ValidArgsFunction = completeArgs(completeDocs, noMoreArgs, completeFiles, noMoreArgs)
+	webhookTestCmd.ValidArgsFunction = completeArgs(completeDocs, noMoreArgs)
+	docExportCmd.ValidArgsFunction = completeArgs(completeDocs,
+		cobra.FixedCompletions(append([]string{"excel", "grist"}, gristapi.TableExportFormats...), cobra.ShellCompDirectiveNoFileComp),
+		noMoreArgs)
+	for _, cmd := range []*cobra.Command{docTableCmd, tableColumnsCmd, tableInspectCmd, tableTruncateCmd} {
+		cmd.ValidArgsFunction = completeArgs(completeDocs, completeTables, noMoreArgs)
+	}
+	tableImportCmd.ValidArgsFunction = completeArgs(completeDocs, completeTables, completeFiles, noMoreArgs)
+	recordsGetCmd.ValidArgsFunction = completeArgs(completeDocs, completeTables, noMoreArgs)
+	recordsSinceCmd.ValidArgsFunction = completeArgs(completeDocs, completeTables, noMoreArgs)
+	moveDocCmd.ValidArgsFunction = completeArgs(completeDocs, completeWorkspaces, noMoreArgs)
+	moveDocsCmd.ValidArgsFunction = completeArgs(completeWorkspaces, completeWorkspaces, noMoreArgs)
+	apiCmd.ValidArgsFunction = completeArgs(cobra.FixedCompletions(gristapi.APIMethods, cobra.ShellCompDirectiveNoFileComp), noMoreArgs)
+	purgeDocCmd.ValidArgsFunction = completeArgs(completeDocs, noMoreArgs)
+	for _, cmd := range []*cobra.Command{workspaceGetCmd, workspaceAccessCmd, deleteWorkspaceCmd} {
+		cmd.ValidArgsFunction = completeArgs(completeWorkspaces, noMoreArgs)
+	}
+	createDocCmd.ValidArgsFunction = completeArgs(completeWorkspaces, cobra.NoFileCompletions, noMoreArgs)
+	_ = createDocCmd.MarkFlagFilename("schema", "json")
+	for _, cmd := range []*cobra.Command{orgGetCmd, orgAccessCmd, orgUsageCmd, deleteOrgCmd} {
+		cmd.ValidArgsFunction = completeArgs(completeOrgs, noMoreArgs)
+	}
+	usersWorkspacesCmd.ValidArgsFunction = noMoreArgs
+	_ = usersWorkspacesCmd.RegisterFlagCompletionFunc("role", cobra.FixedCompletions(gristapi.Roles, cobra.ShellCompDirectiveNoFileComp))
+}
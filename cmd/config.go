@@ -5,6 +5,8 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
@@ -19,6 +21,21 @@ Settings are saved to ~/.gristle`,
 	},
 }
 
+var configTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Check the connection to Grist",
+	Long: `Send an authenticated request to the configured Grist server and show the
+user the token belongs to, or why the server couldn't be reached (DNS,
+connection, TLS certificate, authentication or wrong URL).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.TestConfig() {
+			os.Exit(1)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configTestCmd)
 }
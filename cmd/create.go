@@ -5,14 +5,21 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bdmorin/gristle/gristapi"
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
 
+var createDocSchema string
+
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create resources",
-	Long:  `Create organizations and other resources.`,
+	Long:  `Create organizations, documents and other resources.`,
 }
 
 var createOrgCmd = &cobra.Command{
@@ -24,7 +31,47 @@ var createOrgCmd = &cobra.Command{
 	},
 }
 
+var createDocCmd = &cobra.Command{
+	Use:   "doc <workspace-id> <name>",
+	Short: "Create a new document",
+	Long: `Create a new document in a workspace.
+
+With --schema, the tables of a JSON file are then created in the document,
+to start new projects from a template. The file uses the format of the table
+creation API:
+
+  {"tables": [{"id": "People", "columns": [
+    {"id": "Name", "fields": {"type": "Text"}},
+    {"id": "Age", "fields": {"type": "Int", "label": "Age (years)"}}
+  ]}]}
+
+Grist also adds its default empty table (Table1) to new documents.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsID, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid workspace ID: %s\n", args[0])
+			os.Exit(1)
+		}
+		// The schema is checked before creating the document
+		var template *gristapi.DocTemplate
+		if createDocSchema != "" {
+			loaded, err := gristapi.LoadDocTemplate(createDocSchema)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			template = &loaded
+		}
+		if !gristtools.CreateDoc(wsID, args[1], template) {
+			os.Exit(1)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(createCmd)
 	createCmd.AddCommand(createOrgCmd)
+	createCmd.AddCommand(createDocCmd)
+	createDocCmd.Flags().StringVar(&createDocSchema, "schema", "", "JSON file of the tables to create in the document")
 }
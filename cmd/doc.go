@@ -5,6 +5,12 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
 	"github.com/bdmorin/gristle/gristapi"
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
@@ -21,7 +27,59 @@ var docGetCmd = &cobra.Command{
 	Short: "Get document details",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.DisplayDoc(args[0])
+		gristtools.DisplayDoc(resolveDoc(args[0]))
+	},
+}
+
+var docFindCmd = &cobra.Command{
+	Use:   "find <name>",
+	Short: "Find documents by name",
+	Long:  `Search all organizations and workspaces for documents whose name contains <name> (case insensitive).`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayFoundDocs(args[0])
+	},
+}
+
+var docReloadCmd = &cobra.Command{
+	Use:   "reload <doc-id>",
+	Short: "Force a document to be reloaded",
+	Long:  `Close a document and reopen it from storage, e.g. to recover a document stuck in a bad state or after editing its SQLite file.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.ReloadDoc(resolveDoc(args[0]))
+	},
+}
+
+var docBackupCmd = &cobra.Command{
+	Use:   "backup <doc-id> <dir>",
+	Short: "Back up a document with its attachments",
+	Long: `Save the .grist file of a document and its attachments into a directory,
+with a manifest.json holding the document metadata and the SHA-256 checksum
+of every file.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.BackupDoc(resolveDoc(args[0]), args[1]) {
+			os.Exit(1)
+		}
+	},
+}
+
+var docHistoryLimit int
+
+var docHistoryCmd = &cobra.Command{
+	Use:   "history <doc-id>",
+	Short: "Show the history of a document",
+	Long: `List the last states of a document's history, most recent first, with
+their action number, hash and the rows each one added, updated or removed,
+to choose how many states to keep when purging (gristle purge doc).
+Grist doesn't date the states: only the most recent one shows a time, the
+last modification of the document.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.DisplayDocHistory(resolveDoc(args[0]), docHistoryLimit) {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -30,7 +88,38 @@ var docAccessCmd = &cobra.Command{
 	Short: "Get document access permissions",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.DisplayDocAccess(args[0])
+		gristtools.DisplayDocAccess(resolveDoc(args[0]))
+	},
+}
+
+var docACLCmd = &cobra.Command{
+	Use:   "acl <doc-id>",
+	Short: "Get document access rules",
+	Long: `Get the access rules of a document: the row and column level permissions
+granted or denied by condition, and the user attributes these conditions use.
+Only owners of the document can read them.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayDocAccessRules(resolveDoc(args[0]))
+	},
+}
+
+var docImportCmd = &cobra.Command{
+	Use:     "import <workspace-id> <file.grist>",
+	Aliases: []string{"import-grist"},
+	Short:   "Import a .grist file as a new document",
+	Long: `Import a .grist file, such as one written by "doc export <doc-id> grist"
+or "doc backup", as a new document of a workspace, named after the file.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsID, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid workspace ID: %s\n", args[0])
+			os.Exit(1)
+		}
+		if !gristtools.ImportDocGrist(wsID, args[1]) {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -39,37 +128,157 @@ var docWebhooksCmd = &cobra.Command{
 	Short: "List document webhooks",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.DisplayDocWebhooks(args[0])
+		gristtools.DisplayDocWebhooks(resolveDoc(args[0]))
 	},
 }
 
+// Options of the download endpoints, shared by export commands
+var (
+	exportTable       string
+	exportViewSection int
+	exportFilters     string
+	exportHeader      string
+	exportFields      []string
+	exportTableFormat string
+	exportDir         string
+	exportLocal       bool
+	exportDateFormat  string
+	exportNullString  string
+)
+
+// createExportDir creates the directory given by --output-dir, for export
+// files to be written there
+func createExportDir() string {
+	if exportDir != "" {
+		if err := os.MkdirAll(exportDir, 0o750); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create output directory: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return exportDir
+}
+
+// exportOptions returns the download options given by the flags
+func exportOptions() *gristapi.ExportOptions {
+	return &gristapi.ExportOptions{
+		TableId:       exportTable,
+		ViewSectionId: exportViewSection,
+		Filters:       exportFilters,
+		Header:        exportHeader,
+		Fields:        exportFields,
+	}
+}
+
 var docExportCmd = &cobra.Command{
-	Use:       "export <doc-id> <format>",
-	Short:     "Export document",
-	Long:      `Export document in the specified format: excel or grist`,
-	Args:      cobra.ExactArgs(2),
-	ValidArgs: []string{"excel", "grist"},
+	Use:   "export <doc-id> <format>",
+	Short: "Export document",
+	Long: `Export document in the specified format: excel or grist, or export a
+table (--table) as csv, tsv, dsv or table-schema.
+Excel exports can be restricted to a table (--table) or a view section
+(--view-section), keeping its sort and filters.
+The file is named after the workspace, the document and the table, and
+written to the current directory or to --output-dir.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		docID := args[0]
+		docID := resolveDoc(args[0])
 		format := args[1]
+		gristtools.SetExportDir(createExportDir())
 
 		switch format {
 		case "excel":
-			gristtools.ExportDocExcel(docID)
+			gristtools.ExportDocExcel(docID, exportOptions())
 		case "grist":
 			gristtools.ExportDocGrist(docID)
+		case "csv", "tsv", "dsv", "table-schema":
+			gristtools.ExportDocTable(docID, format, exportOptions())
 		default:
 			_ = cmd.Help()
 		}
 	},
 }
 
+var docBiggestTablesCmd = &cobra.Command{
+	Use:   "biggest-tables <doc-id>",
+	Short: "List document tables sorted by number of rows",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayBiggestTables(resolveDoc(args[0]))
+	},
+}
+
+var docDiffCmd = &cobra.Command{
+	Use:   "diff <doc-a> <doc-b>",
+	Short: "Compare the schemas of two documents",
+	Long:  `List tables and columns added, removed or changed (type, formula, label) in doc-b compared to doc-a. Data is not compared.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayDocDiff(resolveDoc(args[0]), resolveDoc(args[1]))
+	},
+}
+
+var docCompareDataDeep bool
+
+var docCompareDataCmd = &cobra.Command{
+	Use:   "compare-data <doc-a> <doc-b>",
+	Short: "Compare the data of two documents",
+	Long: `Check that two documents hold the same data, e.g. after a migration or a
+copy: the row counts of their common tables are compared, and with --deep,
+the content of the tables with the same row count, by hashing their sorted
+records (without row IDs). Reference columns hold row IDs though: if the
+referenced rows were renumbered in the copy, the tables referencing them are
+reported as different. Tables present in only one document are reported.
+Exits with status 1 if the documents differ.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.DisplayDocDataComparison(resolveDoc(args[0]), resolveDoc(args[1]), docCompareDataDeep) {
+			os.Exit(1)
+		}
+	},
+}
+
 var docTableCmd = &cobra.Command{
 	Use:   "table <doc-id> <table-name>",
-	Short: "Export table as CSV",
-	Args:  cobra.ExactArgs(2),
+	Short: "Export table as CSV or TSV",
+	Long: `Export table as CSV, or in another format with --format: tsv, dsv
+(separated by "💩") or table-schema (Frictionless JSON schema of the columns).
+Use --view-section to export a view of the table with its sort and filters.
+With --format parquet or sqlite, the raw table is written to a Parquet file
+or a SQLite database, keeping the column types, in the current directory or
+--output-dir; --view-section, --filters and --header don't apply.
+With --local, the csv, tsv or dsv text is written by gristle from the table
+records, with RFC 4180 quoting, column IDs as header and dates formatted
+after --date-format (a Go layout, e.g. 02/01/2006; ISO 8601 by default),
+instead of being downloaded from Grist.
+Grist keeps blank Text cells as empty strings, but blank cells of other types
+(Numeric, Int, Date, Ref...) as nulls, which Grist's download also writes as
+empty fields: with --local, --null-string sets the text of nulls, e.g. \N.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristapi.GetTableContent(args[0], args[1])
+		if exportTableFormat == "parquet" || exportTableFormat == "sqlite" {
+			if len(exportFields) > 0 {
+				fmt.Fprintln(os.Stderr, "--fields is only supported with csv, tsv and dsv formats")
+				os.Exit(1)
+			}
+			gristtools.SetExportDir(createExportDir())
+			gristtools.ExportTableFile(resolveDoc(args[0]), args[1], exportTableFormat)
+			return
+		}
+		if !slices.Contains(gristapi.TableExportFormats, exportTableFormat) {
+			fmt.Fprintf(os.Stderr, "Invalid format: %s (expected %s, parquet or sqlite)\n", exportTableFormat, strings.Join(gristapi.TableExportFormats, ", "))
+			os.Exit(1)
+		}
+		if exportLocal || exportDateFormat != "" || cmd.Flags().Changed("null-string") {
+			if exportViewSection != 0 || exportFilters != "" || exportHeader != "" {
+				fmt.Fprintln(os.Stderr, "--view-section, --filters and --header don't apply with --local")
+				os.Exit(1)
+			}
+			options := gristapi.RecordsCSVOptions{DateFormat: exportDateFormat, Fields: exportFields, NullString: exportNullString}
+			if !gristtools.DisplayTableRecordsCSV(resolveDoc(args[0]), args[1], exportTableFormat, options) {
+				os.Exit(1)
+			}
+			return
+		}
+		gristtools.DisplayTableExport(resolveDoc(args[0]), args[1], exportTableFormat, exportOptions())
 	},
 }
 
@@ -77,7 +286,31 @@ func init() {
 	rootCmd.AddCommand(docCmd)
 	docCmd.AddCommand(docGetCmd)
 	docCmd.AddCommand(docAccessCmd)
+	docCmd.AddCommand(docACLCmd)
 	docCmd.AddCommand(docWebhooksCmd)
+	docCmd.AddCommand(docFindCmd)
+	docCmd.AddCommand(docReloadCmd)
+	docCmd.AddCommand(docBackupCmd)
 	docCmd.AddCommand(docExportCmd)
+	docCmd.AddCommand(docImportCmd)
 	docCmd.AddCommand(docTableCmd)
+	docCmd.AddCommand(docBiggestTablesCmd)
+	docCmd.AddCommand(docDiffCmd)
+	docCmd.AddCommand(docCompareDataCmd)
+	docCmd.AddCommand(docHistoryCmd)
+
+	docCompareDataCmd.Flags().BoolVar(&docCompareDataDeep, "deep", false, "Also compare the content of tables with the same row count")
+	docHistoryCmd.Flags().IntVar(&docHistoryLimit, "limit", 10, "Number of states to show, 0 for all")
+	docExportCmd.Flags().StringVar(&exportTable, "table", "", "Export only this table (excel, required for table formats)")
+	docTableCmd.Flags().StringVar(&exportTableFormat, "format", "csv", "Output format: csv, tsv, dsv, table-schema, parquet or sqlite")
+	docTableCmd.Flags().BoolVar(&exportLocal, "local", false, "Write csv, tsv or dsv from the records instead of downloading it from Grist")
+	docTableCmd.Flags().StringVar(&exportDateFormat, "date-format", "", "Go layout of dates with --local (implied), e.g. 02/01/2006")
+	docTableCmd.Flags().StringVar(&exportNullString, "null-string", "", `Text of null cells with --local (implied), e.g. \N`)
+	for _, exportCmd := range []*cobra.Command{docExportCmd, docTableCmd} {
+		exportCmd.Flags().IntVar(&exportViewSection, "view-section", 0, "Export this view section (widget) with its sort and filters")
+		exportCmd.Flags().StringVar(&exportFilters, "filters", "", `Filters as JSON, e.g. [{"colRef": 2, "filter": "{\"included\": [\"A\"]}"}]`)
+		exportCmd.Flags().StringVar(&exportHeader, "header", "", "Column headers: label or colId")
+		exportCmd.Flags().StringVar(&exportDir, "output-dir", "", "Directory of the exported file (parquet and sqlite for table), created if needed")
+		exportCmd.Flags().StringSliceVar(&exportFields, "fields", nil, "Export only these columns (csv, tsv, dsv), named as in the header row, e.g. Name,Email")
+	}
 }
@@ -7,18 +7,37 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	mcpserver "github.com/bdmorin/gristle/mcp"
 	"github.com/spf13/cobra"
 )
 
+var (
+	mcpConcurrency int
+	mcpToolTimeout time.Duration
+	mcpReadOnly    bool
+)
+
 var mcpCmd = &cobra.Command{
 	Use:     "mcp",
 	Aliases: []string{"serve"},
 	Short:   "Start MCP server for AI assistant integration",
 	Long: `Starts the Model Context Protocol (MCP) server on stdio.
-This allows AI assistants to interact with your Grist instance.`,
+This allows AI assistants to interact with your Grist instance.
+
+With --read-only (or GRIST_MCP_READONLY=true), the tools modifying documents
+or writing files are not offered, and calling them returns an error:
+` + strings.Join(mcpserver.MutatingTools(), ", ") + `.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		mcpserver.SetConcurrency(mcpConcurrency)
+		mcpserver.SetToolTimeout(mcpToolTimeout)
+		if !cmd.Flags().Changed("read-only") {
+			mcpReadOnly, _ = strconv.ParseBool(os.Getenv("GRIST_MCP_READONLY"))
+		}
+		mcpserver.SetReadOnly(mcpReadOnly)
 		if err := mcpserver.Run(); err != nil {
 			fmt.Fprintf(os.Stderr, "MCP server error: %v\n", err)
 			os.Exit(1)
@@ -28,4 +47,8 @@ This allows AI assistants to interact with your Grist instance.`,
 
 func init() {
 	rootCmd.AddCommand(mcpCmd)
+
+	mcpCmd.Flags().IntVar(&mcpConcurrency, "concurrency", 8, "Maximum simultaneous requests to Grist per tool call")
+	mcpCmd.Flags().BoolVar(&mcpReadOnly, "read-only", false, "Only offer the tools that don't modify documents (default from GRIST_MCP_READONLY)")
+	mcpCmd.Flags().DurationVar(&mcpToolTimeout, "tool-timeout", 0, "Time after which a tool call is abandoned with an error (default: the --timeout of API requests)")
 }
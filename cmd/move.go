@@ -5,11 +5,9 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
-	"strconv"
 
-	"github.com/bdmorin/gristle/gristapi"
+	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
 
@@ -24,12 +22,9 @@ var moveDocCmd = &cobra.Command{
 	Short: "Move a document to a different workspace",
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		wsID, err := strconv.Atoi(args[1])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid workspace ID: %s\n", args[1])
+		if gristtools.MoveFailed(gristtools.MoveDoc(resolveDoc(args[0]), resolveWorkspace(args[1]))) {
 			os.Exit(1)
 		}
-		gristapi.MoveDoc(args[0], wsID)
 	},
 }
 
@@ -38,17 +33,9 @@ var moveDocsCmd = &cobra.Command{
 	Short: "Move all documents from one workspace to another",
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		fromID, err := strconv.Atoi(args[0])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid from workspace ID: %s\n", args[0])
+		if gristtools.MoveFailed(gristtools.MoveAllDocs(resolveWorkspace(args[0]), resolveWorkspace(args[1]))) {
 			os.Exit(1)
 		}
-		toID, err := strconv.Atoi(args[1])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid to workspace ID: %s\n", args[1])
-			os.Exit(1)
-		}
-		gristapi.MoveAllDocs(fromID, toID)
 	},
 }
 
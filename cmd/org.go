@@ -5,6 +5,11 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
@@ -15,20 +20,32 @@ var orgCmd = &cobra.Command{
 	Long:  `Commands for listing, viewing, and managing Grist organizations.`,
 }
 
+var orgListOptions gristtools.OrgListOptions
+
 var orgListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all organizations",
+	Long: `List all organizations, in the order returned by Grist.
+Use --filter to keep organizations whose name or domain contains a string,
+and --sort to order them.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.DisplayOrgs()
+		if orgListOptions.Sort != "" && !slices.Contains(gristtools.OrgSortKeys, orgListOptions.Sort) {
+			fmt.Fprintf(os.Stderr, "Invalid sort key: %s (expected %s)\n", orgListOptions.Sort, strings.Join(gristtools.OrgSortKeys, ", "))
+			os.Exit(1)
+		}
+		gristtools.DisplayOrgs(orgListOptions)
 	},
 }
 
 var orgGetCmd = &cobra.Command{
-	Use:   "get <org-id>",
+	Use:   "get <org-id-or-domain>",
 	Short: "Get organization details",
-	Args:  cobra.ExactArgs(1),
+	Long: `Show an organization, given by ID, name or domain: the subdomain of a team
+site, "docs" for the personal site, or "current" for the organization of
+the configured server URL.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.DisplayOrg(args[0])
+		gristtools.DisplayOrg(resolveOrg(args[0]))
 	},
 }
 
@@ -37,16 +54,24 @@ var orgAccessCmd = &cobra.Command{
 	Short: "Get organization member access",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.DisplayOrgAccess(args[0])
+		gristtools.DisplayOrgAccess(resolveOrg(args[0]))
 	},
 }
 
+var orgUsageByDoc bool
+
 var orgUsageCmd = &cobra.Command{
 	Use:   "usage <org-id>",
 	Short: "Get organization usage summary",
-	Args:  cobra.ExactArgs(1),
+	Long: `Get organization usage summary.
+With --by-doc, list the usage of each document sorted by size.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		gristtools.GetOrgUsageSummary(args[0])
+		if orgUsageByDoc {
+			gristtools.DisplayOrgDocsUsage(resolveOrg(args[0]))
+		} else {
+			gristtools.GetOrgUsageSummary(resolveOrg(args[0]))
+		}
 	},
 }
 
@@ -56,4 +81,8 @@ func init() {
 	orgCmd.AddCommand(orgGetCmd)
 	orgCmd.AddCommand(orgAccessCmd)
 	orgCmd.AddCommand(orgUsageCmd)
+
+	orgListCmd.Flags().StringVar(&orgListOptions.Filter, "filter", "", "Only list organizations whose name or domain contains this string")
+	orgListCmd.Flags().StringVar(&orgListOptions.Sort, "sort", "", "Sort by id, name, domain or created")
+	orgUsageCmd.Flags().BoolVar(&orgUsageByDoc, "by-doc", false, "Break usage down per document, largest first")
 }
@@ -9,7 +9,7 @@ import (
 	"os"
 	"strconv"
 
-	"github.com/bdmorin/gristle/gristapi"
+	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
 
@@ -37,7 +37,7 @@ var purgeDocCmd = &cobra.Command{
 			}
 		}
 
-		gristapi.PurgeDoc(docID, nbStates)
+		gristtools.PurgeDoc(docID, nbStates)
 	},
 }
 
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bdmorin/gristle/gristtools"
+)
+
+// Arguments naming organizations, workspaces and documents accept an ID or
+// a unique name. The command exits if the name matches nothing or is ambiguous
+
+func resolveOrg(ref string) string {
+	id, err := gristtools.ResolveOrg(ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid organization: %v\n", err)
+		os.Exit(1)
+	}
+	return strconv.Itoa(id)
+}
+
+func resolveWorkspace(ref string) int {
+	id, err := gristtools.ResolveWorkspace(ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid workspace: %v\n", err)
+		os.Exit(1)
+	}
+	return id
+}
+
+func resolveDoc(ref string) string {
+	id, err := gristtools.ResolveDoc(ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid document: %v\n", err)
+		os.Exit(1)
+	}
+	return id
+}
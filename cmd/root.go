@@ -6,8 +6,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/bdmorin/gristle/gristapi"
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/bdmorin/gristle/tui"
 	"github.com/spf13/cobra"
@@ -16,6 +20,12 @@ import (
 var (
 	outputFormat string
 	jsonOutput   bool
+	timeout      time.Duration
+	tokenStdin   bool
+	noCache      bool
+	insecure     bool
+	retries      int
+	retryWrites  bool
 	Version      = "dev" // Set via ldflags during build
 )
 
@@ -28,16 +38,16 @@ It provides commands to manage organizations, workspaces, documents, and more.
 
 Run with no arguments to launch the interactive TUI.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Special case: no subcommand launches TUI
-		if len(os.Args) == 1 || (len(os.Args) == 2 && (os.Args[1] == "-h" || os.Args[1] == "--help")) {
-			// If just running "gristle" or "gristle --help", handle normally
-			if len(os.Args) == 1 {
-				if err := tui.Run(); err != nil {
-					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-					os.Exit(1)
-				}
-				return
+		// Special case: no subcommand launches TUI (flags such as --no-cache
+		// are allowed, --help is handled by cobra before Run)
+		if len(args) == 0 {
+			tui.SetCache(!noCache)
+			tui.SetExportDir(createExportDir())
+			if err := tui.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
 			}
+			return
 		}
 		// Otherwise show help
 		_ = cmd.Help()
@@ -49,11 +59,67 @@ Run with no arguments to launch the interactive TUI.`,
 		} else {
 			gristtools.SetOutput("table")
 		}
+		// The flag takes precedence over GRIST_TIMEOUT
+		if cmd.Flags().Changed("timeout") {
+			if timeout < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid timeout %s: must not be negative\n", timeout)
+				os.Exit(1)
+			}
+			gristapi.SetTimeout(timeout)
+		}
+		if cmd.Flags().Changed("retries") {
+			gristapi.SetRetries(retries)
+		}
+		gristapi.SetRetryWrites(retryWrites)
+		if insecure {
+			if err := gristapi.SetInsecureTLS(true); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to disable TLS verification: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintln(os.Stderr, "WARNING: TLS certificate verification is disabled (--insecure), the connection to Grist is not secure")
+		}
+		if tokenStdin {
+			token, err := readTokenLine(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to read token from stdin: %v\n", err)
+				os.Exit(1)
+			}
+			gristapi.SetToken(token)
+		}
 	},
 }
 
+// readTokenLine reads the first line of r as the API token
+// Bytes are read one at a time so that the rest of the input stays
+// available to the command
+func readTokenLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	token := strings.TrimSpace(string(line))
+	if token == "" {
+		return "", fmt.Errorf("empty token")
+	}
+	return token, nil
+}
+
 // Execute runs the root command
 func Execute() error {
+	gristapi.SetUserAgent("gristle/" + Version)
 	return rootCmd.Execute()
 }
 
@@ -61,4 +127,11 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table or json")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output as JSON (shorthand for -o json)")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Don't cache tables and columns in the TUI")
+	rootCmd.Flags().StringVar(&exportDir, "output-dir", "", "Directory of the files exported from the TUI, created if needed")
+	rootCmd.PersistentFlags().BoolVar(&tokenStdin, "token-stdin", false, "Read the API token from the first line of stdin")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure", false, "Skip TLS certificate verification (development only, see GRIST_CA_FILE)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", gristapi.DefaultTimeout, "Timeout of API requests, e.g. 90s or 5m (0 for none, env: GRIST_TIMEOUT)")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 0, "Retries of API requests failing with a network error or a 502, 503 or 504 status (env: GRIST_RETRIES)")
+	rootCmd.PersistentFlags().BoolVar(&retryWrites, "retry-writes", false, "Also retry POST and PATCH requests, which may then be applied twice")
 }
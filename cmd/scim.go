@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/bdmorin/gristle/gristtools"
+	"github.com/spf13/cobra"
+)
+
+var scimBulkFailOnErrors int
+
+var scimCmd = &cobra.Command{
+	Use:   "scim",
+	Short: "Manage users and groups through SCIM",
+	Long:  `Commands for provisioning users and groups with Grist's SCIM v2 API.`,
+}
+
+var scimConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show the SCIM server capabilities and limits",
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplaySCIMServiceProviderConfig()
+	},
+}
+
+var scimGroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage SCIM groups",
+}
+
+var scimGroupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List SCIM groups",
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplaySCIMGroups()
+	},
+}
+
+var scimGroupGetCmd = &cobra.Command{
+	Use:   "get <group-id>",
+	Short: "Get a SCIM group with its members",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplaySCIMGroup(args[0])
+	},
+}
+
+var scimGroupCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a SCIM group",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.CreateSCIMGroup(args[0])
+	},
+}
+
+var scimGroupAddMembersCmd = &cobra.Command{
+	Use:   "add-members <group-id> <user>...",
+	Short: "Add users to a SCIM group",
+	Long:  `Add users to a SCIM group. Users are given by SCIM id or by user name (email).`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.PatchSCIMGroupMembers(args[0], args[1:], nil)
+	},
+}
+
+var scimGroupRemoveMembersCmd = &cobra.Command{
+	Use:   "remove-members <group-id> <user>...",
+	Short: "Remove users from a SCIM group",
+	Long:  `Remove users from a SCIM group. Users are given by SCIM id or by user name (email).`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.PatchSCIMGroupMembers(args[0], nil, args[1:])
+	},
+}
+
+var scimBulkCmd = &cobra.Command{
+	Use:   "bulk <file.json>",
+	Short: "Run a SCIM bulk request from a file",
+	Long: `Run the operations of a SCIM bulk request read from a JSON file, and print
+how many succeeded, failed or were skipped, with the bulkId and status of
+each failure. Operations are skipped once failOnErrors errors occurred; use
+--fail-on-errors to override the value of the file (0 for no limit).
+Exits with a non-zero status if any operation failed or was skipped.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.SCIMBulkFromFile(args[0], scimBulkFailOnErrors) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scimCmd)
+	scimCmd.AddCommand(scimConfigCmd)
+	scimCmd.AddCommand(scimBulkCmd)
+	scimCmd.AddCommand(scimGroupCmd)
+	scimGroupCmd.AddCommand(scimGroupListCmd)
+	scimGroupCmd.AddCommand(scimGroupGetCmd)
+	scimGroupCmd.AddCommand(scimGroupCreateCmd)
+	scimGroupCmd.AddCommand(scimGroupAddMembersCmd)
+	scimGroupCmd.AddCommand(scimGroupRemoveMembersCmd)
+
+	scimBulkCmd.Flags().IntVar(&scimBulkFailOnErrors, "fail-on-errors", -1, "Stop after this number of failed operations (-1 to use the value of the file)")
+}
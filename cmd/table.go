@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bdmorin/gristle/gristapi"
+	"github.com/bdmorin/gristle/gristtools"
+	"github.com/spf13/cobra"
+)
+
+var tableCmd = &cobra.Command{
+	Use:   "table",
+	Short: "Manage tables",
+	Long:  `Commands for working with the tables of a Grist document.`,
+}
+
+var tableListCmd = &cobra.Command{
+	Use:   "list <doc-id>",
+	Short: "List the tables of a document",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayDocTables(resolveDoc(args[0]))
+	},
+}
+
+var tableColumnsAll bool
+
+var tableColumnsCmd = &cobra.Command{
+	Use:   "columns <doc-id> <table-id>",
+	Short: "List the columns of a table",
+	Long: `List the columns of a table with their label, type and formula.
+
+Grist's internal columns (manualSort, gristHelper_...) are hidden unless
+--all-columns is given.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayTableColumns(resolveDoc(args[0]), args[1], tableColumnsAll)
+	},
+}
+
+var tableTruncateCmd = &cobra.Command{
+	Use:   "truncate <doc-id> <table-id>",
+	Short: "Delete all the rows of a table",
+	Long:  `Delete all the rows of a table, after confirmation. The table and its columns are kept.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.TruncateTable(resolveDoc(args[0]), args[1]) {
+			os.Exit(1)
+		}
+	},
+}
+
+var tableInspectSample int
+
+var tableInspectCmd = &cobra.Command{
+	Use:   "inspect <doc-id> <table-id>",
+	Short: "Show a profile of a table",
+	Long:  `Show the columns of a table with their type, its number of rows and a sample of its first rows.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gristtools.DisplayTableInspect(resolveDoc(args[0]), args[1], tableInspectSample)
+	},
+}
+
+var (
+	tableImportKey       string
+	tableImportColumnMap map[string]string
+	tableImportBatchSize int
+	tableImportFormat    string
+	tableImportWorkers   int
+	tableImportDryRun    bool
+)
+
+var tableImportCmd = &cobra.Command{
+	Use:   "import <doc-id> <table-id> <file>",
+	Short: "Import a CSV or JSON file into a table",
+	Long: `Import a CSV or JSON file into a table.
+For CSV, the header row gives the column IDs; use --map to override them.
+With --key, records matching the key column are updated instead of duplicated.
+With --format json or ndjson, the file holds a JSON array of objects or one
+object per line; use "-" to read it from standard input.
+Batches are sent by --concurrency workers at the same time, so records may
+not be created in file order; use --concurrency 1 to keep it.
+With --dry-run, nothing is imported: the records that would be added,
+updated (with their changed fields) or left unchanged are listed.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		switch tableImportFormat {
+		case "csv":
+		case "json", "ndjson":
+			if tableImportKey != "" || len(tableImportColumnMap) > 0 || tableImportDryRun {
+				fmt.Fprintln(os.Stderr, "--key, --map and --dry-run are only supported with --format csv")
+				os.Exit(1)
+			}
+			if !gristtools.ImportJSON(resolveDoc(args[0]), args[1], args[2], tableImportWorkers) {
+				os.Exit(1)
+			}
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Invalid format: %s (expected csv, json or ndjson)\n", tableImportFormat)
+			os.Exit(1)
+		}
+
+		opts := gristapi.ImportCSVOptions{
+			ColumnMap:   tableImportColumnMap,
+			KeyColumn:   tableImportKey,
+			BatchSize:   tableImportBatchSize,
+			Concurrency: tableImportWorkers,
+		}
+		if tableImportDryRun {
+			gristtools.DisplayImportPlan(resolveDoc(args[0]), args[1], args[2], opts)
+			return
+		}
+		if !gristtools.ImportCSV(resolveDoc(args[0]), args[1], args[2], opts) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tableCmd)
+	tableCmd.AddCommand(tableListCmd)
+	tableCmd.AddCommand(tableColumnsCmd)
+	tableCmd.AddCommand(tableInspectCmd)
+	tableCmd.AddCommand(tableTruncateCmd)
+	tableCmd.AddCommand(tableImportCmd)
+
+	tableColumnsCmd.Flags().BoolVar(&tableColumnsAll, "all-columns", false, "Include Grist's internal columns (manualSort, gristHelper_...)")
+	tableInspectCmd.Flags().IntVar(&tableInspectSample, "sample", 5, "Number of rows shown")
+
+	tableImportCmd.Flags().StringVar(&tableImportKey, "key", "", "Upsert records on this column")
+	tableImportCmd.Flags().StringToStringVar(&tableImportColumnMap, "map", nil, "Map CSV headers to column IDs (Header=ColumnId, empty ID to skip)")
+	tableImportCmd.Flags().StringVar(&tableImportFormat, "format", "csv", "Input format: csv, json or ndjson")
+	tableImportCmd.Flags().IntVar(&tableImportBatchSize, "batch-size", 500, "Number of records sent per CSV request")
+	tableImportCmd.Flags().IntVar(&tableImportWorkers, "concurrency", 4, "Number of batches sent at the same time")
+	tableImportCmd.Flags().BoolVar(&tableImportDryRun, "dry-run", false, "Show the records that would be added or updated without importing")
+}
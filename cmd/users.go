@@ -5,6 +5,9 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
@@ -22,7 +25,46 @@ var usersListCmd = &cobra.Command{
 	},
 }
 
+var accessExportFormat string
+
+var userWorkspacesRole string
+
+var usersWorkspacesCmd = &cobra.Command{
+	Use:   "workspaces <email>",
+	Short: "List the workspaces a user can access",
+	Long: `List the workspaces of every organization on which a user holds a role,
+granted on the workspace or inherited from the organization. With --role,
+keep the workspaces where the user holds at least this role: owners,
+editors, viewers, members or guests, e.g. --role editors for the
+workspaces the user can edit.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.DisplayUserWorkspaces(args[0], userWorkspacesRole) {
+			os.Exit(1)
+		}
+	},
+}
+
+var usersExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the access matrix of all orgs/workspaces/documents",
+	Long: `Export one line per user role on each organization, workspace and
+document, with the columns org, workspace, doc, email, role and inherited.
+A role inherited from the parent resource is listed apart from a role
+granted directly.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gristtools.ExportAccessMatrix(accessExportFormat); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(usersCmd)
 	usersCmd.AddCommand(usersListCmd)
+	usersCmd.AddCommand(usersExportCmd)
+	usersCmd.AddCommand(usersWorkspacesCmd)
+	usersExportCmd.Flags().StringVar(&accessExportFormat, "format", "csv", "Output format: csv")
+	usersWorkspacesCmd.Flags().StringVar(&userWorkspacesRole, "role", "", "Minimum role of the user: owners, editors, viewers, members or guests")
 }
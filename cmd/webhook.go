@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bdmorin/gristle/gristtools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	webhookTestWait       time.Duration
+	webhookStatusWatch    bool
+	webhookStatusInterval time.Duration
+)
+
+// Shortest refresh interval of webhook status --watch, sparing the server
+const minWatchInterval = time.Second
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Manage webhooks",
+	Long:  `Commands for working with the webhooks of a Grist document.`,
+}
+
+var webhookStatusCmd = &cobra.Command{
+	Use:   "status <doc-id>",
+	Short: "Show the health of document webhooks",
+	Long: `Show each webhook's status, queue depth, last HTTP status and last error.
+Webhooks in "error" or "retrying" state are highlighted. With --watch, the
+view is refreshed until interrupted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if webhookStatusWatch {
+			if webhookStatusInterval < minWatchInterval {
+				fmt.Fprintf(os.Stderr, "Invalid interval %s: must be at least %s\n", webhookStatusInterval, minWatchInterval)
+				os.Exit(1)
+			}
+			gristtools.WatchWebhookStatus(resolveDoc(args[0]), webhookStatusInterval)
+			return
+		}
+		gristtools.DisplayWebhookStatus(resolveDoc(args[0]))
+	},
+}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test <doc-id> <webhook-id>",
+	Short: "Check that a webhook fires",
+	Long: `Check that a webhook fires.
+A throwaway record is added to the webhook's table, then the webhook status
+is read until the event is delivered, fails, or --wait elapses. The record
+is deleted afterward.
+Exits with status 1 unless the event is delivered.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gristtools.TestWebhook(resolveDoc(args[0]), args[1], webhookTestWait) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(webhookCmd)
+	webhookCmd.AddCommand(webhookStatusCmd)
+	webhookCmd.AddCommand(webhookTestCmd)
+
+	webhookStatusCmd.Flags().BoolVarP(&webhookStatusWatch, "watch", "w", false, "Refresh the status until interrupted")
+	webhookStatusCmd.Flags().DurationVar(&webhookStatusInterval, "interval", 5*time.Second, "Refresh interval with --watch (at least 1s)")
+
+	webhookTestCmd.Flags().DurationVar(&webhookTestWait, "wait", 10*time.Second, "Maximum time to wait for the delivery")
+}
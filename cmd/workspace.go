@@ -5,10 +5,6 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-	"strconv"
-
 	"github.com/bdmorin/gristle/gristtools"
 	"github.com/spf13/cobra"
 )
@@ -25,12 +21,7 @@ var workspaceGetCmd = &cobra.Command{
 	Short: "Get workspace details",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		wsID, err := strconv.Atoi(args[0])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid workspace ID: %s\n", args[0])
-			os.Exit(1)
-		}
-		gristtools.DisplayWorkspace(wsID)
+		gristtools.DisplayWorkspace(resolveWorkspace(args[0]))
 	},
 }
 
@@ -39,12 +30,7 @@ var workspaceAccessCmd = &cobra.Command{
 	Short: "Get workspace access permissions",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		wsID, err := strconv.Atoi(args[0])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid workspace ID: %s\n", args[0])
-			os.Exit(1)
-		}
-		gristtools.DisplayWorkspaceAccess(wsID)
+		gristtools.DisplayWorkspaceAccess(resolveWorkspace(args[0]))
 	},
 }
 
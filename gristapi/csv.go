@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+// Delimited exports written from the records of a table, rather than
+// downloaded from Grist, for consumers that need a given delimiter, RFC 4180
+// quoting or date format whatever the server conventions
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordsCSVOptions controls the delimited text written by GetTableRecordsCSV
+// Grist stores blank Text cells as empty strings, but blank cells of other
+// types (Numeric, Int, Date, Ref...) as nulls: NullString tells them apart
+type RecordsCSVOptions struct {
+	Delimiter  rune     // Field separator, ',' if 0
+	DateFormat string   // Go layout of Date and DateTime cells, ISO 8601 if empty
+	Fields     []string // Keep only these columns, in this order (all if empty)
+	NullString string   // Text of null cells, e.g. \N ("" by default)
+}
+
+// Default layouts of Date and DateTime cells
+const (
+	csvDateLayout     = "2006-01-02"
+	csvDateTimeLayout = time.RFC3339
+)
+
+// csvColumn formats the values of a column as text
+type csvColumn struct {
+	id     string
+	format func(interface{}) string
+}
+
+// newCSVColumn maps a Grist column type to the formatting of its values
+func newCSVColumn(column TableColumn, dateFormat string) csvColumn {
+	baseType, timezone, _ := strings.Cut(column.Fields.Type, ":")
+	switch baseType {
+	case "Date":
+		return csvColumn{column.Id, csvTime(dateFormat, csvDateLayout, time.UTC)}
+	case "DateTime":
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			location = time.UTC
+		}
+		return csvColumn{column.Id, csvTime(dateFormat, csvDateTimeLayout, location)}
+	}
+	return csvColumn{column.Id, csvText}
+}
+
+// Dates and datetimes are stored by Grist as seconds since the epoch
+func csvTime(layout string, defaultLayout string, location *time.Location) func(interface{}) string {
+	if layout == "" {
+		layout = defaultLayout
+	}
+	return func(value interface{}) string {
+		if seconds, ok := value.(float64); ok {
+			return time.UnixMilli(int64(math.Round(seconds * 1000))).In(location).Format(layout)
+		}
+		return csvText(value)
+	}
+}
+
+// csvText formats a cell: numbers without exponent, lists encoded by Grist
+// as ["L", items...] as comma separated items, and errors encoded as
+// ["E", name, ...] as #name
+func csvText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		if len(v) > 0 && v[0] == "L" {
+			items := make([]string, len(v)-1)
+			for i, item := range v[1:] {
+				items[i] = csvText(item)
+			}
+			return strings.Join(items, ", ")
+		}
+		if len(v) > 1 && v[0] == "E" {
+			return fmt.Sprintf("#%v", v[1])
+		}
+	}
+	text, _ := json.Marshal(value)
+	return string(text)
+}
+
+// encodeRecordsCSV writes records as delimited text, with a header row of
+// column IDs
+func encodeRecordsCSV(columns []TableColumn, records []Record, options RecordsCSVOptions) (string, error) {
+	if len(options.Fields) > 0 {
+		selected := make([]TableColumn, len(options.Fields))
+		for i, field := range options.Fields {
+			index := slices.IndexFunc(columns, func(column TableColumn) bool { return column.Id == field })
+			if index < 0 {
+				return "", fmt.Errorf("unknown column %q", field)
+			}
+			selected[i] = columns[index]
+		}
+		columns = selected
+	}
+
+	csvColumns := make([]csvColumn, len(columns))
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		csvColumns[i] = newCSVColumn(column, options.DateFormat)
+		header[i] = column.Id
+	}
+
+	var content strings.Builder
+	writer := csv.NewWriter(&content)
+	if options.Delimiter != 0 {
+		writer.Comma = options.Delimiter
+	}
+	_ = writer.Write(header)
+	row := make([]string, len(csvColumns))
+	for _, record := range records {
+		for i, column := range csvColumns {
+			if value := record.Fields[column.id]; value == nil {
+				row[i] = options.NullString
+			} else {
+				row[i] = column.format(value)
+			}
+		}
+		_ = writer.Write(row)
+	}
+	writer.Flush()
+	return content.String(), writer.Error()
+}
+
+// GetTableRecordsCSV retrieves the records of a table and writes them as
+// delimited text with encoding/csv, instead of using Grist's download
+// Returns -1 and a message if the columns are unknown or the text can't be
+// written
+func GetTableRecordsCSV(docId string, tableId string, options *RecordsCSVOptions) (string, int) {
+	if options == nil {
+		options = &RecordsCSVOptions{}
+	}
+	columns := GetTableColumns(docId, tableId)
+	if len(columns.Columns) == 0 {
+		return fmt.Sprintf("unable to get the columns of table %s", tableId), -1
+	}
+	records, status := GetRecords(docId, tableId, nil)
+	if status != http.StatusOK {
+		return fmt.Sprintf("unable to get the records of table %s: HTTP %d", tableId, status), status
+	}
+	content, err := encodeRecordsCSV(columns.Columns, records.Records, *options)
+	if err != nil {
+		return err.Error(), -1
+	}
+	return content, status
+}
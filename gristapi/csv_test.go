@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEncodeRecordsCSV(t *testing.T) {
+	columns := []TableColumn{
+		{Id: "Name", Fields: ColumnFields{Type: "Text"}},
+		{Id: "Score", Fields: ColumnFields{Type: "Numeric"}},
+		{Id: "Born", Fields: ColumnFields{Type: "Date"}},
+		{Id: "Seen", Fields: ColumnFields{Type: "DateTime:UTC"}},
+		{Id: "Tags", Fields: ColumnFields{Type: "ChoiceList"}},
+	}
+	records := []Record{
+		{Id: 1, Fields: map[string]interface{}{"Name": "Smith, \"Al\"", "Score": 1e16, "Born": float64(951782400),
+			"Seen": 1.5, "Tags": []interface{}{"L", "a", "b"}}},
+		{Id: 2, Fields: map[string]interface{}{"Name": nil, "Score": []interface{}{"E", "TypeError"}, "Born": "n/a",
+			"Seen": nil, "Tags": nil}},
+	}
+
+	tests := []struct {
+		name     string
+		options  RecordsCSVOptions
+		expected string
+	}{
+		{"defaults", RecordsCSVOptions{},
+			"Name,Score,Born,Seen,Tags\n" +
+				"\"Smith, \"\"Al\"\"\",10000000000000000,2000-02-29,1970-01-01T00:00:01Z,\"a, b\"\n" +
+				",#TypeError,n/a,,\n"},
+		{"null string", RecordsCSVOptions{NullString: `\N`, Fields: []string{"Name", "Seen", "Tags"}},
+			"Name,Seen,Tags\n" +
+				"\"Smith, \"\"Al\"\"\",1970-01-01T00:00:01Z,\"a, b\"\n" +
+				`\N,\N,\N` + "\n"},
+		{"delimiter, date format and fields", RecordsCSVOptions{Delimiter: '\t', DateFormat: "02/01/2006", Fields: []string{"Born", "Name"}},
+			"Born\tName\n" +
+				"29/02/2000\t\"Smith, \"\"Al\"\"\"\n" +
+				"n/a\t\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := encodeRecordsCSV(columns, records, tt.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if content != tt.expected {
+				t.Errorf("Expected:\n%q\ngot:\n%q", tt.expected, content)
+			}
+		})
+	}
+
+	if _, err := encodeRecordsCSV(columns, records, RecordsCSVOptions{Fields: []string{"Missing"}}); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+}
+
+func TestGetTableRecordsCSV(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables/People/columns":
+			w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"type": "Text"}}, {"id": "Age", "fields": {"type": "Int"}}]}`))
+		case "/api/docs/doc123/tables/People/records":
+			w.Write([]byte(`{"records": [{"id": 1, "fields": {"Name": "Alice", "Age": 30}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	content, status := GetTableRecordsCSV("doc123", "People", nil)
+	if status != http.StatusOK || content != "Name,Age\nAlice,30\n" {
+		t.Errorf("Unexpected export: %d %q", status, content)
+	}
+	if _, status := GetTableRecordsCSV("doc123", "Missing", nil); status != -1 {
+		t.Errorf("Expected status -1 for a missing table, got %d", status)
+	}
+}
@@ -99,9 +99,9 @@ func TestDocumentCRUD_Integration(t *testing.T) {
 	// Test 1: Get Document Metadata
 	t.Run("GetDocumentMetadata", func(t *testing.T) {
 		for _, docID := range createdDocIDs {
-			doc := GetDoc(docID)
-			if doc.Id == "" {
-				t.Errorf("Failed to get metadata for document %s", docID)
+			doc, status := GetDoc(docID)
+			if status != http.StatusOK {
+				t.Errorf("Failed to get metadata for document %s (status %d)", docID, status)
 				continue
 			}
 			if doc.Id != docID {
@@ -116,8 +116,8 @@ func TestDocumentCRUD_Integration(t *testing.T) {
 
 	// Test 2: List Documents (via workspace)
 	t.Run("ListDocuments", func(t *testing.T) {
-		workspace := GetWorkspace(playgroundWorkspaceID)
-		if workspace.Id == 0 {
+		workspace, status := GetWorkspace(playgroundWorkspaceID)
+		if status != http.StatusOK {
 			t.Fatal("Failed to get workspace")
 		}
 
@@ -169,7 +169,7 @@ func TestDocumentCRUD_Integration(t *testing.T) {
 			defer os.Remove(tmpFile.Name())
 			tmpFile.Close()
 
-			ExportDocExcel(docID, tmpFile.Name())
+			ExportDocExcel(docID, tmpFile.Name(), nil)
 
 			// Check if file was created and has content
 			stat, err := os.Stat(tmpFile.Name())
@@ -471,8 +471,9 @@ func TestDocumentCRUD_Integration(t *testing.T) {
 		// Test purge history
 		t.Run("PurgeDocumentHistory", func(t *testing.T) {
 			docID := createdDocIDs[len(createdDocIDs)-1]
-			PurgeDoc(docID, 1)
-			// Note: PurgeDoc prints to stdout, we just verify it doesn't panic
+			if err := PurgeDoc(docID, 1); err != nil {
+				t.Errorf("Failed to purge document %s history: %v", docID, err)
+			}
 			t.Logf("✓ Purged document %s history (kept 1 state)", docID)
 		})
 	})
@@ -487,15 +488,16 @@ func TestDocumentCRUD_Integration(t *testing.T) {
 
 		for i := 0; i < deleteCount; i++ {
 			docID := createdDocIDs[i]
-			DeleteDoc(docID)
+			if err := DeleteDoc(docID); err != nil {
+				t.Errorf("Failed to delete document %s: %v", docID, err)
+			}
 			t.Logf("✓ Deleted document %s", docID)
 		}
 
 		// Verify deletion
 		for i := 0; i < deleteCount; i++ {
 			docID := createdDocIDs[i]
-			doc := GetDoc(docID)
-			if doc.Id != "" {
+			if DocExists(docID) {
 				t.Errorf("Document %s still exists after deletion", docID)
 			}
 		}
@@ -598,9 +600,9 @@ func testGetDocValidID(t *testing.T) {
 		return
 	}
 
-	doc := GetDoc(docID)
-	if doc.Id == "" {
-		t.Error("GetDoc returned empty document")
+	doc, status := GetDoc(docID)
+	if status != http.StatusOK {
+		t.Errorf("GetDoc returned status %d", status)
 	}
 	if doc.Id != docID {
 		t.Errorf("Expected doc ID %s, got %s", docID, doc.Id)
@@ -608,10 +610,13 @@ func testGetDocValidID(t *testing.T) {
 }
 
 func testGetDocInvalidID(t *testing.T) {
-	doc := GetDoc("nonexistent-doc-id-12345")
+	doc, status := GetDoc("nonexistent-doc-id-12345")
 	if doc.Id != "" {
 		t.Error("Expected empty document for invalid ID")
 	}
+	if status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for invalid ID, got %d", status)
+	}
 }
 
 func testGetDocTablesValid(t *testing.T) {
@@ -673,7 +678,7 @@ func TestDocumentExport_Formats(t *testing.T) {
 			name:      "Excel",
 			extension: ".xlsx",
 			exportFunc: func(docID, fileName string) {
-				ExportDocExcel(docID, fileName)
+				ExportDocExcel(docID, fileName, nil)
 			},
 		},
 		{
@@ -70,21 +70,17 @@ func TestFormulaAndDatatypeValidation(t *testing.T) {
 		for i := 1; i <= 10; i++ {
 			records = append(records, map[string]interface{}{"A": i, "B": i * 2})
 		}
-		_, status = AddRecords(formulasDoc, tableName, records, nil)
+		// The inserted records come back with their computed formula columns
+		// (expect Sum = A+B, Avg=(A+B)/2)
+		added, _, status := AddRecordsReturning(formulasDoc, tableName, records, nil)
 		if status != 200 {
 			t.Fatalf("Failed to add records to formulas table: %d", status)
 		}
-
-		// Read back records and verify formulas (expect Sum = A+B, Avg=(A+B)/2)
-		recs, status := GetRecords(formulasDoc, tableName, nil)
-		if status != 200 {
-			t.Fatalf("Failed to get records: %d", status)
-		}
-		if len(recs.Records) < 10 {
-			t.Fatalf("Expected >=10 records, got %d", len(recs.Records))
+		if len(added) != 10 {
+			t.Fatalf("Expected 10 records, got %d", len(added))
 		}
 
-		for _, r := range recs.Records {
+		for _, r := range added {
 			aVal, aOk := r.Fields["A"].(float64)
 			bVal, bOk := r.Fields["B"].(float64)
 			sumVal, sumOk := r.Fields["Sum"].(float64)
@@ -157,7 +153,7 @@ func TestFormulaAndDatatypeValidation(t *testing.T) {
 			})
 		}
 
-		_, status = AddRecords(datatypesDoc, tableName, records, nil)
+		_, _, status = AddRecords(datatypesDoc, tableName, records, nil)
 		if status != 200 {
 			t.Fatalf("Failed to add datatypes records: %d", status)
 		}
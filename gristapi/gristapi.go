@@ -6,19 +6,40 @@
 package gristapi
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
+	"math"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/time/rate"
 )
 
 // Grist's user
@@ -59,10 +80,23 @@ type EntityAccess struct {
 type Doc struct {
 	Id        string    `json:"id"`
 	Name      string    `json:"name"`
+	UrlId     string    `json:"urlId,omitempty"`
 	IsPinned  bool      `json:"isPinned"`
+	CreatedAt string    `json:"createdAt"`
+	UpdatedAt string    `json:"updatedAt"`
 	Workspace Workspace `json:"workspace"`
 }
 
+// LastModified returns the time the document was last modified, the zero
+// time if the server didn't report it
+func (d Doc) LastModified() time.Time {
+	updatedAt, err := time.Parse(time.RFC3339, d.UpdatedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return updatedAt
+}
+
 // Grist's table
 type Table struct {
 	Id string `json:"id"`
@@ -73,9 +107,41 @@ type Tables struct {
 	Tables []Table `json:"tables"`
 }
 
+// Column definition used when creating a table
+// Fields holds column properties such as "label", "type" or "formula"
+type ColumnDef struct {
+	Id     string                 `json:"id"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Table definition used when creating a table
+type TableDef struct {
+	Id      string      `json:"id"`
+	Columns []ColumnDef `json:"columns"`
+}
+
+// Tables of a new document, in the format of the body of
+// POST /docs/{docId}/tables
+type DocTemplate struct {
+	Tables []TableDef `json:"tables"`
+}
+
 // Grist's table column
 type TableColumn struct {
-	Id string `json:"id"`
+	Id     string       `json:"id"`
+	Fields ColumnFields `json:"fields"`
+}
+
+// Grist's column metadata
+type ColumnFields struct {
+	Label     string `json:"label"`
+	Type      string `json:"type"`
+	Formula   string `json:"formula"`
+	IsFormula bool   `json:"isFormula"`
+	// Display options encoded as JSON, e.g. the dateFormat of Date columns
+	WidgetOptions string `json:"widgetOptions,omitempty"`
+	// Table referenced by a Ref or RefList column, derived from Type
+	RefTable string `json:"refTable,omitempty"`
 }
 
 // List of Grist's table columns
@@ -83,11 +149,52 @@ type TableColumns struct {
 	Columns []TableColumn `json:"columns"`
 }
 
+// Schema of a table: its columns with their metadata
+type TableSchema struct {
+	Id      string        `json:"id"`
+	Columns []TableColumn `json:"columns"`
+}
+
+// Schema of a document: its tables, in API order
+type DocSchema struct {
+	Tables []TableSchema `json:"tables"`
+}
+
+// Change of a column property between two schemas
+type ColumnChange struct {
+	ColumnId string `json:"columnId"`
+	Property string `json:"property"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+}
+
+// Differences between the two versions of a table
+type TableDiff struct {
+	TableId        string         `json:"tableId"`
+	AddedColumns   []string       `json:"addedColumns"`
+	RemovedColumns []string       `json:"removedColumns"`
+	ChangedColumns []ColumnChange `json:"changedColumns"`
+}
+
+// Differences between two document schemas
+type SchemaDiff struct {
+	AddedTables   []string    `json:"addedTables"`
+	RemovedTables []string    `json:"removedTables"`
+	ChangedTables []TableDiff `json:"changedTables"`
+}
+
 // Grist's table row
 type TableRows struct {
 	Id []uint `json:"id"`
 }
 
+// Number of rows of a table
+type TableRowCount struct {
+	TableId  string `json:"tableId"`
+	RowCount int    `json:"rowCount"`
+	Status   int    `json:"status"` // Status of the count, RowCount being 0 unless 200
+}
+
 // Record represents a single record with its fields
 type Record struct {
 	Id     int                    `json:"id,omitempty"`
@@ -110,6 +217,27 @@ type RecordsWithRequire struct {
 	Records []RecordWithRequire `json:"records"`
 }
 
+// FieldChange is the change of a field of an existing record
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// RecordUpdate is an existing record that an upsert would change
+type RecordUpdate struct {
+	Id      int                    `json:"id"`
+	Require map[string]interface{} `json:"require"`
+	Changes []FieldChange          `json:"changes"`
+}
+
+// RecordsDiff is what an upsert of records would change in a table
+type RecordsDiff struct {
+	Added     []RecordWithRequire `json:"added"`
+	Updated   []RecordUpdate      `json:"updated"`
+	Unchanged []RecordWithRequire `json:"unchanged"`
+}
+
 // RecordsWithoutId represents records without IDs (for POST/add operations)
 type RecordsWithoutId struct {
 	Records []struct {
@@ -133,16 +261,19 @@ type GetRecordsOptions struct {
 	Sort   string                   // Column(s) to sort by, e.g. "name,-age"
 	Limit  int                      // Maximum records to return
 	Hidden bool                     // Include hidden columns
+	Fields []string                 // Keep only these fields, filtered client-side (all if empty)
 }
 
 // AddRecordsOptions contains query parameters for adding records
 type AddRecordsOptions struct {
-	NoParse bool // Don't parse strings into column types
+	NoParse  bool // Don't parse strings into column types
+	Validate bool // Check values against the column types before sending them
 }
 
 // UpdateRecordsOptions contains query parameters for updating records
 type UpdateRecordsOptions struct {
-	NoParse bool // Don't parse strings into column types
+	NoParse  bool // Don't parse strings into column types
+	Validate bool // Check values against the column types before sending them
 }
 
 // UpsertRecordsOptions contains query parameters for upserting records
@@ -178,6 +309,28 @@ type Attachment struct {
 	TotalBytes int `json:"totalBytes"`
 }
 
+// Grist's document usage
+type DocUsage struct {
+	RowCount             int   `json:"rowCount"`
+	DataSizeBytes        int64 `json:"dataSizeBytes"`
+	AttachmentsSizeBytes int64 `json:"attachmentsSizeBytes"`
+}
+
+// TotalBytes returns the data and attachments size of the document
+func (u DocUsage) TotalBytes() int64 {
+	return u.DataSizeBytes + u.AttachmentsSizeBytes
+}
+
+// Usage of a document within an organization
+type DocUsageEntry struct {
+	DocId         string   `json:"docId"`
+	DocName       string   `json:"docName"`
+	WorkspaceId   int      `json:"workspaceId"`
+	WorkspaceName string   `json:"workspaceName"`
+	Usage         DocUsage `json:"usage"`
+	Status        int      `json:"status"` // Status of the usage request, Usage being empty unless 200
+}
+
 // AttachmentMetadata represents metadata for a single attachment
 type AttachmentMetadata struct {
 	Id           int    `json:"id"`
@@ -210,66 +363,515 @@ type GetAttachmentsOptions struct {
 	Limit  int                      // Maximum attachments to return
 }
 
+// Diagnostics logger, writing to stderr so that stdout only carries
+// command output (or MCP protocol messages)
+var logger = newLogger()
+
+// newLogger creates a logger whose level is read from GRIST_LOG_LEVEL
+// (debug, info, warn or error, defaults to warn)
+func newLogger() *slog.Logger {
+	level := slog.LevelWarn
+	if envLevel := os.Getenv("GRIST_LOG_LEVEL"); envLevel != "" {
+		if err := level.UnmarshalText([]byte(envLevel)); err != nil {
+			level = slog.LevelWarn
+		}
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// Default timeout of API requests
+const DefaultTimeout = 60 * time.Second
+
+// Client shared by API requests, so that connections are kept alive and
+// reused across calls
+var (
+	httpClientMu sync.RWMutex
+	httpClient   = newHTTPClient(DefaultTimeout)
+)
+
+// SetTimeout changes the timeout of API requests (0 disables it)
+// The connections of the current client are kept
+func SetTimeout(timeout time.Duration) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	client := *httpClient
+	client.Timeout = timeout
+	httpClient = &client
+}
+
+// Timeout returns the timeout of API requests, 0 when it is disabled
+func Timeout() time.Duration {
+	return getHTTPClient().Timeout
+}
+
+// SetHTTPClient replaces the client used by API requests, e.g. to go
+// through a proxy or use a custom TLS configuration. A nil client restores
+// the default one
+func SetHTTPClient(client *http.Client) {
+	if client == nil {
+		client = newHTTPClient(DefaultTimeout)
+	}
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	httpClient = client
+}
+
+// Limiter of API requests, whichever client sends them, nil when requests
+// aren't limited (the default)
+var (
+	limiterMu sync.RWMutex
+	limiter   *rate.Limiter
+)
+
+// SetRateLimit limits API requests to perSecond requests per second, e.g.
+// to spare a shared instance during bulk operations. Up to burst requests
+// may start at once after an idle period, the following ones being spread
+// evenly (burst is at least 1)
+// 0 requests per second disables the limit
+func SetRateLimit(perSecond float64, burst int) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	limiter = nil
+	if perSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(perSecond), max(burst, 1))
+	}
+}
+
+// waitRateLimit blocks until the rate limit allows a request to start, or
+// ctx is done
+func waitRateLimit(ctx context.Context) error {
+	limiterMu.RLock()
+	l := limiter
+	limiterMu.RUnlock()
+	if l == nil {
+		return nil
+	}
+	return l.Wait(ctx)
+}
+
+// parseRateLimit reads a number of requests per second
+func parseRateLimit(value string) (float64, error) {
+	perSecond, err := strconv.ParseFloat(value, 64)
+	if err != nil || perSecond < 0 {
+		return 0, fmt.Errorf("invalid rate limit %q, expected a number of requests per second", value)
+	}
+	return perSecond, nil
+}
+
+// User-Agent header of the requests, identifying gristle in the access logs
+// of the server
+var userAgent = "gristle/dev"
+
+// SetUserAgent sets the User-Agent header of the following requests
+func SetUserAgent(agent string) {
+	userAgent = agent
+}
+
+// Retries of requests failing with a network error or a 502, 503 or 504
+// status, none by default (see SetRetries)
+// Grist has no idempotency keys: a POST or PATCH that failed on the way
+// back may have been applied, and sending it again could e.g. insert the
+// records twice. Those are only retried with SetRetryWrites, unless they are
+// sent with httpPostReplayable
+var (
+	maxRetries  int
+	retryWrites bool
+	retryDelay  = 500 * time.Millisecond // Doubled at each retry
+)
+
+// SetRetries sets the number of times a failed request is sent again
+func SetRetries(retries int) {
+	maxRetries = max(retries, 0)
+}
+
+// SetRetryWrites allows retrying POST and PATCH requests, at the risk of
+// applying them twice
+func SetRetryWrites(enabled bool) {
+	retryWrites = enabled
+}
+
+// retryable tells whether a request that got resp or err may be sent again
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err == nil && resp.StatusCode != http.StatusBadGateway &&
+		resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusGatewayTimeout {
+		return false
+	}
+	if (req.Method == http.MethodPost || req.Method == http.MethodPatch) && !retryWrites &&
+		req.Context().Value(replayableKey{}) == nil {
+		return false
+	}
+	// The body must be sent again
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// doRequest authenticates a request and sends it with the shared client,
+// once the rate limit allows it, retrying it as set by SetRetries
+func doRequest(req *http.Request) (*http.Response, error) {
+	authenticate(req)
+	req.Header.Set("User-Agent", userAgent)
+	for attempt := 0; ; attempt++ {
+		if err := waitRateLimit(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := getHTTPClient().Do(req)
+		if attempt >= maxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if state, ok := req.Context().Value(replayableKey{}).(*replayable); ok {
+			state.retried.Store(true)
+		}
+		if resp != nil {
+			logger.Warn("retrying request", "method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode)
+			_ = resp.Body.Close()
+		} else {
+			logger.Warn("retrying request", "method", req.Method, "url", req.URL.Redacted(), "error", err)
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(retryDelay << attempt):
+		}
+	}
+}
+
+// Name of the cookie holding the session of Grist's web client
+const SessionCookieName = "grist_sid"
+
+// authenticate sets the credentials of a request: the session cookie
+// GRIST_SESSION_COOKIE when set, for instances behind SSO where API keys
+// can't be created, else the API key GRIST_TOKEN as a bearer token
+// The cookie is given as "name=value", or as the value of the grist_sid
+// cookie
+func authenticate(req *http.Request) {
+	if cookie := os.Getenv("GRIST_SESSION_COOKIE"); cookie != "" {
+		if !strings.Contains(cookie, "=") {
+			cookie = SessionCookieName + "=" + cookie
+		}
+		req.Header.Set("Cookie", cookie)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("GRIST_TOKEN"))
+}
+
+// parseTimeout reads a timeout given as a Go duration ("90s", "5m") or a
+// number of seconds, which can't be negative
+func parseTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if seconds, errSeconds := strconv.Atoi(value); errSeconds == nil {
+		timeout, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return timeout, nil
+}
+
+// SetCAFile trusts the certificates of a PEM bundle, in addition to the
+// system ones, e.g. for a self-hosted instance using a private CA
+func SetCAFile(path string) error {
+	// #nosec G304 - path is the user-provided GRIST_CA_FILE
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificate found in CA file %s", path)
+	}
+	return configureTLS(func(config *tls.Config) {
+		config.RootCAs = pool
+	})
+}
+
+// SetInsecureTLS disables the verification of server certificates
+// Only meant for development instances
+func SetInsecureTLS(insecure bool) error {
+	return configureTLS(func(config *tls.Config) {
+		// #nosec G402 - explicitly requested by the user
+		config.InsecureSkipVerify = insecure
+	})
+}
+
+// configureTLS changes the TLS configuration of the current client
+// The transport is cloned, so a client given to SetHTTPClient isn't modified
+func configureTLS(configure func(*tls.Config)) error {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	var transport *http.Transport
+	switch t := httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return fmt.Errorf("unable to configure TLS of a %T transport", t)
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	configure(transport.TLSClientConfig)
+	client := *httpClient
+	client.Transport = transport
+	httpClient = &client
+	return nil
+}
+
+// newHTTPClient creates a client with its own connection pool
+// Bulk operations send many requests to the same host, so more idle
+// connections are kept per host than the default transport does
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 20
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// getHTTPClient returns the client used by API requests
+func getHTTPClient() *http.Client {
+	httpClientMu.RLock()
+	defer httpClientMu.RUnlock()
+	return httpClient
+}
+
 // Apply config and return the config file path
+// The token is taken from GRIST_TOKEN, else from the file GRIST_TOKEN_FILE
+// points to, else from the configuration file
 func GetConfig() string {
 	home := os.Getenv("HOME")
 	configFile := filepath.Join(home, ".gristle")
+	applyTokenFile()
 	if os.Getenv("GRIST_TOKEN") == "" || os.Getenv("GRIST_URL") == "" {
 		err := godotenv.Load(configFile)
 		if err != nil {
-			fmt.Printf("Error reading configuration file : %s\n", err)
+			logger.Warn("unable to read configuration file", "file", configFile, "error", err)
 		}
+		// GRIST_TOKEN_FILE may be defined in the configuration file
+		applyTokenFile()
 	}
 	return configFile
 }
 
+// SetToken sets the API token used by the following requests
+func SetToken(token string) {
+	os.Setenv("GRIST_TOKEN", token)
+}
+
+// applyTokenFile sets the token from GRIST_TOKEN_FILE if no token is set
+// An unusable file is reported and ignored
+func applyTokenFile() {
+	tokenFile := os.Getenv("GRIST_TOKEN_FILE")
+	if tokenFile == "" || os.Getenv("GRIST_TOKEN") != "" {
+		return
+	}
+	token, err := readTokenFile(tokenFile)
+	if err != nil {
+		logger.Warn("ignoring GRIST_TOKEN_FILE", "error", err)
+		return
+	}
+	SetToken(token)
+}
+
+// readTokenFile reads an API token from a file, refusing world-readable files
+func readTokenFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token file: %w", err)
+	}
+	// Permission bits are not meaningful on Windows
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o004 != 0 {
+		return "", fmt.Errorf("token file %s is world-readable (mode %s), restrict it with chmod 600", path, info.Mode().Perm())
+	}
+	// #nosec G304 - path is the user-provided GRIST_TOKEN_FILE
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
 func init() {
 	GetConfig()
+	// The configuration file may define GRIST_LOG_LEVEL, GRIST_TIMEOUT,
+	// GRIST_CA_FILE, GRIST_RATE_LIMIT, GRIST_RATE_BURST and GRIST_RETRIES
+	logger = newLogger()
+	if envTimeout := os.Getenv("GRIST_TIMEOUT"); envTimeout != "" {
+		timeout, err := parseTimeout(envTimeout)
+		if err != nil {
+			logger.Warn("ignoring GRIST_TIMEOUT", "error", err)
+		} else {
+			SetTimeout(timeout)
+		}
+	}
+	if caFile := os.Getenv("GRIST_CA_FILE"); caFile != "" {
+		if err := SetCAFile(caFile); err != nil {
+			logger.Warn("ignoring GRIST_CA_FILE", "error", err)
+		}
+	}
+	if envRate := os.Getenv("GRIST_RATE_LIMIT"); envRate != "" {
+		perSecond, err := parseRateLimit(envRate)
+		if err != nil {
+			logger.Warn("ignoring GRIST_RATE_LIMIT", "error", err)
+		} else {
+			burst := 1
+			if envBurst := os.Getenv("GRIST_RATE_BURST"); envBurst != "" {
+				if burst, err = strconv.Atoi(envBurst); err != nil || burst < 1 {
+					logger.Warn("ignoring GRIST_RATE_BURST", "error", fmt.Errorf("invalid burst %q", envBurst))
+					burst = 1
+				}
+			}
+			SetRateLimit(perSecond, burst)
+		}
+	}
+	if envRetries := os.Getenv("GRIST_RETRIES"); envRetries != "" {
+		retries, err := strconv.Atoi(envRetries)
+		if err != nil || retries < 0 {
+			logger.Warn("ignoring GRIST_RETRIES", "error", fmt.Errorf("invalid number of retries %q", envRetries))
+		} else {
+			SetRetries(retries)
+		}
+	}
 }
 
 // Sending an HTTP request to Grist's REST API
 // Action: GET, POST, PATCH, DELETE
 // Returns response body
 func httpRequest(action string, myRequest string, data *bytes.Buffer) (string, int) {
-	client := &http.Client{}
+	return httpRequestContext(context.Background(), action, myRequest, data)
+}
+
+// replayableKey marks the context of POST requests that can be retried
+// without SetRetryWrites, because applying them twice can't change data
+// Its value is a *replayable
+type replayableKey struct{}
+
+// replayable records whether a replayable request was sent again
+type replayable struct {
+	retried atomic.Bool
+}
+
+// httpPostReplayable sends a POST request that may be retried like a GET,
+// such as a deletion by row IDs: if the first attempt was applied, the
+// retry fails on the missing rows rather than deleting others
+// Also returns whether the request was retried, for the caller to tell
+// such a failure from a genuine one
+func httpPostReplayable(ctx context.Context, myRequest string, data string) (string, int, bool) {
+	state := &replayable{}
+	ctx = context.WithValue(ctx, replayableKey{}, state)
+	response, status := httpRequestContext(ctx, "POST", myRequest, bytes.NewBuffer([]byte(data)))
+	return response, status, state.retried.Load()
+}
+
+// httpRequestContext sends an HTTP request with a context
+func httpRequestContext(ctx context.Context, action string, myRequest string, data *bytes.Buffer) (string, int) {
 	url := fmt.Sprintf("%s/api/%s", os.Getenv("GRIST_URL"), myRequest)
-	bearer := "Bearer " + os.Getenv("GRIST_TOKEN")
 
-	req, err := http.NewRequest(action, url, data)
+	req, err := http.NewRequestWithContext(ctx, action, url, data)
 	if err != nil {
-		log.Fatalf("Error creating request %s: %s", url, err)
+		logger.Error("unable to create request", "url", url, "error", err)
+		return fmt.Sprintf("Error creating request %s: %s", url, err), -1
 	}
-	req.Header.Add("Authorization", bearer)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send the HTTP request
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
+		logger.Debug("request failed", "method", action, "url", url, "error", err)
 		errMsg := fmt.Sprintf("Error sending request %s: %s", url, err)
 		return errMsg, -10
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Error closing response body: %v", err)
+			logger.Warn("unable to close response body", "error", err)
 		}
 	}()
+	logger.Debug("request", "method", action, "url", url, "status", resp.StatusCode)
 	// Read the HTTP response body
-	body, err := io.ReadAll(resp.Body)
+	body, err := readResponseBody(resp)
 	if err != nil {
-		log.Printf("Error reading response %s: %s", url, err)
+		logger.Warn("unable to read response", "url", url, "error", err)
 	}
 	return string(body), resp.StatusCode
 }
 
+// readResponseBody reads the body of a response, decompressing it if needed
+// The default transport sends "Accept-Encoding: gzip" and decompresses the
+// response itself (resp.Uncompressed is then true), as long as the header
+// isn't set manually. A body still gzip-encoded, e.g. with a client whose
+// transport disables compression, is decompressed here
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.ReadAll(resp.Body)
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// gzipBody decompresses a response body, closing it with the decompressor
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (b gzipBody) Close() error {
+	_ = b.Reader.Close()
+	return b.body.Close()
+}
+
+// httpGetStream sends a GET request and returns the response body
+// unread, decompressed if needed, for the caller to decode and close
+func httpGetStream(endpoint string) (io.ReadCloser, int) {
+	url := fmt.Sprintf("%s/api/%s", os.Getenv("GRIST_URL"), endpoint)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, -1
+	}
+
+	resp, err := doRequest(req)
+	if err != nil {
+		logger.Debug("request failed", "method", "GET", "url", url, "error", err)
+		return nil, -10
+	}
+	logger.Debug("request", "method", "GET", "url", url, "status", resp.StatusCode)
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, resp.StatusCode
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, -1
+	}
+	return gzipBody{Reader: reader, body: resp.Body}, resp.StatusCode
+}
+
 // Send an HTTP GET request to Grist's REST API
 // Returns the response body
 func httpGet(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("GET", myRequest, dataBody)
-	// if status != http.StatusOK {
-	// 	fmt.Printf("Return code from %s : %d (%s)\n", myRequest, status, body)
-	// }
-	return body, status
+	return httpGetContext(context.Background(), myRequest, data)
+}
+
+// httpGetContext sends a GET request that is cancelled with ctx
+func httpGetContext(ctx context.Context, myRequest string, data string) (string, int) {
+	return httpRequestContext(ctx, "GET", myRequest, bytes.NewBuffer([]byte(data)))
 }
 
 // Test Grist API connection
@@ -278,28 +880,148 @@ func TestConnection() bool {
 	return status == http.StatusOK
 }
 
+// GetCurrentUser retrieves the profile of the authenticated user
+// GET /profile/user
+func GetCurrentUser() (User, int) {
+	user := User{}
+	response, status := httpGet("profile/user", "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &user)
+	}
+	return user, status
+}
+
+// CheckConnection sends an authenticated request to the configured server
+// and returns the authenticated user, or an error telling why the server
+// couldn't be reached: DNS, connection, TLS, authentication or URL problem
+func CheckConnection() (User, error) {
+	user := User{}
+	server := os.Getenv("GRIST_URL")
+	if server == "" {
+		return user, errors.New("GRIST_URL is not set, run gristle config")
+	}
+	req, err := http.NewRequest("GET", server+"/api/profile/user", nil)
+	if err != nil {
+		return user, fmt.Errorf("invalid GRIST_URL %q: %w", server, err)
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return user, connectionError(server, err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return user, fmt.Errorf("unable to read the response of %s: %w", server, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return user, fmt.Errorf("authentication refused by %s (HTTP %d), check GRIST_TOKEN", server, resp.StatusCode)
+	case http.StatusNotFound:
+		return user, fmt.Errorf("no Grist API at %s (HTTP 404), check GRIST_URL", server)
+	default:
+		return user, fmt.Errorf("unexpected response from %s: HTTP %d%s", server, resp.StatusCode, responseErrorDetail(body))
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return user, fmt.Errorf("%s didn't answer like a Grist server, check GRIST_URL: %w", server, err)
+	}
+	return user, nil
+}
+
+// connectionError describes the failure of a request that got no response
+func connectionError(server string, err error) error {
+	var dnsErr *net.DNSError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var recordHeaderErr tls.RecordHeaderError
+	var opErr *net.OpError
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("unable to resolve host %s: %w", dnsErr.Name, err)
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("certificate of %s signed by an unknown authority, set GRIST_CA_FILE to trust it: %w", server, err)
+	case errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return fmt.Errorf("invalid TLS certificate for %s: %w", server, err)
+	case errors.As(err, &recordHeaderErr):
+		return fmt.Errorf("TLS handshake with %s failed, is the server using http instead of https? %w", server, err)
+	case errors.As(err, &urlErr) && urlErr.Timeout():
+		return fmt.Errorf("no answer from %s before the timeout: %w", server, err)
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Errorf("unable to connect to %s: %w", server, err)
+	}
+	return fmt.Errorf("unable to reach %s: %w", server, err)
+}
+
+// Version of Grist in the configuration embedded in its web pages
+var serverVersionPattern = regexp.MustCompile(`"version"\s*:\s*"([^"]+)"`)
+
+// GetServerVersion returns the version of the configured Grist server
+// The API has no version endpoint: the version is read from the client
+// configuration (gristConfig) embedded in the home page
+func GetServerVersion() (string, error) {
+	url := os.Getenv("GRIST_URL") + "/"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request %s: %w", url, err)
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to reach %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get %s: HTTP %d", url, resp.StatusCode)
+	}
+	// The configuration is at the beginning of the page
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %w", url, err)
+	}
+	config := string(page)
+	if i := strings.Index(config, "gristConfig"); i >= 0 {
+		config = config[i:]
+	}
+	match := serverVersionPattern.FindStringSubmatch(config)
+	if match == nil {
+		return "", fmt.Errorf("no version found in %s", url)
+	}
+	return match[1], nil
+}
+
 // Sends an HTTP POST request to Grist's REST API with a data load
 // Return the response body
 func httpPost(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("POST", myRequest, dataBody)
-	return body, status
+	return httpPostContext(context.Background(), myRequest, data)
+}
+
+// httpPostContext sends a POST request that is cancelled with ctx
+func httpPostContext(ctx context.Context, myRequest string, data string) (string, int) {
+	return httpRequestContext(ctx, "POST", myRequest, bytes.NewBuffer([]byte(data)))
 }
 
 // Sends an HTTP PATCH request to Grist's REST API with a data load
 // Return the response body
 func httpPatch(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("PATCH", myRequest, dataBody)
-	return body, status
+	return httpPatchContext(context.Background(), myRequest, data)
+}
+
+// httpPatchContext sends a PATCH request that is cancelled with ctx
+func httpPatchContext(ctx context.Context, myRequest string, data string) (string, int) {
+	return httpRequestContext(ctx, "PATCH", myRequest, bytes.NewBuffer([]byte(data)))
 }
 
 // Send an HTTP DELETE request to Grist's REST API with a data load
 // Return the response body
 func httpDelete(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("DELETE", myRequest, dataBody)
-	return body, status
+	return httpDeleteContext(context.Background(), myRequest, data)
+}
+
+// httpDeleteContext sends a DELETE request that is cancelled with ctx
+func httpDeleteContext(ctx context.Context, myRequest string, data string) (string, int) {
+	return httpRequestContext(ctx, "DELETE", myRequest, bytes.NewBuffer([]byte(data)))
 }
 
 // Send an HTTP PUT request to Grist's REST API with a data load
@@ -310,490 +1032,3169 @@ func httpPut(myRequest string, data string) (string, int) {
 	return body, status
 }
 
+// Methods accepted by CallAPI
+var APIMethods = []string{"GET", "POST", "PATCH", "PUT", "DELETE"}
+
+// CallAPI sends an authenticated request to any endpoint of Grist's REST API,
+// for endpoints that have no function of their own
+// path is relative to the API root, e.g. "docs/{docId}/tables", with or
+// without a leading "/api/"
+// Returns the response body and the HTTP status, or -1 and a message for an
+// unsupported method
+func CallAPI(method string, path string, body string) (string, int) {
+	method = strings.ToUpper(method)
+	if !slices.Contains(APIMethods, method) {
+		return fmt.Sprintf("unsupported method %s, expected one of %s", method, strings.Join(APIMethods, ", ")), -1
+	}
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, "api/")
+	return httpRequest(method, path, bytes.NewBufferString(body))
+}
+
 // Retrieves the list of organizations
 func GetOrgs() []Org {
+	return GetOrgsContext(context.Background())
+}
+
+// GetOrgsContext is like GetOrgs, with a context cancelling its requests
+func GetOrgsContext(ctx context.Context) []Org {
 	myOrgs := []Org{}
-	response, _ := httpGet("orgs", "")
-	json.Unmarshal([]byte(response), &myOrgs)
+	response, status := httpGetContext(ctx, "orgs", "")
+	decodeJSON(response, status, &myOrgs)
 	return myOrgs
 }
 
-// Retrieves the organization whose identifier is passed in parameter
-func GetOrg(idOrg string) Org {
+// Retrieves the organization whose identifier is passed in parameter: its
+// numeric ID, or its domain, e.g. a team site subdomain, "docs" for the
+// personal site or "current" for the organization of the server URL
+// Returns the HTTP status, 404 if the organization does not exist
+func GetOrg(idOrg string) (Org, int) {
+	return GetOrgContext(context.Background(), idOrg)
+}
+
+// GetOrgContext is like GetOrg, with a context cancelling its requests
+func GetOrgContext(ctx context.Context, idOrg string) (Org, int) {
 	myOrg := Org{}
-	response, _ := httpGet("orgs/"+idOrg, "")
-	json.Unmarshal([]byte(response), &myOrg)
-	return myOrg
+	response, status := httpGetContext(ctx, "orgs/"+url.PathEscape(idOrg), "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &myOrg)
+	}
+	return myOrg, status
 }
 
 // Retrieves the list of users in the organization whose ID is passed in parameter
 func GetOrgAccess(idOrg string) []User {
+	lstUsers, _ := GetOrgAccessWithStatus(idOrg)
+	return lstUsers.Users
+}
+
+// GetOrgAccessWithStatus is like GetOrgAccess, with the status of the request
+func GetOrgAccessWithStatus(idOrg string) (EntityAccess, int) {
 	var lstUsers EntityAccess
 	url := fmt.Sprintf("orgs/%s/access", idOrg)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &lstUsers)
-	return lstUsers.Users
+	response, status := httpGet(url, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &lstUsers)
+	}
+	return lstUsers, status
 }
 
 // Retrieves information on a specific organization
 func GetOrgWorkspaces(orgId int) []Workspace {
+	return GetOrgWorkspacesContext(context.Background(), orgId)
+}
+
+// GetOrgWorkspacesContext is like GetOrgWorkspaces, with a context cancelling its requests
+func GetOrgWorkspacesContext(ctx context.Context, orgId int) []Workspace {
 	lstWorkspaces := []Workspace{}
-	response, _ := httpGet("orgs/"+strconv.Itoa(orgId)+"/workspaces", "")
-	json.Unmarshal([]byte(response), &lstWorkspaces)
+	response, status := httpGetContext(ctx, "orgs/"+strconv.Itoa(orgId)+"/workspaces", "")
+	decodeJSON(response, status, &lstWorkspaces)
 	return lstWorkspaces
 }
 
-// Get a workspace
-func GetWorkspace(workspaceId int) Workspace {
-	workspace := Workspace{}
-	url := fmt.Sprintf("workspaces/%d", workspaceId)
-	response, returnCode := httpGet(url, "")
-	if returnCode == http.StatusOK {
-		json.Unmarshal([]byte(response), &workspace)
+// WalkWorkspaces calls fn for each workspace of each accessible organization
+// Workspaces are retrieved with their documents
+func WalkWorkspaces(fn func(org Org, workspace Workspace)) {
+	for _, org := range GetOrgs() {
+		for _, ws := range GetOrgWorkspaces(org.Id) {
+			fn(org, ws)
+		}
 	}
-	return workspace
 }
 
-// Delete an organization
-func DeleteOrg(orgId int, orgName string) {
-	url := fmt.Sprintf("orgs/%d/%s", orgId, orgName)
-	response, status := httpDelete(url, "")
-	if status == http.StatusOK {
-		fmt.Printf("Organization %d : %s deleted\t✅\n", orgId, orgName)
-	} else {
-		fmt.Printf("Unable to delete organization %d : %s : %s ❗️\n", orgId, orgName, response)
-	}
+// FindDocsByName searches all organizations and workspaces for documents
+// whose name contains name (case insensitive)
+// Each document is returned with its workspace and the workspace's org
+func FindDocsByName(name string) []Doc {
+	name = strings.ToLower(name)
+	found := []Doc{}
+	WalkWorkspaces(func(org Org, ws Workspace) {
+		for _, doc := range ws.Docs {
+			if !strings.Contains(strings.ToLower(doc.Name), name) {
+				continue
+			}
+			doc.Workspace = ws
+			doc.Workspace.Docs = nil
+			doc.Workspace.Org = org
+			found = append(found, doc)
+		}
+	})
+	return found
 }
 
-// Delete a workspace
-func DeleteWorkspace(workspaceId int) {
-	url := fmt.Sprintf("workspaces/%d", workspaceId)
-	response, status := httpDelete(url, "")
+// AccessEntry is one line of the permission matrix: the role of a user on an
+// organization, a workspace or a document
+// Inherited is true when the role comes from the parent resource rather than
+// being granted on the resource itself
+type AccessEntry struct {
+	Org       string `json:"org"`
+	Workspace string `json:"workspace"`
+	Doc       string `json:"doc"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Inherited bool   `json:"inherited"`
+}
+
+// GetAccessMatrix lists who has what access on every accessible organization,
+// workspace and document
+// Each user gets one entry per resource, with the role computed as
+// EffectiveRole does: an inherited role is capped at the maxInheritedRole of
+// the resource
+// Returns an error as soon as the access of a resource can't be retrieved,
+// rather than an incomplete matrix
+func GetAccessMatrix() ([]AccessEntry, error) {
+	entries := []AccessEntry{}
+	for _, org := range GetOrgs() {
+		base := AccessEntry{Org: org.Name}
+		access, status := GetOrgAccessWithStatus(strconv.Itoa(org.Id))
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("unable to get the access of organization %s: HTTP %d", org.Name, status)
+		}
+		entries = appendAccessEntries(entries, base, access)
+		for _, ws := range GetOrgWorkspaces(org.Id) {
+			base.Workspace = ws.Name
+			base.Doc = ""
+			access, status := GetWorkspaceAccessWithStatus(ws.Id)
+			if status != http.StatusOK {
+				return nil, fmt.Errorf("unable to get the access of workspace %s: HTTP %d", ws.Name, status)
+			}
+			entries = appendAccessEntries(entries, base, access)
+			for _, doc := range ws.Docs {
+				base.Doc = doc.Name
+				access, status := GetDocAccessWithStatus(doc.Id)
+				if status != http.StatusOK {
+					return nil, fmt.Errorf("unable to get the access of document %s: HTTP %d", doc.Name, status)
+				}
+				entries = appendAccessEntries(entries, base, access)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Roles of users, from the most to the least powerful
+var Roles = []string{"owners", "editors", "viewers", "members", "guests"}
+
+// HasRole tells whether role grants at least the rights of minRole, e.g.
+// owners can do what editors can
+// An empty role has no rights
+func HasRole(role string, minRole string) bool {
+	rank := slices.Index(Roles, role)
+	return rank >= 0 && rank <= slices.Index(Roles, minRole)
+}
+
+// EffectiveRole returns the role a user holds on a resource: the most
+// powerful of the role granted on it and the inherited one, the latter being
+// capped at the maxInheritedRole of the resource ("" when the resource
+// inherits no access, as Grist reports it with a null role)
+func (u User) EffectiveRole(maxInheritedRole string) string {
+	inherited := u.ParentAccess
+	if inherited != "" && !HasRole(maxInheritedRole, inherited) {
+		inherited = maxInheritedRole
+	}
+	if inherited != "" && (u.Access == "" || HasRole(inherited, u.Access)) {
+		return inherited
+	}
+	return u.Access
+}
+
+// Role of a user on a workspace
+type UserWorkspace struct {
+	OrgId         int    `json:"orgId"`
+	OrgName       string `json:"orgName"`
+	WorkspaceId   int    `json:"workspaceId"`
+	WorkspaceName string `json:"workspaceName"`
+	Role          string `json:"role"`
+	Inherited     bool   `json:"inherited"`
+}
+
+// GetWorkspacesForUser lists the workspaces of every accessible organization
+// on which the user with this email holds at least minRole (any role if
+// minRole is empty)
+// Inherited is true when the role comes from the organization, capped at the
+// maxInheritedRole of the workspace
+func GetWorkspacesForUser(email string, minRole string) ([]UserWorkspace, error) {
+	if minRole != "" && !slices.Contains(Roles, minRole) {
+		return nil, fmt.Errorf("unknown role %q, expected one of %s", minRole, strings.Join(Roles, ", "))
+	}
+	if minRole == "" {
+		minRole = Roles[len(Roles)-1]
+	}
+	workspaces := []UserWorkspace{}
+	WalkWorkspaces(func(org Org, ws Workspace) {
+		access := GetWorkspaceAccess(ws.Id)
+		for _, user := range access.Users {
+			if !strings.EqualFold(user.Email, email) {
+				continue
+			}
+			role := user.EffectiveRole(access.MaxInheritedRole)
+			if !HasRole(role, minRole) {
+				continue
+			}
+			workspaces = append(workspaces, UserWorkspace{
+				OrgId:         org.Id,
+				OrgName:       org.Name,
+				WorkspaceId:   ws.Id,
+				WorkspaceName: ws.Name,
+				Role:          role,
+				Inherited:     role != user.Access,
+			})
+		}
+	})
+	return workspaces, nil
+}
+
+// appendAccessEntries adds the effective role of each user of access to entries
+func appendAccessEntries(entries []AccessEntry, base AccessEntry, access EntityAccess) []AccessEntry {
+	for _, user := range access.Users {
+		role := user.EffectiveRole(access.MaxInheritedRole)
+		if role == "" {
+			continue
+		}
+		base.Email = user.Email
+		base.Role = role
+		base.Inherited = role != user.Access
+		entries = append(entries, base)
+	}
+	return entries
+}
+
+// Get a workspace
+func GetWorkspace(workspaceId int) (Workspace, int) {
+	return GetWorkspaceContext(context.Background(), workspaceId)
+}
+
+// GetWorkspaceContext is like GetWorkspace, with a context cancelling its requests
+func GetWorkspaceContext(ctx context.Context, workspaceId int) (Workspace, int) {
+	workspace := Workspace{}
+	url := fmt.Sprintf("workspaces/%d", workspaceId)
+	response, status := httpGetContext(ctx, url, "")
 	if status == http.StatusOK {
-		fmt.Printf("Workspace %d deleted\t✅\n", workspaceId)
-	} else {
-		fmt.Printf("Unable to delete workspace %d : %s ❗️\n", workspaceId, response)
+		status = decodeJSON(response, status, &workspace)
+	}
+	return workspace, status
+}
+
+// GetWorkspaceDocs retrieves the documents of a workspace
+// The API has no docs-only endpoint, so the workspace is still fetched,
+// but only its documents are decoded: the org metadata is skipped
+func GetWorkspaceDocs(workspaceId int) ([]Doc, int) {
+	var workspace struct {
+		Docs []Doc `json:"docs"`
+	}
+	url := fmt.Sprintf("workspaces/%d", workspaceId)
+	response, status := httpGet(url, "")
+	if status != http.StatusOK {
+		return nil, status
+	}
+	if status = decodeJSON(response, status, &workspace); status != http.StatusOK {
+		return nil, status
+	}
+	return workspace.Docs, status
+}
+
+// Delete an organization
+func DeleteOrg(orgId int, orgName string) error {
+	url := fmt.Sprintf("orgs/%d/%s", orgId, orgName)
+	response, status := httpDelete(url, "")
+	if status != http.StatusOK {
+		return fmt.Errorf("unable to delete organization %d : %s: HTTP %d: %s", orgId, orgName, status, response)
+	}
+	return nil
+}
+
+// Delete a workspace
+func DeleteWorkspace(workspaceId int) error {
+	url := fmt.Sprintf("workspaces/%d", workspaceId)
+	response, status := httpDelete(url, "")
+	if status != http.StatusOK {
+		return fmt.Errorf("unable to delete workspace %d: HTTP %d: %s", workspaceId, status, response)
 	}
+	return nil
 }
 
 // Delete a document
-func DeleteDoc(docId string) {
+func DeleteDoc(docId string) error {
 	url := fmt.Sprintf("docs/%s", docId)
 	response, status := httpDelete(url, "")
-	if status == http.StatusOK {
-		fmt.Printf("Document %s deleted\t✅\n", docId)
-	} else {
-		fmt.Printf("Unable to delete document %s : %s ❗️", docId, response)
+	if status != http.StatusOK {
+		return fmt.Errorf("unable to delete document %s: HTTP %d: %s", docId, status, response)
 	}
+	return nil
 }
 
 // Delete a user
-func DeleteUser(userId int) {
+func DeleteUser(userId int) error {
 	url := fmt.Sprintf("users/%d", userId)
 	response, status := httpDelete(url, `{"name": ""}`)
 
-	var message string
+	var reason string
 	switch status {
-	case 200:
-		message = "The account has been deleted successfully"
-	case 400:
-		message = "The passed user name does not match the one retrieved from the database given the passed user id"
-	case 403:
-		message = "The caller is not allowed to delete this account"
-	case 404:
-		message = "The user is not found"
-	}
-	fmt.Println(message)
-	if status != http.StatusOK {
-		fmt.Printf("ERREUR: %s\n", response)
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		reason = "the passed user name does not match the one retrieved from the database given the passed user id"
+	case http.StatusForbidden:
+		reason = "the caller is not allowed to delete this account"
+	case http.StatusNotFound:
+		reason = "the user is not found"
+	default:
+		reason = fmt.Sprintf("HTTP %d", status)
 	}
+	return fmt.Errorf("unable to delete user %d: %s: %s", userId, reason, response)
 }
 
 // Workspace access rights query
 func GetWorkspaceAccess(workspaceId int) EntityAccess {
+	workspaceAccess, _ := GetWorkspaceAccessWithStatus(workspaceId)
+	return workspaceAccess
+}
+
+// GetWorkspaceAccessWithStatus is like GetWorkspaceAccess, with the status of
+// the request
+func GetWorkspaceAccessWithStatus(workspaceId int) (EntityAccess, int) {
 	workspaceAccess := EntityAccess{}
 	url := fmt.Sprintf("workspaces/%d/access", workspaceId)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &workspaceAccess)
-	return workspaceAccess
+	response, status := httpGet(url, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &workspaceAccess)
+	}
+	return workspaceAccess, status
 }
 
 // Retrieves information about a specific document
-func GetDoc(docId string) Doc {
+// Returns the HTTP status, 404 if the document does not exist
+func GetDoc(docId string) (Doc, int) {
+	return GetDocContext(context.Background(), docId)
+}
+
+// GetDocContext is like GetDoc, with a context cancelling its requests
+func GetDocContext(ctx context.Context, docId string) (Doc, int) {
 	doc := Doc{}
 	url := "docs/" + docId
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &doc)
-	return doc
+	response, status := httpGetContext(ctx, url, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &doc)
+	}
+	return doc, status
+}
+
+// Checks whether a document exists and is accessible
+func DocExists(docId string) bool {
+	_, status := GetDoc(docId)
+	return status == http.StatusOK
+}
+
+// Retrieves the list of tables contained in a document
+func GetDocTables(docId string) Tables {
+	return GetDocTablesContext(context.Background(), docId)
+}
+
+// GetDocTablesContext is like GetDocTables, with a context cancelling its requests
+func GetDocTablesContext(ctx context.Context, docId string) Tables {
+	tables := Tables{}
+	url := "docs/" + docId + "/tables"
+	response, status := httpGetContext(ctx, url, "")
+	decodeJSON(response, status, &tables)
+
+	return tables
+}
+
+// CreateTable creates a table with its columns in a document
+// POST /docs/{docId}/tables
+// Returns the created table, whose ID may differ from the requested one
+func CreateTable(docId string, table TableDef) (Table, int) {
+	return CreateTableContext(context.Background(), docId, table)
+}
+
+// CreateTableContext is like CreateTable, with a context cancelling its requests
+func CreateTableContext(ctx context.Context, docId string, table TableDef) (Table, int) {
+	created := Table{}
+	body := struct {
+		Tables []TableDef `json:"tables"`
+	}{Tables: []TableDef{table}}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return created, -1
+	}
+
+	url := fmt.Sprintf("docs/%s/tables", docId)
+	response, status := httpPostContext(ctx, url, string(bodyJSON))
+	if status == http.StatusOK {
+		result := Tables{}
+		status = decodeJSON(response, status, &result)
+		if len(result.Tables) > 0 {
+			created = result.Tables[0]
+		}
+	}
+	return created, status
+}
+
+// UpdateTable modifies the metadata of a table
+// PATCH /docs/{docId}/tables
+// Renaming a table is done by setting the "tableId" field
+func UpdateTable(docId string, tableId string, fields map[string]interface{}) (string, int) {
+	return UpdateTableContext(context.Background(), docId, tableId, fields)
+}
+
+// UpdateTableContext is like UpdateTable, with a context cancelling its requests
+func UpdateTableContext(ctx context.Context, docId string, tableId string, fields map[string]interface{}) (string, int) {
+	type tableUpdate struct {
+		Id     string                 `json:"id"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	body := struct {
+		Tables []tableUpdate `json:"tables"`
+	}{Tables: []tableUpdate{{Id: tableId, Fields: fields}}}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return "", -1
+	}
+
+	url := fmt.Sprintf("docs/%s/tables", docId)
+	return httpPatchContext(ctx, url, string(bodyJSON))
+}
+
+// Retrieves a list of table columns, without Grist's internal columns
+// (see IsInternalColumn)
+func GetTableColumns(docId string, tableId string) TableColumns {
+	return GetTableColumnsContext(context.Background(), docId, tableId)
+}
+
+// GetTableColumnsContext is like GetTableColumns, with a context cancelling its requests
+func GetTableColumnsContext(ctx context.Context, docId string, tableId string) TableColumns {
+	columns, _ := getVisibleTableColumns(ctx, docId, tableId)
+	return columns
+}
+
+// GetTableColumnsWithStatus is like GetTableColumns, with the status of the
+// request, e.g. 404 if there is no such table
+func GetTableColumnsWithStatus(docId string, tableId string) (TableColumns, int) {
+	return getVisibleTableColumns(context.Background(), docId, tableId)
+}
+
+func getVisibleTableColumns(ctx context.Context, docId string, tableId string) (TableColumns, int) {
+	columns, status := getTableColumns(ctx, docId, tableId, false)
+	columns.Columns = slices.DeleteFunc(columns.Columns, func(column TableColumn) bool {
+		return IsInternalColumn(column.Id)
+	})
+	return columns, status
+}
+
+// GetAllTableColumns retrieves the columns of a table including the hidden
+// and internal ones, such as manualSort
+func GetAllTableColumns(docId string, tableId string) TableColumns {
+	columns, _ := getTableColumns(context.Background(), docId, tableId, true)
+	return columns
+}
+
+func getTableColumns(ctx context.Context, docId string, tableId string, hidden bool) (TableColumns, int) {
+	columns := TableColumns{}
+	url := "docs/" + docId + "/tables/" + tableId + "/columns"
+	if hidden {
+		url += "?hidden=true"
+	}
+	response, status := httpGetContext(ctx, url, "")
+	if status != http.StatusOK {
+		return columns, status
+	}
+	status = decodeJSON(response, status, &columns)
+	for i := range columns.Columns {
+		columns.Columns[i].Fields.RefTable = refTable(columns.Columns[i].Fields.Type)
+	}
+
+	return columns, status
+}
+
+// IsInternalColumn tells whether a column is used by Grist for its own
+// bookkeeping: manualSort, which keeps the order of rows, and the
+// gristHelper_ columns behind display columns and conditional styles
+func IsInternalColumn(columnId string) bool {
+	return columnId == "manualSort" || strings.HasPrefix(columnId, "gristHelper_")
+}
+
+// refTable returns the table referenced by a column type such as
+// "Ref:Categories" or "RefList:Categories", or "" for other types
+func refTable(columnType string) string {
+	for _, prefix := range []string{"Ref:", "RefList:"} {
+		if table, found := strings.CutPrefix(columnType, prefix); found {
+			return table
+		}
+	}
+	return ""
+}
+
+// GetDocSchema retrieves the tables of a document with their columns
+// Columns are fetched a few tables at a time; if the columns of any table
+// can't be read, the status of the first such failure is returned, as a
+// partial schema would make tables look empty
+func GetDocSchema(docId string) (DocSchema, int) {
+	schema := DocSchema{}
+	response, status := httpGet("docs/"+docId+"/tables", "")
+	if status != http.StatusOK {
+		return schema, status
+	}
+	tables := Tables{}
+	if status = decodeJSON(response, status, &tables); status != http.StatusOK {
+		return schema, status
+	}
+
+	schema.Tables = make([]TableSchema, len(tables.Tables))
+	statuses := make([]int, len(tables.Tables))
+	forEachConcurrently(len(tables.Tables), func(i int) {
+		tableId := tables.Tables[i].Id
+		columns, status := getVisibleTableColumns(context.Background(), docId, tableId)
+		schema.Tables[i] = TableSchema{Id: tableId, Columns: columns.Columns}
+		statuses[i] = status
+	})
+	for _, status := range statuses {
+		if status != http.StatusOK {
+			return schema, status
+		}
+	}
+
+	return schema, status
+}
+
+// DiffSchemas compares schema a (reference) with schema b
+// Tables and columns are matched by ID; columns are compared on their
+// type, formula and label
+func DiffSchemas(a DocSchema, b DocSchema) SchemaDiff {
+	diff := SchemaDiff{}
+
+	tablesB := make(map[string]TableSchema, len(b.Tables))
+	for _, table := range b.Tables {
+		tablesB[table.Id] = table
+	}
+	tablesA := make(map[string]bool, len(a.Tables))
+	for _, tableA := range a.Tables {
+		tablesA[tableA.Id] = true
+		tableB, found := tablesB[tableA.Id]
+		if !found {
+			diff.RemovedTables = append(diff.RemovedTables, tableA.Id)
+			continue
+		}
+		if tableDiff := diffTableColumns(tableA, tableB); tableDiff != nil {
+			diff.ChangedTables = append(diff.ChangedTables, *tableDiff)
+		}
+	}
+	for _, table := range b.Tables {
+		if !tablesA[table.Id] {
+			diff.AddedTables = append(diff.AddedTables, table.Id)
+		}
+	}
+
+	return diff
+}
+
+// diffTableColumns compares the columns of two versions of a table
+// Returns nil if they have the same columns
+func diffTableColumns(a TableSchema, b TableSchema) *TableDiff {
+	diff := TableDiff{TableId: a.Id}
+
+	columnsB := make(map[string]ColumnFields, len(b.Columns))
+	for _, column := range b.Columns {
+		columnsB[column.Id] = column.Fields
+	}
+	columnsA := make(map[string]bool, len(a.Columns))
+	for _, columnA := range a.Columns {
+		columnsA[columnA.Id] = true
+		fieldsB, found := columnsB[columnA.Id]
+		if !found {
+			diff.RemovedColumns = append(diff.RemovedColumns, columnA.Id)
+			continue
+		}
+		fieldsA := columnA.Fields
+		for _, property := range []struct{ name, before, after string }{
+			{"type", fieldsA.Type, fieldsB.Type},
+			{"formula", fieldsA.Formula, fieldsB.Formula},
+			{"label", fieldsA.Label, fieldsB.Label},
+		} {
+			if property.before != property.after {
+				diff.ChangedColumns = append(diff.ChangedColumns, ColumnChange{
+					ColumnId: columnA.Id,
+					Property: property.name,
+					Before:   property.before,
+					After:    property.after,
+				})
+			}
+		}
+	}
+	for _, column := range b.Columns {
+		if !columnsA[column.Id] {
+			diff.AddedColumns = append(diff.AddedColumns, column.Id)
+		}
+	}
+
+	if len(diff.AddedColumns) == 0 && len(diff.RemovedColumns) == 0 && len(diff.ChangedColumns) == 0 {
+		return nil
+	}
+	return &diff
+}
+
+// IsEmpty returns true if the schemas compared are identical
+func (d SchemaDiff) IsEmpty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+// Retrieves records from a table
+func GetTableRows(docId string, tableId string) TableRows {
+	rows, _ := getTableRows(docId, tableId)
+	return rows
+}
+
+// getTableRows retrieves the row IDs of a table, with the status of the request
+func getTableRows(docId string, tableId string) (TableRows, int) {
+	rows := TableRows{}
+	url := "docs/" + docId + "/tables/" + tableId + "/data"
+	response, status := httpGet(url, "")
+	if status != http.StatusOK {
+		return rows, status
+	}
+	status = decodeJSON(response, status, &rows)
+	return rows, status
+}
+
+// TruncateTable deletes all the rows of a table, in batches (see DeleteRecords)
+// Returns the number of deleted rows, and the status of the failed request
+// if a batch couldn't be deleted (the previous batches remain deleted)
+func TruncateTable(docId string, tableId string) (int, int) {
+	rows, status := getTableRows(docId, tableId)
+	if status != http.StatusOK {
+		return 0, status
+	}
+	rowIds := make([]int, len(rows.Id))
+	for i, id := range rows.Id {
+		rowIds[i] = int(id)
+	}
+
+	deleted, _, status := deleteRecordBatches(context.Background(), docId, tableId, rowIds)
+	return deleted, status
+}
+
+// CountRecords returns the number of rows of a table
+// Rows are counted with the SQL endpoint, without fetching them. Rows are
+// fetched only if the SQL endpoint is unavailable
+func CountRecords(docId string, tableId string) (int, int) {
+	sql := fmt.Sprintf("SELECT COUNT(*) AS count FROM %s", quoteSQLIdentifier(tableId))
+	result, status := RunSQL(docId, sql, nil)
+	if status == http.StatusOK && len(result.Records) == 1 {
+		if count, ok := result.Records[0].Fields["count"].(float64); ok {
+			return int(count), status
+		}
+	}
+
+	rows, status := getTableRows(docId, tableId)
+	return len(rows.Id), status
+}
+
+// GetTablesRowCounts counts the rows of every table of a document, a few
+// tables at a time
+// Results are sorted by row count, largest first, the tables that couldn't
+// be counted (see TableRowCount.Status) coming last
+func GetTablesRowCounts(docId string) []TableRowCount {
+	tables := GetDocTables(docId)
+	counts := make([]TableRowCount, len(tables.Tables))
+
+	forEachConcurrently(len(tables.Tables), func(i int) {
+		tableId := tables.Tables[i].Id
+		nbRows, status := CountRecords(docId, tableId)
+		if status != http.StatusOK {
+			nbRows = 0
+		}
+		counts[i] = TableRowCount{TableId: tableId, RowCount: nbRows, Status: status}
+	})
+
+	sortTableRowCounts(counts)
+	return counts
+}
+
+// Comparison of the data of a table present in two documents
+// Hashes are only computed for deep comparisons of tables with the same
+// number of rows
+type TableDataComparison struct {
+	TableId string `json:"tableId"`
+	RowsA   int    `json:"rowsA"`
+	RowsB   int    `json:"rowsB"`
+	HashA   string `json:"hashA,omitempty"`
+	HashB   string `json:"hashB,omitempty"`
+	Match   bool   `json:"match"`
+}
+
+// Comparison of the data of two documents
+type DataComparison struct {
+	Tables  []TableDataComparison `json:"tables"`
+	OnlyInA []string              `json:"onlyInA"`
+	OnlyInB []string              `json:"onlyInB"`
+}
+
+// Matches returns true if both documents have the same tables and each one
+// matched
+func (c DataComparison) Matches() bool {
+	if len(c.OnlyInA) > 0 || len(c.OnlyInB) > 0 {
+		return false
+	}
+	for _, table := range c.Tables {
+		if !table.Match {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareDocsData compares the data of the tables common to docA and docB
+// Row counts are compared with the SQL endpoint; with deep, the content of
+// tables with the same number of rows is also compared, by hashing their
+// records (see HashTableContent, and its limitation on Ref columns)
+// Tables are compared a few at a time
+func CompareDocsData(docA string, docB string, deep bool) (DataComparison, error) {
+	comparison := DataComparison{OnlyInA: []string{}, OnlyInB: []string{}}
+	tablesA := GetDocTables(docA).Tables
+	tablesB := GetDocTables(docB).Tables
+	if len(tablesA) == 0 {
+		return comparison, fmt.Errorf("no tables found in document %s", docA)
+	}
+	if len(tablesB) == 0 {
+		return comparison, fmt.Errorf("no tables found in document %s", docB)
+	}
+
+	inB := map[string]bool{}
+	for _, table := range tablesB {
+		inB[table.Id] = true
+	}
+	for _, table := range tablesA {
+		if inB[table.Id] {
+			comparison.Tables = append(comparison.Tables, TableDataComparison{TableId: table.Id})
+			delete(inB, table.Id)
+		} else {
+			comparison.OnlyInA = append(comparison.OnlyInA, table.Id)
+		}
+	}
+	for _, table := range tablesB {
+		if inB[table.Id] {
+			comparison.OnlyInB = append(comparison.OnlyInB, table.Id)
+		}
+	}
+
+	errs := make([]error, len(comparison.Tables))
+	forEachConcurrently(len(comparison.Tables), func(i int) {
+		errs[i] = compareTableData(docA, docB, deep, &comparison.Tables[i])
+	})
+	return comparison, errors.Join(errs...)
+}
+
+// compareTableData fills the comparison of a table of docA and docB
+func compareTableData(docA string, docB string, deep bool, table *TableDataComparison) error {
+	var status int
+	if table.RowsA, status = CountRecords(docA, table.TableId); status != http.StatusOK {
+		return fmt.Errorf("unable to count the rows of table %s in document %s: HTTP %d", table.TableId, docA, status)
+	}
+	if table.RowsB, status = CountRecords(docB, table.TableId); status != http.StatusOK {
+		return fmt.Errorf("unable to count the rows of table %s in document %s: HTTP %d", table.TableId, docB, status)
+	}
+	table.Match = table.RowsA == table.RowsB
+	if !deep || !table.Match {
+		return nil
+	}
+	if table.HashA, status = HashTableContent(docA, table.TableId); status != http.StatusOK {
+		return fmt.Errorf("unable to read table %s in document %s: HTTP %d", table.TableId, docA, status)
+	}
+	if table.HashB, status = HashTableContent(docB, table.TableId); status != http.StatusOK {
+		return fmt.Errorf("unable to read table %s in document %s: HTTP %d", table.TableId, docB, status)
+	}
+	table.Match = table.HashA == table.HashB
+	return nil
+}
+
+// HashTableContent returns the SHA-256 checksum of the records of a table
+// Records are hashed without their ID and in sorted order, so that a copy
+// of the table matches even if its rows were renumbered or reordered
+// Ref and RefList cells hold the IDs of the referenced rows and are hashed
+// as is: a table referencing rows that were renumbered in the copy doesn't
+// match, even though it points to the same data
+func HashTableContent(docId string, tableId string) (string, int) {
+	records, status := GetRecords(docId, tableId, nil)
+	if status != http.StatusOK {
+		return "", status
+	}
+	rows := make([]string, len(records.Records))
+	for i, record := range records.Records {
+		// Map keys are sorted by json.Marshal
+		row, err := json.Marshal(record.Fields)
+		if err != nil {
+			return "", -1
+		}
+		rows[i] = string(row)
+	}
+	sort.Strings(rows)
+	checksum := sha256.New()
+	for _, row := range rows {
+		checksum.Write([]byte(row))
+		checksum.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(checksum.Sum(nil)), status
+}
+
+// sortTableRowCounts sorts tables by row count, largest first, then by name
+func sortTableRowCounts(counts []TableRowCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		failedI, failedJ := counts[i].Status != http.StatusOK, counts[j].Status != http.StatusOK
+		if failedI != failedJ {
+			return failedJ
+		}
+		if counts[i].RowCount != counts[j].RowCount {
+			return counts[i].RowCount > counts[j].RowCount
+		}
+		return counts[i].TableId < counts[j].TableId
+	})
+}
+
+// Returns the list of users with access to the document
+func GetDocAccess(docId string) EntityAccess {
+	lstUsers, _ := GetDocAccessWithStatus(docId)
+	return lstUsers
+}
+
+// GetDocAccessWithStatus is like GetDocAccess, with the status of the request
+func GetDocAccessWithStatus(docId string) (EntityAccess, int) {
+	var lstUsers EntityAccess
+	url := fmt.Sprintf("docs/%s/access", docId)
+	response, status := httpGet(url, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &lstUsers)
+	}
+	return lstUsers, status
+}
+
+// Access rule of a document (row and column level security)
+type AccessRule struct {
+	Id          int     `json:"id"`
+	TableId     string  `json:"tableId"`     // "*" for the default rules, "*SPECIAL" for special ones
+	ColIds      string  `json:"colIds"`      // "*" for all the columns, or column IDs separated by commas
+	Formula     string  `json:"formula"`     // Condition of the rule, empty if it applies to everyone
+	Permissions string  `json:"permissions"` // Granted (+) and denied (-) permissions, e.g. "+R-UD" or "all"
+	Memo        string  `json:"memo,omitempty"`
+	Position    float64 `json:"position"`
+}
+
+// User attribute of the access rules: the record of TableId whose
+// LookupColId matches the CharId property of the user, e.g. user.Email,
+// made available to the rule conditions as user.Name
+type UserAttribute struct {
+	Name        string `json:"name"`
+	TableId     string `json:"tableId"`
+	LookupColId string `json:"lookupColId"`
+	CharId      string `json:"charId"`
+}
+
+// Access rules of a document, in the order Grist evaluates them
+type DocAccessRules struct {
+	Rules          []AccessRule    `json:"rules"`
+	UserAttributes []UserAttribute `json:"userAttributes"`
+}
+
+// GetDocAccessRules retrieves the access rules of a document from its
+// _grist_ACLRules and _grist_ACLResources metadata tables
+// Only owners of the document can read them
+func GetDocAccessRules(docId string) (DocAccessRules, int) {
+	accessRules := DocAccessRules{Rules: []AccessRule{}, UserAttributes: []UserAttribute{}}
+
+	resources := struct {
+		Records []struct {
+			Id     int `json:"id"`
+			Fields struct {
+				TableId string `json:"tableId"`
+				ColIds  string `json:"colIds"`
+			} `json:"fields"`
+		} `json:"records"`
+	}{}
+	response, status := httpGet(fmt.Sprintf("docs/%s/tables/_grist_ACLResources/records", docId), "")
+	if status = decodeJSON(response, status, &resources); status != http.StatusOK {
+		return accessRules, status
+	}
+
+	rules := struct {
+		Records []struct {
+			Id     int `json:"id"`
+			Fields struct {
+				Resource        int     `json:"resource"`
+				AclFormula      string  `json:"aclFormula"`
+				PermissionsText string  `json:"permissionsText"`
+				RulePos         float64 `json:"rulePos"`
+				UserAttributes  string  `json:"userAttributes"`
+				Memo            string  `json:"memo"`
+			} `json:"fields"`
+		} `json:"records"`
+	}{}
+	response, status = httpGet(fmt.Sprintf("docs/%s/tables/_grist_ACLRules/records", docId), "")
+	if status = decodeJSON(response, status, &rules); status != http.StatusOK {
+		return accessRules, status
+	}
+
+	// Grist evaluates the column rules of a table before its table-wide
+	// rules (colIds "*"), and the default rules (tableId "*") after the
+	// rules of all the tables
+	type resourceOrder struct {
+		table     int // Tables in the order of their first resource, defaults last
+		tableWide bool
+		index     int
+		rule      AccessRule
+	}
+	resourceIndex := map[int]int{}
+	tableIndex := map[string]int{}
+	for i, resource := range resources.Records {
+		resourceIndex[resource.Id] = i
+		if _, found := tableIndex[resource.Fields.TableId]; !found && resource.Fields.TableId != "*" {
+			tableIndex[resource.Fields.TableId] = i
+		}
+	}
+	ordered := []resourceOrder{}
+	for _, record := range rules.Records {
+		fields := record.Fields
+		if fields.UserAttributes != "" {
+			attribute := UserAttribute{}
+			if err := json.Unmarshal([]byte(fields.UserAttributes), &attribute); err != nil {
+				logger.Warn("ignoring user attribute", "rule", record.Id, "error", err)
+				continue
+			}
+			accessRules.UserAttributes = append(accessRules.UserAttributes, attribute)
+			continue
+		}
+		// Rules of older documents without permissions grant nothing
+		if fields.PermissionsText == "" {
+			continue
+		}
+		index, found := resourceIndex[fields.Resource]
+		if !found {
+			continue
+		}
+		resource := resources.Records[index].Fields
+		table, found := tableIndex[resource.TableId]
+		if !found {
+			table = len(resources.Records)
+		}
+		ordered = append(ordered, resourceOrder{table, resource.ColIds == "*", index, AccessRule{
+			Id:          record.Id,
+			TableId:     resource.TableId,
+			ColIds:      resource.ColIds,
+			Formula:     fields.AclFormula,
+			Permissions: fields.PermissionsText,
+			Memo:        fields.Memo,
+			Position:    fields.RulePos,
+		}})
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].table != ordered[j].table {
+			return ordered[i].table < ordered[j].table
+		}
+		if ordered[i].tableWide != ordered[j].tableWide {
+			return !ordered[i].tableWide
+		}
+		if ordered[i].index != ordered[j].index {
+			return ordered[i].index < ordered[j].index
+		}
+		return ordered[i].rule.Position < ordered[j].rule.Position
+	})
+	for _, rule := range ordered {
+		accessRules.Rules = append(accessRules.Rules, rule.rule)
+	}
+	return accessRules, status
+}
+
+// MoveResult records the outcome of moving one document
+type MoveResult struct {
+	DocId   string `json:"docId"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Move all documents from a workspace to another, returning the outcome of
+// each move. An error is returned only if one of the workspaces is not found;
+// failures on individual documents are recorded in the results.
+func MoveAllDocs(fromWorkspaceId int, toWorkspaceId int) ([]MoveResult, error) {
+	from_ws, status := GetWorkspace(fromWorkspaceId)
+	if err := workspaceStatusError(fromWorkspaceId, status); err != nil {
+		return nil, err
+	}
+	_, status = GetWorkspace(toWorkspaceId)
+	if err := workspaceStatusError(toWorkspaceId, status); err != nil {
+		return nil, err
+	}
+
+	results := make([]MoveResult, 0, len(from_ws.Docs))
+	for _, doc := range from_ws.Docs {
+		result := MoveResult{DocId: doc.Id, Success: true}
+		if err := MoveDoc(doc.Id, toWorkspaceId); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// workspaceStatusError turns the status of a workspace lookup into an error,
+// telling a missing workspace apart from any other failure
+func workspaceStatusError(workspaceId int, status int) error {
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("workspace %d not found", workspaceId)
+	default:
+		return fmt.Errorf("unable to get workspace %d: HTTP %d", workspaceId, status)
+	}
+}
+
+// Move a document in a workspace
+func MoveDoc(docId string, workspaceId int) error {
+	url := "docs/" + docId + "/move"
+	data, err := json.Marshal(struct {
+		Workspace int `json:"workspace"`
+	}{workspaceId})
+	if err != nil {
+		return err
+	}
+	response, status := httpPatch(url, string(data))
+	if status != http.StatusOK {
+		return fmt.Errorf("unable to move document %s to workspace %d: HTTP %d: %s", docId, workspaceId, status, response)
+	}
+	return nil
+}
+
+// ForceReloadDoc closes a document and reopens it from storage, e.g. after
+// a direct change of its SQLite file or to clear a stuck in-memory state
+// POST /docs/{docId}/force-reload
+func ForceReloadDoc(docId string) (string, int) {
+	url := fmt.Sprintf("docs/%s/force-reload", docId)
+	return httpPost(url, "")
+}
+
+// Purge a document's history, to retain only the last modifications
+func PurgeDoc(docId string, nbHisto int) error {
+	url := "docs/" + docId + "/states/remove"
+	data, err := json.Marshal(struct {
+		Keep int `json:"keep"`
+	}{nbHisto})
+	if err != nil {
+		return err
+	}
+	response, status := httpPost(url, string(data))
+	if status != http.StatusOK {
+		return fmt.Errorf("unable to purge history of document %s: HTTP %d: %s", docId, status, response)
+	}
+	return nil
+}
+
+// A state of a document's history: the number of the action that produced
+// it and its hash
+type DocState struct {
+	N    int    `json:"n"`
+	Hash string `json:"h"`
+}
+
+// GetDocStates retrieves the states of a document's history, most recent
+// first
+// GET /docs/{docId}/states
+// Grist gives no time for the states: only their action number and hash
+func GetDocStates(docId string) ([]DocState, int) {
+	var states struct {
+		States []DocState `json:"states"`
+	}
+	response, status := httpGet("docs/"+docId+"/states", "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &states)
+	}
+	return states.States, status
+}
+
+// Rows of a table changed by actions
+type TableDelta struct {
+	AddRows    []int `json:"addRows"`
+	UpdateRows []int `json:"updateRows"`
+	RemoveRows []int `json:"removeRows"`
+}
+
+// Changes made by actions, by table
+type ActionSummary struct {
+	TableRenames [][]*string           `json:"tableRenames"`
+	TableDeltas  map[string]TableDelta `json:"tableDeltas"`
+}
+
+// String describes the changes, e.g. "People: 2 added, 1 updated"
+func (a ActionSummary) String() string {
+	changes := []string{}
+	for _, rename := range a.TableRenames {
+		if len(rename) != 2 {
+			continue
+		}
+		switch {
+		case rename[0] == nil && rename[1] != nil:
+			changes = append(changes, *rename[1]+": created")
+		case rename[0] != nil && rename[1] == nil:
+			changes = append(changes, *rename[0]+": removed")
+		case rename[0] != nil && rename[1] != nil:
+			changes = append(changes, *rename[0]+": renamed to "+*rename[1])
+		}
+	}
+	for _, tableId := range slices.Sorted(maps.Keys(a.TableDeltas)) {
+		delta := a.TableDeltas[tableId]
+		rows := []string{}
+		for _, count := range []struct {
+			n    int
+			verb string
+		}{{len(delta.AddRows), "added"}, {len(delta.UpdateRows), "updated"}, {len(delta.RemoveRows), "removed"}} {
+			if count.n > 0 {
+				rows = append(rows, fmt.Sprintf("%d %s", count.n, count.verb))
+			}
+		}
+		if len(rows) > 0 {
+			changes = append(changes, tableId+": "+strings.Join(rows, ", "))
+		}
+	}
+	return strings.Join(changes, "; ")
+}
+
+// Comparison of two states of a document
+// Summary tells which side has changes: "same", "left", "right", "both" or
+// "unrelated"
+type DocStateComparison struct {
+	Left    DocState  `json:"left"`
+	Right   DocState  `json:"right"`
+	Parent  *DocState `json:"parent"`
+	Summary string    `json:"summary"`
+	Details *struct {
+		LeftChanges  ActionSummary `json:"leftChanges"`
+		RightChanges ActionSummary `json:"rightChanges"`
+	} `json:"details"`
+}
+
+// CompareDocStates compares two states of a document, given by hash
+// GET /docs/{docId}/compare?left={left}&right={right}
+func CompareDocStates(docId string, left string, right string) (DocStateComparison, int) {
+	comparison := DocStateComparison{}
+	params := url.Values{"left": {left}, "right": {right}}
+	response, status := httpGet("docs/"+docId+"/compare?"+params.Encode(), "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &comparison)
+	}
+	return comparison, status
+}
+
+// Import a list of user & role into a workspace
+// Search workspace by name in org, creating it if missing
+// Returns the id of the workspace the users were imported into
+func ImportUsers(orgId int, workspaceName string, users []UserRole) (int, error) {
+	lstWorkspaces := GetOrgWorkspaces(orgId)
+	idWorkspace := 0
+	for _, ws := range lstWorkspaces {
+		if ws.Name == workspaceName {
+			idWorkspace = ws.Id
+		}
+	}
+
+	if idWorkspace == 0 {
+		var err error
+		if idWorkspace, err = CreateWorkspace(orgId, workspaceName); err != nil {
+			return 0, err
+		}
+	}
+
+	url := fmt.Sprintf("workspaces/%d/access", idWorkspace)
+
+	// Emails are normalized so that a user listed twice gets a single entry,
+	// the last role given winning
+	delta := map[string]string{}
+	for _, role := range users {
+		delta[strings.ToLower(strings.TrimSpace(role.Email))] = role.Role
+	}
+	patch, err := json.Marshal(accessDelta(delta))
+	if err != nil {
+		return idWorkspace, err
+	}
+
+	body, status := httpPatch(url, string(patch))
+	if status != http.StatusOK {
+		return idWorkspace, fmt.Errorf("unable to import users in workspace %d: HTTP %d: %s", idWorkspace, status, body)
+	}
+	return idWorkspace, nil
+}
+
+// accessDelta builds the body of an access update, giving a role to each email
+func accessDelta(users map[string]string) any {
+	type delta struct {
+		Users map[string]string `json:"users"`
+	}
+	return struct {
+		Delta delta `json:"delta"`
+	}{delta{users}}
+}
+
+// Create an organization
+// Returns the ID of the new organization
+func CreateOrg(orgName string, orgDomain string) (int, error) {
+	data, err := json.Marshal(struct {
+		Name   string `json:"name"`
+		Domain string `json:"domain"`
+	}{orgName, orgDomain})
+	if err != nil {
+		return 0, err
+	}
+	body, status := httpPost("orgs", string(data))
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("unable to create organization %s: HTTP %d%s", orgName, status, responseErrorDetail([]byte(body)))
+	}
+	id, err := parseCreatedId(body)
+	if err != nil {
+		return 0, fmt.Errorf("organization %s created, but %w", orgName, err)
+	}
+	return id, nil
+}
+
+// Create a workspace in an organization
+// Returns the ID of the new workspace
+func CreateWorkspace(orgId int, workspaceName string) (int, error) {
+	url := fmt.Sprintf("orgs/%d/workspaces", orgId)
+	data, err := json.Marshal(struct {
+		Name string `json:"name"`
+	}{workspaceName})
+	if err != nil {
+		return 0, err
+	}
+	body, status := httpPost(url, string(data))
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("unable to create workspace %s: HTTP %d%s", workspaceName, status, responseErrorDetail([]byte(body)))
+	}
+	id, err := parseCreatedId(body)
+	if err != nil {
+		return 0, fmt.Errorf("workspace %s created, but %w", workspaceName, err)
+	}
+	return id, nil
+}
+
+// Create an empty document in a workspace
+// Returns the ID of the new document
+func CreateDoc(workspaceId int, docName string) (string, error) {
+	url := fmt.Sprintf("workspaces/%d/docs", workspaceId)
+	data, err := json.Marshal(struct {
+		Name string `json:"name"`
+	}{docName})
+	if err != nil {
+		return "", err
+	}
+	body, status := httpPost(url, string(data))
+	if status != http.StatusOK {
+		return "", fmt.Errorf("unable to create document %s: HTTP %d%s", docName, status, responseErrorDetail([]byte(body)))
+	}
+	// Grist answers with the document ID as a JSON string
+	docId := strings.TrimSpace(body)
+	var text string
+	if json.Unmarshal([]byte(docId), &text) == nil {
+		docId = text
+	}
+	if docId == "" {
+		return "", fmt.Errorf("document %s created, but no ID found in the response", docName)
+	}
+	return docId, nil
+}
+
+// LoadDocTemplate reads the tables of a new document from a JSON file such as
+// {"tables": [{"id": "People", "columns": [{"id": "Name", "fields": {"type": "Text"}}]}]}
+// Unknown keys are rejected, to report misspelled ones rather than
+// silently creating incomplete tables
+func LoadDocTemplate(path string) (DocTemplate, error) {
+	template := DocTemplate{}
+	// #nosec G304 - the schema file is chosen by the user
+	file, err := os.Open(path)
+	if err != nil {
+		return template, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&template); err != nil {
+		return template, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	if len(template.Tables) == 0 {
+		return template, fmt.Errorf("invalid schema %s: no tables", path)
+	}
+	tableIds := map[string]bool{}
+	for i, table := range template.Tables {
+		if table.Id == "" {
+			return template, fmt.Errorf("invalid schema %s: table %d has no id", path, i+1)
+		}
+		// Grist table IDs are case-insensitive
+		if tableIds[strings.ToLower(table.Id)] {
+			return template, fmt.Errorf("invalid schema %s: duplicate table %s", path, table.Id)
+		}
+		tableIds[strings.ToLower(table.Id)] = true
+		for j, column := range table.Columns {
+			if column.Id == "" {
+				return template, fmt.Errorf("invalid schema %s: column %d of table %s has no id", path, j+1, table.Id)
+			}
+		}
+	}
+	return template, nil
+}
+
+// parseCreatedId reads the ID of a created resource from the response body
+// Grist answers with a bare number, but the ID may also come as a JSON string
+// (like document IDs) or as an object with an "id" field
+func parseCreatedId(body string) (int, error) {
+	body = strings.TrimSpace(body)
+	if id, err := strconv.Atoi(body); err == nil {
+		return id, nil
+	}
+	var text string
+	if json.Unmarshal([]byte(body), &text) == nil {
+		if id, err := strconv.Atoi(strings.TrimSpace(text)); err == nil {
+			return id, nil
+		}
+	}
+	var object struct {
+		Id json.Number `json:"id"`
+	}
+	if json.Unmarshal([]byte(body), &object) == nil && object.Id != "" {
+		if id, err := strconv.Atoi(object.Id.String()); err == nil {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("no ID found in the response %q", responseExcerpt(body))
+}
+
+// Export doc in Grist format (Sqlite) in fileName file
+func ExportDocGrist(docId string, fileName string) error {
+	return ExportDocGristContext(context.Background(), docId, fileName)
+}
+
+// ExportDocGristContext is like ExportDocGrist, with a context cancelling its requests
+func ExportDocGristContext(ctx context.Context, docId string, fileName string) error {
+	url := fmt.Sprintf("docs/%s/download", docId)
+	return exportDoc(ctx, url, fileName)
+}
+
+// Export doc in Excel format (XLSX) in fileName file
+// options may restrict the export to a table or a view section (nil for the whole document)
+func ExportDocExcel(docId string, fileName string, options *ExportOptions) error {
+	return ExportDocExcelContext(context.Background(), docId, fileName, options)
+}
+
+// ExportDocExcelContext is like ExportDocExcel, with a context cancelling its requests
+func ExportDocExcelContext(ctx context.Context, docId string, fileName string, options *ExportOptions) error {
+	url := fmt.Sprintf("docs/%s/download/xlsx%s", docId, exportQueryParams(options))
+	return exportDoc(ctx, url, fileName)
+}
+
+// Characters not allowed in file names on some systems
+var fileNameReplacer = strings.NewReplacer(
+	"/", "_",
+	"\\", "_",
+	":", "_",
+	"*", "_",
+	"?", "_",
+	"\"", "_",
+	"<", "_",
+	">", "_",
+	"|", "_",
+)
+
+// ExportFileName builds the name of an export file from the names of the
+// exported objects, e.g. "Workspace_Doc_Table.csv", replacing the characters
+// not allowed in file names
+func ExportFileName(extension string, names ...string) string {
+	return fileNameReplacer.Replace(strings.Join(names, "_")) + "." + extension
+}
+
+// ExportOptions contains query parameters of the download endpoints
+type ExportOptions struct {
+	TableId       string   // Table to export (Excel exports all tables if empty)
+	ViewSectionId int      // View section (widget) to export, with its sort and filters
+	SortOrder     []int    // Column refs to sort by, negative for descending order
+	Filters       string   // JSON list of {"colRef": <column ref>, "filter": <filter spec>}
+	Header        string   // Column headers: "label" (default) or "colId"
+	Fields        []string // Keep only these columns of csv, tsv and dsv exports, named as in the header row
+}
+
+// exportQueryParams builds the query string of a download endpoint
+func exportQueryParams(options *ExportOptions) string {
+	if options == nil {
+		return ""
+	}
+	params := url.Values{}
+	if options.TableId != "" {
+		params.Set("tableId", options.TableId)
+	}
+	if options.ViewSectionId > 0 {
+		params.Set("viewSection", strconv.Itoa(options.ViewSectionId))
+	}
+	if len(options.SortOrder) > 0 {
+		sortJSON, err := json.Marshal(options.SortOrder)
+		if err == nil {
+			params.Set("sortOrder", string(sortJSON))
+		}
+	}
+	if options.Filters != "" {
+		params.Set("filters", options.Filters)
+	}
+	if options.Header != "" {
+		params.Set("header", options.Header)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
+
+// Formats of table downloads: comma, tab or "💩" separated values, and
+// the Frictionless table schema (JSON) describing the columns
+var TableExportFormats = []string{"csv", "tsv", "dsv", "table-schema"}
+
+// tableExportURL returns the download endpoint of a table in a format
+func tableExportURL(docId string, tableId string, format string, options *ExportOptions) string {
+	tableOptions := ExportOptions{}
+	if options != nil {
+		tableOptions = *options
+	}
+	tableOptions.TableId = tableId
+	return fmt.Sprintf("docs/%s/download/%s%s", docId, format, exportQueryParams(&tableOptions))
+}
+
+// GetTableExport retrieves the content of a table in one of TableExportFormats
+// GET /docs/{docId}/download/{format}
+func GetTableExport(docId string, tableId string, format string, options *ExportOptions) (string, int) {
+	if !slices.Contains(TableExportFormats, format) {
+		return fmt.Sprintf("unsupported format %q", format), -1
+	}
+	content, status := httpGet(tableExportURL(docId, tableId, format, options), "")
+	if status != http.StatusOK || options == nil || len(options.Fields) == 0 {
+		return content, status
+	}
+	projected, err := projectDelimited(content, format, options.Fields)
+	if err != nil {
+		return err.Error(), -1
+	}
+	return projected, status
+}
+
+// exportDelimiters are the separators of the delimited table formats
+var exportDelimiters = map[string]rune{"csv": ',', "tsv": '\t', "dsv": '💩'}
+
+// ExportDelimiter returns the separator of a delimited table format, false
+// for other formats
+func ExportDelimiter(format string) (rune, bool) {
+	delimiter, found := exportDelimiters[format]
+	return delimiter, found
+}
+
+// projectDelimited keeps the given columns of a delimited export, in the
+// order given, matching them against the header row
+// The download endpoints have no column selection, so the export is
+// filtered once downloaded
+func projectDelimited(content string, format string, fields []string) (string, error) {
+	delimiter, found := exportDelimiters[format]
+	if !found {
+		return "", fmt.Errorf("column selection is not supported with format %s", format)
+	}
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s export: %w", format, err)
+	}
+	if len(rows) == 0 {
+		return content, nil
+	}
+
+	indexes := make([]int, len(fields))
+	for i, field := range fields {
+		indexes[i] = slices.Index(rows[0], field)
+		if indexes[i] < 0 {
+			return "", fmt.Errorf("unknown column %q (columns: %s)", field, strings.Join(rows[0], ", "))
+		}
+	}
+
+	var projected strings.Builder
+	writer := csv.NewWriter(&projected)
+	writer.Comma = delimiter
+	for _, row := range rows {
+		line := make([]string, len(indexes))
+		for i, index := range indexes {
+			if index < len(row) {
+				line[i] = row[index]
+			}
+		}
+		_ = writer.Write(line)
+	}
+	writer.Flush()
+	return projected.String(), writer.Error()
+}
+
+// ExportTable exports the content of a table in one of TableExportFormats
+// in fileName file
+// options may select a view section, its sort and filters (nil for the raw table)
+func ExportTable(docId string, tableId string, format string, fileName string, options *ExportOptions) error {
+	if !slices.Contains(TableExportFormats, format) {
+		return fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(TableExportFormats, ", "))
+	}
+	if options == nil || len(options.Fields) == 0 {
+		return exportDoc(context.Background(), tableExportURL(docId, tableId, format, options), fileName)
+	}
+
+	content, status := GetTableExport(docId, tableId, format, options)
+	if status == -1 {
+		return errors.New(content)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("export failed: HTTP %d%s", status, responseErrorDetail([]byte(content)))
+	}
+	// #nosec G304 - fileName is user-provided CLI argument for export destination
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// GetTableCSV retrieves the content of a table in CSV format
+// GET /docs/{docId}/download/csv
+func GetTableCSV(docId string, tableId string, options *ExportOptions) (string, int) {
+	return GetTableExport(docId, tableId, "csv", options)
+}
+
+// ExportTableCSV exports the content of a table in CSV format in fileName file
+// options may select a view section, its sort and filters (nil for the raw table)
+func ExportTableCSV(docId string, tableId string, fileName string, options *ExportOptions) error {
+	return ExportTable(docId, tableId, "csv", fileName, options)
+}
+
+// exportDoc downloads an export endpoint into fileName
+// Returns an error if the download or the file write failed; nothing is
+// written when the server refuses the export
+func exportDoc(ctx context.Context, url string, fileName string) error {
+	export, _, returnCode := httpGetBinaryContext(ctx, url)
+	if returnCode != http.StatusOK {
+		return fmt.Errorf("export failed: HTTP %d%s", returnCode, responseErrorDetail(export))
+	}
+	// #nosec G304 - fileName is user-provided CLI argument for export destination
+	if err := os.WriteFile(fileName, export, 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// Status returned when a response can't be decoded, e.g. an HTML error page
+// returned by a misconfigured proxy instead of Grist's JSON
+const StatusInvalidResponse = -2
+
+// decodeJSON decodes the JSON body of an API response into v
+// A body that can't be decoded is reported, and StatusInvalidResponse
+// returned, unless the request failed: the status then tells why
+func decodeJSON(response string, status int, v interface{}) int {
+	if status < 0 {
+		// The request wasn't sent, response is an error message
+		return status
+	}
+	err := json.Unmarshal([]byte(response), v)
+	switch {
+	case err == nil:
+		return status
+	case strings.TrimSpace(response) == "":
+		logger.Error("unexpected empty response", "status", status)
+		return StatusInvalidResponse
+	case !json.Valid([]byte(response)):
+		logger.Error("unexpected non-JSON response", "status", status, "body", responseExcerpt(response))
+		return StatusInvalidResponse
+	case status < 200 || status > 299:
+		logger.Warn("request failed", "status", status, "error", strings.TrimPrefix(responseErrorDetail([]byte(response)), ": "))
+		return status
+	}
+	logger.Error("unexpected response", "status", status, "error", err)
+	return StatusInvalidResponse
+}
+
+// responseExcerpt returns the beginning of a response body, to be logged
+func responseExcerpt(response string) string {
+	const maxLength = 200
+	response = strings.TrimSpace(response)
+	if len(response) > maxLength {
+		return response[:maxLength] + "..."
+	}
+	return response
+}
+
+// responseErrorDetail extracts the error message of a failed API response,
+// formatted to be appended to an error ("" when the body has no message)
+func responseErrorDetail(body []byte) string {
+	var apiError struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &apiError) == nil && apiError.Error != "" {
+		return ": " + apiError.Error
+	}
+	return ""
+}
+
+// Returns table content in CSV format
+func GetTableContent(docId string, tableName string) (string, int) {
+	return GetTableCSV(docId, tableName, nil)
+}
+
+// Retrieves information on a specific organization
+func GetOrgUsageSummary(orgId string) OrgUsage {
+	return GetOrgUsageSummaryContext(context.Background(), orgId)
+}
+
+// GetOrgUsageSummaryContext is like GetOrgUsageSummary, with a context cancelling its requests
+func GetOrgUsageSummaryContext(ctx context.Context, orgId string) OrgUsage {
+	usage := OrgUsage{}
+	response, status := httpGetContext(ctx, "orgs/"+orgId+"/usage", "")
+	decodeJSON(response, status, &usage)
+	return usage
+}
+
+// GetDocUsage retrieves the usage of a document
+// GET /docs/{docId}/usage
+// Values the server reports as "hidden" or "pending" are returned as 0
+func GetDocUsage(docId string) (DocUsage, int) {
+	return GetDocUsageContext(context.Background(), docId)
+}
+
+// GetDocUsageContext is like GetDocUsage, with a context cancelling its requests
+func GetDocUsageContext(ctx context.Context, docId string) (DocUsage, int) {
+	usage := DocUsage{}
+	response, status := httpGetContext(ctx, "docs/"+docId+"/usage", "")
+	if status != http.StatusOK {
+		return usage, status
+	}
+
+	var raw struct {
+		Usage struct {
+			RowCount             interface{} `json:"rowCount"`
+			DataSizeBytes        interface{} `json:"dataSizeBytes"`
+			AttachmentsSizeBytes interface{} `json:"attachmentsSizeBytes"`
+		} `json:"usage"`
+	}
+	if status = decodeJSON(response, status, &raw); status != http.StatusOK {
+		return usage, status
+	}
+	usage.RowCount = int(usageNumber(raw.Usage.RowCount))
+	usage.DataSizeBytes = usageNumber(raw.Usage.DataSizeBytes)
+	usage.AttachmentsSizeBytes = usageNumber(raw.Usage.AttachmentsSizeBytes)
+	return usage, status
+}
+
+// usageNumber converts a usage value to a number
+// Row counts may be reported as an object with a "total" field
+func usageNumber(value interface{}) int64 {
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case map[string]interface{}:
+		return usageNumber(v["total"])
+	default:
+		return 0
+	}
+}
+
+// GetOrgDocsUsage retrieves the usage of every document in an organization
+// Results are sorted by total size, largest first, the documents whose usage
+// can't be retrieved (see DocUsageEntry.Status) coming last
+func GetOrgDocsUsage(orgId int) []DocUsageEntry {
+	return GetOrgDocsUsageContext(context.Background(), orgId)
+}
+
+// GetOrgDocsUsageContext is like GetOrgDocsUsage, with a context cancelling its requests
+func GetOrgDocsUsageContext(ctx context.Context, orgId int) []DocUsageEntry {
+	entries := []DocUsageEntry{}
+	for _, ws := range GetOrgWorkspacesContext(ctx, orgId) {
+		for _, doc := range ws.Docs {
+			entries = append(entries, DocUsageEntry{
+				DocId:         doc.Id,
+				DocName:       doc.Name,
+				WorkspaceId:   ws.Id,
+				WorkspaceName: ws.Name,
+			})
+		}
+	}
+
+	forEachConcurrently(len(entries), func(i int) {
+		entries[i].Usage, entries[i].Status = GetDocUsageContext(ctx, entries[i].DocId)
+	})
+
+	sortDocUsage(entries)
+	return entries
+}
+
+// Requests sent at the same time by the functions fetching a resource for
+// each document or table
+const maxConcurrentRequests = 8
+
+// forEachConcurrently calls fn for each index from 0 to n-1, at most
+// maxConcurrentRequests at a time, and waits for the calls to end
+func forEachConcurrently(n int, fn func(i int)) {
+	sem := make(chan struct{}, maxConcurrentRequests)
+	var wg sync.WaitGroup
+	for i := range n {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// sortDocUsage sorts document usages by total size, largest first, the
+// failed ones last
+func sortDocUsage(entries []DocUsageEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		failedI, failedJ := entries[i].Status != http.StatusOK, entries[j].Status != http.StatusOK
+		if failedI != failedJ {
+			return failedJ
+		}
+		return entries[i].Usage.TotalBytes() > entries[j].Usage.TotalBytes()
+	})
+}
+
+// buildRecordsQueryParams builds the query string for records API endpoints
+func buildRecordsQueryParams(params map[string]string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	parts := []string{}
+	for key, value := range params {
+		if value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "?" + strings.Join(parts, "&")
+}
+
+// GetRecords fetches records from a table
+// GET /docs/{docId}/tables/{tableId}/records
+func GetRecords(docId string, tableId string, options *GetRecordsOptions) (RecordsList, int) {
+	records := RecordsList{}
+
+	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, recordsQueryParams(options))
+	response, status := httpGet(url, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &records)
+	}
+	if options != nil && len(options.Fields) > 0 {
+		for i := range records.Records {
+			projectRecordFields(&records.Records[i], options.Fields)
+		}
+	}
+	return records, status
+}
+
+// GetRecord retrieves the record of a table with the given row ID
+// The API has no endpoint for a single record: records are filtered on
+// their ID. Returns 404 if there is no such record
+func GetRecord(docId string, tableId string, id int) (Record, int) {
+	records, status := GetRecords(docId, tableId, &GetRecordsOptions{
+		Filter: map[string][]interface{}{"id": {id}},
+	})
+	if status != http.StatusOK {
+		return Record{}, status
+	}
+	if len(records.Records) == 0 {
+		return Record{}, http.StatusNotFound
+	}
+	return records.Records[0], status
+}
+
+// Cursor of an incremental sync: records added after LastRowId, or, if
+// UpdatedColumn is set, whose value in this column is greater than
+// UpdatedAfter
+// Grist doesn't track modification times: UpdatedColumn must be a Numeric
+// or DateTime column with a trigger formula applied to changes of any
+// field, e.g. NOW()
+type RecordsSince struct {
+	LastRowId     int     `json:"lastRowId"`
+	UpdatedColumn string  `json:"updatedColumn,omitempty"`
+	UpdatedAfter  float64 `json:"updatedAfter,omitempty"`
+}
+
+// Records added or updated since a cursor, with the cursor of the next sync
+type IncrementalRecords struct {
+	Records []Record     `json:"records"`
+	Next    RecordsSince `json:"next"`
+	Method  string       `json:"method"` // "sql" if the records were selected by the server, "client" otherwise
+}
+
+// Records fetched per request when filtering on their IDs, the filter being
+// sent in the URL
+const idFilterBatchSize = 500
+
+// GetRecordsSince retrieves the records of a table added or updated since a
+// cursor, sorted by ID, to sync a table incrementally
+// The records API only filters on equal values: the matching row IDs are
+// selected with the SQL endpoint, then the records are fetched by the records
+// API, in batches, to keep its cell format. If the SQL endpoint is forbidden
+// (e.g. because of access rules), all the records are fetched and filtered
+// client-side; other failures are returned. Method tells which path was used
+// Returns the HTTP status, and an error if the updated column doesn't exist on
+// the client-side path
+func GetRecordsSince(docId string, tableId string, since RecordsSince) (IncrementalRecords, int, error) {
+	result := IncrementalRecords{Records: []Record{}, Next: since, Method: "sql"}
+
+	sql := fmt.Sprintf("SELECT id FROM %s WHERE id > ?", quoteSQLIdentifier(tableId))
+	args := []interface{}{since.LastRowId}
+	if since.UpdatedColumn != "" {
+		sql += fmt.Sprintf(" OR %s > ?", quoteSQLIdentifier(since.UpdatedColumn))
+		args = append(args, since.UpdatedAfter)
+	}
+	selected, status := RunSQL(docId, sql+" ORDER BY id", args)
+	switch status {
+	case http.StatusOK:
+		ids := make([]interface{}, 0, len(selected.Records))
+		for _, record := range selected.Records {
+			if id, ok := record.Fields["id"].(float64); ok {
+				ids = append(ids, int(id))
+			}
+		}
+		for batch := range slices.Chunk(ids, idFilterBatchSize) {
+			records, status := GetRecords(docId, tableId, &GetRecordsOptions{
+				Filter: map[string][]interface{}{"id": batch},
+				Sort:   "id",
+			})
+			if status != http.StatusOK {
+				return result, status, nil
+			}
+			result.Records = append(result.Records, records.Records...)
+		}
+	case http.StatusForbidden:
+		logger.Info("SQL endpoint unavailable, filtering records client-side", "table", tableId, "status", status)
+		result.Method = "client"
+		records, status := GetRecords(docId, tableId, &GetRecordsOptions{Sort: "id"})
+		if status != http.StatusOK {
+			return result, status, nil
+		}
+		if since.UpdatedColumn != "" {
+			// Records without the column would all be taken as never updated
+			columns, status := getTableColumns(context.Background(), docId, tableId, true)
+			if status != http.StatusOK {
+				return result, status, nil
+			}
+			hasColumn := slices.ContainsFunc(columns.Columns, func(column TableColumn) bool {
+				return column.Id == since.UpdatedColumn
+			})
+			if !hasColumn {
+				return result, status, fmt.Errorf("no column %s in table %s", since.UpdatedColumn, tableId)
+			}
+		}
+		for _, record := range records.Records {
+			updated, _ := record.Fields[since.UpdatedColumn].(float64)
+			if record.Id > since.LastRowId || (since.UpdatedColumn != "" && updated > since.UpdatedAfter) {
+				result.Records = append(result.Records, record)
+			}
+		}
+	default:
+		return result, status, nil
+	}
+
+	for _, record := range result.Records {
+		result.Next.LastRowId = max(result.Next.LastRowId, record.Id)
+		if updated, ok := record.Fields[since.UpdatedColumn].(float64); ok && since.UpdatedColumn != "" {
+			result.Next.UpdatedAfter = max(result.Next.UpdatedAfter, updated)
+		}
+	}
+	return result, http.StatusOK, nil
+}
+
+// projectRecordFields keeps only the given fields of a record
+// The API has no column selection, so records are filtered once fetched
+func projectRecordFields(record *Record, fields []string) {
+	for colId := range record.Fields {
+		if !slices.Contains(fields, colId) {
+			delete(record.Fields, colId)
+		}
+	}
+}
+
+// StreamRecords fetches records from a table, calling fn for each record
+// as it is decoded, so that memory stays bounded whatever the table size.
+// Stops at the first error returned by fn, which is returned.
+// Returns the HTTP status and a decoding or callback error
+// GET /docs/{docId}/tables/{tableId}/records
+func StreamRecords(docId string, tableId string, options *GetRecordsOptions, fn func(Record) error) (int, error) {
+	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, recordsQueryParams(options))
+	body, status := httpGetStream(url)
+	if body == nil {
+		return status, fmt.Errorf("unable to fetch records of table %s", tableId)
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			logger.Warn("unable to close response body", "error", err)
+		}
+	}()
+	if status != http.StatusOK {
+		content, _ := io.ReadAll(body)
+		return status, fmt.Errorf("unable to fetch records of table %s: HTTP %d%s", tableId, status, responseErrorDetail(content))
+	}
+
+	if options != nil && len(options.Fields) > 0 {
+		callback := fn
+		fn = func(record Record) error {
+			projectRecordFields(&record, options.Fields)
+			return callback(record)
+		}
+	}
+	return status, decodeRecordsStream(json.NewDecoder(body), fn)
+}
+
+// decodeRecordsStream decodes {"records": [...]} one record at a time
+func decodeRecordsStream(decoder *json.Decoder, fn func(Record) error) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key, _ := token.(string); key != "records" {
+			// Skip the values of other keys
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var record Record
+			if err := decoder.Decode(&record); err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(decoder, '}')
+}
+
+// expectDelim reads the next token, which must be the delimiter delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("invalid records response: expected %s, got %v", delim, token)
+	}
+	return nil
+}
+
+// recordsQueryParams builds the query string of GET /records
+func recordsQueryParams(options *GetRecordsOptions) string {
+	params := make(map[string]string)
+
+	if options != nil {
+		if options.Filter != nil {
+			filterJSON, err := json.Marshal(options.Filter)
+			if err == nil {
+				params["filter"] = string(filterJSON)
+			}
+		}
+		if options.Sort != "" {
+			params["sort"] = options.Sort
+		}
+		if options.Limit > 0 {
+			params["limit"] = strconv.Itoa(options.Limit)
+		}
+		if options.Hidden {
+			params["hidden"] = "true"
+		}
+	}
+	return buildRecordsQueryParams(params)
+}
+
+// AddRecords adds records to a table
+// POST /docs/{docId}/tables/{tableId}/records
+// On failure, the message is the response of the server
+// With the Validate option, returns -1 and a message, without sending
+// anything, if a value does not match the type of its column
+func AddRecords(docId string, tableId string, records []map[string]interface{}, options *AddRecordsOptions) (RecordsWithoutFields, string, int) {
+	result := RecordsWithoutFields{}
+	params := make(map[string]string)
+
+	if options != nil && options.NoParse {
+		params["noparse"] = "true"
+	}
+	if options != nil && options.Validate {
+		if err := validateTableRecords(docId, tableId, records, !options.NoParse); err != nil {
+			return result, err.Error(), -1
+		}
+	}
+
+	// Build request body
+	body := struct {
+		Records []struct {
+			Fields map[string]interface{} `json:"fields"`
+		} `json:"records"`
+	}{}
+	for _, fields := range records {
+		body.Records = append(body.Records, struct {
+			Fields map[string]interface{} `json:"fields"`
+		}{Fields: fields})
+	}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return result, err.Error(), -1
+	}
+
+	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
+	response, status := httpPost(url, string(bodyJSON))
+	if status != http.StatusOK {
+		return result, response, status
+	}
+	return result, "", decodeJSON(response, status, &result)
+}
+
+// AddRecordsReturning adds records to a table, then fetches them back so that
+// the result includes the values computed by the server, e.g. formula columns
+// Records are returned in the order they were given. They are fetched in
+// batches of IDs, so that large inserts don't exceed the URL length limit
+// Returns the status and message of the insertion (see AddRecords), or the
+// status of the fetch if it failed
+func AddRecordsReturning(docId string, tableId string, records []map[string]interface{}, options *AddRecordsOptions) ([]Record, string, int) {
+	added, message, status := AddRecords(docId, tableId, records, options)
+	if status != http.StatusOK || len(added.Records) == 0 {
+		return []Record{}, message, status
+	}
+
+	ids := make([]interface{}, len(added.Records))
+	for i, record := range added.Records {
+		ids[i] = record.Id
+	}
+	byId := make(map[int]Record, len(ids))
+	for batch := range slices.Chunk(ids, idFilterBatchSize) {
+		fetched, status := GetRecords(docId, tableId, &GetRecordsOptions{
+			Filter: map[string][]interface{}{"id": batch},
+		})
+		if status != http.StatusOK {
+			return []Record{}, "", status
+		}
+		for _, record := range fetched.Records {
+			byId[record.Id] = record
+		}
+	}
+	result := make([]Record, 0, len(added.Records))
+	for _, record := range added.Records {
+		if full, found := byId[record.Id]; found {
+			result = append(result, full)
+		}
+	}
+	return result, "", status
+}
+
+// UpdateRecords modifies records in a table
+// PATCH /docs/{docId}/tables/{tableId}/records
+// Records sharing the same id are merged into one, later fields taking precedence
+// With the Validate option, returns -1 and a message, without sending
+// anything, if a value does not match the type of its column
+func UpdateRecords(docId string, tableId string, records []Record, options *UpdateRecordsOptions) (string, int) {
+	params := make(map[string]string)
+
+	if options != nil && options.Validate {
+		fields := make([]map[string]interface{}, len(records))
+		for i, record := range records {
+			fields[i] = record.Fields
+		}
+		if err := validateTableRecords(docId, tableId, fields, !options.NoParse); err != nil {
+			return err.Error(), -1
+		}
+	}
+
+	records, duplicates := mergeDuplicateRecords(records)
+	if len(duplicates) > 0 {
+		logger.Warn("duplicate record ids merged before update", "ids", duplicates)
+	}
+
+	if options != nil && options.NoParse {
+		params["noparse"] = "true"
+	}
+
+	// Build request body
+	body := struct {
+		Records []Record `json:"records"`
+	}{Records: records}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return "", -1
+	}
+
+	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
+	response, status := httpPatch(url, string(bodyJSON))
+	return response, status
+}
+
+// validateTableRecords fetches the columns of a table and checks records
+// against their types
+// Nothing is checked if the columns can't be fetched: the server will report
+// the error
+func validateTableRecords(docId string, tableId string, records []map[string]interface{}, parse bool) error {
+	columns := GetAllTableColumns(docId, tableId).Columns
+	if len(columns) == 0 {
+		return nil
+	}
+	return ValidateRecords(columns, records, parse)
+}
+
+// ValidateRecords checks the values of records against the types of the
+// table's columns: Int, Numeric, Bool, Date, DateTime and Ref columns
+// When parse is true, strings that Grist can parse into the column type are
+// accepted, e.g. "42" for an Int column, or for Date and DateTime columns
+// ISO dates and dates in the dateFormat (and timeFormat) of the column
+// Empty values and Grist encoded values such as ["L", ...] are not checked
+// Returns an error naming the first offending record, counted from 1, and
+// column
+func ValidateRecords(columns []TableColumn, records []map[string]interface{}, parse bool) error {
+	types := make(map[string]ColumnFields, len(columns))
+	for _, column := range columns {
+		types[column.Id] = column.Fields
+	}
+	for i, fields := range records {
+		colIds := slices.Sorted(maps.Keys(fields))
+		for _, colId := range colIds {
+			column, found := types[colId]
+			if !found {
+				return fmt.Errorf("record %d: unknown column %s", i+1, colId)
+			}
+			if !validColumnValue(column, fields[colId], parse) {
+				value, _ := json.Marshal(fields[colId])
+				return fmt.Errorf("record %d: column %s (%s): invalid value %s", i+1, colId, column.Type, value)
+			}
+		}
+	}
+	return nil
+}
+
+// validColumnValue tells whether a value fits the type of a column
+func validColumnValue(column ColumnFields, value interface{}, parse bool) bool {
+	if value == nil {
+		return true
+	}
+	if list, ok := value.([]interface{}); ok && len(list) > 0 {
+		if _, ok := list[0].(string); ok {
+			return true
+		}
+	}
+	text, isText := value.(string)
+	if isText && text == "" {
+		return true
+	}
+	number, isNumber := numberValue(value)
+
+	baseType, _, _ := strings.Cut(column.Type, ":")
+	switch baseType {
+	case "Int", "Ref":
+		if isText && parse {
+			if baseType == "Ref" {
+				// Parsed by looking up the visible column of the referenced table
+				return true
+			}
+			_, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+			return err == nil
+		}
+		return isNumber && number == math.Trunc(number)
+	case "Numeric":
+		if isText && parse {
+			_, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			return err == nil
+		}
+		return isNumber
+	case "Bool":
+		if isText && parse {
+			_, err := strconv.ParseBool(strings.TrimSpace(text))
+			return err == nil
+		}
+		_, isBool := value.(bool)
+		return isBool || (isNumber && (number == 0 || number == 1))
+	case "Date", "DateTime":
+		if isText && parse {
+			return validDateText(text, baseType == "DateTime") || validFormattedDate(column, text, baseType == "DateTime")
+		}
+		return isNumber
+	}
+	return true
+}
+
+// Date formats accepted for Date and DateTime columns, in addition to
+// timestamps in seconds
+var (
+	dateLayouts     = []string{"2006-01-02"}
+	dateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+)
+
+// validDateText tells whether text is an ISO date, or date and time
+func validDateText(text string, withTime bool) bool {
+	layouts := dateLayouts
+	if withTime {
+		layouts = slices.Concat(dateLayouts, dateTimeLayouts)
+	}
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validFormattedDate tells whether text is a date in the dateFormat of a
+// column, followed for DateTime columns by a time in its timeFormat
+// Columns with a format Go can't parse (see dateLayout) accept any text:
+// Grist will report what it can't parse
+func validFormattedDate(column ColumnFields, text string, withTime bool) bool {
+	var options struct {
+		DateFormat string `json:"dateFormat"`
+		TimeFormat string `json:"timeFormat"`
+	}
+	if json.Unmarshal([]byte(column.WidgetOptions), &options) != nil || options.DateFormat == "" {
+		return false
+	}
+	layout, ok := dateLayout(options.DateFormat)
+	if !ok {
+		return true
+	}
+	layouts := []string{layout}
+	if withTime && options.TimeFormat != "" {
+		timeLayout, ok := dateLayout(options.TimeFormat)
+		if !ok {
+			return true
+		}
+		layouts = append(layouts, layout+" "+timeLayout)
+	}
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Moment.js tokens used by Grist date and time formats, with their Go
+// layout, longest first so that e.g. MMMM is matched before MM
+// Tokens without a layout can't be parsed by Go
+var momentTokens = []struct{ moment, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"dddd", "Monday"}, {"ddd", "Mon"},
+	{"Do", ""}, {"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"H", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"m", "4"}, {"ss", "05"}, {"s", "5"},
+	{"SSS", ".000"}, {"A", "PM"}, {"a", "pm"},
+	{"ZZ", "-0700"}, {"Z", "-07:00"},
+}
+
+// dateLayout converts a moment.js format to a Go layout
+// Returns false if the format has a token Go can't parse, such as ordinal
+// days (Do), or a literal digit that Go would read as part of the layout
+func dateLayout(format string) (string, bool) {
+	var layout strings.Builder
+	for format != "" {
+		if literal, ok := strings.CutPrefix(format, "["); ok {
+			text, rest, found := strings.Cut(literal, "]")
+			if !found || strings.ContainsAny(text, "0123456789") {
+				return "", false
+			}
+			layout.WriteString(text)
+			format = rest
+			continue
+		}
+		index := slices.IndexFunc(momentTokens, func(token struct{ moment, layout string }) bool {
+			return strings.HasPrefix(format, token.moment)
+		})
+		if index >= 0 {
+			if momentTokens[index].layout == "" {
+				return "", false
+			}
+			layout.WriteString(momentTokens[index].layout)
+			format = format[len(momentTokens[index].moment):]
+			continue
+		}
+		char := format[0]
+		if ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z') || ('0' <= char && char <= '9') {
+			return "", false
+		}
+		layout.WriteByte(char)
+		format = format[1:]
+	}
+	return layout.String(), true
+}
+
+// numberValue returns the value of a number given as any Go numeric type
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// UpsertByKey adds or updates records in a table, matching existing records
+// on keyColumns: the key columns of each record go to "require", the other
+// ones to "fields"
+// Returns -1 and a message, without sending anything, if a record lacks one
+// of the key columns
+func UpsertByKey(docId string, tableId string, keyColumns []string, records []map[string]interface{}, options *UpsertRecordsOptions) (string, int) {
+	upserts, err := splitKeyColumns(keyColumns, records)
+	if err != nil {
+		return err.Error(), -1
+	}
+	return UpsertRecords(docId, tableId, upserts, options)
+}
+
+// splitKeyColumns splits records into the values of their key columns
+// ("require") and the other ones ("fields")
+func splitKeyColumns(keyColumns []string, records []map[string]interface{}) ([]RecordWithRequire, error) {
+	if len(keyColumns) == 0 {
+		return nil, errors.New("no key column given")
+	}
+	upserts := make([]RecordWithRequire, 0, len(records))
+	for i, fields := range records {
+		record := RecordWithRequire{
+			Require: make(map[string]interface{}, len(keyColumns)),
+			Fields:  make(map[string]interface{}, len(fields)),
+		}
+		for _, key := range keyColumns {
+			value, found := fields[key]
+			if !found {
+				return nil, fmt.Errorf("record %d has no value for key column %s", i+1, key)
+			}
+			record.Require[key] = value
+		}
+		for colId, value := range fields {
+			if !slices.Contains(keyColumns, colId) {
+				record.Fields[colId] = value
+			}
+		}
+		upserts = append(upserts, record)
+	}
+	return upserts, nil
+}
+
+// UpsertRecords adds or updates records in a table (upsert)
+// PUT /docs/{docId}/tables/{tableId}/records
+func UpsertRecords(docId string, tableId string, records []RecordWithRequire, options *UpsertRecordsOptions) (string, int) {
+	params := make(map[string]string)
+
+	if options != nil {
+		if options.OnMany != "" {
+			params["onmany"] = options.OnMany
+		}
+		if options.NoAdd {
+			params["noadd"] = "true"
+		}
+		if options.NoUpdate {
+			params["noupdate"] = "true"
+		}
+		if options.AllowEmptyRequire {
+			params["allow_empty_require"] = "true"
+		}
+		if options.NoParse {
+			params["noparse"] = "true"
+		}
+	}
+
+	// Build request body
+	body := struct {
+		Records []RecordWithRequire `json:"records"`
+	}{Records: records}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return "", -1
+	}
+
+	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
+	response, status := httpPut(url, string(bodyJSON))
+	return response, status
+}
+
+// DiffRecords compares records to upsert with the records of a table,
+// matching them on keyColumns (the values of their "require"), without
+// changing anything
+// Like an upsert, a record matching several existing records is compared to
+// the first one. Values are compared on their text form, as imported values
+// are often strings that Grist parses into the column types.
+// Without key columns, all the records are added.
+func DiffRecords(docId string, tableId string, incoming []RecordWithRequire, keyColumns []string) (RecordsDiff, int) {
+	diff := RecordsDiff{
+		Added:     []RecordWithRequire{},
+		Updated:   []RecordUpdate{},
+		Unchanged: []RecordWithRequire{},
+	}
+	if len(keyColumns) == 0 {
+		diff.Added = append(diff.Added, incoming...)
+		return diff, http.StatusOK
+	}
+
+	existing, status := GetRecords(docId, tableId, nil)
+	if status != http.StatusOK {
+		return diff, status
+	}
+	byKey := make(map[string]Record, len(existing.Records))
+	for _, record := range existing.Records {
+		key := recordKey(keyColumns, record.Fields)
+		if _, found := byKey[key]; !found {
+			byKey[key] = record
+		}
+	}
+
+	for _, record := range incoming {
+		match, found := byKey[recordKey(keyColumns, record.Require)]
+		if !found {
+			diff.Added = append(diff.Added, record)
+			continue
+		}
+		changes := []FieldChange{}
+		for _, field := range slices.Sorted(maps.Keys(record.Fields)) {
+			before := match.Fields[field]
+			if comparableValue(before) != comparableValue(record.Fields[field]) {
+				changes = append(changes, FieldChange{Field: field, Before: before, After: record.Fields[field]})
+			}
+		}
+		if len(changes) == 0 {
+			diff.Unchanged = append(diff.Unchanged, record)
+		} else {
+			diff.Updated = append(diff.Updated, RecordUpdate{Id: match.Id, Require: record.Require, Changes: changes})
+		}
+	}
+	return diff, http.StatusOK
+}
+
+// recordKey builds a lookup key from the values of the key columns
+func recordKey(keyColumns []string, fields map[string]interface{}) string {
+	values := make([]string, len(keyColumns))
+	for i, column := range keyColumns {
+		values[i] = comparableValue(fields[column])
+	}
+	key, _ := json.Marshal(values)
+	return string(key)
+}
+
+// comparableValue returns the text form of a cell value, so that the number
+// 30 matches the string "30" and an empty cell matches ""
+func comparableValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// Records deleted per request, to keep the bodies of large deletions small
+const deleteBatchSize = 2000
+
+// DeleteRecords deletes records from a table
+// POST /docs/{docId}/tables/{tableId}/records/delete
+// Duplicate ids are only sent once. Records are deleted in batches of
+// deleteBatchSize, retried as set by SetRetries: if a batch fails, the
+// previous ones remain deleted and the response of the failed one is returned
+func DeleteRecords(docId string, tableId string, recordIds []int) (string, int) {
+	return DeleteRecordsContext(context.Background(), docId, tableId, recordIds)
+}
+
+// DeleteRecordsContext is like DeleteRecords, with a context cancelling its requests
+func DeleteRecordsContext(ctx context.Context, docId string, tableId string, recordIds []int) (string, int) {
+	_, response, status := deleteRecordBatches(ctx, docId, tableId, recordIds)
+	return response, status
+}
+
+// deleteRecordBatches deletes records in batches
+// A batch whose deletion was retried may have been applied by the first
+// attempt, the retry then failing with 400 on the missing rows: the batch is
+// counted as deleted if none of its rows are left
+// Returns the number of deleted records, with the response and the status of
+// the last request sent
+func deleteRecordBatches(ctx context.Context, docId string, tableId string, recordIds []int) (int, string, int) {
+	recordIds, duplicates := dedupeRecordIds(recordIds)
+	if len(duplicates) > 0 {
+		logger.Warn("duplicate record ids ignored before delete", "ids", duplicates)
+	}
+
+	url := fmt.Sprintf("docs/%s/tables/%s/records/delete", docId, tableId)
+	deleted := 0
+	response, status := "", http.StatusOK
+	for batch := range slices.Chunk(recordIds, deleteBatchSize) {
+		bodyJSON, err := json.Marshal(batch)
+		if err != nil {
+			return deleted, "", -1
+		}
+		var retried bool
+		response, status, retried = httpPostReplayable(ctx, url, string(bodyJSON))
+		if status == http.StatusBadRequest && retried {
+			if remaining, remainingStatus := remainingRecordIds(ctx, docId, tableId, batch); remainingStatus == http.StatusOK && len(remaining) == 0 {
+				logger.Info("batch deleted by a previous attempt", "table", tableId, "records", len(batch))
+				response, status = "", http.StatusOK
+			}
+		}
+		if status != http.StatusOK {
+			if deleted > 0 {
+				logger.Warn("deletion interrupted", "table", tableId, "deleted", deleted, "remaining", len(recordIds)-deleted)
+			}
+			return deleted, response, status
+		}
+		deleted += len(batch)
+	}
+	return deleted, response, status
+}
+
+// remainingRecordIds returns the IDs, among ids, of the rows still in a table
+// IDs are looked up in batches, so that the URL stays short enough
+func remainingRecordIds(ctx context.Context, docId string, tableId string, ids []int) ([]int, int) {
+	remaining := []int{}
+	for batch := range slices.Chunk(ids, idFilterBatchSize) {
+		filter := make([]interface{}, len(batch))
+		for i, id := range batch {
+			filter[i] = id
+		}
+		options := &GetRecordsOptions{Filter: map[string][]interface{}{"id": filter}}
+		url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, recordsQueryParams(options))
+		response, status := httpGetContext(ctx, url, "")
+		if status != http.StatusOK {
+			return nil, status
+		}
+		records := RecordsList{}
+		if status = decodeJSON(response, status, &records); status != http.StatusOK {
+			return nil, status
+		}
+		for _, record := range records.Records {
+			remaining = append(remaining, record.Id)
+		}
+	}
+	return remaining, http.StatusOK
+}
+
+// mergeDuplicateRecords merges records sharing the same id, keeping the order of first appearance
+// Returns the merged records and the ids that were duplicated
+func mergeDuplicateRecords(records []Record) ([]Record, []int) {
+	merged := make([]Record, 0, len(records))
+	positions := make(map[int]int, len(records))
+	duplicates := []int{}
+	for _, record := range records {
+		pos, seen := positions[record.Id]
+		if !seen {
+			positions[record.Id] = len(merged)
+			fields := make(map[string]interface{}, len(record.Fields))
+			for k, v := range record.Fields {
+				fields[k] = v
+			}
+			merged = append(merged, Record{Id: record.Id, Fields: fields})
+			continue
+		}
+		if !slices.Contains(duplicates, record.Id) {
+			duplicates = append(duplicates, record.Id)
+		}
+		for k, v := range record.Fields {
+			merged[pos].Fields[k] = v
+		}
+	}
+	return merged, duplicates
 }
 
-// Retrieves the list of tables contained in a document
-func GetDocTables(docId string) Tables {
-	tables := Tables{}
-	url := "docs/" + docId + "/tables"
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &tables)
-
-	return tables
+// dedupeRecordIds removes duplicate ids, keeping the order of first appearance
+// Returns the unique ids and the ids that were duplicated
+func dedupeRecordIds(ids []int) ([]int, []int) {
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	duplicates := []int{}
+	for _, id := range ids {
+		if seen[id] {
+			if !slices.Contains(duplicates, id) {
+				duplicates = append(duplicates, id)
+			}
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique, duplicates
 }
 
-// Retrieves a list of table columns
-func GetTableColumns(docId string, tableId string) TableColumns {
-	columns := TableColumns{}
-	url := "docs/" + docId + "/tables/" + tableId + "/columns"
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &columns)
+// Import API
 
-	return columns
+// Number of records sent per request when importing
+const defaultImportBatchSize = 500
+
+// ImportCSVOptions contains options for importing a CSV file
+type ImportCSVOptions struct {
+	ColumnMap   map[string]string // CSV header -> column ID overrides, an empty ID skips the column
+	KeyColumn   string            // Upsert on this column instead of adding records
+	BatchSize   int               // Records per request (default 500)
+	Concurrency int               // Batches sent at the same time (default 1, in file order)
 }
 
-// Retrieves records from a table
-func GetTableRows(docId string, tableId string) TableRows {
-	rows := TableRows{}
-	url := "docs/" + docId + "/tables/" + tableId + "/data"
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &rows)
+// recordImporter sends records to a table in batches
+// Records are added, or upserted on keyColumn when it is set.
+// With a concurrency above 1, batches are sent by that many workers; the
+// records may then be created out of order in the table, but the result
+// lists them in input order.
+type recordImporter struct {
+	docId       string
+	tableId     string
+	keyColumn   string
+	batchSize   int
+	concurrency int
+	batch       []map[string]interface{}
+	queued      int
+	result      RecordsWithoutFields
+	status      int
+
+	// Concurrent sending
+	batches chan importBatch
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	sent    map[int]importBatchResult
+	nbSent  int
+	failed  atomic.Bool
+}
 
-	return rows
+// importBatch is a batch of records waiting to be sent by a worker
+type importBatch struct {
+	seq     int
+	records []map[string]interface{}
 }
 
-// Returns the list of users with access to the document
-func GetDocAccess(docId string) EntityAccess {
-	var lstUsers EntityAccess
-	url := fmt.Sprintf("docs/%s/access", docId)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &lstUsers)
-	return lstUsers
+// importBatchResult is the outcome of sending a batch
+type importBatchResult struct {
+	added  RecordsWithoutFields
+	status int
 }
 
-// Move all documents from a workspace to another
-func MoveAllDocs(fromWorkspaceId int, toWorkspaceId int) {
-	// Getting the workspaces
-	from_ws := GetWorkspace(fromWorkspaceId)
-	to_ws := GetWorkspace(toWorkspaceId)
-	if from_ws.Id == 0 {
-		fmt.Printf("❗️ Workspace %d not found ❗️\n", fromWorkspaceId)
-	} else if to_ws.Id == 0 {
-		fmt.Printf("❗️ Workspace %d not found ❗️\n", toWorkspaceId)
-	} else {
-		// Workspaces were found
-		for _, doc := range from_ws.Docs {
-			url := "docs/" + doc.Id + "/move"
-			data := fmt.Sprintf(`{"workspace": "%d"}`, toWorkspaceId)
-			_, status := httpPatch(url, data)
-			if status == http.StatusOK {
-				fmt.Printf("Document %s moved to workspace %d ✅\n", doc.Id, toWorkspaceId)
-			} else {
-				fmt.Printf("Unable to move document %s", doc.Id)
-			}
-		}
+func newRecordImporter(docId string, tableId string, keyColumn string, batchSize int, concurrency int) *recordImporter {
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &recordImporter{
+		docId:       docId,
+		tableId:     tableId,
+		keyColumn:   keyColumn,
+		batchSize:   batchSize,
+		concurrency: concurrency,
+		status:      http.StatusOK,
 	}
 }
 
-// Move a document in a workspace
-func MoveDoc(docId string, workspaceId int) {
-	url := "docs/" + docId + "/move"
-	data := fmt.Sprintf(`{"workspace": "%d"}`, workspaceId)
-	_, status := httpPatch(url, data)
-	if status == http.StatusOK {
-		fmt.Printf("Document moved to workspace %d ✅\n", workspaceId)
-	} else {
-		fmt.Printf("Unable to move document")
+// add queues a record, sending the batch when it is full
+// Returns false if a request failed
+func (imp *recordImporter) add(fields map[string]interface{}) bool {
+	imp.batch = append(imp.batch, fields)
+	imp.queued++
+	if len(imp.batch) >= imp.batchSize {
+		return imp.flush()
 	}
+	return true
 }
 
-// Purge a document's history, to retain only the last modifications
-func PurgeDoc(docId string, nbHisto int) {
-	url := "docs/" + docId + "/states/remove"
-	data := fmt.Sprintf(`{"keep": "%d"}`, nbHisto)
-	_, status := httpPost(url, data)
-	if status == http.StatusOK {
-		fmt.Printf("History cleared (%d last states) ✅\n", nbHisto)
+// flush sends the queued records, or hands them to a worker
+// Returns false if a request failed
+func (imp *recordImporter) flush() bool {
+	if len(imp.batch) == 0 {
+		return !imp.failed.Load()
 	}
-}
 
-// Import a list of user & role into a workspace
-// Search workspace by name in org
-func ImportUsers(orgId int, workspaceName string, users []UserRole) {
-	lstWorkspaces := GetOrgWorkspaces(orgId)
-	idWorkspace := 0
-	for _, ws := range lstWorkspaces {
-		if ws.Name == workspaceName {
-			idWorkspace = ws.Id
-		}
+	if imp.concurrency == 1 {
+		defer func() { imp.batch = imp.batch[:0] }()
+		added, status := imp.send(imp.batch)
+		imp.status = status
+		imp.result.Records = append(imp.result.Records, added.Records...)
+		return status == http.StatusOK
 	}
 
-	if idWorkspace == 0 {
-		idWorkspace = CreateWorkspace(orgId, workspaceName)
+	if imp.batches == nil {
+		imp.startWorkers()
 	}
-	if idWorkspace == 0 {
-		fmt.Printf("Unable to create workspace %s\n", workspaceName)
-	} else {
-		url := fmt.Sprintf("workspaces/%d/access", idWorkspace)
+	if imp.failed.Load() {
+		imp.batch = nil
+		return false
+	}
+	imp.batches <- importBatch{seq: imp.nbSent, records: imp.batch}
+	imp.nbSent++
+	imp.batch = make([]map[string]interface{}, 0, imp.batchSize)
+	return true
+}
 
-		roleLine := []string{}
-		for _, role := range users {
-			roleLine = append(roleLine, fmt.Sprintf(`"%s": "%s"`, role.Email, role.Role))
-		}
-		patch := fmt.Sprintf(`{	"delta": { "users": {%s}}}`, strings.Join(roleLine, ","))
+// startWorkers starts the goroutines sending batches concurrently
+func (imp *recordImporter) startWorkers() {
+	imp.batches = make(chan importBatch)
+	imp.sent = map[int]importBatchResult{}
+	for i := 0; i < imp.concurrency; i++ {
+		imp.wg.Add(1)
+		go func() {
+			defer imp.wg.Done()
+			for batch := range imp.batches {
+				// Once a batch failed, the following ones are dropped
+				if imp.failed.Load() {
+					continue
+				}
+				added, status := imp.send(batch.records)
+				if status != http.StatusOK {
+					imp.failed.Store(true)
+				}
+				imp.mu.Lock()
+				imp.sent[batch.seq] = importBatchResult{added, status}
+				imp.mu.Unlock()
+			}
+		}()
+	}
+}
 
-		body, status := httpPatch(url, patch)
+// finish sends the remaining records and waits for the workers
+// Returns the records of every successful batch, in input order so that the
+// result does not depend on the scheduling, and the status of the first
+// failed batch. Batches sent concurrently with a failed one may still have
+// been imported, and are then returned too
+func (imp *recordImporter) finish() (RecordsWithoutFields, int) {
+	imp.flush()
+	if imp.batches == nil {
+		return imp.result, imp.status
+	}
 
-		var result string
-		if status == http.StatusOK {
-			result = "✅"
-		} else {
-			result = fmt.Sprintf("❗️ (%s)", body)
+	close(imp.batches)
+	imp.wg.Wait()
+	for seq := 0; seq < imp.nbSent; seq++ {
+		sent, found := imp.sent[seq]
+		switch {
+		case !found:
+			// Dropped after a failure
+		case sent.status == http.StatusOK:
+			imp.result.Records = append(imp.result.Records, sent.added.Records...)
+		case imp.status == http.StatusOK:
+			imp.status = sent.status
 		}
-		fmt.Printf("Import %d users in workspace n°%d\t : %s\n", len(users), idWorkspace, result)
 	}
-
+	return imp.result, imp.status
 }
 
-// Create an organization
-func CreateOrg(orgName string, orgDomain string) int {
-	url := fmt.Sprintf("orgs")
-	data := fmt.Sprintf(`{"name":"%s", "domain":"%s"}`, orgName, orgDomain)
-	body, status := httpPost(url, data)
-	idOrg := 0
-	if status == http.StatusOK {
-		id, err := strconv.Atoi(body)
-		if err == nil {
-			idOrg = id
+// send adds or upserts a batch of records
+func (imp *recordImporter) send(batch []map[string]interface{}) (RecordsWithoutFields, int) {
+	if imp.keyColumn == "" {
+		added, message, status := AddRecords(imp.docId, imp.tableId, batch, nil)
+		if status == -1 {
+			logger.Error("unable to add records", "table", imp.tableId, "error", message)
 		}
+		return added, status
 	}
-	return idOrg
-}
 
-// Create a workspace in an organization
-func CreateWorkspace(orgId int, workspaceName string) int {
-	url := fmt.Sprintf("orgs/%d/workspaces", orgId)
-	data := fmt.Sprintf(`{"name":"%s"}`, workspaceName)
-	body, status := httpPost(url, data)
-	idWorkspace := 0
-	if status == http.StatusOK {
-		id, err := strconv.Atoi(body)
-		if err == nil {
-			idWorkspace = id
-		}
+	response, status := UpsertByKey(imp.docId, imp.tableId, []string{imp.keyColumn}, batch, nil)
+	if status == -1 {
+		logger.Error("unable to upsert records", "table", imp.tableId, "error", response)
 	}
-	return idWorkspace
+	return RecordsWithoutFields{}, status
 }
 
-// Export doc in Grist format (Sqlite) in fileName file
-func ExportDocGrist(docId string, fileName string) {
-	url := fmt.Sprintf("docs/%s/download", docId)
-	export, returnCode := httpGet(url, "")
-	if returnCode == http.StatusOK {
-		// #nosec G304 - fileName is user-provided CLI argument for export destination
-		f, e := os.Create(fileName)
-		if e != nil {
-			panic(e)
-		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				log.Printf("Error closing file: %v", err)
-			}
-		}()
-		if _, err := fmt.Fprintln(f, export); err != nil {
-			log.Printf("Error writing to file: %v", err)
+// ImportCSV imports the rows of a CSV file into a table
+// The header row gives the column IDs, unless overridden by opts.ColumnMap.
+// Values are sent as strings, for Grist to parse them into the column types.
+// When opts.KeyColumn is set, records are upserted and the IDs of the
+// upserted records are not returned.
+// Returns the last HTTP status, and an error if the file can't be read; the
+// records read before the error are still imported
+func ImportCSV(docId string, tableId string, csvPath string, opts ImportCSVOptions) (RecordsWithoutFields, int, error) {
+	// #nosec G304 - csvPath is user-provided CLI argument for import source
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return RecordsWithoutFields{}, http.StatusOK, fmt.Errorf("unable to open %s: %w", csvPath, err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	columnIds, err := readCSVColumnIds(reader, opts.ColumnMap)
+	if err != nil {
+		return RecordsWithoutFields{}, http.StatusOK, fmt.Errorf("unable to read the header of %s: %w", csvPath, err)
+	}
+
+	importer := newRecordImporter(docId, tableId, opts.KeyColumn, opts.BatchSize, opts.Concurrency)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result, status := importer.finish()
+			return result, status, fmt.Errorf("unable to read %s: %w", csvPath, err)
+		}
+		if !importer.add(csvRowFields(columnIds, row)) {
+			break
 		}
 	}
+	result, status := importer.finish()
+	return result, status, nil
 }
 
-// Export doc in Excel format (XLSX) in fileName file
-func ExportDocExcel(docId string, fileName string) {
-	url := fmt.Sprintf("docs/%s/download/xlsx", docId)
-	export, returnCode := httpGet(url, "")
-	if returnCode == http.StatusOK {
-		// #nosec G304 - fileName is user-provided CLI argument for export destination
-		f, e := os.Create(fileName)
-		if e != nil {
-			panic(e)
-		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				log.Printf("Error closing file: %v", err)
-			}
-		}()
-		if _, err := fmt.Fprintln(f, export); err != nil {
-			log.Printf("Error writing to file: %v", err)
+// readCSVColumnIds reads the header row of a CSV file and returns the
+// column ID of each CSV column, overridden by columnMap
+func readCSVColumnIds(reader *csv.Reader, columnMap map[string]string) ([]string, error) {
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columnIds := make([]string, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columnIds[i] = name
+		if colId, found := columnMap[name]; found {
+			columnIds[i] = colId
 		}
 	}
+	return columnIds, nil
 }
 
-// Returns table content as Dataframe
-func GetTableContent(docId string, tableName string) {
-	url := fmt.Sprintf("docs/%s/download/csv?tableId=%s", docId, tableName)
-	csvFile, _ := httpGet(url, "")
-	fmt.Println(csvFile)
+// csvRowFields maps the values of a CSV row to their column IDs, skipping
+// the columns without ID
+func csvRowFields(columnIds []string, row []string) map[string]interface{} {
+	fields := make(map[string]interface{}, len(row))
+	for i, value := range row {
+		if columnIds[i] != "" {
+			fields[columnIds[i]] = value
+		}
+	}
+	return fields
 }
 
-// Retrieves information on a specific organization
-func GetOrgUsageSummary(orgId string) OrgUsage {
-	usage := OrgUsage{}
-	response, _ := httpGet("orgs/"+orgId+"/usage", "")
-	json.Unmarshal([]byte(response), &usage)
-	return usage
-}
+// PlanImportCSV reports what ImportCSV would change in a table, without
+// changing anything: with opts.KeyColumn, the records that would be added,
+// updated (with the changed fields) or left unchanged; otherwise all the
+// records are added
+// Returns -1 if the file can't be read
+func PlanImportCSV(docId string, tableId string, csvPath string, opts ImportCSVOptions) (RecordsDiff, int) {
+	// #nosec G304 - csvPath is user-provided CLI argument for import source
+	f, err := os.Open(csvPath)
+	if err != nil {
+		logger.Error("unable to open CSV file", "file", csvPath, "error", err)
+		return RecordsDiff{}, -1
+	}
+	defer f.Close()
 
-// buildRecordsQueryParams builds the query string for records API endpoints
-func buildRecordsQueryParams(params map[string]string) string {
-	if len(params) == 0 {
-		return ""
+	reader := csv.NewReader(f)
+	columnIds, err := readCSVColumnIds(reader, opts.ColumnMap)
+	if err != nil {
+		logger.Error("unable to read CSV header", "file", csvPath, "error", err)
+		return RecordsDiff{}, -1
 	}
-	parts := []string{}
-	for key, value := range params {
-		if value != "" {
-			parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+	rows, err := reader.ReadAll()
+	if err != nil {
+		logger.Error("unable to read CSV row", "file", csvPath, "error", err)
+		return RecordsDiff{}, -1
+	}
+	records := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		records[i] = csvRowFields(columnIds, row)
+	}
+
+	if opts.KeyColumn == "" {
+		incoming := make([]RecordWithRequire, len(records))
+		for i, fields := range records {
+			incoming[i] = RecordWithRequire{Fields: fields}
 		}
+		return DiffRecords(docId, tableId, incoming, nil)
 	}
-	if len(parts) == 0 {
-		return ""
+	keyColumns := []string{opts.KeyColumn}
+	incoming, err := splitKeyColumns(keyColumns, records)
+	if err != nil {
+		logger.Error("unable to plan the import", "file", csvPath, "error", err)
+		return RecordsDiff{}, -1
 	}
-	return "?" + strings.Join(parts, "&")
+	return DiffRecords(docId, tableId, incoming, keyColumns)
 }
 
-// GetRecords fetches records from a table
-// GET /docs/{docId}/tables/{tableId}/records
-func GetRecords(docId string, tableId string, options *GetRecordsOptions) (RecordsList, int) {
-	records := RecordsList{}
-	params := make(map[string]string)
+// ImportJSON imports JSON objects into a table, each object becoming a record
+// r holds either a JSON array of objects or newline-delimited JSON objects;
+// both are decoded as a stream and sent in batches, without reading the
+// whole input in memory.
+// concurrency is the number of batches sent at the same time (1 keeps the
+// input order).
+// Returns the last HTTP status, and an error if the input can't be decoded;
+// the records decoded before the error are still imported
+func ImportJSON(docId string, tableId string, r io.Reader, concurrency int) (RecordsWithoutFields, int, error) {
+	importer := newRecordImporter(docId, tableId, "", defaultImportBatchSize, concurrency)
+
+	reader := bufio.NewReader(r)
+	isArray, err := startsWithArray(reader)
+	if err != nil {
+		return importer.result, http.StatusOK, fmt.Errorf("unable to read JSON input: %w", err)
+	}
+	decoder := json.NewDecoder(reader)
+	if isArray {
+		// Skip the opening bracket, then decode the elements one at a time
+		if _, err := decoder.Token(); err != nil {
+			return importer.result, http.StatusOK, fmt.Errorf("unable to decode JSON array: %w", err)
+		}
+	}
 
-	if options != nil {
-		if options.Filter != nil {
-			filterJSON, err := json.Marshal(options.Filter)
-			if err == nil {
-				params["filter"] = string(filterJSON)
-			}
+	for decoder.More() {
+		var fields map[string]interface{}
+		if err := decoder.Decode(&fields); err != nil {
+			result, status := importer.finish()
+			return result, status, fmt.Errorf("unable to decode JSON record %d: %w", importer.queued+1, err)
 		}
-		if options.Sort != "" {
-			params["sort"] = options.Sort
+		if !importer.add(fields) {
+			break
 		}
-		if options.Limit > 0 {
-			params["limit"] = strconv.Itoa(options.Limit)
+	}
+	result, status := importer.finish()
+	return result, status, nil
+}
+
+// startsWithArray skips leading whitespace and reports if the input is a
+// JSON array; an empty input is not an error
+func startsWithArray(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return false, nil
 		}
-		if options.Hidden {
-			params["hidden"] = "true"
+		if err != nil {
+			return false, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = reader.ReadByte()
+		default:
+			return b[0] == '[', nil
 		}
 	}
+}
 
-	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
-	response, status := httpGet(url, "")
-	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &records)
-	}
-	return records, status
+// SQL API
+// See: https://support.getgrist.com/api/#tag/sql
+
+// SQLRecord represents a single row returned by a SQL query
+type SQLRecord struct {
+	Fields map[string]interface{} `json:"fields"`
 }
 
-// AddRecords adds records to a table
-// POST /docs/{docId}/tables/{tableId}/records
-func AddRecords(docId string, tableId string, records []map[string]interface{}, options *AddRecordsOptions) (RecordsWithoutFields, int) {
-	result := RecordsWithoutFields{}
-	params := make(map[string]string)
+// SQLResult represents the response from POST /sql
+type SQLResult struct {
+	Statement string      `json:"statement"`
+	Records   []SQLRecord `json:"records"`
+}
 
-	if options != nil && options.NoParse {
-		params["noparse"] = "true"
-	}
+// RunSQL runs a read-only SQL query against a document
+// POST /docs/{docId}/sql
+// Parameters are bound to "?" placeholders in the query
+func RunSQL(docId string, sql string, args []interface{}) (SQLResult, int) {
+	return RunSQLContext(context.Background(), docId, sql, args)
+}
 
-	// Build request body
+// RunSQLContext is like RunSQL, with a context cancelling its requests
+func RunSQLContext(ctx context.Context, docId string, sql string, args []interface{}) (SQLResult, int) {
+	result := SQLResult{}
 	body := struct {
-		Records []struct {
-			Fields map[string]interface{} `json:"fields"`
-		} `json:"records"`
-	}{}
-	for _, fields := range records {
-		body.Records = append(body.Records, struct {
-			Fields map[string]interface{} `json:"fields"`
-		}{Fields: fields})
-	}
+		SQL  string        `json:"sql"`
+		Args []interface{} `json:"args,omitempty"`
+	}{SQL: sql, Args: args}
 
 	bodyJSON, err := json.Marshal(body)
 	if err != nil {
 		return result, -1
 	}
 
-	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
-	response, status := httpPost(url, string(bodyJSON))
+	url := fmt.Sprintf("docs/%s/sql", docId)
+	response, status := httpPostContext(ctx, url, string(bodyJSON))
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
 
-// UpdateRecords modifies records in a table
-// PATCH /docs/{docId}/tables/{tableId}/records
-func UpdateRecords(docId string, tableId string, records []Record, options *UpdateRecordsOptions) (string, int) {
-	params := make(map[string]string)
-
-	if options != nil && options.NoParse {
-		params["noparse"] = "true"
-	}
+// quoteSQLIdentifier quotes a table or column name for use in a SQL query
+func quoteSQLIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
 
-	// Build request body
-	body := struct {
-		Records []Record `json:"records"`
-	}{Records: records}
+// sqlMutatingKeywords are statements that modify a database
+var sqlMutatingKeywords = []string{
+	"INSERT", "UPDATE", "DELETE", "REPLACE", "UPSERT", "MERGE",
+	"CREATE", "DROP", "ALTER", "TRUNCATE", "ATTACH", "DETACH",
+	"PRAGMA", "VACUUM", "REINDEX", "ANALYZE", "GRANT", "REVOKE",
+	"BEGIN", "COMMIT", "ROLLBACK", "SAVEPOINT", "RELEASE",
+}
 
-	bodyJSON, err := json.Marshal(body)
-	if err != nil {
-		return "", -1
+// ValidateReadOnlySQL checks that a query is a single SELECT statement,
+// possibly preceded by common table expressions (WITH)
+// Only the keyword of the statement is checked, string literals and quoted
+// identifiers aside, so that functions such as replace() or columns named
+// e.g. update are accepted
+// Returns an error describing why the query was rejected
+func ValidateReadOnlySQL(sql string) error {
+	query := strings.TrimSpace(stripSQLComments(sql))
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		return fmt.Errorf("empty SQL query")
+	}
+	if strings.Contains(stripSQLStrings(query), ";") {
+		return fmt.Errorf("only a single SQL statement is allowed")
 	}
 
-	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
-	response, status := httpPatch(url, string(bodyJSON))
-	return response, status
+	keyword := sqlStatementKeyword(strings.ToUpper(stripSQLStrings(query)))
+	switch {
+	case keyword == "SELECT":
+		return nil
+	case slices.Contains(sqlMutatingKeywords, keyword):
+		return fmt.Errorf("mutating statement %s is not allowed", keyword)
+	}
+	return fmt.Errorf("only SELECT queries are allowed")
 }
 
-// UpsertRecords adds or updates records in a table (upsert)
-// PUT /docs/{docId}/tables/{tableId}/records
-func UpsertRecords(docId string, tableId string, records []RecordWithRequire, options *UpsertRecordsOptions) (string, int) {
-	params := make(map[string]string)
-
-	if options != nil {
-		if options.OnMany != "" {
-			params["onmany"] = options.OnMany
-		}
-		if options.NoAdd {
-			params["noadd"] = "true"
-		}
-		if options.NoUpdate {
-			params["noupdate"] = "true"
-		}
-		if options.AllowEmptyRequire {
-			params["allow_empty_require"] = "true"
-		}
-		if options.NoParse {
-			params["noparse"] = "true"
+// sqlStatementKeyword returns the keyword of a statement, in upper case
+// Common table expressions are skipped: the keyword is then the first word
+// following the closing parenthesis of a definition, other than AS (which
+// follows the column names of a definition) and a comma
+func sqlStatementKeyword(sql string) string {
+	isWordChar := func(r rune) bool {
+		return r == '_' || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+	}
+	depth := 0
+	afterParenthesis := false
+	first := true
+	for i, r := range sql {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			afterParenthesis = depth == 0
+		case r == ',' && depth == 0:
+			afterParenthesis = false
+		case isWordChar(r) && depth == 0 && (i == 0 || !isWordChar(rune(sql[i-1]))):
+			end := strings.IndexFunc(sql[i:], func(r rune) bool { return !isWordChar(r) })
+			word := sql[i:]
+			if end >= 0 {
+				word = sql[i : i+end]
+			}
+			switch {
+			case first && word != "WITH":
+				return word
+			case afterParenthesis && word != "AS":
+				return word
+			}
+			first = false
+			afterParenthesis = false
 		}
 	}
+	return ""
+}
 
-	// Build request body
-	body := struct {
-		Records []RecordWithRequire `json:"records"`
-	}{Records: records}
-
-	bodyJSON, err := json.Marshal(body)
-	if err != nil {
-		return "", -1
+// stripSQLComments removes "--" line comments and "/* */" block comments
+func stripSQLComments(sql string) string {
+	var b strings.Builder
+	inString := byte(0)
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if inString != 0 {
+			if c == inString {
+				inString = 0
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"':
+			inString = c
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end
+			c = '\n'
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += end + 3
+			c = ' '
+		}
+		b.WriteByte(c)
 	}
-
-	url := fmt.Sprintf("docs/%s/tables/%s/records%s", docId, tableId, buildRecordsQueryParams(params))
-	response, status := httpPut(url, string(bodyJSON))
-	return response, status
+	return b.String()
 }
 
-// DeleteRecords deletes records from a table
-// POST /docs/{docId}/tables/{tableId}/records/delete
-func DeleteRecords(docId string, tableId string, recordIds []int) (string, int) {
-	bodyJSON, err := json.Marshal(recordIds)
-	if err != nil {
-		return "", -1
+// stripSQLStrings removes the content of quoted strings and identifiers,
+// quoted with double quotes, backquotes or square brackets
+func stripSQLStrings(sql string) string {
+	var b strings.Builder
+	inString := rune(0)
+	for _, c := range sql {
+		if inString != 0 && c != inString {
+			continue
+		}
+		switch {
+		case inString != 0:
+			inString = 0
+		case c == '\'' || c == '"' || c == '`':
+			inString = c
+		case c == '[':
+			inString = ']'
+		}
+		b.WriteRune(c)
 	}
-
-	url := fmt.Sprintf("docs/%s/tables/%s/records/delete", docId, tableId)
-	response, status := httpPost(url, string(bodyJSON))
-	return response, status
+	return b.String()
 }
 
 // SCIM v2 Bulk Operations
@@ -813,6 +4214,7 @@ type SCIMBulkRequest struct {
 	Schemas      []string            `json:"schemas"`                // Must include "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
 	FailOnErrors int                 `json:"failOnErrors,omitempty"` // Number of errors before stopping (0 = unlimited)
 	Operations   []SCIMBulkOperation `json:"Operations"`
+	Parallel     int                 `json:"-"` // Number of operations executed concurrently (0 or 1 = sequential)
 }
 
 // SCIMBulkOperationResponse represents the response for a single bulk operation
@@ -846,7 +4248,9 @@ const (
 )
 
 // SCIMBulk performs SCIM v2 bulk operations
-// POST /scim/v2/Bulk
+// The operations are sent one at a time to the endpoints of their resources,
+// not as a POST /scim/v2/Bulk request, so the bulk maxOperations and
+// maxPayloadSize of the server don't apply
 func SCIMBulk(request SCIMBulkRequest) (SCIMBulkResponse, int) {
 	response := SCIMBulkResponse{
 		Schemas:    []string{SCIMBulkResponseSchema},
@@ -865,23 +4269,61 @@ func SCIMBulk(request SCIMBulkRequest) (SCIMBulkResponse, int) {
 		return response, http.StatusBadRequest
 	}
 
+	response.Operations = executeSCIMOperations(request.Operations, request.Parallel, request.FailOnErrors)
+	return response, http.StatusOK
+}
+
+// executeSCIMOperations executes operations through a pool of parallel workers
+// No operation is started once failOnErrors errors occurred; the responses of
+// the operations started are returned in order
+func executeSCIMOperations(ops []SCIMBulkOperation, parallel int, failOnErrors int) []SCIMBulkOperationResponse {
+	if parallel < 1 {
+		parallel = 1
+	}
 	errorCount := 0
-	for _, op := range request.Operations {
-		opResponse := executeSCIMOperation(op)
-		response.Operations = append(response.Operations, opResponse)
-
-		// Check if operation failed (status >= 400)
-		statusCode := 0
-		_, _ = fmt.Sscanf(opResponse.Status, "%d", &statusCode) // Ignore error - statusCode stays 0 on parse failure
-		if statusCode >= 400 {
-			errorCount++
-			if request.FailOnErrors > 0 && errorCount >= request.FailOnErrors {
-				break
-			}
+	thresholdReached := func() bool {
+		return failOnErrors > 0 && errorCount >= failOnErrors
+	}
+
+	responses := make([]SCIMBulkOperationResponse, len(ops))
+	started := 0
+	sem := make(chan struct{}, parallel)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := thresholdReached()
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
 		}
+		started++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = executeSCIMOperation(op)
+			// The error is counted before the worker is released, so that
+			// the next operation sees it
+			if scimOperationFailed(responses[i]) {
+				mu.Lock()
+				errorCount++
+				mu.Unlock()
+			}
+			<-sem
+		}()
 	}
+	wg.Wait()
+
+	return responses[:started]
+}
 
-	return response, http.StatusOK
+// scimOperationFailed returns true if the status of an operation is an error (>= 400)
+func scimOperationFailed(response SCIMBulkOperationResponse) bool {
+	statusCode := 0
+	_, _ = fmt.Sscanf(response.Status, "%d", &statusCode) // Ignore error - statusCode stays 0 on parse failure
+	return statusCode >= 400
 }
 
 // createSCIMError creates a SCIM error response
@@ -1020,14 +4462,304 @@ func SCIMBulkFromJSON(jsonBody string) (SCIMBulkResponse, int) {
 	return SCIMBulk(request)
 }
 
+// SCIMBulkSummary counts the outcome of the operations of a bulk request
+type SCIMBulkSummary struct {
+	Total     int                         `json:"total"`
+	Succeeded int                         `json:"succeeded"`
+	Skipped   int                         `json:"skipped"` // Not executed once failOnErrors errors occurred
+	Failures  []SCIMBulkOperationResponse `json:"failures"`
+}
+
+// SummarizeSCIMBulk counts the succeeded, failed and skipped operations of
+// a bulk request from its response
+func SummarizeSCIMBulk(request SCIMBulkRequest, response SCIMBulkResponse) SCIMBulkSummary {
+	summary := SCIMBulkSummary{
+		Total:    len(request.Operations),
+		Skipped:  len(request.Operations) - len(response.Operations),
+		Failures: []SCIMBulkOperationResponse{},
+	}
+	for _, op := range response.Operations {
+		if scimOperationFailed(op) {
+			summary.Failures = append(summary.Failures, op)
+		} else {
+			summary.Succeeded++
+		}
+	}
+	return summary
+}
+
+// SCIM v2 Service Provider Configuration
+// See RFC 7643 Section 5: https://datatracker.ietf.org/doc/html/rfc7643#section-5
+
+const SCIMServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+// SCIMSupported represents a SCIM feature that is only enabled or not
+type SCIMSupported struct {
+	Supported bool `json:"supported"`
+}
+
+// SCIMBulkConfig represents the bulk capabilities of a SCIM server
+type SCIMBulkConfig struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"` // In bytes
+}
+
+// SCIMFilterConfig represents the filtering capabilities of a SCIM server
+type SCIMFilterConfig struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+// SCIMAuthenticationScheme represents an authentication scheme supported by a SCIM server
+type SCIMAuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SCIMProviderConfig represents the configuration of a SCIM service provider
+type SCIMProviderConfig struct {
+	Schemas               []string                   `json:"schemas"`
+	Patch                 SCIMSupported              `json:"patch"`
+	Bulk                  SCIMBulkConfig             `json:"bulk"`
+	Filter                SCIMFilterConfig           `json:"filter"`
+	ChangePassword        SCIMSupported              `json:"changePassword"`
+	Sort                  SCIMSupported              `json:"sort"`
+	Etag                  SCIMSupported              `json:"etag"`
+	AuthenticationSchemes []SCIMAuthenticationScheme `json:"authenticationSchemes"`
+}
+
+// SCIMServiceProviderConfig retrieves the capabilities and limits of the SCIM server
+// GET /scim/v2/ServiceProviderConfig
+func SCIMServiceProviderConfig() (SCIMProviderConfig, int) {
+	config := SCIMProviderConfig{}
+	response, status := httpGet("scim/v2/ServiceProviderConfig", "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &config)
+	}
+	return config, status
+}
+
+// SCIM v2 Users
+// See RFC 7643 Section 4.1: https://datatracker.ietf.org/doc/html/rfc7643#section-4.1
+
+const (
+	SCIMUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+)
+
+// SCIMName represents the name of a SCIM user
+type SCIMName struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// SCIMEmail represents an email address of a SCIM user
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMUser represents a SCIM v2 user resource
+type SCIMUser struct {
+	Schemas     []string    `json:"schemas,omitempty"`
+	Id          string      `json:"id,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        SCIMName    `json:"name"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []SCIMEmail `json:"emails,omitempty"`
+	Active      bool        `json:"active"`
+}
+
+// SCIMUserList represents a SCIM v2 list response of users
+type SCIMUserList struct {
+	Schemas      []string   `json:"schemas"`
+	TotalResults int        `json:"totalResults"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	StartIndex   int        `json:"startIndex"`
+	Resources    []SCIMUser `json:"Resources"`
+}
+
+// SCIMGetUser retrieves a user
+// GET /scim/v2/Users/{id}
+func SCIMGetUser(id string) (SCIMUser, int) {
+	user := SCIMUser{}
+	response, status := httpGet("scim/v2/Users/"+id, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &user)
+	}
+	return user, status
+}
+
+// SCIMListUsers lists users, optionally matching a SCIM filter
+// such as `userName eq "jane@example.com"` (empty for all users)
+// GET /scim/v2/Users
+func SCIMListUsers(filter string) (SCIMUserList, int) {
+	users := SCIMUserList{}
+	endpoint := "scim/v2/Users"
+	if filter != "" {
+		endpoint += "?filter=" + url.QueryEscape(filter)
+	}
+	response, status := httpGet(endpoint, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &users)
+	}
+	return users, status
+}
+
+// SCIMCreateUser creates a user
+// POST /scim/v2/Users
+// Returns the created user, with its id
+func SCIMCreateUser(user SCIMUser) (SCIMUser, int) {
+	created := SCIMUser{}
+	if len(user.Schemas) == 0 {
+		user.Schemas = []string{SCIMUserSchema}
+	}
+	bodyJSON, err := json.Marshal(user)
+	if err != nil {
+		return created, -1
+	}
+	response, status := httpPost("scim/v2/Users", string(bodyJSON))
+	if status == http.StatusCreated || status == http.StatusOK {
+		status = decodeJSON(response, status, &created)
+	}
+	return created, status
+}
+
+// SCIMDeleteUser deletes a user
+// DELETE /scim/v2/Users/{id}
+func SCIMDeleteUser(id string) (string, int) {
+	return httpDelete("scim/v2/Users/"+id, "")
+}
+
+// SCIM v2 Groups
+// See RFC 7643 Section 4.2: https://datatracker.ietf.org/doc/html/rfc7643#section-4.2
+
+const (
+	SCIMGroupSchema   = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMPatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+)
+
+// SCIMMember represents a member of a SCIM group
+type SCIMMember struct {
+	Value   string `json:"value"` // Id of the member
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMGroup represents a SCIM v2 group resource
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas,omitempty"`
+	Id          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members"`
+}
+
+// SCIMGroupList represents a SCIM v2 list response of groups
+type SCIMGroupList struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	StartIndex   int         `json:"startIndex"`
+	Resources    []SCIMGroup `json:"Resources"`
+}
+
+// SCIMPatchOperation represents a single operation of a SCIM PATCH request
+type SCIMPatchOperation struct {
+	Op    string      `json:"op"` // add, remove or replace
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// SCIMPatchRequest represents a SCIM v2 PATCH request
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMListGroups lists the groups
+// GET /scim/v2/Groups
+func SCIMListGroups() (SCIMGroupList, int) {
+	groups := SCIMGroupList{}
+	response, status := httpGet("scim/v2/Groups", "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &groups)
+	}
+	return groups, status
+}
+
+// SCIMGetGroup retrieves a group with its members
+// GET /scim/v2/Groups/{id}
+func SCIMGetGroup(id string) (SCIMGroup, int) {
+	group := SCIMGroup{}
+	response, status := httpGet("scim/v2/Groups/"+id, "")
+	if status == http.StatusOK {
+		status = decodeJSON(response, status, &group)
+	}
+	return group, status
+}
+
+// SCIMCreateGroup creates a group
+// POST /scim/v2/Groups
+// Returns the created group, with its id
+func SCIMCreateGroup(group SCIMGroup) (SCIMGroup, int) {
+	created := SCIMGroup{}
+	if len(group.Schemas) == 0 {
+		group.Schemas = []string{SCIMGroupSchema}
+	}
+	if group.Members == nil {
+		group.Members = []SCIMMember{}
+	}
+	bodyJSON, err := json.Marshal(group)
+	if err != nil {
+		return created, -1
+	}
+	response, status := httpPost("scim/v2/Groups", string(bodyJSON))
+	if status == http.StatusCreated || status == http.StatusOK {
+		status = decodeJSON(response, status, &created)
+	}
+	return created, status
+}
+
+// SCIMFilterValue quotes a value compared in a SCIM filter, escaping
+// backslashes and double quotes as in a JSON string (RFC 7644 section 3.4.2.2)
+func SCIMFilterValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// SCIMPatchGroupMembers adds and removes members of a group, given their ids
+// PATCH /scim/v2/Groups/{id}
+func SCIMPatchGroupMembers(id string, add []string, remove []string) (string, int) {
+	request := SCIMPatchRequest{
+		Schemas:    []string{SCIMPatchOpSchema},
+		Operations: []SCIMPatchOperation{},
+	}
+	if len(add) > 0 {
+		members := make([]SCIMMember, 0, len(add))
+		for _, memberId := range add {
+			members = append(members, SCIMMember{Value: memberId})
+		}
+		request.Operations = append(request.Operations, SCIMPatchOperation{Op: "add", Path: "members", Value: members})
+	}
+	for _, memberId := range remove {
+		request.Operations = append(request.Operations, SCIMPatchOperation{
+			Op:   "remove",
+			Path: "members[value eq " + SCIMFilterValue(memberId) + "]",
+		})
+	}
+
+	bodyJSON, err := json.Marshal(request)
+	if err != nil {
+		return "", -1
+	}
+	return httpPatch("scim/v2/Groups/"+id, string(bodyJSON))
+}
+
 // Attachment APIs
 // See: https://support.getgrist.com/api/#tag/attachments
 
 // httpMultipartUpload sends a multipart form upload request to Grist's REST API
 func httpMultipartUpload(endpoint string, fieldName string, files []string) (string, int) {
-	client := &http.Client{}
 	url := fmt.Sprintf("%s/api/%s", os.Getenv("GRIST_URL"), endpoint)
-	bearer := "Bearer " + os.Getenv("GRIST_TOKEN")
 
 	// Create multipart form body
 	body := &bytes.Buffer{}
@@ -1058,7 +4790,7 @@ func httpMultipartUpload(endpoint string, fieldName string, files []string) (str
 
 		// Close file immediately after reading
 		if err := file.Close(); err != nil {
-			log.Printf("Error closing file: %v", err)
+			logger.Warn("unable to close file", "file", filePath, "error", err)
 		}
 	}
 
@@ -1071,20 +4803,19 @@ func httpMultipartUpload(endpoint string, fieldName string, files []string) (str
 		return fmt.Sprintf("Error creating request: %s", err), -1
 	}
 
-	req.Header.Add("Authorization", bearer)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return fmt.Sprintf("Error sending request: %s", err), -10
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Error closing response body: %v", err)
+			logger.Warn("unable to close response body", "error", err)
 		}
 	}()
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := readResponseBody(resp)
 	if err != nil {
 		return fmt.Sprintf("Error reading response: %s", err), resp.StatusCode
 	}
@@ -1094,9 +4825,7 @@ func httpMultipartUpload(endpoint string, fieldName string, files []string) (str
 
 // httpMultipartUploadReader sends a multipart form upload request using an io.Reader
 func httpMultipartUploadReader(endpoint string, fieldName string, fileName string, reader io.Reader) (string, int) {
-	client := &http.Client{}
 	url := fmt.Sprintf("%s/api/%s", os.Getenv("GRIST_URL"), endpoint)
-	bearer := "Bearer " + os.Getenv("GRIST_TOKEN")
 
 	// Create multipart form body
 	body := &bytes.Buffer{}
@@ -1122,20 +4851,19 @@ func httpMultipartUploadReader(endpoint string, fieldName string, fileName strin
 		return fmt.Sprintf("Error creating request: %s", err), -1
 	}
 
-	req.Header.Add("Authorization", bearer)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return fmt.Sprintf("Error sending request: %s", err), -10
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Error closing response body: %v", err)
+			logger.Warn("unable to close response body", "error", err)
 		}
 	}()
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := readResponseBody(resp)
 	if err != nil {
 		return fmt.Sprintf("Error reading response: %s", err), resp.StatusCode
 	}
@@ -1145,28 +4873,30 @@ func httpMultipartUploadReader(endpoint string, fieldName string, fileName strin
 
 // httpGetBinary sends a GET request and returns raw binary response
 func httpGetBinary(endpoint string) ([]byte, string, int) {
-	client := &http.Client{}
+	return httpGetBinaryContext(context.Background(), endpoint)
+}
+
+// httpGetBinaryContext sends a GET request that is cancelled with ctx and
+// returns raw binary response
+func httpGetBinaryContext(ctx context.Context, endpoint string) ([]byte, string, int) {
 	url := fmt.Sprintf("%s/api/%s", os.Getenv("GRIST_URL"), endpoint)
-	bearer := "Bearer " + os.Getenv("GRIST_TOKEN")
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, "", -1
 	}
 
-	req.Header.Add("Authorization", bearer)
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, "", -10
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Error closing response body: %v", err)
+			logger.Warn("unable to close response body", "error", err)
 		}
 	}()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := readResponseBody(resp)
 	if err != nil {
 		return nil, "", resp.StatusCode
 	}
@@ -1178,6 +4908,11 @@ func httpGetBinary(endpoint string) ([]byte, string, int) {
 // ListAttachments retrieves all attachments for a document
 // GET /docs/{docId}/attachments
 func ListAttachments(docId string, options *GetAttachmentsOptions) (AttachmentList, int) {
+	return ListAttachmentsContext(context.Background(), docId, options)
+}
+
+// ListAttachmentsContext is like ListAttachments, with a context cancelling its requests
+func ListAttachmentsContext(ctx context.Context, docId string, options *GetAttachmentsOptions) (AttachmentList, int) {
 	attachments := AttachmentList{}
 	params := make(map[string]string)
 
@@ -1197,9 +4932,9 @@ func ListAttachments(docId string, options *GetAttachmentsOptions) (AttachmentLi
 	}
 
 	url := fmt.Sprintf("docs/%s/attachments%s", docId, buildRecordsQueryParams(params))
-	response, status := httpGet(url, "")
+	response, status := httpGetContext(ctx, url, "")
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &attachments)
+		status = decodeJSON(response, status, &attachments)
 	}
 	return attachments, status
 }
@@ -1218,11 +4953,42 @@ func UploadAttachments(docId string, filePaths []string) (UploadAttachmentsRespo
 	response, status := httpMultipartUpload(endpoint, "upload", filePaths)
 
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
 
+// UploadDocGrist imports a .grist file (e.g. from ExportDocGrist or BackupDoc)
+// as a new document of a workspace, named after the file
+// POST /workspaces/{workspaceId}/import
+// Returns the ID of the new document, or an error message if the status
+// isn't 200
+func UploadDocGrist(workspaceId int, path string) (string, int) {
+	if !strings.EqualFold(filepath.Ext(path), ".grist") {
+		return fmt.Sprintf("%s is not a .grist file", path), -1
+	}
+
+	endpoint := fmt.Sprintf("workspaces/%d/import", workspaceId)
+	response, status := httpMultipartUpload(endpoint, "upload", []string{path})
+	if status != http.StatusOK {
+		if status > 0 {
+			response = fmt.Sprintf("unable to import %s: HTTP %d%s", path, status, responseErrorDetail([]byte(response)))
+		}
+		return response, status
+	}
+
+	result := struct {
+		Id string `json:"id"`
+	}{}
+	if status = decodeJSON(response, status, &result); status != http.StatusOK {
+		return fmt.Sprintf("unable to import %s: invalid response", path), status
+	}
+	if result.Id == "" {
+		return fmt.Sprintf("%s imported, but no document ID found in the response %q", path, responseExcerpt(response)), StatusInvalidResponse
+	}
+	return result.Id, status
+}
+
 // UploadAttachmentsFromReader uploads an attachment from an io.Reader
 // POST /docs/{docId}/attachments
 // Returns array of attachment IDs
@@ -1233,7 +4999,7 @@ func UploadAttachmentsFromReader(docId string, fileName string, reader io.Reader
 	response, status := httpMultipartUploadReader(endpoint, "upload", fileName, reader)
 
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
@@ -1241,11 +5007,16 @@ func UploadAttachmentsFromReader(docId string, fileName string, reader io.Reader
 // GetAttachmentMetadata retrieves metadata for a specific attachment
 // GET /docs/{docId}/attachments/{attachmentId}
 func GetAttachmentMetadata(docId string, attachmentId int) (AttachmentMetadata, int) {
+	return GetAttachmentMetadataContext(context.Background(), docId, attachmentId)
+}
+
+// GetAttachmentMetadataContext is like GetAttachmentMetadata, with a context cancelling its requests
+func GetAttachmentMetadataContext(ctx context.Context, docId string, attachmentId int) (AttachmentMetadata, int) {
 	attachment := AttachmentMetadata{}
 	url := fmt.Sprintf("docs/%s/attachments/%d", docId, attachmentId)
-	response, status := httpGet(url, "")
+	response, status := httpGetContext(ctx, url, "")
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &attachment)
+		status = decodeJSON(response, status, &attachment)
 	}
 	return attachment, status
 }
@@ -1254,8 +5025,13 @@ func GetAttachmentMetadata(docId string, attachmentId int) (AttachmentMetadata,
 // GET /docs/{docId}/attachments/{attachmentId}/download
 // Returns the raw bytes and content type
 func DownloadAttachment(docId string, attachmentId int) ([]byte, string, int) {
+	return DownloadAttachmentContext(context.Background(), docId, attachmentId)
+}
+
+// DownloadAttachmentContext is like DownloadAttachment, with a context cancelling its requests
+func DownloadAttachmentContext(ctx context.Context, docId string, attachmentId int) ([]byte, string, int) {
 	url := fmt.Sprintf("docs/%s/attachments/%d/download", docId, attachmentId)
-	return httpGetBinary(url)
+	return httpGetBinaryContext(ctx, url)
 }
 
 // DownloadAttachmentToFile downloads an attachment and saves it to a file
@@ -1268,6 +5044,128 @@ func DownloadAttachmentToFile(docId string, attachmentId int, destPath string) e
 	return os.WriteFile(destPath, content, 0600)
 }
 
+// AttachmentFileName returns the name an attachment is saved under: its file
+// name without directories, or attachment-<id> if it has no usable name
+func AttachmentFileName(attachmentId int, fileName string) string {
+	name := filepath.Base(filepath.Clean("/" + fileName))
+	if name == "/" || name == "." {
+		return fmt.Sprintf("attachment-%d", attachmentId)
+	}
+	return name
+}
+
+// BackupFile is a file of a document backup
+type BackupFile struct {
+	Path         string `json:"path"` // Relative to the backup directory
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+	AttachmentId int    `json:"attachmentId,omitempty"`
+}
+
+// BackupManifest describes a document backup, so that it can be verified
+type BackupManifest struct {
+	DocId     string       `json:"docId"`
+	DocName   string       `json:"docName"`
+	Workspace string       `json:"workspace"`
+	Org       string       `json:"org"`
+	Server    string       `json:"server"`
+	CreatedAt string       `json:"createdAt"`
+	Files     []BackupFile `json:"files"`
+}
+
+// Name of the manifest written in backup directories
+const BackupManifestFile = "manifest.json"
+
+// writeBackupFile writes content in dir/path and returns its description
+func writeBackupFile(dir string, path string, content []byte) (BackupFile, error) {
+	// #nosec G304 - dir is user-provided CLI argument for backup destination
+	if err := os.WriteFile(filepath.Join(dir, path), content, 0600); err != nil {
+		return BackupFile{}, fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	checksum := sha256.Sum256(content)
+	return BackupFile{Path: filepath.ToSlash(path), Size: int64(len(content)), SHA256: hex.EncodeToString(checksum[:])}, nil
+}
+
+// DownloadAllAttachments downloads the attachments of a document into dir
+// Files are named "<id>_<file name>", as names are not unique in a document,
+// and each BackupFile has the path of the file written, relative to dir
+// Stops at the first failed download
+func DownloadAllAttachments(docId string, dir string) ([]BackupFile, error) {
+	attachments, status := ListAttachments(docId, nil)
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unable to list attachments of document %s: HTTP %d", docId, status)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	files := []BackupFile{}
+	for _, attachment := range attachments.Records {
+		content, _, status := DownloadAttachment(docId, attachment.Id)
+		if status != http.StatusOK {
+			return files, fmt.Errorf("unable to download attachment %d: HTTP %d", attachment.Id, status)
+		}
+		name := fmt.Sprintf("%d_%s", attachment.Id, AttachmentFileName(attachment.Id, attachment.FileName))
+		file, err := writeBackupFile(dir, name, content)
+		if err != nil {
+			return files, err
+		}
+		file.AttachmentId = attachment.Id
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// BackupDoc saves a document into dir: the .grist file, the attachments in
+// an attachments subdirectory, and a manifest with the document metadata
+// and the checksum of every file
+func BackupDoc(docId string, dir string) (BackupManifest, error) {
+	manifest := BackupManifest{}
+	doc, status := GetDoc(docId)
+	if status != http.StatusOK {
+		return manifest, fmt.Errorf("unable to get document %s: HTTP %d", docId, status)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return manifest, err
+	}
+
+	content, _, status := httpGetBinary(fmt.Sprintf("docs/%s/download", docId))
+	if status != http.StatusOK {
+		return manifest, fmt.Errorf("export failed: HTTP %d%s", status, responseErrorDetail(content))
+	}
+	gristFile, err := writeBackupFile(dir, docId+".grist", content)
+	if err != nil {
+		return manifest, err
+	}
+
+	attachments, err := DownloadAllAttachments(docId, filepath.Join(dir, "attachments"))
+	if err != nil {
+		return manifest, err
+	}
+	for i := range attachments {
+		attachments[i].Path = "attachments/" + attachments[i].Path
+	}
+
+	manifest = BackupManifest{
+		DocId:     doc.Id,
+		DocName:   doc.Name,
+		Workspace: doc.Workspace.Name,
+		Org:       doc.Workspace.Org.Name,
+		Server:    os.Getenv("GRIST_URL"),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Files:     append([]BackupFile{gristFile}, attachments...),
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	// #nosec G304 - dir is user-provided CLI argument for backup destination
+	if err := os.WriteFile(filepath.Join(dir, BackupManifestFile), manifestJSON, 0600); err != nil {
+		return manifest, fmt.Errorf("unable to write %s: %w", BackupManifestFile, err)
+	}
+	return manifest, nil
+}
+
 // RestoreAttachments uploads a .tar archive to restore missing attachments
 // POST /docs/{docId}/attachments/archive
 func RestoreAttachments(docId string, tarFilePath string) (RestoreAttachmentsResponse, int) {
@@ -1277,7 +5175,7 @@ func RestoreAttachments(docId string, tarFilePath string) (RestoreAttachmentsRes
 	response, status := httpMultipartUpload(endpoint, "upload", []string{tarFilePath})
 
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
@@ -1291,7 +5189,7 @@ func RestoreAttachmentsFromReader(docId string, fileName string, reader io.Reade
 	response, status := httpMultipartUploadReader(endpoint, "upload", fileName, reader)
 
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
@@ -1303,6 +5201,56 @@ func DeleteUnusedAttachments(docId string) (string, int) {
 	return httpPost(url, "")
 }
 
+// FindUnusedAttachments lists the attachments not referenced by any cell of
+// an Attachments column, i.e. those DeleteUnusedAttachments would remove
+func FindUnusedAttachments(docId string) ([]AttachmentMetadata, int) {
+	attachments, status := ListAttachments(docId, nil)
+	if status != http.StatusOK {
+		return nil, status
+	}
+	schema, status := GetDocSchema(docId)
+	if status != http.StatusOK {
+		return nil, status
+	}
+
+	used := map[int]bool{}
+	for _, table := range schema.Tables {
+		fields := []string{}
+		for _, column := range table.Columns {
+			if column.Fields.Type == "Attachments" {
+				fields = append(fields, column.Id)
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		records, status := GetRecords(docId, table.Id, &GetRecordsOptions{Fields: fields})
+		if status != http.StatusOK {
+			return nil, status
+		}
+		for _, record := range records.Records {
+			for _, field := range fields {
+				// Attachments cells are encoded as ["L", id, ...]
+				if cell, ok := record.Fields[field].([]interface{}); ok && len(cell) > 0 && cell[0] == "L" {
+					for _, id := range cell[1:] {
+						if n, ok := id.(float64); ok {
+							used[int(n)] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	unused := []AttachmentMetadata{}
+	for _, attachment := range attachments.Records {
+		if !used[attachment.Id] {
+			unused = append(unused, attachment)
+		}
+	}
+	return unused, http.StatusOK
+}
+
 // Webhook API Types
 // See: https://support.getgrist.com/api/#tag/webhooks
 
@@ -1393,15 +5341,58 @@ func GetWebhooks(docId string) (WebhooksList, int) {
 	url := fmt.Sprintf("docs/%s/webhooks", docId)
 	response, status := httpGet(url, "")
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &webhooks)
+		status = decodeJSON(response, status, &webhooks)
 	}
 	return webhooks, status
 }
 
+// Events a webhook can be triggered by
+var WebhookEventTypes = []string{"add", "update"}
+
+// ValidateWebhooksCreate checks that each webhook to create has an http(s)
+// URL, a table and at least one event type among WebhookEventTypes, which
+// Grist requires
+// Returns an error listing every missing or invalid field, or nil
+func ValidateWebhooksCreate(webhooks []WebhookPartialFields) error {
+	var errs []error
+	for i, fields := range webhooks {
+		if fields.URL == nil || *fields.URL == "" {
+			errs = append(errs, fmt.Errorf("webhook %d: missing url", i+1))
+		} else if parsed, err := url.Parse(*fields.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("webhook %d: invalid url %q (expected http or https)", i+1, *fields.URL))
+		}
+		if fields.TableId == nil || *fields.TableId == "" {
+			errs = append(errs, fmt.Errorf("webhook %d: missing tableId", i+1))
+		}
+		if fields.EventTypes == nil || len(*fields.EventTypes) == 0 {
+			errs = append(errs, fmt.Errorf("webhook %d: missing eventTypes", i+1))
+		} else {
+			for _, eventType := range *fields.EventTypes {
+				if !slices.Contains(WebhookEventTypes, eventType) {
+					errs = append(errs, fmt.Errorf("webhook %d: unknown event type %q (expected %s)",
+						i+1, eventType, strings.Join(WebhookEventTypes, " or ")))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // CreateWebhooks creates one or more webhooks for a document
 // POST /docs/{docId}/webhooks
+// Returns -1 without sending anything if a webhook lacks a required field,
+// the missing fields being logged
 func CreateWebhooks(docId string, webhooks []WebhookPartialFields) (WebhooksCreateResponse, int) {
+	return CreateWebhooksContext(context.Background(), docId, webhooks)
+}
+
+// CreateWebhooksContext is like CreateWebhooks, with a context cancelling its requests
+func CreateWebhooksContext(ctx context.Context, docId string, webhooks []WebhookPartialFields) (WebhooksCreateResponse, int) {
 	result := WebhooksCreateResponse{}
+	if err := ValidateWebhooksCreate(webhooks); err != nil {
+		logger.Error("webhooks not created", "doc", docId, "error", err)
+		return result, -1
+	}
 
 	// Build request body
 	request := WebhooksCreateRequest{
@@ -1417,13 +5408,68 @@ func CreateWebhooks(docId string, webhooks []WebhookPartialFields) (WebhooksCrea
 	}
 
 	url := fmt.Sprintf("docs/%s/webhooks", docId)
-	response, status := httpPost(url, string(bodyJSON))
+	response, status := httpPostContext(ctx, url, string(bodyJSON))
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
 
+// CreateWebhookIfNotExists creates a webhook unless an identical one already exists
+// Webhooks are considered identical when they share URL, table and event types
+// Returns the webhook ID and whether it was created
+func CreateWebhookIfNotExists(docId string, fields WebhookPartialFields) (WebhookId, bool, error) {
+	if err := ValidateWebhooksCreate([]WebhookPartialFields{fields}); err != nil {
+		return WebhookId{}, false, err
+	}
+	existing, status := GetWebhooks(docId)
+	if status != http.StatusOK {
+		return WebhookId{}, false, fmt.Errorf("unable to list webhooks of document %s: HTTP %d", docId, status)
+	}
+	for _, wh := range existing.Webhooks {
+		if webhookMatches(wh.Fields, fields) {
+			return WebhookId{Id: wh.Id}, false, nil
+		}
+	}
+
+	created, status := CreateWebhooks(docId, []WebhookPartialFields{fields})
+	if status != http.StatusOK {
+		return WebhookId{}, false, fmt.Errorf("unable to create webhook in document %s: HTTP %d", docId, status)
+	}
+	if len(created.Webhooks) == 0 {
+		return WebhookId{}, false, fmt.Errorf("no webhook ID returned for document %s", docId)
+	}
+	return created.Webhooks[0], true, nil
+}
+
+// webhookMatches reports whether an existing webhook has the same URL, table and event types
+func webhookMatches(existing WebhookFields, fields WebhookPartialFields) bool {
+	if fields.URL == nil || existing.URL != *fields.URL {
+		return false
+	}
+	if fields.TableId == nil || existing.TableId != *fields.TableId {
+		return false
+	}
+	var events []string
+	if fields.EventTypes != nil {
+		events = *fields.EventTypes
+	}
+	if len(existing.EventTypes) != len(events) {
+		return false
+	}
+	wanted := make(map[string]int, len(events))
+	for _, e := range events {
+		wanted[e]++
+	}
+	for _, e := range existing.EventTypes {
+		if wanted[e] == 0 {
+			return false
+		}
+		wanted[e]--
+	}
+	return true
+}
+
 // UpdateWebhook modifies an existing webhook
 // PATCH /docs/{docId}/webhooks/{webhookId}
 func UpdateWebhook(docId string, webhookId string, fields WebhookPartialFields) (string, int) {
@@ -1440,11 +5486,16 @@ func UpdateWebhook(docId string, webhookId string, fields WebhookPartialFields)
 // DeleteWebhook removes a webhook from a document
 // DELETE /docs/{docId}/webhooks/{webhookId}
 func DeleteWebhook(docId string, webhookId string) (WebhookDeleteResponse, int) {
+	return DeleteWebhookContext(context.Background(), docId, webhookId)
+}
+
+// DeleteWebhookContext is like DeleteWebhook, with a context cancelling its requests
+func DeleteWebhookContext(ctx context.Context, docId string, webhookId string) (WebhookDeleteResponse, int) {
 	result := WebhookDeleteResponse{}
 	url := fmt.Sprintf("docs/%s/webhooks/%s", docId, webhookId)
-	response, status := httpDelete(url, "")
+	response, status := httpDeleteContext(ctx, url, "")
 	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &result)
+		status = decodeJSON(response, status, &result)
 	}
 	return result, status
 }
@@ -1459,9 +5510,134 @@ func ClearWebhookQueue(docId string) (string, int) {
 
 // Retrieves the list of webhooks for a document
 func GetDocWebhooks(docId string) []Webhook {
+	return GetDocWebhooksContext(context.Background(), docId)
+}
+
+// GetDocWebhooksContext is like GetDocWebhooks, with a context cancelling its requests
+func GetDocWebhooksContext(ctx context.Context, docId string) []Webhook {
 	webhooks := WebhooksList{}
 	url := fmt.Sprintf("docs/%s/webhooks", docId)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &webhooks)
+	response, status := httpGetContext(ctx, url, "")
+	decodeJSON(response, status, &webhooks)
 	return webhooks.Webhooks
 }
+
+// Interval between two reads of the webhook usage while testing a webhook
+var webhookTestPollInterval = 500 * time.Millisecond
+
+// WebhookTestResult reports the outcome of a webhook delivery test
+type WebhookTestResult struct {
+	WebhookId        string  `json:"webhookId"`
+	TableId          string  `json:"tableId"`
+	RecordId         int     `json:"recordId"`
+	Delivered        bool    `json:"delivered"`
+	Failed           bool    `json:"failed"`
+	LastSuccessTime  *int64  `json:"lastSuccessTime,omitempty"`
+	LastHttpStatus   *int    `json:"lastHttpStatus,omitempty"`
+	LastErrorMessage *string `json:"lastErrorMessage,omitempty"`
+}
+
+// TriggerWebhookTest checks that a webhook fires
+// A throwaway record is added to the webhook's table (then updated if the
+// webhook only listens to updates), and the webhook usage is read until
+// the delivery succeeds, fails, or wait elapses. The record is deleted
+// afterward
+func TriggerWebhookTest(docId string, webhookId string, wait time.Duration) (WebhookTestResult, error) {
+	result := WebhookTestResult{WebhookId: webhookId}
+	webhook, err := findWebhook(docId, webhookId)
+	if err != nil {
+		return result, err
+	}
+	result.TableId = webhook.Fields.TableId
+	before := WebhookUsage{}
+	if webhook.Usage != nil {
+		before = *webhook.Usage
+	}
+
+	addFields, updateFields, err := webhookTestFields(docId, webhook.Fields)
+	if err != nil {
+		return result, err
+	}
+	added, _, status := AddRecords(docId, result.TableId, []map[string]interface{}{addFields}, nil)
+	if status != http.StatusOK || len(added.Records) == 0 {
+		return result, fmt.Errorf("unable to add a test record to table %s: HTTP %d", result.TableId, status)
+	}
+	result.RecordId = added.Records[0].Id
+	defer func() {
+		if _, status := DeleteRecords(docId, result.TableId, []int{result.RecordId}); status != http.StatusOK {
+			logger.Warn("unable to delete webhook test record", "table", result.TableId, "id", result.RecordId, "status", status)
+		}
+	}()
+
+	if updateFields != nil {
+		update := []Record{{Id: result.RecordId, Fields: updateFields}}
+		if _, status := UpdateRecords(docId, result.TableId, update, nil); status != http.StatusOK {
+			return result, fmt.Errorf("unable to update the test record of table %s: HTTP %d", result.TableId, status)
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		time.Sleep(webhookTestPollInterval)
+		webhook, err := findWebhook(docId, webhookId)
+		if err != nil {
+			return result, err
+		}
+		if usage := webhook.Usage; usage != nil {
+			result.LastSuccessTime = usage.LastSuccessTime
+			result.LastHttpStatus = usage.LastHttpStatus
+			result.LastErrorMessage = usage.LastErrorMessage
+			result.Delivered = timeAdvanced(before.LastSuccessTime, usage.LastSuccessTime)
+			result.Failed = timeAdvanced(before.LastFailureTime, usage.LastFailureTime)
+		}
+		if result.Delivered || result.Failed || !time.Now().Before(deadline) {
+			return result, nil
+		}
+	}
+}
+
+// findWebhook retrieves a webhook of a document by its ID
+func findWebhook(docId string, webhookId string) (Webhook, error) {
+	webhooks, status := GetWebhooks(docId)
+	if status != http.StatusOK {
+		return Webhook{}, fmt.Errorf("unable to list webhooks of document %s: HTTP %d", docId, status)
+	}
+	for _, wh := range webhooks.Webhooks {
+		if wh.Id == webhookId {
+			return wh, nil
+		}
+	}
+	return Webhook{}, fmt.Errorf("webhook %s not found in document %s", webhookId, docId)
+}
+
+// webhookTestFields returns the fields of the test record, and the fields
+// to update it with when the webhook only listens to updates (nil otherwise)
+// A webhook with a ready column only fires once that column is true
+func webhookTestFields(docId string, fields WebhookFields) (map[string]interface{}, map[string]interface{}, error) {
+	ready := map[string]interface{}{}
+	if fields.IsReadyColumn != nil && *fields.IsReadyColumn != "" {
+		ready[*fields.IsReadyColumn] = true
+	}
+	if slices.Contains(fields.EventTypes, "add") {
+		return ready, nil, nil
+	}
+	if !slices.Contains(fields.EventTypes, "update") {
+		return nil, nil, fmt.Errorf("unsupported event types %v", fields.EventTypes)
+	}
+
+	if len(ready) > 0 {
+		return map[string]interface{}{*fields.IsReadyColumn: false}, ready, nil
+	}
+	// Without a ready column, the update changes a text column
+	for _, column := range GetTableColumns(docId, fields.TableId).Columns {
+		if !column.Fields.IsFormula && column.Fields.Type == "Text" {
+			return map[string]interface{}{}, map[string]interface{}{column.Id: "gristle webhook test"}, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no text column to update in table %s", fields.TableId)
+}
+
+// timeAdvanced reports whether a webhook usage time is set and later than before
+func timeAdvanced(before *int64, after *int64) bool {
+	return after != nil && (before == nil || *after > *before)
+}
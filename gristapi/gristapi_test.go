@@ -6,13 +6,25 @@ package gristapi
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestConnect(t *testing.T) {
@@ -25,7 +37,7 @@ func TestConnect(t *testing.T) {
 
 	for i, org := range orgs {
 		orgId := fmt.Sprintf("%d", org.Id)
-		if GetOrg(orgId).Name != orgs[i].Name {
+		if org, _ := GetOrg(orgId); org.Name != orgs[i].Name {
 			t.Error("We don't find main organization.")
 		}
 
@@ -40,7 +52,7 @@ func TestConnect(t *testing.T) {
 				t.Errorf("Workspace %d : le domaine du workspace %s ne correspond pas à %s", workspace.Id, workspace.OrgDomain, org.Domain)
 			}
 
-			myWorkspace := GetWorkspace(workspace.Id)
+			myWorkspace, _ := GetWorkspace(workspace.Id)
 			if myWorkspace.Name != workspace.Name {
 				t.Errorf("Workspace n°%d : les noms ne correspondent pas (%s/%s)", workspace.Id, workspace.Name, myWorkspace.Name)
 			}
@@ -128,6 +140,598 @@ func TestBuildRecordsQueryParams(t *testing.T) {
 	}
 }
 
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"90", 90 * time.Second, false},
+		{"5m", 5 * time.Minute, false},
+		{"1m30s", 90 * time.Second, false},
+		{"0", 0, false},
+		{"soon", 0, true},
+		{"-5", 0, true},
+		{"-1m", 0, true},
+	}
+
+	for _, tt := range tests {
+		timeout, err := parseTimeout(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimeout(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if timeout != tt.expected {
+			t.Errorf("parseTimeout(%q) = %v, expected %v", tt.value, timeout, tt.expected)
+		}
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+		wantErr  bool
+	}{
+		{"10", 10, false},
+		{"0.5", 0.5, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"fast", 0, true},
+	}
+
+	for _, tt := range tests {
+		perSecond, err := parseRateLimit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRateLimit(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if perSecond != tt.expected {
+			t.Errorf("parseRateLimit(%q) = %v, expected %v", tt.value, perSecond, tt.expected)
+		}
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+
+	SetRateLimit(20, 1)
+	defer SetRateLimit(0, 0)
+
+	// Concurrent requests are spread too
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			httpGet("orgs", "")
+		}()
+	}
+	wg.Wait()
+
+	if len(starts) != 5 {
+		t.Fatalf("Expected 5 requests, got %d", len(starts))
+	}
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+	if elapsed := starts[4].Sub(starts[0]); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected 5 requests at 20/s to span at least 200ms, got %v", elapsed)
+	}
+}
+
+func TestSetRateLimit_Burst(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+
+	SetRateLimit(5, 3)
+	defer SetRateLimit(0, 0)
+
+	begin := time.Now()
+	for range 4 {
+		httpGet("orgs", "")
+	}
+	if len(starts) != 4 {
+		t.Fatalf("Expected 4 requests, got %d", len(starts))
+	}
+	// The bucket holds 3 requests, the 4th one waits for a token (200ms)
+	if elapsed := starts[2].Sub(begin); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected the first 3 requests to start at once, the 3rd started after %v", elapsed)
+	}
+	if elapsed := starts[3].Sub(begin); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected the 4th request to wait for a token, started after %v", elapsed)
+	}
+}
+
+func TestSetRateLimit_Cancel(t *testing.T) {
+	requests := atomic.Int32{}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+
+	SetRateLimit(0.1, 1)
+	defer SetRateLimit(0, 0)
+
+	httpGet("orgs", "")
+	// The next token comes in 10s, after the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if _, status := httpGetContext(ctx, "orgs", ""); status == http.StatusOK {
+		t.Error("Expected the request to fail once its context is done")
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Expected the wait to end with the context, took %v", elapsed)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected the cancelled request not to be sent, got %d requests", requests.Load())
+	}
+}
+
+func TestSessionCookieAuth(t *testing.T) {
+	tests := []struct {
+		cookie   string
+		expected string
+	}{
+		{"grist_sid=s%3Aabc", "grist_sid=s%3Aabc"},
+		{"s%3Aabc", "grist_sid=s%3Aabc"},
+	}
+
+	for _, tt := range tests {
+		_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("Cookie"); got != tt.expected {
+				t.Errorf("Expected cookie %q, got %q", tt.expected, got)
+			}
+			if got := r.Header.Get("Authorization"); got != "" {
+				t.Errorf("Expected no Authorization header with a session cookie, got %q", got)
+			}
+			w.Write([]byte(`[]`))
+		})
+		t.Setenv("GRIST_SESSION_COOKIE", tt.cookie)
+		if _, status := httpGet("orgs", ""); status != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", status)
+		}
+		cleanup()
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "gristle/1.2.3" {
+			t.Errorf("Expected User-Agent gristle/1.2.3, got %q", got)
+		}
+		w.Write([]byte(`[]`))
+	})
+	defer cleanup()
+	defer SetUserAgent(userAgent)
+
+	SetUserAgent("gristle/1.2.3")
+	if _, status := httpGet("orgs", ""); status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	calls := 0
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	defer cleanup()
+	defer SetRetries(maxRetries)
+	defer SetRetryWrites(retryWrites)
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+
+	SetRetries(2)
+	tests := []struct {
+		name        string
+		retryWrites bool
+		send        func() int
+		status      int
+		calls       int
+	}{
+		{"get", false, func() int { _, status := httpGet("orgs", ""); return status }, http.StatusOK, 2},
+		{"post", false, func() int { _, status := httpPost("records", `{"records":[]}`); return status }, http.StatusServiceUnavailable, 1},
+		{"post with retry writes", true, func() int {
+			body, status := httpPost("records", `{"records":[]}`)
+			if body != `{"records":[]}` {
+				t.Errorf("Expected the body to be sent again, got %q", body)
+			}
+			return status
+		}, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			SetRetryWrites(tt.retryWrites)
+			if status := tt.send(); status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, status)
+			}
+			if calls != tt.calls {
+				t.Errorf("Expected %d calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+}
+
+func TestExportFileName(t *testing.T) {
+	tests := []struct {
+		extension string
+		names     []string
+		expected  string
+	}{
+		{"xlsx", []string{"Home", "Budget"}, "Home_Budget.xlsx"},
+		{"csv", []string{"Home", "Q1/Q2: plan?", "Table1"}, "Home_Q1_Q2_ plan__Table1.csv"},
+		{"grist", []string{`a\b*"c"<d>|e`}, "a_b__c__d__e.grist"},
+	}
+
+	for _, tt := range tests {
+		if got := ExportFileName(tt.extension, tt.names...); got != tt.expected {
+			t.Errorf("ExportFileName(%q, %q) = %q, expected %q", tt.extension, tt.names, got, tt.expected)
+		}
+	}
+}
+
+func TestCallAPI(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected an authenticated request, got %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(fmt.Sprintf(`{"method": %q, "path": %q, "body": %q}`, r.Method, r.URL.Path, body)))
+	})
+	defer cleanup()
+
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		expected string
+	}{
+		{"GET", "docs/doc123/tables", "", `{"method": "GET", "path": "/api/docs/doc123/tables", "body": ""}`},
+		{"post", "/api/docs/doc123/apply", `[["AddTable"]]`, `{"method": "POST", "path": "/api/docs/doc123/apply", "body": "[[\"AddTable\"]]"}`},
+	}
+	for _, tt := range tests {
+		response, status := CallAPI(tt.method, tt.path, tt.body)
+		if status != http.StatusOK || response != tt.expected {
+			t.Errorf("CallAPI(%q, %q) = %d %s, expected %s", tt.method, tt.path, status, response, tt.expected)
+		}
+	}
+
+	if _, status := CallAPI("TRACE", "orgs", ""); status != -1 {
+		t.Errorf("Expected status -1 for an unsupported method, got %d", status)
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{"ok", http.StatusOK, `{"id": 5, "name": "Alice", "email": "alice@example.com"}`, ""},
+		{"bad token", http.StatusUnauthorized, `{"error": "invalid API key"}`, "check GRIST_TOKEN"},
+		{"wrong URL", http.StatusNotFound, ``, "check GRIST_URL"},
+		{"not Grist", http.StatusOK, `<html></html>`, "didn't answer like a Grist server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/profile/user" {
+					t.Errorf("Expected profile endpoint, got %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			defer cleanup()
+
+			user, err := CheckConnection()
+			if tt.expected == "" {
+				if err != nil || user.Email != "alice@example.com" {
+					t.Errorf("Expected alice, got %+v, %v", user, err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		server, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {})
+		defer cleanup()
+		server.Close()
+
+		if _, err := CheckConnection(); err == nil || !strings.Contains(err.Error(), "unable to connect") {
+			t.Errorf("Expected a connection error, got %v", err)
+		}
+	})
+}
+
+func TestReadTokenFile(t *testing.T) {
+	dir := t.TempDir()
+
+	private := filepath.Join(dir, "token")
+	if err := os.WriteFile(private, []byte("  secret-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	token, err := readTokenFile(private)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if token != "secret-token" {
+		t.Errorf("Expected trimmed token, got %q", token)
+	}
+
+	public := filepath.Join(dir, "public-token")
+	if err := os.WriteFile(public, []byte("secret-token"), 0644); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	if err := os.Chmod(public, 0644); err != nil {
+		t.Fatalf("Failed to chmod token file: %v", err)
+	}
+	if _, err := readTokenFile(public); err == nil {
+		t.Error("Expected an error for a world-readable token file")
+	}
+
+	empty := filepath.Join(dir, "empty-token")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	if _, err := readTokenFile(empty); err == nil {
+		t.Error("Expected an error for an empty token file")
+	}
+
+	if _, err := readTokenFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing token file")
+	}
+}
+
+func TestApplyTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	t.Setenv("GRIST_TOKEN_FILE", tokenFile)
+
+	// An explicit token takes precedence over the file
+	t.Setenv("GRIST_TOKEN", "env-token")
+	applyTokenFile()
+	if got := os.Getenv("GRIST_TOKEN"); got != "env-token" {
+		t.Errorf("Expected env token to be kept, got %q", got)
+	}
+
+	t.Setenv("GRIST_TOKEN", "")
+	applyTokenFile()
+	if got := os.Getenv("GRIST_TOKEN"); got != "file-token" {
+		t.Errorf("Expected token from file, got %q", got)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"records": []}`))
+	})
+	defer cleanup()
+
+	SetTimeout(20 * time.Millisecond)
+	defer SetTimeout(DefaultTimeout)
+
+	_, status := GetRecords("doc123", "Table1", nil)
+	if status != -10 {
+		t.Errorf("Expected status -10 on timeout, got %d", status)
+	}
+}
+
+func TestHTTPClientReused(t *testing.T) {
+	if getHTTPClient() != getHTTPClient() {
+		t.Error("Expected the same client to be reused across requests")
+	}
+
+	SetTimeout(5 * time.Second)
+	defer SetTimeout(DefaultTimeout)
+	client := getHTTPClient()
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Expected timeout 5s, got %s", client.Timeout)
+	}
+	if client.Transport == nil {
+		t.Error("Expected the client to have a configured transport")
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"records": []}`))
+	})
+	defer cleanup()
+
+	transport := &countingTransport{}
+	SetHTTPClient(&http.Client{Transport: transport})
+	defer SetHTTPClient(nil)
+
+	GetRecords("doc123", "Table1", nil)
+	GetRecords("doc123", "Table1", nil)
+	if transport.requests != 2 {
+		t.Errorf("Expected 2 requests through the injected client, got %d", transport.requests)
+	}
+
+	SetHTTPClient(nil)
+	if getHTTPClient().Timeout != DefaultTimeout {
+		t.Errorf("Expected the default client to be restored, got timeout %s", getHTTPClient().Timeout)
+	}
+}
+
+func setupTLSMockServer(t *testing.T) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"records": []}`))
+	}))
+	oldURL := os.Getenv("GRIST_URL")
+	os.Setenv("GRIST_URL", server.URL)
+	t.Cleanup(func() {
+		server.Close()
+		os.Setenv("GRIST_URL", oldURL)
+		SetHTTPClient(nil)
+	})
+	return server
+}
+
+func TestSetCAFile(t *testing.T) {
+	server := setupTLSMockServer(t)
+
+	if _, status := GetRecords("doc123", "Table1", nil); status != -10 {
+		t.Errorf("Expected status -10 with an unknown CA, got %d", status)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCAFile(caFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, status := GetRecords("doc123", "Table1", nil); status != http.StatusOK {
+		t.Errorf("Expected status 200 with the CA trusted, got %d", status)
+	}
+}
+
+func TestSetCAFile_Invalid(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCAFile(caFile); err == nil {
+		t.Error("Expected an error for a file without certificates")
+	}
+	if err := SetCAFile(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestSetInsecureTLS(t *testing.T) {
+	setupTLSMockServer(t)
+
+	if err := SetInsecureTLS(true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, status := GetRecords("doc123", "Table1", nil); status != http.StatusOK {
+		t.Errorf("Expected status 200 without verification, got %d", status)
+	}
+}
+
+func TestNonJSONResponse(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Bad Gateway</body></html>"))
+	})
+	defer cleanup()
+
+	if _, status := GetRecords("doc123", "Table1", nil); status != StatusInvalidResponse {
+		t.Errorf("Expected status %d for an HTML response, got %d", StatusInvalidResponse, status)
+	}
+	if _, status := GetWorkspaceDocs(1); status != StatusInvalidResponse {
+		t.Errorf("Expected status %d for an HTML response, got %d", StatusInvalidResponse, status)
+	}
+	if orgs := GetOrgs(); len(orgs) != 0 {
+		t.Errorf("Expected no organizations, got %v", orgs)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		status   int
+		expected int
+	}{
+		{"valid", `{"records": []}`, http.StatusOK, http.StatusOK},
+		{"empty", "", http.StatusOK, StatusInvalidResponse},
+		{"html", "<html>error</html>", http.StatusBadGateway, StatusInvalidResponse},
+		{"unexpected shape", `[1, 2]`, http.StatusOK, StatusInvalidResponse},
+		{"json error", `{"error": "not found"}`, http.StatusNotFound, http.StatusNotFound},
+		{"request not sent", "Error sending request", -10, -10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := RecordsList{}
+			if got := decodeJSON(tt.response, tt.status, &records); got != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGzipResponse(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("Expected gzip to be accepted, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(`{"records": [{"id": 1, "fields": {"Name": "Alice"}}]}`))
+		gz.Close()
+	})
+	defer cleanup()
+
+	records, status := GetRecords("doc123", "Table1", nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(records.Records) != 1 || records.Records[0].Fields["Name"] != "Alice" {
+		t.Errorf("Expected decompressed records, got %+v", records)
+	}
+}
+
+func TestReadResponseBody_Gzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("Name,Age\nAlice,30\n"))
+	gz.Close()
+
+	// Response left compressed by the transport
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"gzip"}},
+		Body:   io.NopCloser(&compressed),
+	}
+	body, err := readResponseBody(resp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(body) != "Name,Age\nAlice,30\n" {
+		t.Errorf("Expected decompressed body, got %q", body)
+	}
+}
+
 func TestGetRecords(t *testing.T) {
 	expectedRecords := RecordsList{
 		Records: []Record{
@@ -220,12 +824,65 @@ func TestGetRecordsWithFilter(t *testing.T) {
 	}
 }
 
-func TestAddRecords(t *testing.T) {
-	expectedResponse := RecordsWithoutFields{
-		Records: []struct {
-			Id int `json:"id"`
-		}{{Id: 1}, {Id: 2}},
-	}
+func TestStreamRecords(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/tables/Table1/records" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "Table not found"}`))
+			return
+		}
+		if r.URL.Query().Get("sort") != "Name" {
+			t.Errorf("Expected sort parameter, got %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"meta": {"x": [1, 2]}, "records": [{"id": 1, "fields": {"Name": "Alice"}}, {"id": 2, "fields": {"Name": "Bob"}}, {"id": 3, "fields": {"Name": "Carol"}}]}`))
+	})
+	defer cleanup()
+
+	names := []string{}
+	status, err := StreamRecords("doc123", "Table1", &GetRecordsOptions{Sort: "Name"}, func(record Record) error {
+		names = append(names, record.Fields["Name"].(string))
+		return nil
+	})
+	if status != http.StatusOK || err != nil {
+		t.Fatalf("Expected status 200 without error, got %d and %v", status, err)
+	}
+	if strings.Join(names, ",") != "Alice,Bob,Carol" {
+		t.Errorf("Unexpected records: %v", names)
+	}
+
+	// The callback stops the stream
+	stop := fmt.Errorf("stop")
+	count := 0
+	_, err = StreamRecords("doc123", "Table1", &GetRecordsOptions{Sort: "Name"}, func(record Record) error {
+		count++
+		if record.Id == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 2 {
+		t.Errorf("Expected the callback error after 2 records, got %v after %d", err, count)
+	}
+
+	status, err = StreamRecords("doc123", "Missing", nil, func(Record) error { return nil })
+	if status != http.StatusNotFound || err == nil || !strings.Contains(err.Error(), "Table not found") {
+		t.Errorf("Expected a descriptive 404 error, got %d and %v", status, err)
+	}
+}
+
+func TestDecodeRecordsStream_Invalid(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`[{"id": 1}]`))
+	if err := decodeRecordsStream(decoder, func(Record) error { return nil }); err == nil {
+		t.Error("Expected an error for a response that isn't an object")
+	}
+}
+
+func TestAddRecords(t *testing.T) {
+	expectedResponse := RecordsWithoutFields{
+		Records: []struct {
+			Id int `json:"id"`
+		}{{Id: 1}, {Id: 2}},
+	}
 
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -253,7 +910,7 @@ func TestAddRecords(t *testing.T) {
 		{"name": "Alice", "age": 30},
 		{"name": "Bob", "age": 25},
 	}
-	result, status := AddRecords("doc123", "Table1", records, nil)
+	result, _, status := AddRecords("doc123", "Table1", records, nil)
 	if status != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", status)
 	}
@@ -275,12 +932,83 @@ func TestAddRecordsWithNoParse(t *testing.T) {
 	defer cleanup()
 
 	options := &AddRecordsOptions{NoParse: true}
-	_, status := AddRecords("doc123", "Table1", []map[string]interface{}{}, options)
+	_, _, status := AddRecords("doc123", "Table1", []map[string]interface{}{}, options)
 	if status != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", status)
 	}
 }
 
+func TestAddRecordsReturning(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			w.Write([]byte(`{"records": [{"id": 12}, {"id": 11}]}`))
+		case "GET":
+			if filter := r.URL.Query().Get("filter"); filter != `{"id":[12,11]}` {
+				t.Errorf("Expected a filter on the new IDs, got %s", filter)
+			}
+			w.Write([]byte(`{"records": [
+				{"id": 11, "fields": {"Name": "Bob", "Upper": "BOB"}},
+				{"id": 12, "fields": {"Name": "Alice", "Upper": "ALICE"}}
+			]}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	defer cleanup()
+
+	records := []map[string]interface{}{{"Name": "Alice"}, {"Name": "Bob"}}
+	result, _, status := AddRecordsReturning("doc123", "Table1", records, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(result) != 2 || result[0].Id != 12 || result[1].Id != 11 {
+		t.Fatalf("Expected records 12 and 11 in insertion order, got %+v", result)
+	}
+	if result[0].Fields["Upper"] != "ALICE" {
+		t.Errorf("Expected the computed formula value, got %v", result[0].Fields)
+	}
+}
+
+func TestAddRecordsReturning_Batches(t *testing.T) {
+	const count = 2*idFilterBatchSize + 1
+	gets := 0
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			added := make([]Record, count)
+			for i := range added {
+				added[i].Id = i + 1
+			}
+			json.NewEncoder(w).Encode(RecordsList{Records: added})
+			return
+		}
+		gets++
+		var filter map[string][]int
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filter")), &filter); err != nil {
+			t.Errorf("Invalid filter: %v", err)
+			return
+		}
+		if len(filter["id"]) > idFilterBatchSize {
+			t.Errorf("Expected at most %d IDs per request, got %d", idFilterBatchSize, len(filter["id"]))
+		}
+		fetched := RecordsList{}
+		for _, id := range filter["id"] {
+			fetched.Records = append(fetched.Records, Record{Id: id, Fields: map[string]interface{}{}})
+		}
+		json.NewEncoder(w).Encode(fetched)
+	})
+	defer cleanup()
+
+	records := make([]map[string]interface{}, count)
+	result, _, status := AddRecordsReturning("doc123", "Table1", records, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(result) != count || gets != 3 {
+		t.Errorf("Expected %d records fetched in 3 requests, got %d in %d", count, len(result), gets)
+	}
+}
+
 func TestUpdateRecords(t *testing.T) {
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "PATCH" {
@@ -313,6 +1041,123 @@ func TestUpdateRecords(t *testing.T) {
 	}
 }
 
+func TestValidateRecords(t *testing.T) {
+	columns := []TableColumn{
+		{Id: "BigNum", Fields: ColumnFields{Type: "Numeric"}},
+		{Id: "Count", Fields: ColumnFields{Type: "Int"}},
+		{Id: "Active", Fields: ColumnFields{Type: "Bool"}},
+		{Id: "DateField", Fields: ColumnFields{Type: "Date"}},
+		{Id: "Seen", Fields: ColumnFields{Type: "DateTime:Europe/Paris"}},
+		{Id: "Owner", Fields: ColumnFields{Type: "Ref:People"}},
+		{Id: "TextField", Fields: ColumnFields{Type: "Text"}},
+		{Id: "Tags", Fields: ColumnFields{Type: "ChoiceList"}},
+		{Id: "Birthday", Fields: ColumnFields{Type: "Date", WidgetOptions: `{"dateFormat": "DD/MM/YYYY"}`}},
+		{Id: "Meeting", Fields: ColumnFields{Type: "DateTime:UTC", WidgetOptions: `{"dateFormat": "MMM D, YYYY", "timeFormat": "h:mma"}`}},
+		{Id: "Signed", Fields: ColumnFields{Type: "Date", WidgetOptions: `{"dateFormat": "MMMM Do YYYY"}`}},
+	}
+
+	valid := map[string]interface{}{
+		"BigNum": 1e16, "Count": 42, "Active": true, "DateField": "2020-02-29",
+		"Seen": "2020-02-29T10:00:00Z", "Owner": "Alice", "TextField": "emoji: 🚀\n\t",
+		"Tags": []interface{}{"L", "a"},
+	}
+	tests := []struct {
+		name    string
+		fields  map[string]interface{}
+		parse   bool
+		wantErr string
+	}{
+		{"valid values", valid, true, ""},
+		{"empty values", map[string]interface{}{"Count": nil, "DateField": ""}, false, ""},
+		{"numeric strings parsed", map[string]interface{}{"BigNum": "-1.5", "Count": " 7 ", "Active": "false"}, true, ""},
+		{"encoded error", map[string]interface{}{"Count": []interface{}{"E", "TypeError"}}, false, ""},
+		{"text in Int", map[string]interface{}{"Count": "abc"}, true, `record 2: column Count (Int): invalid value "abc"`},
+		{"fraction in Int", map[string]interface{}{"Count": 1.5}, true, "record 2: column Count (Int): invalid value 1.5"},
+		{"string without parsing", map[string]interface{}{"BigNum": "12"}, false, `record 2: column BigNum (Numeric): invalid value "12"`},
+		{"number in Bool", map[string]interface{}{"Active": 2}, true, "record 2: column Active (Bool): invalid value 2"},
+		{"invalid leap day", map[string]interface{}{"DateField": "2021-02-29"}, true, `record 2: column DateField (Date): invalid value "2021-02-29"`},
+		{"time in Date", map[string]interface{}{"DateField": "2020-02-28 10:00"}, true, `record 2: column DateField (Date): invalid value "2020-02-28 10:00"`},
+		{"bool in Ref", map[string]interface{}{"Owner": true}, true, "record 2: column Owner (Ref:People): invalid value true"},
+		{"date in column format", map[string]interface{}{"Birthday": "29/02/2020", "Meeting": "Feb 29, 2020 9:30pm"}, true, ""},
+		{"ISO date with column format", map[string]interface{}{"Birthday": "2020-02-29", "Meeting": "2020-02-29 21:30"}, true, ""},
+		{"date out of column format", map[string]interface{}{"Birthday": "02/29/2020"}, true, `record 2: column Birthday (Date): invalid value "02/29/2020"`},
+		{"US date without column format", map[string]interface{}{"DateField": "02/29/2020"}, true, `record 2: column DateField (Date): invalid value "02/29/2020"`},
+		{"unsupported column format", map[string]interface{}{"Signed": "February 29th 2020"}, true, ""},
+		{"unknown column", map[string]interface{}{"Missing": 1}, true, "record 2: unknown column Missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRecords(columns, []map[string]interface{}{{"Count": 1}, tt.fields}, tt.parse)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			} else if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDateLayout(t *testing.T) {
+	tests := []struct {
+		format string
+		layout string
+		ok     bool
+	}{
+		{"YYYY-MM-DD", "2006-01-02", true},
+		{"MM/DD/YY", "01/02/06", true},
+		{"dddd, MMMM D YYYY", "Monday, January 2 2006", true},
+		{"HH:mm:ss", "15:04:05", true},
+		{"h:mma", "3:04pm", true},
+		{"[Week of] DD.MM", "Week of 02.01", true},
+		{"MMMM Do, YYYY", "", false},
+		{"Q YYYY", "", false},
+		{"[Day 1] DD", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			layout, ok := dateLayout(tt.format)
+			if ok != tt.ok || (ok && layout != tt.layout) {
+				t.Errorf("Expected %q %v, got %q %v", tt.layout, tt.ok, layout, ok)
+			}
+		})
+	}
+}
+
+func TestAddRecords_Validate(t *testing.T) {
+	posted := false
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`{"columns": [{"id": "Age", "fields": {"type": "Int"}}]}`))
+		default:
+			posted = true
+			w.Write([]byte(`{"records": [{"id": 1}]}`))
+		}
+	})
+	defer cleanup()
+
+	records := []map[string]interface{}{{"Age": 30}, {"Age": "thirty"}}
+	_, message, status := AddRecords("doc123", "People", records, &AddRecordsOptions{Validate: true})
+	if status != -1 || message != `record 2: column Age (Int): invalid value "thirty"` {
+		t.Errorf("Expected a validation error, got %d %q", status, message)
+	}
+	if posted {
+		t.Error("Expected no request for invalid records")
+	}
+
+	if _, _, status := AddRecords("doc123", "People", records[:1], &AddRecordsOptions{Validate: true}); status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+
+	message, status = UpdateRecords("doc123", "People", []Record{{Id: 1, Fields: records[1]}}, &UpdateRecordsOptions{Validate: true})
+	if status != -1 || message != `record 1: column Age (Int): invalid value "thirty"` {
+		t.Errorf("Expected a validation error, got %d %q", status, message)
+	}
+}
+
 func TestUpsertRecords(t *testing.T) {
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "PUT" {
@@ -380,6 +1225,47 @@ func TestUpsertRecordsWithOptions(t *testing.T) {
 	}
 }
 
+func TestUpsertByKey(t *testing.T) {
+	var received RecordsWithRequire
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("Expected PUT request, got %s", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer cleanup()
+
+	records := []map[string]interface{}{
+		{"Email": "a@example.com", "Org": "A", "Name": "Alice"},
+		{"Email": "b@example.com", "Org": "B", "Name": "Bob"},
+	}
+	_, status := UpsertByKey("doc123", "People", []string{"Email", "Org"}, records, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	expected := RecordWithRequire{
+		Require: map[string]interface{}{"Email": "b@example.com", "Org": "B"},
+		Fields:  map[string]interface{}{"Name": "Bob"},
+	}
+	if len(received.Records) != 2 || !reflect.DeepEqual(received.Records[1], expected) {
+		t.Errorf("Expected second record %+v, got %+v", expected, received.Records)
+	}
+
+	// A record without a key column is refused before any request
+	received = RecordsWithRequire{}
+	records = append(records, map[string]interface{}{"Email": "c@example.com", "Name": "Carol"})
+	message, status := UpsertByKey("doc123", "People", []string{"Email", "Org"}, records, nil)
+	if status != -1 || !strings.Contains(message, "record 3") || !strings.Contains(message, "Org") {
+		t.Errorf("Expected an error on record 3 missing Org, got %d %q", status, message)
+	}
+	if received.Records != nil {
+		t.Error("Expected no request to be sent")
+	}
+}
+
 func TestDeleteRecords(t *testing.T) {
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -410,6 +1296,181 @@ func TestDeleteRecords(t *testing.T) {
 	}
 }
 
+func TestDeleteRecords_Batches(t *testing.T) {
+	calls := 0
+	deleted := 0
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var ids []int
+		if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(ids) > deleteBatchSize {
+			t.Errorf("Expected batches of at most %d IDs, got %d", deleteBatchSize, len(ids))
+		}
+		switch {
+		case calls == 2:
+			// Deletions are retried without --retry-writes
+			w.WriteHeader(http.StatusBadGateway)
+		case ids[0] > 2*deleteBatchSize:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid row id"}`))
+		default:
+			deleted += len(ids)
+			w.Write([]byte(`null`))
+		}
+	})
+	defer cleanup()
+	defer SetRetries(maxRetries)
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+	SetRetries(1)
+
+	ids := make([]int, 2*deleteBatchSize+10)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	response, status := DeleteRecords("doc123", "Table1", ids)
+	if status != http.StatusBadRequest || !strings.Contains(response, "Invalid row id") {
+		t.Errorf("Expected the failed batch to be reported, got %d %q", status, response)
+	}
+	if calls != 4 || deleted != 2*deleteBatchSize {
+		t.Errorf("Expected 4 calls deleting %d records, got %d calls deleting %d", 2*deleteBatchSize, calls, deleted)
+	}
+}
+
+// The response of a deletion is lost and its retry fails on the rows the
+// first attempt deleted: the batch is deleted, unless rows are left
+func TestDeleteRecords_RetriedBatchApplied(t *testing.T) {
+	var mu sync.Mutex
+	rows := map[int]bool{1: true, 2: true, 3: true}
+	attempts := 0
+	firstApplied := true // Whether the first attempt, whose response is lost, deletes the rows
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			var filter map[string][]int
+			json.Unmarshal([]byte(r.URL.Query().Get("filter")), &filter)
+			records := []Record{}
+			for _, id := range filter["id"] {
+				if rows[id] {
+					records = append(records, Record{Id: id})
+				}
+			}
+			json.NewEncoder(w).Encode(RecordsList{Records: records})
+			return
+		}
+
+		attempts++
+		if attempts == 1 && !firstApplied {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var ids []int
+		json.NewDecoder(r.Body).Decode(&ids)
+		for _, id := range ids {
+			if !rows[id] {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "Invalid row id"}`))
+				return
+			}
+		}
+		for _, id := range ids {
+			delete(rows, id)
+		}
+		if attempts == 1 {
+			// Applied, but the response is lost
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`null`))
+	})
+	defer cleanup()
+	defer SetRetries(maxRetries)
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+	SetRetries(1)
+
+	if response, status := DeleteRecords("doc123", "Table1", []int{1, 2}); status != http.StatusOK {
+		t.Errorf("Expected the batch applied by the first attempt to be deleted, got %d %q", status, response)
+	}
+
+	// Row 9 never existed: the retry fails for good, row 3 being left
+	attempts, firstApplied = 0, false
+	if _, status := DeleteRecords("doc123", "Table1", []int{3, 9}); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a missing row, got %d", status)
+	}
+	if !rows[3] {
+		t.Error("Expected row 3 to be kept")
+	}
+}
+
+func TestUpdateRecords_DuplicateIds(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Records []Record `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(body.Records) != 2 {
+			t.Fatalf("Expected 2 merged records in request, got %d", len(body.Records))
+		}
+		first := body.Records[0]
+		if first.Id != 1 || first.Fields["name"] != "Alice Final" || first.Fields["age"] != float64(31) {
+			t.Errorf("Expected merged record 1, got %v", first)
+		}
+		if body.Records[1].Id != 2 {
+			t.Errorf("Expected second record ID 2, got %d", body.Records[1].Id)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+	defer cleanup()
+
+	records := []Record{
+		{Id: 1, Fields: map[string]interface{}{"name": "Alice"}},
+		{Id: 2, Fields: map[string]interface{}{"name": "Bob"}},
+		{Id: 1, Fields: map[string]interface{}{"name": "Alice Final", "age": 31}},
+	}
+	_, status := UpdateRecords("doc123", "Table1", records, nil)
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+}
+
+func TestDeleteRecords_DuplicateIds(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		var ids []int
+		if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(ids) != 3 || ids[0] != 3 || ids[1] != 1 || ids[2] != 2 {
+			t.Errorf("Expected IDs [3, 1, 2], got %v", ids)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+	defer cleanup()
+
+	_, status := DeleteRecords("doc123", "Table1", []int{3, 1, 3, 2, 1})
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+}
+
+func TestDedupeRecordIds(t *testing.T) {
+	unique, duplicates := dedupeRecordIds([]int{5, 5, 6, 5, 7})
+	if len(unique) != 3 {
+		t.Errorf("Expected 3 unique IDs, got %v", unique)
+	}
+	if len(duplicates) != 1 || duplicates[0] != 5 {
+		t.Errorf("Expected duplicates [5], got %v", duplicates)
+	}
+}
+
 // SCIM Bulk Operations Tests
 
 func TestSCIMBulk_ValidRequest(t *testing.T) {
@@ -609,6 +1670,31 @@ func TestSCIMBulk_FailOnErrors(t *testing.T) {
 	}
 }
 
+func TestSummarizeSCIMBulk(t *testing.T) {
+	request := SCIMBulkRequest{
+		FailOnErrors: 1,
+		Operations: []SCIMBulkOperation{
+			{Method: "POST", Path: "/Users", BulkId: "op1"},
+			{Method: "POST", Path: "/Users", BulkId: "op2"},
+			{Method: "POST", Path: "/Users", BulkId: "op3"},
+		},
+	}
+	response := SCIMBulkResponse{
+		Operations: []SCIMBulkOperationResponse{
+			{Method: "POST", BulkId: "op1", Status: "201"},
+			{Method: "POST", BulkId: "op2", Status: "409"},
+		},
+	}
+
+	summary := SummarizeSCIMBulk(request, response)
+	if summary.Total != 3 || summary.Succeeded != 1 || summary.Skipped != 1 {
+		t.Errorf("Expected 3 total, 1 succeeded and 1 skipped, got %+v", summary)
+	}
+	if len(summary.Failures) != 1 || summary.Failures[0].BulkId != "op2" || summary.Failures[0].Status != "409" {
+		t.Errorf("Expected op2 to fail with status 409, got %+v", summary.Failures)
+	}
+}
+
 func TestSCIMBulkFromJSON_ValidJSON(t *testing.T) {
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusCreated)
@@ -685,63 +1771,363 @@ func TestSCIMBulk_PUTOperation(t *testing.T) {
 	}
 }
 
-// Attachment API Tests
-
-func TestListAttachments(t *testing.T) {
-	expectedAttachments := AttachmentList{
-		Records: []AttachmentMetadata{
-			{Id: 1, FileName: "test.png", FileSize: 1024, TimeUploaded: "2024-01-15T10:30:00Z"},
-			{Id: 2, FileName: "doc.pdf", FileSize: 2048, TimeUploaded: "2024-01-16T11:00:00Z"},
-		},
-	}
-
+func TestSCIMBulk_Parallel(t *testing.T) {
+	var inFlight, maxInFlight int32
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "GET" {
-			t.Errorf("Expected GET request, got %s", r.Method)
-		}
-		if !contains(r.URL.Path, "/attachments") {
-			t.Errorf("Expected attachments endpoint, got %s", r.URL.Path)
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(expectedAttachments)
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "user"}`))
 	})
 	defer cleanup()
 
-	attachments, status := ListAttachments("doc123", nil)
+	request := SCIMBulkRequest{Schemas: []string{SCIMBulkRequestSchema}, Parallel: 3}
+	for i := 1; i <= 9; i++ {
+		request.Operations = append(request.Operations, SCIMBulkOperation{
+			Method: "POST",
+			Path:   "/Users",
+			BulkId: fmt.Sprintf("op%d", i),
+			Data:   map[string]interface{}{"userName": fmt.Sprintf("user%d", i)},
+		})
+	}
+
+	response, status := SCIMBulk(request)
 	if status != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", status)
+		t.Fatalf("Expected status 200, got %d", status)
 	}
-	if len(attachments.Records) != 2 {
-		t.Errorf("Expected 2 attachments, got %d", len(attachments.Records))
+	if len(response.Operations) != 9 {
+		t.Fatalf("Expected 9 operation responses, got %d", len(response.Operations))
 	}
-	if attachments.Records[0].FileName != "test.png" {
-		t.Errorf("Expected first attachment name 'test.png', got %s", attachments.Records[0].FileName)
+	for i, op := range response.Operations {
+		if op.BulkId != fmt.Sprintf("op%d", i+1) {
+			t.Errorf("Expected responses in request order, got %s at %d", op.BulkId, i)
+		}
+	}
+	if observed := atomic.LoadInt32(&maxInFlight); observed < 2 || observed > 3 {
+		t.Errorf("Expected between 2 and 3 concurrent operations, got %d", observed)
 	}
 }
 
-func TestListAttachmentsWithOptions(t *testing.T) {
+func TestSCIMBulk_ParallelFailOnErrors(t *testing.T) {
+	var callCount int32
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query()
-		if query.Get("sort") != "fileName" {
-			t.Errorf("Expected sort=fileName, got %s", query.Get("sort"))
-		}
-		if query.Get("limit") != "5" {
-			t.Errorf("Expected limit=5, got %s", query.Get("limit"))
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(AttachmentList{Records: []AttachmentMetadata{}})
+		atomic.AddInt32(&callCount, 1)
+		w.WriteHeader(http.StatusConflict)
 	})
 	defer cleanup()
 
-	options := &GetAttachmentsOptions{
-		Sort:  "fileName",
-		Limit: 5,
-	}
-	_, status := ListAttachments("doc123", options)
-	if status != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", status)
+	request := SCIMBulkRequest{Schemas: []string{SCIMBulkRequestSchema}, FailOnErrors: 1, Parallel: 2}
+	for i := 1; i <= 10; i++ {
+		request.Operations = append(request.Operations, SCIMBulkOperation{Method: "DELETE", Path: fmt.Sprintf("/Users/%d", i), BulkId: fmt.Sprintf("op%d", i)})
+	}
+
+	response, _ := SCIMBulk(request)
+	// Only the operations already running when the first error occurred complete
+	if len(response.Operations) > 2 {
+		t.Errorf("Expected at most 2 operation responses, got %d", len(response.Operations))
+	}
+	if int(atomic.LoadInt32(&callCount)) != len(response.Operations) {
+		t.Errorf("Expected one response per request sent, got %d responses for %d requests", len(response.Operations), callCount)
+	}
+	for i, op := range response.Operations {
+		if op.BulkId != fmt.Sprintf("op%d", i+1) {
+			t.Errorf("Expected responses in request order, got %s at %d", op.BulkId, i)
+		}
+	}
+}
+
+func TestSCIMServiceProviderConfig(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/scim/v2/ServiceProviderConfig" {
+			t.Errorf("Expected ServiceProviderConfig endpoint, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"],
+			"patch": {"supported": true},
+			"bulk": {"supported": true, "maxOperations": 1000, "maxPayloadSize": 1048576},
+			"filter": {"supported": true, "maxResults": 200},
+			"changePassword": {"supported": false},
+			"sort": {"supported": false},
+			"etag": {"supported": false},
+			"authenticationSchemes": [{"type": "oauthbearertoken", "name": "OAuth Bearer Token"}]
+		}`))
+	})
+	defer cleanup()
+
+	config, status := SCIMServiceProviderConfig()
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if !config.Bulk.Supported || config.Bulk.MaxOperations != 1000 || config.Bulk.MaxPayloadSize != 1048576 {
+		t.Errorf("Unexpected bulk config: %+v", config.Bulk)
+	}
+	if !config.Patch.Supported || config.Filter.MaxResults != 200 || config.Sort.Supported {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if len(config.AuthenticationSchemes) != 1 {
+		t.Errorf("Expected 1 authentication scheme, got %d", len(config.AuthenticationSchemes))
+	}
+}
+
+// SCIM User Tests
+
+func TestSCIMGetUser(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/api/scim/v2/Users/5" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "id": "5", "userName": "jane@example.com", "name": {"formatted": "Jane Doe"}, "emails": [{"value": "jane@example.com", "primary": true}], "active": true}`))
+	})
+	defer cleanup()
+
+	user, status := SCIMGetUser("5")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if user.Id != "5" || user.UserName != "jane@example.com" || user.Name.Formatted != "Jane Doe" || !user.Active {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+	if len(user.Emails) != 1 || !user.Emails[0].Primary {
+		t.Errorf("Unexpected emails: %+v", user.Emails)
+	}
+
+	if _, status := SCIMGetUser("404"); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+}
+
+func TestSCIMListUsers(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/scim/v2/Users" {
+			t.Errorf("Expected Users endpoint, got %s", r.URL.Path)
+		}
+		if filter := r.URL.Query().Get("filter"); filter != `userName eq "jane@example.com"` {
+			t.Errorf("Unexpected filter: %q", filter)
+		}
+		w.Write([]byte(`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"], "totalResults": 1, "itemsPerPage": 1, "startIndex": 1, "Resources": [{"id": "5", "userName": "jane@example.com"}]}`))
+	})
+	defer cleanup()
+
+	users, status := SCIMListUsers(`userName eq "jane@example.com"`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if users.TotalResults != 1 || len(users.Resources) != 1 || users.Resources[0].Id != "5" {
+		t.Errorf("Unexpected users: %+v", users)
+	}
+}
+
+func TestSCIMCreateUser(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+		var user SCIMUser
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(user.Schemas) != 1 || user.Schemas[0] != SCIMUserSchema {
+			t.Errorf("Expected user schema, got %v", user.Schemas)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "6", "userName": "joe@example.com", "active": true}`))
+	})
+	defer cleanup()
+
+	created, status := SCIMCreateUser(SCIMUser{
+		UserName: "joe@example.com",
+		Emails:   []SCIMEmail{{Value: "joe@example.com", Primary: true}},
+		Active:   true,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	if created.Id != "6" {
+		t.Errorf("Expected created user id 6, got %q", created.Id)
+	}
+}
+
+func TestSCIMDeleteUser(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/api/scim/v2/Users/6" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer cleanup()
+
+	if _, status := SCIMDeleteUser("6"); status != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", status)
+	}
+}
+
+// SCIM Group Tests
+
+func TestSCIMListGroups(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/scim/v2/Groups" {
+			t.Errorf("Expected Groups endpoint, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "3", "displayName": "Team", "members": [{"value": "5", "display": "Jane"}]}]}`))
+	})
+	defer cleanup()
+
+	groups, status := SCIMListGroups()
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(groups.Resources) != 1 || groups.Resources[0].DisplayName != "Team" || len(groups.Resources[0].Members) != 1 {
+		t.Errorf("Unexpected groups: %+v", groups)
+	}
+}
+
+func TestSCIMCreateGroup(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		var group SCIMGroup
+		if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(group.Schemas) != 1 || group.Schemas[0] != SCIMGroupSchema {
+			t.Errorf("Expected group schema, got %v", group.Schemas)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "4", "displayName": "New team", "members": []}`))
+	})
+	defer cleanup()
+
+	group, status := SCIMCreateGroup(SCIMGroup{DisplayName: "New team"})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	if group.Id != "4" {
+		t.Errorf("Expected group id 4, got %q", group.Id)
+	}
+}
+
+func TestSCIMPatchGroupMembers(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/api/scim/v2/Groups/3" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var request struct {
+			Schemas    []string `json:"schemas"`
+			Operations []struct {
+				Op    string       `json:"op"`
+				Path  string       `json:"path"`
+				Value []SCIMMember `json:"value"`
+			} `json:"Operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(request.Schemas) != 1 || request.Schemas[0] != SCIMPatchOpSchema {
+			t.Errorf("Expected PatchOp schema, got %v", request.Schemas)
+		}
+		if len(request.Operations) != 2 {
+			t.Fatalf("Expected 2 operations, got %d", len(request.Operations))
+		}
+		add := request.Operations[0]
+		if add.Op != "add" || add.Path != "members" || len(add.Value) != 2 || add.Value[1].Value != "6" {
+			t.Errorf("Unexpected add operation: %+v", add)
+		}
+		remove := request.Operations[1]
+		if remove.Op != "remove" || remove.Path != `members[value eq "7\" or value pr \\"]` {
+			t.Errorf("Unexpected remove operation: %+v", remove)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer cleanup()
+
+	// The quote of the id can't end the filter value
+	_, status := SCIMPatchGroupMembers("3", []string{"5", "6"}, []string{`7" or value pr \`})
+	if status != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", status)
+	}
+}
+
+func TestSCIMFilterValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"ann@example.com", `"ann@example.com"`},
+		{"", `""`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{`x" or userName pr "`, `"x\" or userName pr \""`},
+	}
+	for _, tt := range tests {
+		if got := SCIMFilterValue(tt.value); got != tt.expected {
+			t.Errorf("SCIMFilterValue(%q) = %s, expected %s", tt.value, got, tt.expected)
+		}
+	}
+}
+
+// Attachment API Tests
+
+func TestListAttachments(t *testing.T) {
+	expectedAttachments := AttachmentList{
+		Records: []AttachmentMetadata{
+			{Id: 1, FileName: "test.png", FileSize: 1024, TimeUploaded: "2024-01-15T10:30:00Z"},
+			{Id: 2, FileName: "doc.pdf", FileSize: 2048, TimeUploaded: "2024-01-16T11:00:00Z"},
+		},
+	}
+
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+		if !contains(r.URL.Path, "/attachments") {
+			t.Errorf("Expected attachments endpoint, got %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(expectedAttachments)
+	})
+	defer cleanup()
+
+	attachments, status := ListAttachments("doc123", nil)
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+	if len(attachments.Records) != 2 {
+		t.Errorf("Expected 2 attachments, got %d", len(attachments.Records))
+	}
+	if attachments.Records[0].FileName != "test.png" {
+		t.Errorf("Expected first attachment name 'test.png', got %s", attachments.Records[0].FileName)
+	}
+}
+
+func TestListAttachmentsWithOptions(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("sort") != "fileName" {
+			t.Errorf("Expected sort=fileName, got %s", query.Get("sort"))
+		}
+		if query.Get("limit") != "5" {
+			t.Errorf("Expected limit=5, got %s", query.Get("limit"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AttachmentList{Records: []AttachmentMetadata{}})
+	})
+	defer cleanup()
+
+	options := &GetAttachmentsOptions{
+		Sort:  "fileName",
+		Limit: 5,
+	}
+	_, status := ListAttachments("doc123", options)
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
 	}
 }
 
@@ -931,6 +2317,123 @@ func TestDownloadAttachmentToFileError(t *testing.T) {
 	}
 }
 
+func TestAttachmentFileName(t *testing.T) {
+	tests := []struct {
+		fileName string
+		expected string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{"dir/report.pdf", "report.pdf"},
+		{"", "attachment-7"},
+		{"..", "attachment-7"},
+		{"/", "attachment-7"},
+	}
+	for _, tt := range tests {
+		if got := AttachmentFileName(7, tt.fileName); got != tt.expected {
+			t.Errorf("AttachmentFileName(7, %q) = %q, expected %q", tt.fileName, got, tt.expected)
+		}
+	}
+}
+
+func TestExportDocGrist(t *testing.T) {
+	// SQLite header followed by bytes that are not valid UTF-8
+	expectedContent := []byte("SQLite format 3\x00\xff\xfe\x00")
+
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/download" {
+			t.Errorf("Expected download endpoint, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/x-sqlite3")
+		w.Write(expectedContent)
+	})
+	defer cleanup()
+
+	destPath := filepath.Join(t.TempDir(), "export.grist")
+	if err := ExportDocGrist("doc123", destPath); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	content, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read exported file: %v", err)
+	}
+	if !bytes.Equal(content, expectedContent) {
+		t.Errorf("Expected file content %q, got %q", expectedContent, content)
+	}
+}
+
+func TestExportDocExcel_Forbidden(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"No view access"}`))
+	})
+	defer cleanup()
+
+	destPath := filepath.Join(t.TempDir(), "export.xlsx")
+	err := ExportDocExcel("doc123", destPath, nil)
+	if err == nil {
+		t.Fatal("Expected error for forbidden export")
+	}
+	if !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "No view access") {
+		t.Errorf("Expected a descriptive error, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Error("Expected no file to be written on a failed export")
+	}
+}
+
+func TestExportTableCSV(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/download/csv" {
+			t.Errorf("Expected CSV download endpoint, got %s", r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("tableId") != "People" || query.Get("viewSection") != "4" || query.Get("header") != "colId" {
+			t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+		}
+		if query.Get("filters") != `[{"colRef": 2, "filter": "{\"included\": [\"A\"]}"}]` {
+			t.Errorf("Unexpected filters: %s", query.Get("filters"))
+		}
+		if query.Get("sortOrder") != "[2,-3]" {
+			t.Errorf("Unexpected sort order: %s", query.Get("sortOrder"))
+		}
+		w.Write([]byte("Name,Age\nAlice,30\n"))
+	})
+	defer cleanup()
+
+	options := &ExportOptions{
+		TableId:       "Ignored",
+		ViewSectionId: 4,
+		SortOrder:     []int{2, -3},
+		Filters:       `[{"colRef": 2, "filter": "{\"included\": [\"A\"]}"}]`,
+		Header:        "colId",
+	}
+	destPath := filepath.Join(t.TempDir(), "people.csv")
+	if err := ExportTableCSV("doc123", "People", destPath, options); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	content, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read exported file: %v", err)
+	}
+	if string(content) != "Name,Age\nAlice,30\n" {
+		t.Errorf("Unexpected file content %q", content)
+	}
+}
+
+func TestExportQueryParams(t *testing.T) {
+	if params := exportQueryParams(nil); params != "" {
+		t.Errorf("Expected no parameters, got %q", params)
+	}
+	if params := exportQueryParams(&ExportOptions{}); params != "" {
+		t.Errorf("Expected no parameters, got %q", params)
+	}
+	if params := exportQueryParams(&ExportOptions{TableId: "My Table"}); params != "?tableId=My+Table" {
+		t.Errorf("Expected escaped table parameter, got %q", params)
+	}
+}
+
 func TestRestoreAttachments(t *testing.T) {
 	expectedResponse := RestoreAttachmentsResponse{
 		Added:   5,
@@ -1026,6 +2529,42 @@ func TestDeleteUnusedAttachments(t *testing.T) {
 	}
 }
 
+func TestFindUnusedAttachments(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/attachments":
+			w.Write([]byte(`{"records": [
+				{"id": 1, "fileName": "a.png", "fileSize": 100},
+				{"id": 2, "fileName": "b.pdf", "fileSize": 200},
+				{"id": 3, "fileName": "c.txt", "fileSize": 300}
+			]}`))
+		case "/api/docs/doc123/tables":
+			w.Write([]byte(`{"tables": [{"id": "Files"}, {"id": "Notes"}]}`))
+		case "/api/docs/doc123/tables/Files/columns":
+			w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"type": "Text"}}, {"id": "Scan", "fields": {"type": "Attachments"}}]}`))
+		case "/api/docs/doc123/tables/Notes/columns":
+			w.Write([]byte(`{"columns": [{"id": "Text", "fields": {"type": "Text"}}]}`))
+		case "/api/docs/doc123/tables/Files/records":
+			w.Write([]byte(`{"records": [
+				{"id": 1, "fields": {"Name": "x", "Scan": ["L", 2]}},
+				{"id": 2, "fields": {"Name": "y", "Scan": null}}
+			]}`))
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	unused, status := FindUnusedAttachments("doc123")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(unused) != 2 || unused[0].Id != 1 || unused[1].Id != 3 {
+		t.Errorf("Expected attachments 1 and 3 to be unused, got %+v", unused)
+	}
+}
+
 // Helper function for string contains check
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
@@ -1218,19 +2757,152 @@ func TestCreateWebhooks_SingleWebhook(t *testing.T) {
 	}
 }
 
-func TestUpdateWebhook(t *testing.T) {
+func TestCreateWebhooks_MissingURL(t *testing.T) {
 	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "PATCH" {
-			t.Errorf("Expected PATCH request, got %s", r.Method)
-		}
-		if r.URL.Path != "/api/docs/doc123/webhooks/webhook-456" {
-			t.Errorf("Expected /api/docs/doc123/webhooks/webhook-456, got %s", r.URL.Path)
-		}
+		t.Errorf("Expected no request, got %s %s", r.Method, r.URL.Path)
+	})
+	defer cleanup()
 
-		var body WebhookPartialFields
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			t.Errorf("Failed to decode request body: %v", err)
-		}
+	url := "https://example.com/hook"
+	tableId := "Table1"
+	eventTypes := []string{"add"}
+	badURL := "example.com/hook"
+	webhooks := []WebhookPartialFields{
+		{URL: &url, TableId: &tableId, EventTypes: &eventTypes},
+		{TableId: &tableId, EventTypes: &eventTypes},
+		{URL: &url, EventTypes: &[]string{}},
+		{URL: &badURL, TableId: &tableId, EventTypes: &[]string{"add", "delete"}},
+	}
+
+	err := ValidateWebhooksCreate(webhooks)
+	expected := "webhook 2: missing url\nwebhook 3: missing tableId\nwebhook 3: missing eventTypes\n" +
+		`webhook 4: invalid url "example.com/hook" (expected http or https)` + "\n" +
+		`webhook 4: unknown event type "delete" (expected add or update)`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+	if err := ValidateWebhooksCreate(webhooks[:1]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, status := CreateWebhooks("doc123", webhooks); status != -1 {
+		t.Errorf("Expected status -1, got %d", status)
+	}
+	if _, _, err := CreateWebhookIfNotExists("doc123", webhooks[1]); err == nil || !strings.Contains(err.Error(), "missing url") {
+		t.Errorf("Expected a missing url error, got %v", err)
+	}
+}
+
+func TestCreateWebhookIfNotExists_Exists(t *testing.T) {
+	existing := WebhooksList{
+		Webhooks: []Webhook{
+			{
+				Id: "webhook-existing",
+				Fields: WebhookFields{
+					URL:        "https://example.com/hook",
+					TableId:    "Table1",
+					EventTypes: []string{"update", "add"},
+				},
+			},
+		},
+	}
+
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected only GET requests, got %s", r.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(existing)
+	})
+	defer cleanup()
+
+	url := "https://example.com/hook"
+	tableId := "Table1"
+	eventTypes := []string{"add", "update"}
+
+	id, created, err := CreateWebhookIfNotExists("doc123", WebhookPartialFields{
+		URL:        &url,
+		TableId:    &tableId,
+		EventTypes: &eventTypes,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if created {
+		t.Error("Expected webhook not to be created")
+	}
+	if id.Id != "webhook-existing" {
+		t.Errorf("Expected webhook ID 'webhook-existing', got %s", id.Id)
+	}
+}
+
+func TestCreateWebhookIfNotExists_Creates(t *testing.T) {
+	existing := WebhooksList{
+		Webhooks: []Webhook{
+			{
+				Id: "webhook-other",
+				Fields: WebhookFields{
+					URL:        "https://example.com/hook",
+					TableId:    "Table1",
+					EventTypes: []string{"add"},
+				},
+			},
+		},
+	}
+
+	posted := false
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(existing)
+		case "POST":
+			posted = true
+			var body WebhooksCreateRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			if len(body.Webhooks) != 1 {
+				t.Errorf("Expected 1 webhook in request, got %d", len(body.Webhooks))
+			}
+			json.NewEncoder(w).Encode(WebhooksCreateResponse{Webhooks: []WebhookId{{Id: "webhook-new"}}})
+		default:
+			t.Errorf("Unexpected %s request", r.Method)
+		}
+	})
+	defer cleanup()
+
+	url := "https://example.com/hook"
+	tableId := "Table1"
+	eventTypes := []string{"add", "update"}
+
+	id, created, err := CreateWebhookIfNotExists("doc123", WebhookPartialFields{
+		URL:        &url,
+		TableId:    &tableId,
+		EventTypes: &eventTypes,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !created || !posted {
+		t.Error("Expected webhook to be created")
+	}
+	if id.Id != "webhook-new" {
+		t.Errorf("Expected webhook ID 'webhook-new', got %s", id.Id)
+	}
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Expected PATCH request, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/docs/doc123/webhooks/webhook-456" {
+			t.Errorf("Expected /api/docs/doc123/webhooks/webhook-456, got %s", r.URL.Path)
+		}
+
+		var body WebhookPartialFields
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
 		if body.Enabled == nil || *body.Enabled != false {
 			t.Errorf("Expected enabled=false, got %v", body.Enabled)
 		}
@@ -1398,3 +3070,2285 @@ func TestWebhookUsage_WithAllFields(t *testing.T) {
 		t.Errorf("Expected lastEventBatch.size=10, got %v", usage.LastEventBatch)
 	}
 }
+
+func TestTriggerWebhookTest(t *testing.T) {
+	webhookTestPollInterval = time.Millisecond
+	defer func() { webhookTestPollInterval = 500 * time.Millisecond }()
+
+	before := int64(1000)
+	after := int64(2000)
+	httpStatus := 200
+	ready := "Ready"
+	webhook := Webhook{
+		Id: "wh1",
+		Fields: WebhookFields{
+			TableId:       "Table1",
+			EventTypes:    []string{"add"},
+			IsReadyColumn: &ready,
+		},
+		Usage: &WebhookUsage{LastSuccessTime: &before},
+	}
+
+	var added map[string]interface{}
+	var deleted []int
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/docs/doc123/webhooks":
+			json.NewEncoder(w).Encode(WebhooksList{Webhooks: []Webhook{webhook}})
+		case r.Method == "POST" && r.URL.Path == "/api/docs/doc123/tables/Table1/records":
+			var body struct {
+				Records []struct {
+					Fields map[string]interface{} `json:"fields"`
+				} `json:"records"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			added = body.Records[0].Fields
+			// The event is delivered once the record is added
+			webhook.Usage = &WebhookUsage{LastSuccessTime: &after, LastHttpStatus: &httpStatus}
+			w.Write([]byte(`{"records": [{"id": 42}]}`))
+		case r.Method == "POST" && r.URL.Path == "/api/docs/doc123/tables/Table1/records/delete":
+			json.NewDecoder(r.Body).Decode(&deleted)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	defer cleanup()
+
+	result, err := TriggerWebhookTest("doc123", "wh1", time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Delivered || result.Failed {
+		t.Errorf("Expected a delivered event, got %+v", result)
+	}
+	if added["Ready"] != true {
+		t.Errorf("Expected the ready column to be set, got %v", added)
+	}
+	if len(deleted) != 1 || deleted[0] != 42 {
+		t.Errorf("Expected the test record to be deleted, got %v", deleted)
+	}
+}
+
+func TestTriggerWebhookTest_NotDelivered(t *testing.T) {
+	webhookTestPollInterval = time.Millisecond
+	defer func() { webhookTestPollInterval = 500 * time.Millisecond }()
+
+	var deleted bool
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/webhooks":
+			json.NewEncoder(w).Encode(WebhooksList{Webhooks: []Webhook{
+				{Id: "wh1", Fields: WebhookFields{TableId: "Table1", EventTypes: []string{"add"}}},
+			}})
+		case "/api/docs/doc123/tables/Table1/records":
+			w.Write([]byte(`{"records": [{"id": 1}]}`))
+		case "/api/docs/doc123/tables/Table1/records/delete":
+			deleted = true
+		}
+	})
+	defer cleanup()
+
+	result, err := TriggerWebhookTest("doc123", "wh1", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Delivered || result.Failed {
+		t.Errorf("Expected no delivery, got %+v", result)
+	}
+	if !deleted {
+		t.Error("Expected the test record to be deleted")
+	}
+}
+
+func TestTriggerWebhookTest_UnknownWebhook(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected no record to be added, got %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"webhooks": []}`))
+	})
+	defer cleanup()
+
+	if _, err := TriggerWebhookTest("doc123", "missing", time.Second); err == nil {
+		t.Error("Expected an error for an unknown webhook")
+	}
+}
+
+func TestGetDocUsage(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/usage" {
+			t.Errorf("Expected /api/docs/doc123/usage, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"usage": {"rowCount": {"total": 42}, "dataSizeBytes": 2048, "attachmentsSizeBytes": "hidden"}}`))
+	})
+	defer cleanup()
+
+	usage, status := GetDocUsage("doc123")
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+	if usage.RowCount != 42 {
+		t.Errorf("Expected 42 rows, got %d", usage.RowCount)
+	}
+	if usage.DataSizeBytes != 2048 {
+		t.Errorf("Expected 2048 data bytes, got %d", usage.DataSizeBytes)
+	}
+	if usage.AttachmentsSizeBytes != 0 {
+		t.Errorf("Expected hidden attachments size to be 0, got %d", usage.AttachmentsSizeBytes)
+	}
+}
+
+func TestGetOrgDocsUsage(t *testing.T) {
+	sizes := map[string]int{"small": 10, "big": 5000, "medium": 300}
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/orgs/1/workspaces" {
+			docs := []Doc{{Id: "small", Name: "Small"}, {Id: "big", Name: "Big"}, {Id: "locked", Name: "Locked"}}
+			for i := range 20 {
+				docs = append(docs, Doc{Id: fmt.Sprintf("empty%d", i)})
+			}
+			json.NewEncoder(w).Encode([]Workspace{
+				{Id: 1, Name: "ws1", Docs: docs},
+				{Id: 2, Name: "ws2", Docs: []Doc{{Id: "medium", Name: "Medium"}}},
+			})
+			return
+		}
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(time.Millisecond)
+
+		docId := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/docs/"), "/usage")
+		if docId == "locked" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"usage": {"rowCount": 1, "dataSizeBytes": %d, "attachmentsSizeBytes": 0}}`, sizes[docId])
+	})
+	defer cleanup()
+
+	entries := GetOrgDocsUsage(1)
+	if len(entries) != 24 {
+		t.Fatalf("Expected 24 entries, got %d", len(entries))
+	}
+	if maxInFlight > maxConcurrentRequests {
+		t.Errorf("Expected at most %d requests at a time, got %d", maxConcurrentRequests, maxInFlight)
+	}
+	if last := entries[len(entries)-1]; last.DocId != "locked" || last.Status != http.StatusForbidden {
+		t.Errorf("Expected the failed document last with its status, got %+v", last)
+	}
+	expected := []string{"big", "medium", "small"}
+	for i, id := range expected {
+		if entries[i].DocId != id {
+			t.Errorf("Entry %d: expected %s, got %s", i, id, entries[i].DocId)
+		}
+	}
+	if entries[1].WorkspaceName != "ws2" {
+		t.Errorf("Expected workspace 'ws2' for medium doc, got %s", entries[1].WorkspaceName)
+	}
+}
+
+func TestGetDoc_Metadata(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "doc123", "name": "Budget", "urlId": "budget",
+			"createdAt": "2024-01-15T10:30:00.000Z", "updatedAt": "2024-03-02T08:15:42.123Z"}`))
+	})
+	defer cleanup()
+
+	doc, status := GetDoc("doc123")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if doc.UrlId != "budget" || doc.CreatedAt != "2024-01-15T10:30:00.000Z" {
+		t.Errorf("Unexpected document metadata: %+v", doc)
+	}
+	expected := time.Date(2024, 3, 2, 8, 15, 42, 123000000, time.UTC)
+	if !doc.LastModified().Equal(expected) {
+		t.Errorf("Expected last modification at %v, got %v", expected, doc.LastModified())
+	}
+	if !(Doc{}).LastModified().IsZero() {
+		t.Error("Expected a zero time without updatedAt")
+	}
+}
+
+func TestGetDoc_NotFound(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/docs/doc123" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Doc{Id: "doc123", Name: ""})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "document not found"}`))
+	})
+	defer cleanup()
+
+	doc, status := GetDoc("missing")
+	if status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+	if doc.Id != "" {
+		t.Errorf("Expected empty document, got %v", doc)
+	}
+	if DocExists("missing") {
+		t.Error("Expected missing document not to exist")
+	}
+	// An unnamed document still exists
+	if !DocExists("doc123") {
+		t.Error("Expected unnamed document to exist")
+	}
+}
+
+func TestGetWorkspaceDocs(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/workspaces/42" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": 42, "name": "Team", "org": {"id": 1, "name": "Org"}, "docs": [{"id": "doc1", "name": "First"}, {"id": "doc2", "name": "Second"}]}`))
+	})
+	defer cleanup()
+
+	docs, status := GetWorkspaceDocs(42)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(docs) != 2 || docs[0].Id != "doc1" || docs[1].Name != "Second" {
+		t.Errorf("Unexpected documents: %+v", docs)
+	}
+
+	docs, status = GetWorkspaceDocs(7)
+	if status != http.StatusNotFound || docs != nil {
+		t.Errorf("Expected no documents and status 404, got %v and %d", docs, status)
+	}
+}
+
+func TestDeleteDoc(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Expected DELETE request, got %s", r.Method)
+		}
+		if r.URL.Path == "/api/docs/doc123" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"No access"}`))
+	})
+	defer cleanup()
+
+	if err := DeleteDoc("doc123"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	err := DeleteDoc("locked")
+	if err == nil {
+		t.Fatal("Expected an error on a forbidden delete")
+	}
+	if !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected error to mention the status, got %v", err)
+	}
+}
+
+func TestDeleteWorkspaceAndUser(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Expected DELETE request, got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/api/workspaces/1", "/api/users/1":
+			w.WriteHeader(http.StatusOK)
+		case "/api/users/2":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+	defer cleanup()
+
+	if err := DeleteWorkspace(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := DeleteWorkspace(2); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected error to mention the status, got %v", err)
+	}
+	if err := DeleteUser(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := DeleteUser(2); err == nil || !strings.Contains(err.Error(), "the user is not found") {
+		t.Errorf("Expected error to explain the status, got %v", err)
+	}
+}
+
+func TestMoveDoc(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Expected PATCH request, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/docs/doc123/move" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer cleanup()
+
+	if err := MoveDoc("doc123", 2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := MoveDoc("missing", 2); err == nil {
+		t.Error("Expected an error when moving a missing document")
+	}
+}
+
+func TestMoveAllDocs(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/workspaces/1":
+			w.Write([]byte(`{"id": 1, "name": "From", "docs": [{"id": "doc1"}, {"id": "doc2"}]}`))
+		case r.URL.Path == "/api/workspaces/2":
+			w.Write([]byte(`{"id": 2, "name": "To", "docs": []}`))
+		case r.URL.Path == "/api/docs/doc1/move":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	results, err := MoveAllDocs(1, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].DocId != "doc1" || !results[0].Success {
+		t.Errorf("Expected doc1 to be moved, got %+v", results[0])
+	}
+	if results[1].DocId != "doc2" || results[1].Success || results[1].Error == "" {
+		t.Errorf("Expected doc2 to fail with an error, got %+v", results[1])
+	}
+
+	if _, err := MoveAllDocs(1, 3); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error when the target workspace is missing, got %v", err)
+	}
+}
+
+func TestGetOrgStatus(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orgs/1":
+			w.Write([]byte(`{"id": 1, "name": "Main", "domain": "main"}`))
+		case "/api/orgs/2":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	if org, status := GetOrg("1"); status != http.StatusOK || org.Name != "Main" {
+		t.Errorf("Expected organization Main with status 200, got %+v (%d)", org, status)
+	}
+	if org, status := GetOrg("3"); status != http.StatusNotFound || org.Id != 0 {
+		t.Errorf("Expected an empty organization with status 404, got %+v (%d)", org, status)
+	}
+	if _, status := GetOrg("2"); status != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", status)
+	}
+}
+
+func TestGetOrg_Domain(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/orgs/acme", "/api/orgs/current":
+			w.Write([]byte(`{"id": 3, "name": "Acme", "domain": "acme"}`))
+		case "/api/orgs/docs":
+			w.Write([]byte(`{"id": 2, "name": "Personal", "domain": "docs-5"}`))
+		case "/api/orgs/a%2Fb":
+			w.Write([]byte(`{"id": 4, "name": "Escaped", "domain": "a/b"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	tests := []struct {
+		domain   string
+		expected int
+		status   int
+	}{
+		{"acme", 3, http.StatusOK},
+		{"current", 3, http.StatusOK},
+		{"docs", 2, http.StatusOK},
+		{"a/b", 4, http.StatusOK},
+		{"unknown", 0, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if org, status := GetOrg(tt.domain); org.Id != tt.expected || status != tt.status {
+			t.Errorf("GetOrg(%q) = %d (%d), expected %d (%d)", tt.domain, org.Id, status, tt.expected, tt.status)
+		}
+	}
+}
+
+func TestGetWorkspaceStatus(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/workspaces/1":
+			w.Write([]byte(`{"id": 1, "name": "Main"}`))
+		case "/api/workspaces/2":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	if ws, status := GetWorkspace(1); status != http.StatusOK || ws.Name != "Main" {
+		t.Errorf("Expected workspace Main with status 200, got %+v (%d)", ws, status)
+	}
+	if _, status := GetWorkspace(3); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+
+	_, err := MoveAllDocs(2, 1)
+	if err == nil || strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a server error distinct from not found, got %v", err)
+	}
+}
+
+func TestPurgeDoc_Error(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer cleanup()
+
+	if err := PurgeDoc("doc123", 3); err == nil {
+		t.Error("Expected an error when the purge fails")
+	}
+}
+
+func TestDocHistory(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/states":
+			w.Write([]byte(`{"states": [{"n": 12, "h": "c3"}, {"n": 11, "h": "b2"}, {"n": 10, "h": "a1"}]}`))
+		case "/api/docs/doc123/compare":
+			if r.URL.Query().Get("left") != "b2" || r.URL.Query().Get("right") != "c3" {
+				t.Errorf("Unexpected comparison %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"left": {"n": 11, "h": "b2"}, "right": {"n": 12, "h": "c3"}, "parent": {"n": 11, "h": "b2"},
+				"summary": "right", "details": {"leftChanges": {"tableRenames": [], "tableDeltas": {}},
+				"rightChanges": {"tableRenames": [[null, "Orders"]], "tableDeltas": {
+					"People": {"addRows": [4, 5], "updateRows": [1], "removeRows": []},
+					"Orders": {"addRows": [1], "updateRows": [], "removeRows": []}}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	states, status := GetDocStates("doc123")
+	if status != http.StatusOK || len(states) != 3 || states[0] != (DocState{N: 12, Hash: "c3"}) {
+		t.Fatalf("Unexpected states: %d %v", status, states)
+	}
+
+	comparison, status := CompareDocStates("doc123", "b2", "c3")
+	if status != http.StatusOK || comparison.Summary != "right" || comparison.Details == nil {
+		t.Fatalf("Unexpected comparison: %d %+v", status, comparison)
+	}
+	expected := "Orders: created; Orders: 1 added; People: 2 added, 1 updated"
+	if summary := comparison.Details.RightChanges.String(); summary != expected {
+		t.Errorf("Expected summary %q, got %q", expected, summary)
+	}
+	if summary := comparison.Details.LeftChanges.String(); summary != "" {
+		t.Errorf("Expected no left changes, got %q", summary)
+	}
+
+	if _, status := GetDocStates("missing"); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+}
+
+func TestParseCreatedId(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected int
+		wantErr  bool
+	}{
+		{"42", 42, false},
+		{" 42\n", 42, false},
+		{`"42"`, 42, false},
+		{`{"id": 42}`, 42, false},
+		{`{"id": "42"}`, 42, false},
+		{`"abc"`, 0, true},
+		{`{"name": "Team"}`, 0, true},
+		{"<html>", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		id, err := parseCreatedId(tt.body)
+		if id != tt.expected || (err != nil) != tt.wantErr {
+			t.Errorf("parseCreatedId(%q) = %d, %v; expected %d (error: %v)", tt.body, id, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateOrgAndWorkspace(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orgs":
+			w.Write([]byte(`{"id": 5}`))
+		case "/api/orgs/5/workspaces":
+			w.Write([]byte(`"8"`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "access denied"}`))
+		}
+	})
+	defer cleanup()
+
+	if id, err := CreateOrg("Team", "team"); id != 5 || err != nil {
+		t.Errorf("Expected organization 5, got %d, %v", id, err)
+	}
+	if id, err := CreateWorkspace(5, "Projects"); id != 8 || err != nil {
+		t.Errorf("Expected workspace 8, got %d, %v", id, err)
+	}
+	_, err := CreateWorkspace(6, "Projects")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 403: access denied") {
+		t.Errorf("Expected an access denied error, got %v", err)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 7, "name": "Team"}]`))
+		case r.Method == "PATCH" && r.URL.Path == "/api/workspaces/7/access":
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	wsId, err := ImportUsers(1, "Team", []UserRole{{Email: "a@example.com", Role: "editors"}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if wsId != 7 {
+		t.Errorf("Expected workspace 7, got %d", wsId)
+	}
+}
+
+func TestImportUsers_Normalized(t *testing.T) {
+	var delta struct {
+		Delta struct {
+			Users map[string]string `json:"users"`
+		} `json:"delta"`
+	}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 7, "name": "Team"}]`))
+		case r.Method == "PATCH" && r.URL.Path == "/api/workspaces/7/access":
+			if err := json.NewDecoder(r.Body).Decode(&delta); err != nil {
+				t.Errorf("Invalid JSON body: %v", err)
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	users := []UserRole{
+		{Email: "a@example.com", Role: "viewers"},
+		{Email: " A@Example.com ", Role: "editors"},
+		{Email: `quote"d@example.com`, Role: "viewers"},
+	}
+	if _, err := ImportUsers(1, "Team", users); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"a@example.com": "editors", `quote"d@example.com`: "viewers"}
+	if !reflect.DeepEqual(delta.Delta.Users, expected) {
+		t.Errorf("Expected users %v, got %v", expected, delta.Delta.Users)
+	}
+}
+
+func TestRequestBodiesAreEscaped(t *testing.T) {
+	bodies := map[string]map[string]any{}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid JSON body for %s: %v", r.URL.Path, err)
+		}
+		bodies[r.URL.Path] = body
+		switch r.URL.Path {
+		case "/api/orgs", "/api/orgs/1/workspaces":
+			w.Write([]byte(`5`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	defer cleanup()
+
+	name := `Evil", "injected": "yes`
+	CreateOrg(name, "evil")
+	CreateWorkspace(1, name)
+	MoveDoc("doc1", 3)
+	PurgeDoc("doc1", 2)
+
+	if bodies["/api/orgs"]["name"] != name || bodies["/api/orgs"]["injected"] != nil {
+		t.Errorf("Org name not escaped: %v", bodies["/api/orgs"])
+	}
+	if bodies["/api/orgs/1/workspaces"]["name"] != name {
+		t.Errorf("Workspace name not escaped: %v", bodies["/api/orgs/1/workspaces"])
+	}
+	if bodies["/api/docs/doc1/move"]["workspace"] != float64(3) {
+		t.Errorf("Unexpected move body: %v", bodies["/api/docs/doc1/move"])
+	}
+	if bodies["/api/docs/doc1/states/remove"]["keep"] != float64(2) {
+		t.Errorf("Unexpected purge body: %v", bodies["/api/docs/doc1/states/remove"])
+	}
+}
+
+func TestCountRecords(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/tables/Table1/data" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": [1, 2, 3], "name": ["a", "b", "c"]}`))
+	})
+	defer cleanup()
+
+	count, status := CountRecords("doc123", "Table1")
+	if status != http.StatusOK || count != 3 {
+		t.Errorf("Expected 3 rows with status 200, got %d (status %d)", count, status)
+	}
+	count, status = CountRecords("doc123", "Missing")
+	if status != http.StatusNotFound || count != 0 {
+		t.Errorf("Expected 0 rows with status 404, got %d (status %d)", count, status)
+	}
+}
+
+func TestCountRecords_SQL(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/sql" {
+			t.Errorf("Expected only the SQL endpoint to be used, got %s", r.URL.Path)
+			return
+		}
+		var body struct {
+			SQL string `json:"sql"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.SQL != `SELECT COUNT(*) AS count FROM "Table1"` {
+			t.Errorf("Unexpected query: %s", body.SQL)
+		}
+		w.Write([]byte(`{"statement": "", "records": [{"fields": {"count": 1234}}]}`))
+	})
+	defer cleanup()
+
+	count, status := CountRecords("doc123", "Table1")
+	if status != http.StatusOK || count != 1234 {
+		t.Errorf("Expected 1234 rows with status 200, got %d (status %d)", count, status)
+	}
+}
+
+func TestCompareDocsData(t *testing.T) {
+	tables := map[string]string{
+		"docA": `{"tables": [{"id": "People"}, {"id": "Orders"}, {"id": "Old"}]}`,
+		"docB": `{"tables": [{"id": "People"}, {"id": "Orders"}, {"id": "New"}]}`,
+	}
+	records := map[string]string{
+		"docA/People": `{"records": [{"id": 1, "fields": {"Name": "Alice", "Age": 30}}, {"id": 2, "fields": {"Name": "Bob", "Age": 25}}]}`,
+		"docB/People": `{"records": [{"id": 7, "fields": {"Age": 25, "Name": "Bob"}}, {"id": 9, "fields": {"Name": "Alice", "Age": 30}}]}`,
+		"docA/Orders": `{"records": [{"id": 1, "fields": {"Total": 10}}]}`,
+		"docB/Orders": `{"records": [{"id": 1, "fields": {"Total": 10}}, {"id": 2, "fields": {"Total": 20}}]}`,
+	}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/docs/"), "/")
+		switch {
+		case len(parts) == 2 && parts[1] == "tables":
+			w.Write([]byte(tables[parts[0]]))
+		case len(parts) == 2 && parts[1] == "sql":
+			var body struct {
+				SQL string `json:"sql"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			tableId := strings.Trim(strings.TrimPrefix(body.SQL, "SELECT COUNT(*) AS count FROM "), `"`)
+			var table RecordsList
+			json.Unmarshal([]byte(records[parts[0]+"/"+tableId]), &table)
+			fmt.Fprintf(w, `{"records": [{"fields": {"count": %d}}]}`, len(table.Records))
+		case len(parts) == 4 && parts[3] == "records":
+			w.Write([]byte(records[parts[0]+"/"+parts[2]]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	comparison, err := CompareDocsData("docA", "docB", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := DataComparison{
+		Tables: []TableDataComparison{
+			{TableId: "People", RowsA: 2, RowsB: 2, Match: true},
+			{TableId: "Orders", RowsA: 1, RowsB: 2},
+		},
+		OnlyInA: []string{"Old"},
+		OnlyInB: []string{"New"},
+	}
+	if !reflect.DeepEqual(comparison, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, comparison)
+	}
+	if comparison.Matches() {
+		t.Error("Expected the documents not to match")
+	}
+
+	comparison, err = CompareDocsData("docA", "docB", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	people := comparison.Tables[0]
+	if !people.Match || people.HashA == "" || people.HashA != people.HashB {
+		t.Errorf("Expected reordered and renumbered rows to match, got %+v", people)
+	}
+	if comparison.Tables[1].HashA != "" {
+		t.Errorf("Expected no hash for tables with different row counts, got %+v", comparison.Tables[1])
+	}
+
+	records["docB/People"] = `{"records": [{"id": 1, "fields": {"Name": "Alice", "Age": 31}}, {"id": 2, "fields": {"Name": "Bob", "Age": 25}}]}`
+	comparison, _ = CompareDocsData("docA", "docB", true)
+	if people := comparison.Tables[0]; people.Match || people.HashA == people.HashB {
+		t.Errorf("Expected a content mismatch, got %+v", people)
+	}
+}
+
+// Ref cells hold row IDs, which are hashed as is: renumbering the referenced
+// rows makes the referencing table differ (see HashTableContent)
+func TestCompareDocsData_RefColumns(t *testing.T) {
+	records := map[string]string{
+		"docA/People": `{"records": [{"id": 1, "fields": {"Name": "Alice"}}, {"id": 2, "fields": {"Name": "Bob"}}]}`,
+		"docB/People": `{"records": [{"id": 1, "fields": {"Name": "Alice"}}, {"id": 2, "fields": {"Name": "Bob"}}]}`,
+		"docA/Pets":   `{"records": [{"id": 1, "fields": {"Name": "Rex", "Owner": 2}}]}`,
+		"docB/Pets":   `{"records": [{"id": 1, "fields": {"Name": "Rex", "Owner": 2}}]}`,
+	}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/docs/"), "/")
+		switch {
+		case len(parts) == 2 && parts[1] == "tables":
+			w.Write([]byte(`{"tables": [{"id": "People"}, {"id": "Pets"}]}`))
+		case len(parts) == 2 && parts[1] == "sql":
+			w.Write([]byte(`{"records": [{"fields": {"count": 2}}]}`))
+		case len(parts) == 4 && parts[3] == "records":
+			w.Write([]byte(records[parts[0]+"/"+parts[2]]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	comparison, err := CompareDocsData("docA", "docB", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !comparison.Matches() {
+		t.Errorf("Expected identical documents to match, got %+v", comparison)
+	}
+
+	// Bob is now row 7 in docB, and Rex still belongs to him
+	records["docB/People"] = `{"records": [{"id": 1, "fields": {"Name": "Alice"}}, {"id": 7, "fields": {"Name": "Bob"}}]}`
+	records["docB/Pets"] = `{"records": [{"id": 1, "fields": {"Name": "Rex", "Owner": 7}}]}`
+	comparison, err = CompareDocsData("docA", "docB", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !comparison.Tables[0].Match {
+		t.Errorf("Expected the renumbered People table to match, got %+v", comparison.Tables[0])
+	}
+	if comparison.Tables[1].Match {
+		t.Errorf("Expected Pets to differ as its Ref cells hold other row IDs, got %+v", comparison.Tables[1])
+	}
+}
+
+func TestGetTablesRowCounts(t *testing.T) {
+	rows := map[string]string{
+		"Small":  `{"id": [1]}`,
+		"Big":    `{"id": [1, 2, 3, 4]}`,
+		"Medium": `{"id": [1, 2]}`,
+		"Empty":  `{"id": []}`,
+		"Also2":  `{"id": [7, 8]}`,
+	}
+	tables := `{"id": "Small"}, {"id": "Big"}, {"id": "Medium"}, {"id": "Empty"}, {"id": "Also2"}, {"id": "Locked"}`
+	for i := range 20 {
+		tables += fmt.Sprintf(`, {"id": "Extra%d"}`, i)
+	}
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/docs/doc123/tables" {
+			fmt.Fprintf(w, `{"tables": [%s]}`, tables)
+			return
+		}
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(time.Millisecond)
+
+		tableId := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/docs/doc123/tables/"), "/data")
+		if tableId == "Locked" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if data, ok := rows[tableId]; ok {
+			w.Write([]byte(data))
+			return
+		}
+		w.Write([]byte(`{"id": []}`))
+	})
+	defer cleanup()
+
+	counts := GetTablesRowCounts("doc123")
+	if len(counts) != 26 {
+		t.Fatalf("Expected 26 tables, got %d", len(counts))
+	}
+	if maxInFlight > maxConcurrentRequests {
+		t.Errorf("Expected at most %d requests at a time, got %d", maxConcurrentRequests, maxInFlight)
+	}
+	expected := []TableRowCount{
+		{TableId: "Big", RowCount: 4, Status: http.StatusOK},
+		{TableId: "Also2", RowCount: 2, Status: http.StatusOK},
+		{TableId: "Medium", RowCount: 2, Status: http.StatusOK},
+		{TableId: "Small", RowCount: 1, Status: http.StatusOK},
+		{TableId: "Empty", RowCount: 0, Status: http.StatusOK},
+	}
+	for i, want := range expected {
+		if counts[i] != want {
+			t.Errorf("Position %d: expected %v, got %v", i, want, counts[i])
+		}
+	}
+	want := TableRowCount{TableId: "Locked", RowCount: 0, Status: http.StatusForbidden}
+	if last := counts[len(counts)-1]; last != want {
+		t.Errorf("Expected the failed table last with its status, got %+v", last)
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/docs/doc123/tables" {
+			t.Errorf("Expected /api/docs/doc123/tables, got %s", r.URL.Path)
+		}
+
+		var body struct {
+			Tables []TableDef `json:"tables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(body.Tables) != 1 || body.Tables[0].Id != "People" {
+			t.Fatalf("Expected one table 'People', got %v", body.Tables)
+		}
+		if len(body.Tables[0].Columns) != 2 || body.Tables[0].Columns[1].Fields["type"] != "Int" {
+			t.Errorf("Unexpected columns: %v", body.Tables[0].Columns)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tables": [{"id": "People"}]}`))
+	})
+	defer cleanup()
+
+	table, status := CreateTable("doc123", TableDef{
+		Id: "People",
+		Columns: []ColumnDef{
+			{Id: "Name", Fields: map[string]interface{}{"type": "Text"}},
+			{Id: "Age", Fields: map[string]interface{}{"type": "Int"}},
+		},
+	})
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+	if table.Id != "People" {
+		t.Errorf("Expected table ID 'People', got %s", table.Id)
+	}
+}
+
+func TestGetDocAccessRules(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables/_grist_ACLResources/records":
+			w.Write([]byte(`{"records": [
+				{"id": 1, "fields": {"tableId": "", "colIds": ""}},
+				{"id": 2, "fields": {"tableId": "*", "colIds": "*"}},
+				{"id": 3, "fields": {"tableId": "Salaries", "colIds": "*"}},
+				{"id": 4, "fields": {"tableId": "Salaries", "colIds": "Amount,Bonus"}}
+			]}`))
+		case "/api/docs/doc123/tables/_grist_ACLRules/records":
+			w.Write([]byte(`{"records": [
+				{"id": 1, "fields": {"resource": 1, "aclFormula": "", "permissionsText": "", "rulePos": 1}},
+				{"id": 2, "fields": {"resource": 2, "aclFormula": "user.Access != OWNER", "permissionsText": "-S", "rulePos": 2}},
+				{"id": 3, "fields": {"resource": 3, "aclFormula": "rec.Employee != user.Email", "permissionsText": "-R", "rulePos": 5, "memo": "Own salary"}},
+				{"id": 4, "fields": {"resource": 1, "userAttributes": "{\"name\": \"Team\", \"tableId\": \"Members\", \"lookupColId\": \"Email\", \"charId\": \"Email\"}", "rulePos": 3}},
+				{"id": 5, "fields": {"resource": 4, "aclFormula": "user.Team.Role != 'HR'", "permissionsText": "-RU", "rulePos": 4}},
+				{"id": 6, "fields": {"resource": 3, "aclFormula": "", "permissionsText": "+R", "rulePos": 6}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Only owners can access ACL rules"}`))
+		}
+	})
+	defer cleanup()
+
+	accessRules, status := GetDocAccessRules("doc123")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	ids := []int{}
+	for _, rule := range accessRules.Rules {
+		ids = append(ids, rule.Id)
+	}
+	// Column rules of a table first, then its table-wide rules by position,
+	// then the default ones
+	if !reflect.DeepEqual(ids, []int{5, 3, 6, 2}) {
+		t.Errorf("Expected rules [5 3 6 2], got %v", ids)
+	}
+	if rule := accessRules.Rules[0]; rule.TableId != "Salaries" || rule.ColIds != "Amount,Bonus" || rule.Permissions != "-RU" {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+	expected := []UserAttribute{{Name: "Team", TableId: "Members", LookupColId: "Email", CharId: "Email"}}
+	if !reflect.DeepEqual(accessRules.UserAttributes, expected) {
+		t.Errorf("Expected user attributes %v, got %v", expected, accessRules.UserAttributes)
+	}
+
+	if _, status := GetDocAccessRules("other"); status != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", status)
+	}
+}
+
+func TestUploadDocGrist(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/workspaces/42/import" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "workspace not found"}`))
+			return
+		}
+		file, header, err := r.FormFile("upload")
+		if err != nil {
+			t.Fatalf("Expected an upload field: %v", err)
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		if header.Filename != "Budget.grist" || string(content) != "SQLite format 3" {
+			t.Errorf("Unexpected upload %s: %q", header.Filename, content)
+		}
+		w.Write([]byte(`{"id": "newDoc123", "title": "Budget"}`))
+	})
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "Budget.grist")
+	if err := os.WriteFile(path, []byte("SQLite format 3"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if docId, status := UploadDocGrist(42, path); status != http.StatusOK || docId != "newDoc123" {
+		t.Errorf("Expected document newDoc123, got %q %d", docId, status)
+	}
+	if message, status := UploadDocGrist(7, path); status != http.StatusNotFound || !strings.Contains(message, "workspace not found") {
+		t.Errorf("Expected status 404 with the error, got %q %d", message, status)
+	}
+	if _, status := UploadDocGrist(42, filepath.Join(t.TempDir(), "Budget.xlsx")); status != -1 {
+		t.Errorf("Expected status -1 for a file that isn't a .grist, got %d", status)
+	}
+	if _, status := UploadDocGrist(42, filepath.Join(t.TempDir(), "missing.grist")); status != -1 {
+		t.Errorf("Expected status -1 for a missing file, got %d", status)
+	}
+}
+
+func TestTruncateTable(t *testing.T) {
+	batches := [][]int{}
+	rowCount := 2*deleteBatchSize + 1
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables/People/data", "/api/docs/doc123/tables/Empty/data":
+			ids := []int{}
+			if strings.Contains(r.URL.Path, "People") {
+				for id := 1; id <= rowCount; id++ {
+					ids = append(ids, id)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string][]int{"id": ids})
+		case "/api/docs/doc123/tables/People/records/delete":
+			var ids []int
+			if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			batches = append(batches, ids)
+			if len(batches) == 3 && len(ids) != 1 {
+				t.Errorf("Expected the last batch to hold 1 row, got %d", len(ids))
+			}
+			w.Write([]byte(`null`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "Table not found"}`))
+		}
+	})
+	defer cleanup()
+
+	deleted, status := TruncateTable("doc123", "People")
+	if status != http.StatusOK || deleted != rowCount {
+		t.Errorf("Expected %d rows deleted, got %d (status %d)", rowCount, deleted, status)
+	}
+	if len(batches) != 3 || len(batches[0]) != deleteBatchSize || batches[2][0] != rowCount {
+		t.Errorf("Expected 3 batches of at most %d rows, got %d", deleteBatchSize, len(batches))
+	}
+
+	if deleted, status := TruncateTable("doc123", "Empty"); status != http.StatusOK || deleted != 0 {
+		t.Errorf("Expected an empty table to be truncated without error, got %d (status %d)", deleted, status)
+	}
+	if _, status := TruncateTable("doc123", "Missing"); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing table, got %d", status)
+	}
+}
+
+func TestGetRecordsSince(t *testing.T) {
+	sqlStatus := http.StatusOK
+	columnsFetched := false
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		sqlAvailable := sqlStatus == http.StatusOK
+		switch r.URL.Path {
+		case "/api/docs/doc123/sql":
+			if !sqlAvailable {
+				w.WriteHeader(sqlStatus)
+				w.Write([]byte(`{"error": "Cannot access SQL with access rules"}`))
+				return
+			}
+			var body struct {
+				SQL  string        `json:"sql"`
+				Args []interface{} `json:"args"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			expected := `SELECT id FROM "People" WHERE id > ? OR "Updated" > ? ORDER BY id`
+			if body.SQL != expected || !reflect.DeepEqual(body.Args, []interface{}{float64(2), float64(100)}) {
+				t.Errorf("Unexpected query: %s %v", body.SQL, body.Args)
+			}
+			w.Write([]byte(`{"statement": "", "records": [{"fields": {"id": 1}}, {"fields": {"id": 3}}]}`))
+		case "/api/docs/doc123/tables/People/records":
+			filter := r.URL.Query().Get("filter")
+			if sqlAvailable && filter != `{"id":[1,3]}` {
+				t.Errorf("Expected the selected IDs to be fetched, got filter %q", filter)
+			}
+			records := `{"id": 1, "fields": {"Name": "Alice", "Updated": 150}},
+				{"id": 3, "fields": {"Name": "Carol", "Updated": 90}}`
+			if !sqlAvailable {
+				records += `, {"id": 2, "fields": {"Name": "Bob", "Updated": 50}}`
+			}
+			w.Write([]byte(`{"records": [` + records + `]}`))
+		case "/api/docs/doc123/tables/People/columns":
+			columnsFetched = true
+			w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"type": "Text"}}, {"id": "Updated", "fields": {"type": "Numeric"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	since := RecordsSince{LastRowId: 2, UpdatedColumn: "Updated", UpdatedAfter: 100}
+	for _, method := range []string{"sql", "client"} {
+		sqlStatus = http.StatusOK
+		if method == "client" {
+			sqlStatus = http.StatusForbidden
+		}
+		result, status, err := GetRecordsSince("doc123", "People", since)
+		if err != nil || status != http.StatusOK || result.Method != method {
+			t.Fatalf("Expected method %s with status 200, got %s (status %d, %v)", method, result.Method, status, err)
+		}
+		if len(result.Records) != 2 || result.Records[0].Id != 1 || result.Records[1].Id != 3 {
+			t.Errorf("%s: expected records 1 and 3, got %v", method, result.Records)
+		}
+		expected := RecordsSince{LastRowId: 3, UpdatedColumn: "Updated", UpdatedAfter: 150}
+		if result.Next != expected {
+			t.Errorf("%s: expected next cursor %+v, got %+v", method, expected, result.Next)
+		}
+	}
+
+	// Only a forbidden SQL endpoint falls back to the client-side filter
+	sqlStatus = http.StatusInternalServerError
+	if _, status, _ := GetRecordsSince("doc123", "People", since); status != http.StatusInternalServerError {
+		t.Errorf("Expected the status of the SQL endpoint, got %d", status)
+	}
+	sqlStatus = http.StatusForbidden
+	since.UpdatedColumn = "Modified"
+	if _, _, err := GetRecordsSince("doc123", "People", since); err == nil || !strings.Contains(err.Error(), "Modified") {
+		t.Errorf("Expected an error for an unknown updated column, got %v", err)
+	}
+	// Without an updated column, the columns aren't fetched
+	columnsFetched = false
+	since.UpdatedColumn = ""
+	if _, status, err := GetRecordsSince("doc123", "People", since); err != nil || status != http.StatusOK || columnsFetched {
+		t.Errorf("Expected the records without fetching the columns, got status %d (%v)", status, err)
+	}
+}
+
+func TestCreateDoc(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/workspaces/42/docs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["name"] != "Project" {
+			t.Errorf("Unexpected body: %v %v", body, err)
+		}
+		w.Write([]byte(`"newDoc123"`))
+	})
+	defer cleanup()
+
+	docId, err := CreateDoc(42, "Project")
+	if err != nil || docId != "newDoc123" {
+		t.Errorf("Expected document newDoc123, got %q %v", docId, err)
+	}
+	if _, err := CreateDoc(7, "Project"); err == nil {
+		t.Error("Expected an error for a missing workspace")
+	}
+}
+
+func TestLoadDocTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"tables": [{"id": "People", "columns": [{"id": "Name", "fields": {"type": "Text"}}, {"id": "Age", "fields": {"type": "Int"}}]}, {"id": "Teams", "columns": []}]}`, false},
+		{"invalid JSON", `{"tables": [`, true},
+		{"unknown key", `{"tables": [{"id": "People", "colums": []}]}`, true},
+		{"no tables", `{"tables": []}`, true},
+		{"table without id", `{"tables": [{"columns": []}]}`, true},
+		{"duplicate table", `{"tables": [{"id": "People"}, {"id": "people"}]}`, true},
+		{"column without id", `{"tables": [{"id": "People", "columns": [{"fields": {"type": "Text"}}]}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schema.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			template, err := LoadDocTemplate(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && (len(template.Tables) != 2 || template.Tables[0].Columns[1].Fields["type"] != "Int") {
+				t.Errorf("Unexpected template: %+v", template)
+			}
+		})
+	}
+	if _, err := LoadDocTemplate(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestUpdateTable_Rename(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Expected PATCH request, got %s", r.Method)
+		}
+
+		var body struct {
+			Tables []struct {
+				Id     string                 `json:"id"`
+				Fields map[string]interface{} `json:"fields"`
+			} `json:"tables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(body.Tables) != 1 || body.Tables[0].Id != "Old" || body.Tables[0].Fields["tableId"] != "New" {
+			t.Errorf("Unexpected request body: %v", body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+	defer cleanup()
+
+	_, status := UpdateTable("doc123", "Old", map[string]interface{}{"tableId": "New"})
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+}
+
+func TestGetDocSchema(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables":
+			w.Write([]byte(`{"tables": [{"id": "People"}, {"id": "Pets"}]}`))
+		case "/api/docs/doc123/tables/People/columns":
+			w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"label": "Name", "type": "Text"}}]}`))
+		case "/api/docs/doc123/tables/Pets/columns":
+			w.Write([]byte(`{"columns": [{"id": "Owner", "fields": {"type": "Ref:People", "formula": "", "isFormula": false}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	schema, status := GetDocSchema("doc123")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(schema.Tables) != 2 || schema.Tables[0].Id != "People" || schema.Tables[1].Id != "Pets" {
+		t.Fatalf("Unexpected tables: %+v", schema.Tables)
+	}
+	if schema.Tables[1].Columns[0].Fields.Type != "Ref:People" {
+		t.Errorf("Expected column type Ref:People, got %s", schema.Tables[1].Columns[0].Fields.Type)
+	}
+
+	if _, status := GetDocSchema("missing"); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+}
+
+func TestGetTableColumnsWithStatus(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables/People/columns":
+			w.Write([]byte(`{"columns": [{"id": "manualSort", "fields": {"type": "ManualSortPos"}}, {"id": "Name", "fields": {"type": "Text"}}]}`))
+		case "/api/docs/doc123/tables/Locked/columns":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	columns, status := GetTableColumnsWithStatus("doc123", "People")
+	if status != http.StatusOK || len(columns.Columns) != 1 || columns.Columns[0].Id != "Name" {
+		t.Errorf("Expected the visible column Name, got %d %+v", status, columns)
+	}
+	if _, status := GetTableColumnsWithStatus("doc123", "Missing"); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+	if _, status := GetTableColumnsWithStatus("doc123", "Locked"); status != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", status)
+	}
+}
+
+func TestGetDocSchema_TableFailure(t *testing.T) {
+	tables := `{"id": "Locked"}`
+	for i := range 20 {
+		tables += fmt.Sprintf(`, {"id": "Table%d"}`, i)
+	}
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/docs/doc123/tables" {
+			fmt.Fprintf(w, `{"tables": [%s]}`, tables)
+			return
+		}
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(time.Millisecond)
+
+		if r.URL.Path == "/api/docs/doc123/tables/Locked/columns" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"type": "Text"}}]}`))
+	})
+	defer cleanup()
+
+	if _, status := GetDocSchema("doc123"); status != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", status)
+	}
+	if maxInFlight > maxConcurrentRequests {
+		t.Errorf("Expected at most %d requests at a time, got %d", maxConcurrentRequests, maxInFlight)
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	a := DocSchema{Tables: []TableSchema{
+		{Id: "People", Columns: []TableColumn{
+			{Id: "Name", Fields: ColumnFields{Type: "Text"}},
+			{Id: "Age", Fields: ColumnFields{Type: "Int"}},
+		}},
+		{Id: "Old", Columns: []TableColumn{{Id: "A"}}},
+		{Id: "Same", Columns: []TableColumn{{Id: "A", Fields: ColumnFields{Type: "Text"}}}},
+	}}
+	b := DocSchema{Tables: []TableSchema{
+		{Id: "People", Columns: []TableColumn{
+			{Id: "Name", Fields: ColumnFields{Type: "Text", Label: "Full name"}},
+			{Id: "Email", Fields: ColumnFields{Type: "Text"}},
+		}},
+		{Id: "Same", Columns: []TableColumn{{Id: "A", Fields: ColumnFields{Type: "Text"}}}},
+		{Id: "New", Columns: []TableColumn{{Id: "A"}}},
+	}}
+
+	diff := DiffSchemas(a, b)
+	if len(diff.AddedTables) != 1 || diff.AddedTables[0] != "New" {
+		t.Errorf("Expected added table New, got %v", diff.AddedTables)
+	}
+	if len(diff.RemovedTables) != 1 || diff.RemovedTables[0] != "Old" {
+		t.Errorf("Expected removed table Old, got %v", diff.RemovedTables)
+	}
+	if len(diff.ChangedTables) != 1 {
+		t.Fatalf("Expected 1 changed table, got %+v", diff.ChangedTables)
+	}
+	people := diff.ChangedTables[0]
+	if people.TableId != "People" {
+		t.Errorf("Expected changed table People, got %s", people.TableId)
+	}
+	if len(people.AddedColumns) != 1 || people.AddedColumns[0] != "Email" {
+		t.Errorf("Expected added column Email, got %v", people.AddedColumns)
+	}
+	if len(people.RemovedColumns) != 1 || people.RemovedColumns[0] != "Age" {
+		t.Errorf("Expected removed column Age, got %v", people.RemovedColumns)
+	}
+	expectedChange := ColumnChange{ColumnId: "Name", Property: "label", Before: "", After: "Full name"}
+	if len(people.ChangedColumns) != 1 || people.ChangedColumns[0] != expectedChange {
+		t.Errorf("Expected label change on Name, got %+v", people.ChangedColumns)
+	}
+
+	if !DiffSchemas(a, a).IsEmpty() {
+		t.Error("Expected no difference between identical schemas")
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	var batches [][]map[string]interface{}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/docs/doc123/tables/People/records" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			Records []struct {
+				Fields map[string]interface{} `json:"fields"`
+			} `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		batch := []map[string]interface{}{}
+		ids := []string{}
+		for _, record := range body.Records {
+			batch = append(batch, record.Fields)
+			ids = append(ids, fmt.Sprintf(`{"id": %d}`, len(batches)*10+len(ids)+1))
+		}
+		batches = append(batches, batch)
+		fmt.Fprintf(w, `{"records": [%s]}`, strings.Join(ids, ","))
+	})
+	defer cleanup()
+
+	csvPath := filepath.Join(t.TempDir(), "people.csv")
+	content := "\ufeffName,Age,Notes\nAlice,30,x\nBob,25,y\nCarol,41,z\n"
+	if err := os.WriteFile(csvPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write CSV file: %v", err)
+	}
+
+	opts := ImportCSVOptions{
+		ColumnMap: map[string]string{"Name": "FullName", "Notes": ""},
+		BatchSize: 2,
+	}
+	result, status, err := ImportCSV("doc123", "People", csvPath, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(result.Records) != 3 {
+		t.Errorf("Expected 3 imported records, got %d", len(result.Records))
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("Expected batches of 2 and 1 records, got %v", batches)
+	}
+	first := batches[0][0]
+	if first["FullName"] != "Alice" || first["Age"] != "30" {
+		t.Errorf("Unexpected first record fields: %v", first)
+	}
+	if _, found := first["Notes"]; found {
+		t.Error("Expected skipped column Notes not to be sent")
+	}
+}
+
+func TestImportCSV_Upsert(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("Expected PUT request, got %s", r.Method)
+		}
+		var body RecordsWithRequire
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(body.Records) != 1 {
+			t.Fatalf("Expected 1 record, got %d", len(body.Records))
+		}
+		record := body.Records[0]
+		if record.Require["Email"] != "a@example.com" || record.Fields["Name"] != "Alice" {
+			t.Errorf("Unexpected upsert record: %+v", record)
+		}
+		if _, found := record.Fields["Email"]; found {
+			t.Error("Expected key column only in require")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer cleanup()
+
+	csvPath := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(csvPath, []byte("Email,Name\na@example.com,Alice\n"), 0600); err != nil {
+		t.Fatalf("Failed to write CSV file: %v", err)
+	}
+
+	_, status, err := ImportCSV("doc123", "People", csvPath, ImportCSVOptions{KeyColumn: "Email"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+		w.Write([]byte(`{"records": [
+			{"id": 1, "fields": {"Email": "a@example.com", "Name": "Alice", "Age": 30}},
+			{"id": 2, "fields": {"Email": "b@example.com", "Name": "Bob", "Age": 25}},
+			{"id": 3, "fields": {"Email": "b@example.com", "Name": "Bobby", "Age": 52}}
+		]}`))
+	})
+	defer cleanup()
+
+	incoming := []RecordWithRequire{
+		{Require: map[string]interface{}{"Email": "a@example.com"}, Fields: map[string]interface{}{"Name": "Alice", "Age": "30"}},
+		{Require: map[string]interface{}{"Email": "b@example.com"}, Fields: map[string]interface{}{"Name": "Robert", "Age": "25"}},
+		{Require: map[string]interface{}{"Email": "c@example.com"}, Fields: map[string]interface{}{"Name": "Carol"}},
+	}
+	diff, status := DiffRecords("doc123", "People", incoming, []string{"Email"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Fields["Name"] != "Carol" {
+		t.Errorf("Expected Carol to be added, got %+v", diff.Added)
+	}
+	if len(diff.Unchanged) != 1 || diff.Unchanged[0].Fields["Name"] != "Alice" {
+		t.Errorf("Expected Alice to be unchanged, got %+v", diff.Unchanged)
+	}
+	expected := []RecordUpdate{{
+		Id:      2,
+		Require: map[string]interface{}{"Email": "b@example.com"},
+		Changes: []FieldChange{{Field: "Name", Before: "Bob", After: "Robert"}},
+	}}
+	if !reflect.DeepEqual(diff.Updated, expected) {
+		t.Errorf("Expected the first matching record to be updated, got %+v", diff.Updated)
+	}
+}
+
+func TestPlanImportCSV(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected no change during a dry run, got %s", r.Method)
+		}
+		w.Write([]byte(`{"records": [{"id": 1, "fields": {"Email": "a@example.com", "Name": "Alice"}}]}`))
+	})
+	defer cleanup()
+
+	csvPath := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(csvPath, []byte("Email,Name\na@example.com,Alicia\nb@example.com,Bob\n"), 0600); err != nil {
+		t.Fatalf("Failed to write CSV file: %v", err)
+	}
+
+	plan, status := PlanImportCSV("doc123", "People", csvPath, ImportCSVOptions{KeyColumn: "Email"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(plan.Added) != 1 || len(plan.Updated) != 1 || len(plan.Unchanged) != 0 {
+		t.Errorf("Expected 1 record added and 1 updated, got %+v", plan)
+	}
+
+	plan, _ = PlanImportCSV("doc123", "People", csvPath, ImportCSVOptions{})
+	if len(plan.Added) != 2 {
+		t.Errorf("Expected all records added without key, got %+v", plan)
+	}
+}
+
+func TestImportCSV_MissingFile(t *testing.T) {
+	if _, _, err := ImportCSV("doc123", "People", filepath.Join(t.TempDir(), "missing.csv"), ImportCSVOptions{}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestImportCSV_InvalidRow(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"records": [{"id": 1}]}`))
+	})
+	defer cleanup()
+
+	csvPath := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(csvPath, []byte("Name\nAlice\n\"Bob\n"), 0600); err != nil {
+		t.Fatalf("Failed to write CSV file: %v", err)
+	}
+	result, status, err := ImportCSV("doc123", "People", csvPath, ImportCSVOptions{})
+	if err == nil {
+		t.Error("Expected an error for an unterminated quote")
+	}
+	// Records read before the error are still imported
+	if status != http.StatusOK || len(result.Records) != 1 {
+		t.Errorf("Expected 1 imported record, got %d (status %d)", len(result.Records), status)
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"array", `[{"Name": "Alice", "Age": 30}, {"Name": "Bob", "Age": 25}]`},
+		{"ndjson", "{\"Name\": \"Alice\", \"Age\": 30}\n{\"Name\": \"Bob\", \"Age\": 25}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []map[string]interface{}
+			_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Records []struct {
+						Fields map[string]interface{} `json:"fields"`
+					} `json:"records"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+				for _, record := range body.Records {
+					received = append(received, record.Fields)
+				}
+				w.Write([]byte(`{"records": [{"id": 1}, {"id": 2}]}`))
+			})
+			defer cleanup()
+
+			result, status, err := ImportJSON("doc123", "People", strings.NewReader(tt.input), 1)
+			if err != nil || status != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d (%v)", status, err)
+			}
+			if len(result.Records) != 2 {
+				t.Errorf("Expected 2 imported records, got %d", len(result.Records))
+			}
+			if len(received) != 2 || received[1]["Name"] != "Bob" || received[1]["Age"] != float64(25) {
+				t.Errorf("Unexpected records sent: %v", received)
+			}
+		})
+	}
+}
+
+func TestImportJSON_Batches(t *testing.T) {
+	batchSizes := []int{}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Records []json.RawMessage `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		batchSizes = append(batchSizes, len(body.Records))
+		w.Write([]byte(`{"records": []}`))
+	})
+	defer cleanup()
+
+	var input strings.Builder
+	for i := 0; i < defaultImportBatchSize+1; i++ {
+		fmt.Fprintf(&input, "{\"N\": %d}\n", i)
+	}
+	_, status, err := ImportJSON("doc123", "Numbers", strings.NewReader(input.String()), 1)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%v)", status, err)
+	}
+	if len(batchSizes) != 2 || batchSizes[0] != defaultImportBatchSize || batchSizes[1] != 1 {
+		t.Errorf("Expected batches of %d and 1 records, got %v", defaultImportBatchSize, batchSizes)
+	}
+}
+
+func TestImportJSON_InvalidRecord(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"records": [{"id": 1}]}`))
+	})
+	defer cleanup()
+
+	result, status, err := ImportJSON("doc123", "People", strings.NewReader("{\"Name\": \"Alice\"}\n[1, 2]\n"), 1)
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("Expected an error on record 2, got %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+	// Records decoded before the error are still imported
+	if len(result.Records) != 1 {
+		t.Errorf("Expected 1 imported record, got %d", len(result.Records))
+	}
+}
+
+// importServer records the batches of a concurrent import: they are
+// answered once `workers` of them are in flight, returning the value of N as
+// record ID, and the batch containing failOn fails before the others are
+// answered
+type importServer struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	created     []int
+	full        chan struct{}
+	fullOnce    sync.Once
+	failed      chan struct{}
+}
+
+func newImportServer(t *testing.T, workers int, failOn int) (*importServer, func()) {
+	server := &importServer{full: make(chan struct{}), failed: make(chan struct{})}
+	if failOn == 0 {
+		close(server.failed)
+	}
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Records []struct {
+				Fields struct {
+					N int `json:"N"`
+				} `json:"fields"`
+			} `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		server.mu.Lock()
+		server.inFlight++
+		server.maxInFlight = max(server.maxInFlight, server.inFlight)
+		if server.inFlight == workers {
+			server.fullOnce.Do(func() { close(server.full) })
+		}
+		server.mu.Unlock()
+		defer func() {
+			server.mu.Lock()
+			server.inFlight--
+			server.mu.Unlock()
+		}()
+		// Bounded waits, so that a bug fails the test rather than hanging it
+		select {
+		case <-server.full:
+		case <-time.After(5 * time.Second):
+		}
+
+		ids := []string{}
+		for _, record := range body.Records {
+			if record.Fields.N == failOn {
+				w.WriteHeader(http.StatusInternalServerError)
+				close(server.failed)
+				return
+			}
+			ids = append(ids, fmt.Sprintf(`{"id": %d}`, record.Fields.N))
+		}
+		select {
+		case <-server.failed:
+		case <-time.After(5 * time.Second):
+		}
+		server.mu.Lock()
+		for _, record := range body.Records {
+			server.created = append(server.created, record.Fields.N)
+		}
+		server.mu.Unlock()
+		fmt.Fprintf(w, `{"records": [%s]}`, strings.Join(ids, ","))
+	})
+	return server, cleanup
+}
+
+func numbersNDJSON(count int) string {
+	var input strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&input, "{\"N\": %d}\n", i)
+	}
+	return input.String()
+}
+
+func TestImportJSON_Concurrency(t *testing.T) {
+	// 8 batches, sent one at a time then by 4 workers
+	input := numbersNDJSON(8 * defaultImportBatchSize)
+	for _, concurrency := range []int{1, 4} {
+		server, cleanup := newImportServer(t, concurrency, 0)
+		result, status, err := ImportJSON("doc123", "Numbers", strings.NewReader(input), concurrency)
+		cleanup()
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("Expected status 200 with concurrency %d, got %d (%v)", concurrency, status, err)
+		}
+		if server.maxInFlight != concurrency {
+			t.Errorf("Expected %d batches in flight, got %d", concurrency, server.maxInFlight)
+		}
+		if len(result.Records) != 8*defaultImportBatchSize {
+			t.Fatalf("Expected %d records with concurrency %d, got %d", 8*defaultImportBatchSize, concurrency, len(result.Records))
+		}
+		for i, record := range result.Records {
+			if record.Id != i+1 {
+				t.Fatalf("Expected records in input order with concurrency %d, got ID %d at %d", concurrency, record.Id, i)
+			}
+		}
+	}
+}
+
+func TestImportJSON_ConcurrencyFailure(t *testing.T) {
+	// The third of the 4 batches in flight fails, the others are created
+	server, cleanup := newImportServer(t, 4, 2*defaultImportBatchSize+1)
+	defer cleanup()
+
+	result, status, err := ImportJSON("doc123", "Numbers", strings.NewReader(numbersNDJSON(6*defaultImportBatchSize)), 4)
+	if err != nil || status != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", status)
+	}
+	if len(server.created) < 3*defaultImportBatchSize {
+		t.Errorf("Expected the batches in flight to be created, got %d records", len(server.created))
+	}
+	// Every created record is reported, including the ones of the batch
+	// sent after the failed one
+	if len(result.Records) != len(server.created) {
+		t.Errorf("Expected the %d created records, got %d", len(server.created), len(result.Records))
+	}
+	if len(result.Records) > 0 && result.Records[len(result.Records)-1].Id <= 3*defaultImportBatchSize {
+		t.Errorf("Expected the records of the fourth batch, got up to ID %d", result.Records[len(result.Records)-1].Id)
+	}
+}
+
+func TestRunSQL(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/docs/doc123/sql" {
+			t.Errorf("Expected /api/docs/doc123/sql, got %s", r.URL.Path)
+		}
+
+		var body struct {
+			SQL  string        `json:"sql"`
+			Args []interface{} `json:"args"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if body.SQL != "SELECT * FROM Orders WHERE amount > ?" || len(body.Args) != 1 {
+			t.Errorf("Unexpected request body: %v", body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"statement": "SELECT * FROM Orders WHERE amount > ?", "records": [{"fields": {"id": 1, "amount": 150}}]}`))
+	})
+	defer cleanup()
+
+	result, status := RunSQL("doc123", "SELECT * FROM Orders WHERE amount > ?", []interface{}{100})
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+	if len(result.Records) != 1 || result.Records[0].Fields["amount"] != float64(150) {
+		t.Errorf("Unexpected records: %v", result.Records)
+	}
+}
+
+func TestValidateReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		sql   string
+		valid bool
+	}{
+		{"simple select", "SELECT * FROM Orders", true},
+		{"lowercase with trailing semicolon", "select count(*) from Orders;", true},
+		{"common table expression", "WITH t AS (SELECT 1) SELECT * FROM t", true},
+		{"keyword inside string", "SELECT * FROM Logs WHERE action = 'DELETE; DROP'", true},
+		{"keyword inside column name", "SELECT updated_at, \"Delete\" FROM Logs", true},
+		{"leading comment", "-- monthly orders\nSELECT * FROM Orders", true},
+		{"empty", "  ", false},
+		{"delete", "DELETE FROM Orders", false},
+		{"update", "UPDATE Orders SET amount = 0", false},
+		{"stacked statements", "SELECT 1; DROP TABLE Orders", false},
+		{"cte with delete", "WITH t AS (SELECT 1) DELETE FROM Orders", false},
+		{"pragma", "PRAGMA table_info(Orders)", false},
+		{"comment hiding statement", "/* SELECT */ DELETE FROM Orders", false},
+		{"replace function", "SELECT replace(name, 'a', 'b') FROM People", true},
+		{"columns named like keywords", "SELECT update, release, \"drop\" FROM Versions WHERE create > 0", true},
+		{"quoted identifiers", "SELECT [delete], `insert` FROM [Logs]", true},
+		{"cte with columns and subquery", "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 5), u AS NOT MATERIALIZED (SELECT (SELECT 2)) SELECT * FROM t, u", true},
+		{"cte with update", "WITH t(id) AS (SELECT 1) UPDATE Orders SET amount = 0 WHERE id IN t", false},
+		{"cte with insert", "WITH t AS (SELECT 1), u AS (SELECT 2) INSERT INTO Orders SELECT * FROM u", false},
+		{"replace statement", "REPLACE INTO Orders VALUES (1)", false},
+		{"values", "VALUES (1)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReadOnlySQL(tt.sql)
+			if tt.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", tt.sql, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected %q to be rejected", tt.sql)
+			}
+		})
+	}
+}
+
+func TestFindDocsByName(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/orgs":
+			w.Write([]byte(`[{"id": 1, "name": "Team"}, {"id": 2, "name": "Personal"}]`))
+		case "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 10, "name": "Finance", "docs": [{"id": "a", "name": "Budget 2024"}, {"id": "b", "name": "Invoices"}]}]`))
+		case "/api/orgs/2/workspaces":
+			w.Write([]byte(`[{"id": 20, "name": "Home", "docs": [{"id": "c", "name": "Household budget"}]}]`))
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	})
+	defer cleanup()
+
+	docs := FindDocsByName("BUDGET")
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(docs))
+	}
+	if docs[0].Id != "a" || docs[0].Workspace.Name != "Finance" || docs[0].Workspace.Org.Name != "Team" {
+		t.Errorf("Unexpected first match: %+v", docs[0])
+	}
+	if docs[1].Id != "c" || docs[1].Workspace.Id != 20 || docs[1].Workspace.Org.Id != 2 {
+		t.Errorf("Unexpected second match: %+v", docs[1])
+	}
+	if docs[0].Workspace.Docs != nil {
+		t.Error("Expected the workspace of a match not to list its documents")
+	}
+}
+
+func TestForceReloadDoc(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/docs/doc123/force-reload" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`null`))
+	})
+	defer cleanup()
+
+	if _, status := ForceReloadDoc("doc123"); status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+}
+
+func TestGetTableColumns_RefTable(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"columns": [
+			{"id": "Name", "fields": {"type": "Text"}},
+			{"id": "Category", "fields": {"type": "Ref:Categories"}},
+			{"id": "Tags", "fields": {"type": "RefList:Tags", "isFormula": true, "formula": "$Category.tags"}}
+		]}`))
+	})
+	defer cleanup()
+
+	columns := GetTableColumns("doc123", "Products").Columns
+	if len(columns) != 3 {
+		t.Fatalf("Expected 3 columns, got %d", len(columns))
+	}
+	expected := []string{"", "Categories", "Tags"}
+	for i, column := range columns {
+		if column.Fields.RefTable != expected[i] {
+			t.Errorf("Column %s: expected referenced table %q, got %q", column.Id, expected[i], column.Fields.RefTable)
+		}
+	}
+	if !columns[2].Fields.IsFormula || columns[1].Fields.IsFormula {
+		t.Errorf("Unexpected isFormula values: %+v", columns)
+	}
+}
+
+func TestGetRecord(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("filter") {
+		case `{"id":[7]}`:
+			w.Write([]byte(`{"records": [{"id": 7, "fields": {"Name": "Alice"}}]}`))
+		default:
+			w.Write([]byte(`{"records": []}`))
+		}
+	})
+	defer cleanup()
+
+	record, status := GetRecord("doc123", "People", 7)
+	if status != http.StatusOK || record.Id != 7 || record.Fields["Name"] != "Alice" {
+		t.Errorf("Unexpected record: %d %+v", status, record)
+	}
+	if _, status := GetRecord("doc123", "People", 8); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing record, got %d", status)
+	}
+}
+
+func TestGetTableColumns_Internal(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"columns": [
+			{"id": "manualSort", "fields": {"type": "ManualSortPos"}},
+			{"id": "Name", "fields": {"type": "Text"}},
+			{"id": "gristHelper_Display", "fields": {"type": "Any", "isFormula": true}}
+		]}`))
+		if hidden := r.URL.Query().Get("hidden"); hidden != "" && hidden != "true" {
+			t.Errorf("Unexpected hidden parameter %q", hidden)
+		}
+	})
+	defer cleanup()
+
+	columns := GetTableColumns("doc123", "People").Columns
+	if len(columns) != 1 || columns[0].Id != "Name" {
+		t.Errorf("Expected only the Name column, got %+v", columns)
+	}
+	if all := GetAllTableColumns("doc123", "People").Columns; len(all) != 3 {
+		t.Errorf("Expected 3 columns, got %d", len(all))
+	}
+}
+
+func TestGetTableExport(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/docs/doc123/download/tsv" {
+			t.Errorf("Expected TSV endpoint, got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("tableId") != "People" {
+			t.Errorf("Expected tableId=People, got %q", r.URL.Query().Get("tableId"))
+		}
+		w.Write([]byte("Name\tAge\nAlice\t30\n"))
+	})
+	defer cleanup()
+
+	content, status := GetTableExport("doc123", "People", "tsv", nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if content != "Name\tAge\nAlice\t30\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	if _, status := GetTableExport("doc123", "People", "pdf", nil); status != -1 {
+		t.Errorf("Expected status -1 for an unsupported format, got %d", status)
+	}
+	if err := ExportTable("doc123", "People", "pdf", filepath.Join(t.TempDir(), "out.pdf"), nil); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestGetTableExport_Fields(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/download/csv":
+			w.Write([]byte("Name,Age,Notes\nAlice,30,\"a, b\"\nBob,25,c\n"))
+		case "/api/docs/doc123/download/dsv":
+			w.Write([]byte("Name💩Age\nAlice💩30\n"))
+		}
+	})
+	defer cleanup()
+
+	content, status := GetTableExport("doc123", "People", "csv", &ExportOptions{Fields: []string{"Notes", "Name"}})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if content != "Notes,Name\n\"a, b\",Alice\nc,Bob\n" {
+		t.Errorf("Unexpected projected CSV %q", content)
+	}
+
+	content, _ = GetTableExport("doc123", "People", "dsv", &ExportOptions{Fields: []string{"Age"}})
+	if content != "Age\n30\n" {
+		t.Errorf("Unexpected projected DSV %q", content)
+	}
+
+	if content, status := GetTableExport("doc123", "People", "csv", &ExportOptions{Fields: []string{"Missing"}}); status != -1 || !strings.Contains(content, "Missing") {
+		t.Errorf("Expected an error for an unknown column, got %d %q", status, content)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "out.csv")
+	if err := ExportTable("doc123", "People", "csv", fileName, &ExportOptions{Fields: []string{"Age"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if written, _ := os.ReadFile(fileName); string(written) != "Age\n30\n25\n" {
+		t.Errorf("Unexpected exported file %q", written)
+	}
+}
+
+func TestGetRecords_Fields(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"records": [{"id": 1, "fields": {"Name": "Alice", "Age": 30, "Notes": "x"}}]}`))
+	})
+	defer cleanup()
+
+	options := &GetRecordsOptions{Fields: []string{"Name", "Age"}}
+	records, status := GetRecords("doc123", "People", options)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	expected := map[string]interface{}{"Name": "Alice", "Age": float64(30)}
+	if !reflect.DeepEqual(records.Records[0].Fields, expected) {
+		t.Errorf("Expected fields %v, got %v", expected, records.Records[0].Fields)
+	}
+
+	var streamed []Record
+	if _, err := StreamRecords("doc123", "People", options, func(record Record) error {
+		streamed = append(streamed, record)
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(streamed) != 1 || !reflect.DeepEqual(streamed[0].Fields, expected) {
+		t.Errorf("Expected streamed fields %v, got %v", expected, streamed)
+	}
+}
+
+func TestGetServerVersion(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><script>window.gristConfig = {"homeUrl":"x","version":"1.2.3","org":"docs"};</script></head></html>`))
+	})
+	defer cleanup()
+
+	version, err := GetServerVersion()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if version != "1.2.3" {
+		t.Errorf("Expected version 1.2.3, got %q", version)
+	}
+}
+
+func TestGetServerVersion_NotFound(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html></html>`))
+	})
+	defer cleanup()
+
+	if _, err := GetServerVersion(); err == nil {
+		t.Error("Expected an error when the page has no version")
+	}
+}
+
+func TestGetAccessMatrix(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orgs":
+			w.Write([]byte(`[{"id": 1, "name": "Org"}]`))
+		case "/api/orgs/1/access":
+			w.Write([]byte(`{"users": [{"email": "owner@example.com", "access": "owners"}]}`))
+		case "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 10, "name": "WS", "docs": [{"id": "doc1", "name": "Doc"}]}]`))
+		case "/api/workspaces/10/access":
+			w.Write([]byte(`{"maxInheritedRole": "owners", "users": [{"email": "owner@example.com", "parentAccess": "owners"}, {"email": "editor@example.com", "access": "editors"}]}`))
+		case "/api/docs/doc1/access":
+			// Inherited roles are capped at viewers on this document
+			w.Write([]byte(`{"maxInheritedRole": "viewers", "users": [{"email": "owner@example.com", "parentAccess": "owners"}, {"email": "editor@example.com", "access": "viewers", "parentAccess": "editors"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	expected := []AccessEntry{
+		{Org: "Org", Email: "owner@example.com", Role: "owners"},
+		{Org: "Org", Workspace: "WS", Email: "owner@example.com", Role: "owners", Inherited: true},
+		{Org: "Org", Workspace: "WS", Email: "editor@example.com", Role: "editors"},
+		{Org: "Org", Workspace: "WS", Doc: "Doc", Email: "owner@example.com", Role: "viewers", Inherited: true},
+		{Org: "Org", Workspace: "WS", Doc: "Doc", Email: "editor@example.com", Role: "viewers"},
+	}
+	entries, err := GetAccessMatrix()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Unexpected access matrix:\n got %+v\nwant %+v", entries, expected)
+	}
+}
+
+func TestGetAccessMatrix_AccessError(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orgs":
+			w.Write([]byte(`[{"id": 1, "name": "Org"}]`))
+		case "/api/orgs/1/access":
+			w.Write([]byte(`{"users": [{"email": "owner@example.com", "access": "owners"}]}`))
+		case "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 10, "name": "WS", "docs": [{"id": "doc1", "name": "Doc"}]}]`))
+		case "/api/workspaces/10/access":
+			w.Write([]byte(`{"maxInheritedRole": "owners", "users": []}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+	defer cleanup()
+
+	entries, err := GetAccessMatrix()
+	if err == nil {
+		t.Fatalf("Expected an error when the access of a document can't be read, got %+v", entries)
+	}
+	if !strings.Contains(err.Error(), "Doc") || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEffectiveRole(t *testing.T) {
+	tests := []struct {
+		user             User
+		maxInheritedRole string
+		expected         string
+	}{
+		{User{Access: "editors"}, "owners", "editors"},
+		{User{ParentAccess: "viewers"}, "owners", "viewers"},
+		{User{Access: "viewers", ParentAccess: "owners"}, "owners", "owners"},
+		{User{Access: "owners", ParentAccess: "editors"}, "owners", "owners"},
+		{User{}, "owners", ""},
+		// The inherited role is capped
+		{User{ParentAccess: "owners"}, "viewers", "viewers"},
+		{User{Access: "editors", ParentAccess: "owners"}, "viewers", "editors"},
+		{User{ParentAccess: "owners"}, "", ""},
+		{User{Access: "viewers", ParentAccess: "owners"}, "", "viewers"},
+	}
+	for _, tt := range tests {
+		if role := tt.user.EffectiveRole(tt.maxInheritedRole); role != tt.expected {
+			t.Errorf("EffectiveRole(%+v, %q) = %q, expected %q", tt.user, tt.maxInheritedRole, role, tt.expected)
+		}
+	}
+	if !HasRole("owners", "editors") || HasRole("viewers", "editors") || HasRole("", "guests") {
+		t.Error("Unexpected role ordering")
+	}
+}
+
+func TestGetWorkspacesForUser(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orgs":
+			w.Write([]byte(`[{"id": 1, "name": "Org"}]`))
+		case "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 10, "name": "Sales"}, {"id": 11, "name": "Finance"}, {"id": 12, "name": "HR"}, {"id": 13, "name": "Board"}]`))
+		case "/api/workspaces/10/access":
+			w.Write([]byte(`{"maxInheritedRole": "owners", "users": [{"email": "Ann@example.com", "access": "editors"}]}`))
+		case "/api/workspaces/11/access":
+			w.Write([]byte(`{"maxInheritedRole": "owners", "users": [{"email": "ann@example.com", "access": "viewers", "parentAccess": "owners"}]}`))
+		case "/api/workspaces/12/access":
+			w.Write([]byte(`{"maxInheritedRole": "owners", "users": [{"email": "ann@example.com", "access": "viewers"}, {"email": "bob@example.com", "access": "owners"}]}`))
+		case "/api/workspaces/13/access":
+			// Owners of the organization only view this workspace
+			w.Write([]byte(`{"maxInheritedRole": "viewers", "users": [{"email": "ann@example.com", "parentAccess": "owners"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	workspaces, err := GetWorkspacesForUser("ann@example.com", "editors")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []UserWorkspace{
+		{OrgId: 1, OrgName: "Org", WorkspaceId: 10, WorkspaceName: "Sales", Role: "editors"},
+		{OrgId: 1, OrgName: "Org", WorkspaceId: 11, WorkspaceName: "Finance", Role: "owners", Inherited: true},
+	}
+	if !reflect.DeepEqual(workspaces, expected) {
+		t.Errorf("Unexpected workspaces:\n got %+v\nwant %+v", workspaces, expected)
+	}
+
+	workspaces, _ = GetWorkspacesForUser("ann@example.com", "")
+	if len(workspaces) != 4 {
+		t.Fatalf("Expected 4 workspaces with any role, got %d", len(workspaces))
+	}
+	if board := workspaces[3]; board.Role != "viewers" || !board.Inherited {
+		t.Errorf("Expected the inherited role capped at viewers, got %+v", board)
+	}
+	if _, err := GetWorkspacesForUser("ann@example.com", "admins"); err == nil {
+		t.Error("Expected an error for an unknown role")
+	}
+}
+
+func TestBackupDoc(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123":
+			w.Write([]byte(`{"id": "doc123", "name": "Budget", "workspace": {"name": "Finance", "org": {"name": "Acme"}}}`))
+		case "/api/docs/doc123/download":
+			w.Write([]byte("SQLite format 3"))
+		case "/api/docs/doc123/attachments":
+			w.Write([]byte(`{"records": [{"id": 1, "fileName": "report.pdf"}, {"id": 2, "fileName": "../report.pdf"}, {"id": 3, "fileName": ""}, {"id": 4, "fileName": ".."}]}`))
+		case "/api/docs/doc123/attachments/1/download":
+			w.Write([]byte("first"))
+		case "/api/docs/doc123/attachments/2/download":
+			w.Write([]byte("second"))
+		case "/api/docs/doc123/attachments/3/download":
+			w.Write([]byte("third"))
+		case "/api/docs/doc123/attachments/4/download":
+			w.Write([]byte("fourth"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	dir := filepath.Join(t.TempDir(), "backup")
+	manifest, err := BackupDoc("doc123", dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if manifest.DocName != "Budget" || manifest.Workspace != "Finance" || manifest.Org != "Acme" {
+		t.Errorf("Unexpected document metadata: %+v", manifest)
+	}
+
+	expectedPaths := []string{"doc123.grist", "attachments/1_report.pdf", "attachments/2_report.pdf", "attachments/3_attachment-3", "attachments/4_attachment-4"}
+	if len(manifest.Files) != len(expectedPaths) {
+		t.Fatalf("Expected %d files, got %+v", len(expectedPaths), manifest.Files)
+	}
+	for i, file := range manifest.Files {
+		if file.Path != expectedPaths[i] {
+			t.Errorf("Expected file %s, got %s", expectedPaths[i], file.Path)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, file.Path))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Path, err)
+		}
+		checksum := sha256.Sum256(content)
+		if file.SHA256 != hex.EncodeToString(checksum[:]) || file.Size != int64(len(content)) {
+			t.Errorf("Checksum or size mismatch for %s", file.Path)
+		}
+	}
+
+	var written BackupManifest
+	content, err := os.ReadFile(filepath.Join(dir, BackupManifestFile))
+	if err != nil {
+		t.Fatalf("Failed to read the manifest: %v", err)
+	}
+	if err := json.Unmarshal(content, &written); err != nil || !reflect.DeepEqual(written, manifest) {
+		t.Errorf("Expected the manifest to be written, got %s (%v)", content, err)
+	}
+
+	if _, err := BackupDoc("missing", dir); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+}
@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bdmorin/gristle/internal/parquet"
+)
+
+// ExportTableParquet exports the records of a table in Parquet format in
+// destPath file
+// Records are streamed from the API into row groups, so that memory stays
+// bounded whatever the table size. See parquet.NewWriter for the mapping of
+// the column types. The file is removed if the export fails
+func ExportTableParquet(docId string, tableId string, destPath string) error {
+	columns := GetTableColumns(docId, tableId)
+	if len(columns.Columns) == 0 {
+		return fmt.Errorf("unable to get the columns of table %s", tableId)
+	}
+	parquetColumns := make([]parquet.Column, len(columns.Columns))
+	for i, column := range columns.Columns {
+		parquetColumns[i] = parquet.Column{Name: column.Id, Type: column.Fields.Type}
+	}
+
+	// #nosec G304 - destPath is user-provided CLI argument for export destination
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", destPath, err)
+	}
+	buffered := bufio.NewWriter(file)
+	err = func() error {
+		writer, err := parquet.NewWriter(buffered, parquetColumns)
+		if err != nil {
+			return err
+		}
+		if _, err := StreamRecords(docId, tableId, nil, func(record Record) error {
+			return writer.Write(record.Id, record.Fields)
+		}); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}()
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		_ = os.Remove(destPath)
+		return fmt.Errorf("unable to export table %s to %s: %w", tableId, destPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportTableParquet(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables/People/columns", "/api/docs/doc123/tables/Broken/columns":
+			w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"type": "Text"}}]}`))
+		case "/api/docs/doc123/tables/People/records":
+			w.Write([]byte(`{"records": [{"id": 1, "fields": {"Name": "Alice"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	destPath := filepath.Join(t.TempDir(), "people.parquet")
+	if err := ExportTableParquet("doc123", "People", destPath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read the export: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) || !bytes.Contains(data, []byte("Alice")) {
+		t.Errorf("Expected a Parquet file holding the record, got %q", data)
+	}
+
+	if err := ExportTableParquet("doc123", "Missing", destPath); err == nil {
+		t.Error("Expected an error for a missing table")
+	}
+	brokenPath := filepath.Join(t.TempDir(), "broken.parquet")
+	if err := ExportTableParquet("doc123", "Broken", brokenPath); err == nil {
+		t.Error("Expected an error when the records can't be read")
+	}
+	if _, err := os.Stat(brokenPath); !os.IsNotExist(err) {
+		t.Error("Expected the incomplete file to be removed")
+	}
+}
@@ -64,8 +64,7 @@ func TestRecordCRUD(t *testing.T) {
 	docID := "g7pesgBnD5B5FsN4hUF9BB"
 
 	// Verify it's accessible
-	testDoc := GetDoc(docID)
-	if testDoc.Id == "" {
+	if !DocExists(docID) {
 		// Fallback: try to find or create a document
 		docID = findOrCreateTestDocument(t, playgroundWorkspaceID)
 		if docID == "" {
@@ -183,7 +182,7 @@ func testAddSingleRecord(t *testing.T, docId, tableId string) {
 		},
 	}
 
-	result, status := AddRecords(docId, tableId, records, nil)
+	result, _, status := AddRecords(docId, tableId, records, nil)
 	if status != http.StatusOK {
 		t.Errorf("AddRecords failed with status %d", status)
 		return
@@ -225,7 +224,7 @@ func testAddBulkRecords(t *testing.T, docId, tableId string) {
 		{"name": "Diana", "email": "diana@example.com", "age": 28},
 	}
 
-	result, status := AddRecords(docId, tableId, records, nil)
+	result, _, status := AddRecords(docId, tableId, records, nil)
 	if status != http.StatusOK {
 		t.Errorf("AddRecords failed with status %d", status)
 		return
@@ -376,7 +375,7 @@ func testUpdateSingleRecord(t *testing.T, docId, tableId string) {
 	records := []map[string]interface{}{
 		{"name": "UpdateMe", "email": "updateme@test.com", "age": 25},
 	}
-	result, _ := AddRecords(docId, tableId, records, nil)
+	result, _, _ := AddRecords(docId, tableId, records, nil)
 	recordId := result.Records[0].Id
 
 	// Update the record
@@ -423,7 +422,7 @@ func testUpdateBulkRecords(t *testing.T, docId, tableId string) {
 		{"name": "Bulk2", "email": "bulk2@test.com", "age": 31},
 		{"name": "Bulk3", "email": "bulk3@test.com", "age": 32},
 	}
-	result, _ := AddRecords(docId, tableId, records, nil)
+	result, _, _ := AddRecords(docId, tableId, records, nil)
 
 	// Update all records
 	updateRecords := make([]Record, 3)
@@ -462,7 +461,7 @@ func testUpdatePartialFields(t *testing.T, docId, tableId string) {
 	records := []map[string]interface{}{
 		{"name": "Partial", "email": "partial@test.com", "age": 25},
 	}
-	result, _ := AddRecords(docId, tableId, records, nil)
+	result, _, _ := AddRecords(docId, tableId, records, nil)
 	recordId := result.Records[0].Id
 
 	// Update only the age field
@@ -506,7 +505,7 @@ func testDeleteSingleRecord(t *testing.T, docId, tableId string) {
 	records := []map[string]interface{}{
 		{"name": "DeleteMe", "email": "deleteme@test.com", "age": 25},
 	}
-	result, _ := AddRecords(docId, tableId, records, nil)
+	result, _, _ := AddRecords(docId, tableId, records, nil)
 	recordId := result.Records[0].Id
 
 	// Delete the record
@@ -534,7 +533,7 @@ func testDeleteBulkRecords(t *testing.T, docId, tableId string) {
 		{"name": "BulkDel2", "email": "bulkdel2@test.com", "age": 31},
 		{"name": "BulkDel3", "email": "bulkdel3@test.com", "age": 32},
 	}
-	result, _ := AddRecords(docId, tableId, records, nil)
+	result, _, _ := AddRecords(docId, tableId, records, nil)
 
 	// Delete all records
 	recordIds := make([]int, 3)
@@ -671,7 +670,7 @@ func testEdgeCasesUnicode(t *testing.T, docId, tableId string) {
 				},
 			}
 
-			result, status := AddRecords(docId, tableId, records, nil)
+			result, _, status := AddRecords(docId, tableId, records, nil)
 			if status != http.StatusOK {
 				t.Errorf("AddRecords failed for %s with status %d", tc.name, status)
 				return
@@ -746,7 +745,7 @@ func testEdgeCasesSpecialChars(t *testing.T, docId, tableId string) {
 				},
 			}
 
-			result, status := AddRecords(docId, tableId, records, nil)
+			result, _, status := AddRecords(docId, tableId, records, nil)
 			if status != http.StatusOK {
 				t.Errorf("AddRecords failed for %s with status %d", tc.name, status)
 				return
@@ -806,7 +805,7 @@ func testEdgeCasesNulls(t *testing.T, docId, tableId string) {
 				},
 			}
 
-			result, status := AddRecords(docId, tableId, records, nil)
+			result, _, status := AddRecords(docId, tableId, records, nil)
 			if status != http.StatusOK {
 				t.Errorf("AddRecords failed for %s with status %d", tc.name, status)
 				return
@@ -841,7 +840,7 @@ func testEdgeCasesLargeText(t *testing.T, docId, tableId string) {
 		},
 	}
 
-	result, status := AddRecords(docId, tableId, records, nil)
+	result, _, status := AddRecords(docId, tableId, records, nil)
 	if status != http.StatusOK {
 		t.Errorf("AddRecords failed for large text with status %d", status)
 		return
@@ -904,7 +903,7 @@ func testEdgeCasesLargeNumbers(t *testing.T, docId, tableId string) {
 				},
 			}
 
-			result, status := AddRecords(docId, tableId, records, nil)
+			result, _, status := AddRecords(docId, tableId, records, nil)
 			if status != http.StatusOK {
 				t.Errorf("AddRecords failed for %s with status %d", tc.name, status)
 				return
@@ -951,7 +950,7 @@ func testBulkDataLoad(t *testing.T, docId, tableId string) {
 		}
 
 		batch := records[i:end]
-		result, status := AddRecords(docId, tableId, batch, nil)
+		result, _, status := AddRecords(docId, tableId, batch, nil)
 		if status != http.StatusOK {
 			t.Errorf("AddRecords failed for batch %d-%d with status %d", i, end, status)
 			continue
@@ -1051,12 +1050,11 @@ func prettyPrintJSON(v interface{}) string {
 // findOrCreateTestDocument finds an existing test document or creates a new one
 func findOrCreateTestDocument(t *testing.T, workspaceID int) string {
 	// Try to find an existing document first
-	workspace := GetWorkspace(workspaceID)
+	workspace, _ := GetWorkspace(workspaceID)
 	for _, doc := range workspace.Docs {
 		if strings.Contains(doc.Name, "Record") || strings.Contains(doc.Name, "Test") {
 			// Verify the document is accessible
-			testDoc := GetDoc(doc.Id)
-			if testDoc.Id != "" {
+			if DocExists(doc.Id) {
 				t.Logf("Found existing document: %s (%s)", doc.Name, doc.Id)
 				return doc.Id
 			}
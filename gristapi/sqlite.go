@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bdmorin/gristle/internal/sqlite"
+)
+
+// ExportTableSQLite exports a table into a standalone SQLite database in
+// destPath file, the table keeping its name and its column types. See
+// sqlite.Write for the mapping of the column types. The file is removed if
+// the export fails
+func ExportTableSQLite(docId string, tableId string, destPath string) error {
+	columns := GetTableColumns(docId, tableId)
+	if len(columns.Columns) == 0 {
+		return fmt.Errorf("unable to get the columns of table %s", tableId)
+	}
+	records, status := GetRecords(docId, tableId, nil)
+	if status != http.StatusOK {
+		return fmt.Errorf("unable to get the records of table %s: HTTP %d", tableId, status)
+	}
+	sqliteColumns := make([]sqlite.Column, len(columns.Columns))
+	for i, column := range columns.Columns {
+		sqliteColumns[i] = sqlite.Column{Name: column.Id, Type: column.Fields.Type}
+	}
+	rows := make([]sqlite.Row, len(records.Records))
+	for i, record := range records.Records {
+		rows[i] = sqlite.Row{Id: record.Id, Fields: record.Fields}
+	}
+
+	// #nosec G304 - destPath is user-provided CLI argument for export destination
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", destPath, err)
+	}
+	buffered := bufio.NewWriter(file)
+	err = sqlite.Write(buffered, tableId, sqliteColumns, rows)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		_ = os.Remove(destPath)
+		return fmt.Errorf("unable to export table %s to %s: %w", tableId, destPath, err)
+	}
+	return nil
+}
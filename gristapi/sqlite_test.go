@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportTableSQLite(t *testing.T) {
+	_, cleanup := setupMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc123/tables/People/columns":
+			w.Write([]byte(`{"columns": [{"id": "Name", "fields": {"type": "Text"}}]}`))
+		case "/api/docs/doc123/tables/People/records":
+			w.Write([]byte(`{"records": [{"id": 1, "fields": {"Name": "Alice"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	destPath := filepath.Join(t.TempDir(), "people.sqlite")
+	if err := ExportTableSQLite("doc123", "People", destPath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read the export: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) || !bytes.Contains(data, []byte("Alice")) {
+		t.Error("Expected the record in a SQLite database")
+	}
+
+	if err := ExportTableSQLite("doc123", "Missing", destPath); err == nil {
+		t.Error("Expected an error for a missing table")
+	}
+}
@@ -409,7 +409,7 @@ func testAllColumnTypes(t *testing.T, docID string) {
 func testPopulateTestData(t *testing.T, docID string) {
 	t.Run("PopulateProducts", func(t *testing.T) {
 		records := generateProductRecords(75)
-		result, status := AddRecords(docID, "Products", records, nil)
+		result, _, status := AddRecords(docID, "Products", records, nil)
 
 		if status != http.StatusOK {
 			t.Errorf("Failed to add product records: HTTP %d", status)
@@ -425,7 +425,7 @@ func testPopulateTestData(t *testing.T, docID string) {
 
 	t.Run("PopulateEvents", func(t *testing.T) {
 		records := generateEventRecords(50)
-		result, status := AddRecords(docID, "Events", records, nil)
+		result, _, status := AddRecords(docID, "Events", records, nil)
 
 		if status != http.StatusOK {
 			t.Errorf("Failed to add event records: HTTP %d", status)
@@ -456,7 +456,7 @@ func testPopulateTestData(t *testing.T, docID string) {
 		}
 
 		records := generateAllTypesRecords(60)
-		result, status := AddRecords(docID, "AllTypes", records, nil)
+		result, _, status := AddRecords(docID, "AllTypes", records, nil)
 
 		if status != http.StatusOK {
 			t.Logf("Note: Failed to add AllTypes records (HTTP %d) - some column types may not accept the test data format", status)
@@ -7,18 +7,29 @@ package gristtools
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bdmorin/gristle/common"
 	"github.com/bdmorin/gristle/gristapi"
 	"github.com/go-gota/gota/dataframe"
+	"github.com/muesli/termenv"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -28,6 +39,14 @@ func SetOutput(out string) {
 	output = out
 }
 
+// Directory export files are written to, the current directory if empty
+var exportDir string
+
+// SetExportDir sets the directory export files are written to
+func SetExportDir(dir string) {
+	exportDir = dir
+}
+
 // Display help message and quit
 func Help() {
 
@@ -78,9 +97,107 @@ func Help() {
 	os.Exit(0)
 }
 
-// Displays the version of the program
+// Displays the version of the program with its build metadata, and the
+// version of the configured Grist server if it is reachable
 func Version(version string) {
-	fmt.Println("Version : ", version)
+	type versionInfo struct {
+		Version       string `json:"version"`
+		Commit        string `json:"commit,omitempty"`
+		Modified      bool   `json:"modified,omitempty"`
+		GoVersion     string `json:"goVersion"`
+		OS            string `json:"os"`
+		Arch          string `json:"arch"`
+		ServerURL     string `json:"serverUrl,omitempty"`
+		ServerVersion string `json:"serverVersion,omitempty"`
+		ServerError   string `json:"serverError,omitempty"`
+	}
+	info := versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		ServerURL: os.Getenv("GRIST_URL"),
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.ServerURL != "" {
+		serverVersion, err := gristapi.GetServerVersion()
+		if err != nil {
+			info.ServerError = err.Error()
+		}
+		info.ServerVersion = serverVersion
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	default:
+		fmt.Println("Version : ", info.Version)
+		if info.Commit != "" {
+			commit := info.Commit
+			if info.Modified {
+				commit += " (modified)"
+			}
+			fmt.Println("Commit : ", commit)
+		}
+		fmt.Printf("Go : %s %s/%s\n", info.GoVersion, info.OS, info.Arch)
+		switch {
+		case info.ServerURL == "":
+			fmt.Println("Server : not configured")
+		case info.ServerError != "":
+			fmt.Printf("Server : %s (version unknown: %s)\n", info.ServerURL, info.ServerError)
+		default:
+			fmt.Printf("Server : %s (Grist %s)\n", info.ServerURL, info.ServerVersion)
+		}
+	}
+}
+
+// Checks the connection to the configured server and prints the
+// authenticated user, or why the server couldn't be reached
+// Returns false if the connection failed
+func TestConfig() bool {
+	user, err := gristapi.CheckConnection()
+	if err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return false
+	}
+	fmt.Printf("Connected to %s as %s <%s> ✅\n", os.Getenv("GRIST_URL"), user.Name, user.Email)
+	return true
+}
+
+// CallAPI sends a request to any endpoint of the API and prints the
+// response, indented if it is JSON
+// Returns false if the request failed or the server answered with an error
+func CallAPI(method string, path string, body string) bool {
+	response, status := gristapi.CallAPI(method, path, body)
+	if status < 0 {
+		fmt.Fprintf(os.Stderr, "❗️ %s ❗️\n", response)
+		return false
+	}
+	var indented bytes.Buffer
+	if json.Indent(&indented, []byte(response), "", "  ") == nil {
+		response = indented.String()
+	}
+	if response != "" {
+		fmt.Println(strings.TrimRight(response, "\n"))
+	}
+	if status < 200 || status >= 300 {
+		fmt.Fprintf(os.Stderr, "❗️ HTTP %d ❗️\n", status)
+		return false
+	}
+	return true
 }
 
 /*
@@ -153,6 +270,47 @@ func Config() {
 	}
 }
 
+// Displays the workspaces on which the user with this email holds at least
+// minRole, in every organization
+// Returns false if minRole is not a role
+func DisplayUserWorkspaces(email string, minRole string) bool {
+	workspaces, err := gristapi.GetWorkspacesForUser(email, minRole)
+	if err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return false
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(workspaces, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		title := fmt.Sprintf("Workspaces of %s", email)
+		if minRole != "" {
+			title = fmt.Sprintf("Workspaces of %s with role %s or above", email, minRole)
+		}
+		common.DisplayTitle(title)
+		if len(workspaces) == 0 {
+			fmt.Println("No workspace found")
+			return true
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Org", "Workspace id", "Workspace", "Role", "Inherited"})
+		for _, ws := range workspaces {
+			inherited := ""
+			if ws.Inherited {
+				inherited = "yes"
+			}
+			table.Append([]string{ws.OrgName, strconv.Itoa(ws.WorkspaceId), ws.WorkspaceName, ws.Role, inherited})
+		}
+		table.Render()
+	}
+	return true
+}
+
 /*
 User role translation
 
@@ -246,7 +404,12 @@ func ImportUsers() {
 				roles = append(roles, newRole)
 			}
 		}
-		gristapi.ImportUsers(orgId, workspaceId, roles)
+		idWorkspace, err := gristapi.ImportUsers(orgId, workspaceId, roles)
+		if err != nil {
+			fmt.Printf("Import %d users in workspace %s\t : ❗️ (%s)\n", len(roles), workspaceId, err)
+		} else {
+			fmt.Printf("Import %d users in workspace n°%d\t : ✅\n", len(roles), idWorkspace)
+		}
 	}
 }
 
@@ -287,6 +450,50 @@ func DisplayOrgAccess(idOrg string) {
   - Number of rows
   - List of columns
 */
+// DocDetails describes a document with the workspace and organization
+// containing it and its tables, as shown by doc get and the get_doc MCP tool
+type DocDetails struct {
+	Id            string           `json:"id"`
+	Name          string           `json:"name"`
+	IsPinned      bool             `json:"is_pinned"`
+	CreatedAt     string           `json:"created_at"`
+	UpdatedAt     string           `json:"updated_at"`
+	WorkspaceId   int              `json:"workspace_id"`
+	WorkspaceName string           `json:"workspace"`
+	OrgId         int              `json:"org_id"`
+	OrgName       string           `json:"org"`
+	OrgDomain     string           `json:"org_domain"`
+	Tables        []gristapi.Table `json:"tables"`
+}
+
+// GetDocDetails retrieves a document, its workspace and organization and
+// the list of its tables
+// Returns the HTTP status of the document request. The requests are
+// cancelled with ctx
+func GetDocDetails(ctx context.Context, docId string) (DocDetails, int) {
+	doc, status := gristapi.GetDocContext(ctx, docId)
+	if status != http.StatusOK {
+		return DocDetails{}, status
+	}
+	orgDomain := doc.Workspace.Org.Domain
+	if orgDomain == "" {
+		orgDomain = doc.Workspace.OrgDomain
+	}
+	return DocDetails{
+		Id:            doc.Id,
+		Name:          doc.Name,
+		IsPinned:      doc.IsPinned,
+		CreatedAt:     doc.CreatedAt,
+		UpdatedAt:     doc.UpdatedAt,
+		WorkspaceId:   doc.Workspace.Id,
+		WorkspaceName: doc.Workspace.Name,
+		OrgId:         doc.Workspace.Org.Id,
+		OrgName:       doc.Workspace.Org.Name,
+		OrgDomain:     orgDomain,
+		Tables:        gristapi.GetDocTablesContext(ctx, docId).Tables,
+	}, status
+}
+
 func DisplayDoc(docId string) {
 	type TableDetails struct {
 		Name       string
@@ -296,33 +503,51 @@ func DisplayDoc(docId string) {
 	}
 
 	type DocInfo struct {
-		Id       string         `json:"id"`
-		Name     string         `json:"name"`
-		IsPinned bool           `json:"pined"`
-		NbTables int            `json:"nbTables"`
-		Tables   []TableDetails `json:"tables"`
+		Id          string             `json:"id"`
+		Name        string             `json:"name"`
+		IsPinned    bool               `json:"pined"`
+		CreatedAt   string             `json:"createdAt"`
+		UpdatedAt   string             `json:"updatedAt"`
+		WorkspaceId int                `json:"workspaceId"`
+		Workspace   string             `json:"workspace"`
+		OrgId       int                `json:"orgId"`
+		Org         string             `json:"org"`
+		OrgDomain   string             `json:"orgDomain"`
+		Usage       *gristapi.DocUsage `json:"usage,omitempty"` // nil if it couldn't be retrieved
+		NbTables    int                `json:"nbTables"`
+		Tables      []TableDetails     `json:"tables"`
 	}
 
-	// Getting the document
-	doc := gristapi.GetDoc(docId)
-	if doc.Id == "" {
+	// Getting the document, its context and its tables
+	doc, status := GetDocDetails(context.Background(), docId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
 	} else {
 		// Document was found
-		// Getting the doc's tables
-		var tables gristapi.Tables = gristapi.GetDocTables(docId)
+		usage, usageStatus := gristapi.GetDocUsage(docId)
 
 		myDoc := DocInfo{
-			Id:       doc.Id,
-			Name:     doc.Name,
-			IsPinned: doc.IsPinned,
-			NbTables: len(tables.Tables),
+			Id:          doc.Id,
+			Name:        doc.Name,
+			IsPinned:    doc.IsPinned,
+			CreatedAt:   doc.CreatedAt,
+			UpdatedAt:   doc.UpdatedAt,
+			WorkspaceId: doc.WorkspaceId,
+			Workspace:   doc.WorkspaceName,
+			OrgId:       doc.OrgId,
+			Org:         doc.OrgName,
+			OrgDomain:   doc.OrgDomain,
+			NbTables:    len(doc.Tables),
+		}
+
+		if usageStatus == http.StatusOK {
+			myDoc.Usage = &usage
 		}
 
 		// Getting the tables details
 		var wg sync.WaitGroup
 		var tables_details []TableDetails
-		for _, table := range tables.Tables {
+		for _, table := range doc.Tables {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
@@ -368,6 +593,16 @@ func DisplayDoc(docId string) {
 					pinned = "📌"
 				}
 				common.DisplayTitle(fmt.Sprintf("Document '%s' (%s) %s", myDoc.Name, myDoc.Id, pinned))
+				fmt.Printf("Workspace: %s (%d)\n", myDoc.Workspace, myDoc.WorkspaceId)
+				fmt.Printf("Organization: %s (%s)\n", myDoc.Org, myDoc.OrgDomain)
+				if modified := (gristapi.Doc{UpdatedAt: doc.UpdatedAt}).LastModified(); !modified.IsZero() {
+					fmt.Printf("Last modified: %s\n", modified.Local().Format("2006-01-02 15:04"))
+				}
+				if usageStatus == http.StatusOK {
+					fmt.Printf("Size: %s\n", formatBytes(usage.TotalBytes()))
+				} else {
+					fmt.Printf("Size: unknown (HTTP %d)\n", usageStatus)
+				}
 				fmt.Printf("Contains %d tables :\n", myDoc.NbTables)
 				// Displaying the tables details
 				tableView := tablewriter.NewWriter(os.Stdout)
@@ -388,15 +623,23 @@ func DisplayDoc(docId string) {
 
 }
 
+// Keys by which organizations can be sorted
+var OrgSortKeys = []string{"id", "name", "domain", "created"}
+
+// Options of the list of organizations
+// Filter keeps the organizations whose name or domain contains it (case
+// insensitive). Sort is one of OrgSortKeys, the API order being kept if empty
+type OrgListOptions struct {
+	Filter string
+	Sort   string
+}
+
 // Displays the list of accessible organizations
-func DisplayOrgs() {
+func DisplayOrgs(options OrgListOptions) {
 
 	// Getting the list of organizations
-	lstOrgs := gristapi.GetOrgs()
-	// Sorting the list of organizations by name (lowercase)
-	sort.Slice(lstOrgs, func(i, j int) bool {
-		return strings.ToLower(lstOrgs[i].Name) < strings.ToLower(lstOrgs[j].Name)
-	})
+	lstOrgs := filterOrgs(gristapi.GetOrgs(), options.Filter)
+	sortOrgs(lstOrgs, options.Sort)
 	table := tablewriter.NewWriter(os.Stdout)
 
 	switch output {
@@ -419,6 +662,40 @@ func DisplayOrgs() {
 	}
 }
 
+// filterOrgs keeps the organizations whose name or domain contains filter
+func filterOrgs(orgs []gristapi.Org, filter string) []gristapi.Org {
+	if filter == "" {
+		return orgs
+	}
+	filter = strings.ToLower(filter)
+	filtered := []gristapi.Org{}
+	for _, org := range orgs {
+		if strings.Contains(strings.ToLower(org.Name), filter) || strings.Contains(strings.ToLower(org.Domain), filter) {
+			filtered = append(filtered, org)
+		}
+	}
+	return filtered
+}
+
+// sortOrgs sorts organizations by one of OrgSortKeys, names and domains
+// being compared in lowercase
+func sortOrgs(orgs []gristapi.Org, key string) {
+	var less func(a, b gristapi.Org) bool
+	switch key {
+	case "id":
+		less = func(a, b gristapi.Org) bool { return a.Id < b.Id }
+	case "name":
+		less = func(a, b gristapi.Org) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "domain":
+		less = func(a, b gristapi.Org) bool { return strings.ToLower(a.Domain) < strings.ToLower(b.Domain) }
+	case "created":
+		less = func(a, b gristapi.Org) bool { return a.CreatedAt < b.CreatedAt }
+	default:
+		return
+	}
+	sort.SliceStable(orgs, func(i, j int) bool { return less(orgs[i], orgs[j]) })
+}
+
 // Displays details about an organization
 func DisplayOrg(orgId string) {
 
@@ -438,9 +715,9 @@ func DisplayOrg(orgId string) {
 
 	var lstWsDesc []WpDesc
 
-	org := gristapi.GetOrg(orgId)
-	if org.Id == 0 {
-		fmt.Printf("❗️ Organization %s not found ❗️\n", orgId)
+	org, status := gristapi.GetOrg(orgId)
+	if status != http.StatusOK {
+		printOrgError(orgId, status)
 	} else {
 
 		// Org was found
@@ -517,8 +794,8 @@ func DisplayWorkspace(workspaceId int) {
 	}
 
 	// Getting the workspace
-	ws := gristapi.GetWorkspace(workspaceId)
-	if ws.Id == 0 {
+	ws, status := gristapi.GetWorkspace(workspaceId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Workspace %d not found ❗️\n", workspaceId)
 	} else {
 		// Workspace was found
@@ -602,8 +879,8 @@ func DisplayWorkspaceAccess(workspaceId int) {
 	}
 
 	// Getting the workspace
-	ws := gristapi.GetWorkspace((workspaceId))
-	if ws.Id == 0 {
+	ws, status := gristapi.GetWorkspace(workspaceId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Workspace %d not found ❗️\n", workspaceId)
 	} else {
 		// Workspace was found
@@ -689,8 +966,8 @@ func DisplayDocAccess(docId string) {
 	var myDocAccess DocAcces
 
 	// Getting the document
-	doc := gristapi.GetDoc(docId)
-	if doc.Name == "" {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
 	} else {
 		// Document was found
@@ -773,8 +1050,8 @@ func DisplayDocWebhooks(docId string) {
 	}
 
 	// Getting the document
-	doc := gristapi.GetDoc(docId)
-	if doc.Name == "" {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
 		return
 	}
@@ -845,6 +1122,67 @@ func DisplayDocWebhooks(docId string) {
 	}
 }
 
+// DisplayDocAccessRules displays the access rules of a document and the
+// user attributes their conditions rely on
+func DisplayDocAccessRules(docId string) {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return
+	}
+	accessRules, status := gristapi.GetDocAccessRules(docId)
+	switch status {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		fmt.Printf("❗️ Only owners of document %s can read its access rules ❗️\n", docId)
+		return
+	default:
+		fmt.Printf("❗️ Unable to get the access rules of document %s: HTTP %d ❗️\n", docId, status)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(struct {
+			DocId   string `json:"docId"`
+			DocName string `json:"docName"`
+			gristapi.DocAccessRules
+		}{doc.Id, doc.Name, accessRules}, "", "   ")
+		if err != nil {
+			fmt.Println(err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Document \"%s\" (%s)", doc.Name, doc.Id))
+		if len(accessRules.Rules) == 0 {
+			fmt.Println("No access rules: every user has the permissions of their role")
+		} else {
+			fmt.Printf("Contains %d access rule(s), applied in this order:\n", len(accessRules.Rules))
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Table", "Columns", "Condition", "Permissions", "Memo"})
+			table.SetAutoWrapText(false)
+			for _, rule := range accessRules.Rules {
+				condition := rule.Formula
+				if condition == "" {
+					condition = "Everyone"
+				}
+				table.Append([]string{rule.TableId, rule.ColIds, condition, rule.Permissions, rule.Memo})
+			}
+			table.Render()
+			fmt.Println("Permissions: C create, R read, U update, D delete, S schema edit (+ granted, - denied)")
+		}
+		if len(accessRules.UserAttributes) > 0 {
+			fmt.Printf("\n%d user attribute(s):\n", len(accessRules.UserAttributes))
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Name", "Table", "Lookup column", "User property"})
+			for _, attribute := range accessRules.UserAttributes {
+				table.Append([]string{attribute.Name, attribute.TableId, attribute.LookupColId, "user." + attribute.CharId})
+			}
+			table.Render()
+		}
+	}
+}
+
 // Displaying the rights matrix
 func DisplayUserMatrix() {
 	type userAccess struct {
@@ -861,34 +1199,31 @@ func DisplayUserMatrix() {
 	}
 	lstUserAccess := []userAccess{}
 
-	lstOrg := gristapi.GetOrgs()
-	for _, org := range lstOrg {
-		for _, ws := range gristapi.GetOrgWorkspaces(org.Id) {
-			for _, access := range gristapi.GetWorkspaceAccess(ws.Id).Users {
-				tmpUserAccess := userAccess{
-					Id:            access.Id,
-					Email:         access.Email,
-					Name:          access.Name,
-					OrgId:         org.Id,
-					OrgName:       org.Name,
-					WorkspaceName: ws.Name,
-					WokspaceId:    ws.Id,
-					ParentAccess:  access.ParentAccess,
-					DirectAccess:  access.Access,
-				}
-				if access.Access != "" {
+	gristapi.WalkWorkspaces(func(org gristapi.Org, ws gristapi.Workspace) {
+		for _, access := range gristapi.GetWorkspaceAccess(ws.Id).Users {
+			tmpUserAccess := userAccess{
+				Id:            access.Id,
+				Email:         access.Email,
+				Name:          access.Name,
+				OrgId:         org.Id,
+				OrgName:       org.Name,
+				WorkspaceName: ws.Name,
+				WokspaceId:    ws.Id,
+				ParentAccess:  access.ParentAccess,
+				DirectAccess:  access.Access,
+			}
+			if access.Access != "" {
+				tmpUserAccess.Access = access.Access
+			} else {
+				if access.ParentAccess != "" {
 					tmpUserAccess.Access = access.Access
-				} else {
-					if access.ParentAccess != "" {
-						tmpUserAccess.Access = access.Access
-					}
-				}
-				if access.Access != "" {
-					lstUserAccess = append(lstUserAccess, tmpUserAccess)
 				}
 			}
+			if access.Access != "" {
+				lstUserAccess = append(lstUserAccess, tmpUserAccess)
+			}
 		}
-	}
+	})
 
 	switch output {
 	case "json":
@@ -917,108 +1252,1595 @@ func DisplayUserMatrix() {
 	}
 }
 
+// AccessExportFormats lists the formats accepted by ExportAccessMatrix
+var AccessExportFormats = []string{"csv"}
+
+// Export the permission matrix of the whole instance: one line per user role
+// on an organization, a workspace or a document
+func ExportAccessMatrix(format string) error {
+	if !slices.Contains(AccessExportFormats, format) {
+		return fmt.Errorf("invalid format: %s (expected %s)", format, strings.Join(AccessExportFormats, ", "))
+	}
+
+	entries, err := gristapi.GetAccessMatrix()
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write([]string{"org", "workspace", "doc", "email", "role", "inherited"})
+	for _, entry := range entries {
+		writer.Write([]string{entry.Org, entry.Workspace, entry.Doc, entry.Email, entry.Role, strconv.FormatBool(entry.Inherited)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
 // Delete an organization
 func DeleteOrg(orgId int, orgName string) {
 	if common.Confirm(fmt.Sprintf("Do you really want to delete workspace %d : %s ?", orgId, orgName)) {
-		gristapi.DeleteOrg(orgId, orgName)
+		if err := gristapi.DeleteOrg(orgId, orgName); err != nil {
+			fmt.Printf("%s ❗️\n", err)
+		} else {
+			fmt.Printf("Organization %d : %s deleted\t✅\n", orgId, orgName)
+		}
 	}
 }
 
 // Delete a workspace
 func DeleteWorkspace(workspaceId int) {
 	if common.Confirm(fmt.Sprintf("Do you really want to delete workspace %d ?", workspaceId)) {
-		gristapi.DeleteWorkspace(workspaceId)
+		if err := gristapi.DeleteWorkspace(workspaceId); err != nil {
+			fmt.Printf("%s ❗️\n", err)
+		} else {
+			fmt.Printf("Workspace %d deleted\t✅\n", workspaceId)
+		}
 	}
 }
 
 // Delete a document
 func DeleteDoc(docId string) {
 	if common.Confirm(fmt.Sprintf("Do you really want to delete document %s ?", docId)) {
-		gristapi.DeleteDoc(docId)
+		if err := gristapi.DeleteDoc(docId); err != nil {
+			fmt.Printf("%s ❗️\n", err)
+		} else {
+			fmt.Printf("Document %s deleted\t✅\n", docId)
+		}
 	}
 }
 
 // Delete a user
 func DeleteUser(userId int) {
 	if common.Confirm(fmt.Sprintf("Do you really want to delete user %d ?", userId)) {
-		gristapi.DeleteUser(userId)
+		if err := gristapi.DeleteUser(userId); err != nil {
+			fmt.Printf("%s ❗️\n", err)
+		} else {
+			fmt.Printf("User %d deleted\t✅\n", userId)
+		}
+	}
+}
+
+// docExportPath returns the path of the export of a document, or of one of
+// its tables, in the export directory, named after its workspace
+func docExportPath(doc gristapi.Doc, tableId string, extension string) string {
+	names := []string{doc.Workspace.Name, doc.Name}
+	if tableId != "" {
+		names = append(names, tableId)
 	}
+	return filepath.Join(exportDir, gristapi.ExportFileName(extension, names...))
 }
 
 // Export a document as a Grist file
 func ExportDocGrist(docId string) {
-	doc := gristapi.GetDoc(docId)
-	if doc.Name != "" {
-		gristapi.ExportDocGrist(docId, doc.Workspace.Name+"_"+doc.Name+".grist")
+	doc, status := gristapi.GetDoc(docId)
+	if status == http.StatusOK {
+		fileName := docExportPath(doc, "", "grist")
+		if err := gristapi.ExportDocGrist(docId, fileName); err != nil {
+			fmt.Printf("❗️ %s ❗️\n", err)
+		} else {
+			fmt.Printf("Document exported to %s ✅\n", fileName)
+		}
+	} else {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+	}
+}
+
+// Exports a table of a document in one of gristapi.TableExportFormats
+func ExportDocTable(docId string, format string, options *gristapi.ExportOptions) {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return
+	}
+	if options == nil || options.TableId == "" {
+		fmt.Printf("❗️ A table is required to export as %s (--table) ❗️\n", format)
+		return
+	}
+	extension := format
+	if format == "table-schema" {
+		extension = "schema.json"
+	}
+	fileName := docExportPath(doc, options.TableId, extension)
+	if err := gristapi.ExportTable(docId, options.TableId, format, fileName, options); err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
 	} else {
+		fmt.Printf("Table exported to %s ✅\n", fileName)
+	}
+}
+
+// Export a table in Parquet format or into a SQLite database, in a file
+// named after the document
+func ExportTableFile(docId string, tableId string, format string) {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return
+	}
+	fileName := docExportPath(doc, tableId, format)
+	export := gristapi.ExportTableParquet
+	if format == "sqlite" {
+		export = gristapi.ExportTableSQLite
+	}
+	if err := export(docId, tableId, fileName); err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+	} else {
+		fmt.Printf("Table exported to %s ✅\n", fileName)
 	}
 }
 
 // Export a document as an Excel file
-func ExportDocExcel(docId string) {
-	doc := gristapi.GetDoc(docId)
-	if doc.Name != "" {
-		gristapi.ExportDocExcel(docId, doc.Workspace.Name+"_"+doc.Name+".xlsx")
+// options may restrict the export to a table or a view section
+func ExportDocExcel(docId string, options *gristapi.ExportOptions) {
+	doc, status := gristapi.GetDoc(docId)
+	if status == http.StatusOK {
+		tableId := ""
+		if options != nil {
+			tableId = options.TableId
+		}
+		fileName := docExportPath(doc, tableId, "xlsx")
+		if err := gristapi.ExportDocExcel(docId, fileName, options); err != nil {
+			fmt.Printf("❗️ %s ❗️\n", err)
+		} else {
+			fmt.Printf("Document exported to %s ✅\n", fileName)
+		}
 	} else {
 		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
 	}
 }
 
-// Move a document to a workspace
-func MoveDoc(docId string, workspaceId int) {
-	doc := gristapi.GetDoc(docId)
-	ws := gristapi.GetWorkspace(workspaceId)
+// Move a document to a workspace, returning the outcome of the move
+func MoveDoc(docId string, workspaceId int) ([]gristapi.MoveResult, error) {
+	_, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return nil, fmt.Errorf("document %s not found", docId)
+	}
+	if _, status := gristapi.GetWorkspace(workspaceId); status != http.StatusOK {
+		fmt.Printf("❗️ Workspace %d not found ❗️\n", workspaceId)
+		return nil, fmt.Errorf("workspace %d not found", workspaceId)
+	}
+
+	result := gristapi.MoveResult{DocId: docId, Success: true}
+	if err := gristapi.MoveDoc(docId, workspaceId); err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		fmt.Printf("%s ❗️\n", err)
+	} else {
+		fmt.Printf("Document moved to workspace %d ✅\n", workspaceId)
+	}
+	return []gristapi.MoveResult{result}, nil
+}
+
+// historyWorkers is the number of state comparisons DisplayDocHistory runs
+// at a time
+const historyWorkers = 4
+
+// Displays the history of a document: its limit last states, most recent
+// first, with the changes made by each one
+// Grist only dates the document's last modification, given for the most
+// recent state
+// Returns false if the history couldn't be retrieved
+func DisplayDocHistory(docId string, limit int) bool {
+	type HistoryEntry struct {
+		Action  int    `json:"action"`
+		Hash    string `json:"hash"`
+		Time    string `json:"time,omitempty"`
+		Summary string `json:"summary,omitempty"`
+	}
 
-	if doc.Name == "" {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
 		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return false
+	}
+	states, status := gristapi.GetDocStates(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the history of document %s: HTTP %d ❗️\n", docId, status)
+		return false
+	}
+
+	shown := states
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	entries := make([]HistoryEntry, len(shown))
+	for i, state := range shown {
+		entries[i] = HistoryEntry{Action: state.N, Hash: state.Hash}
+	}
+
+	// Each state is compared with the previous one by a few workers
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for range historyWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				comparison, status := gristapi.CompareDocStates(docId, states[i+1].Hash, states[i].Hash)
+				if status == http.StatusOK && comparison.Details != nil {
+					entries[i].Summary = comparison.Details.RightChanges.String()
+				}
+			}
+		}()
+	}
+	for i := range shown {
+		if i+1 < len(states) {
+			pending <- i
+		}
+	}
+	close(pending)
+	wg.Wait()
+	if modified := doc.LastModified(); len(entries) > 0 && !modified.IsZero() {
+		entries[0].Time = modified.Format(time.RFC3339)
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("History of document %s (%d states)", doc.Name, len(states)))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Action", "Hash", "Time", "Changes"})
+		for _, entry := range entries {
+			when := ""
+			if entry.Time != "" {
+				when = doc.LastModified().Local().Format("2006-01-02 15:04")
+			}
+			table.Append([]string{strconv.Itoa(entry.Action), entry.Hash, when, entry.Summary})
+		}
+		table.Render()
+		if len(shown) < len(states) {
+			fmt.Printf("%d older states not shown (--limit)\n", len(states)-len(shown))
+		}
+	}
+	return true
+}
+
+// Purge a document's history, keeping the nbHisto last states
+func PurgeDoc(docId string, nbHisto int) {
+	if err := gristapi.PurgeDoc(docId, nbHisto); err != nil {
+		fmt.Printf("%s ❗️\n", err)
 	} else {
-		if ws.Id == 0 {
-			fmt.Printf("❗️ Workspace %d not found ❗️\n", workspaceId)
-		} else {
-			gristapi.MoveDoc(docId, workspaceId)
+		fmt.Printf("History cleared (%d last states) ✅\n", nbHisto)
+	}
+}
+
+// Displays the attachments of a document
+func DisplayAttachments(docId string) {
+	attachments, status := gristapi.ListAttachments(docId, nil)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to list the attachments of document %s: HTTP %d ❗️\n", docId, status)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(attachments.Records, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Attachments of document %s", docId))
+		if len(attachments.Records) == 0 {
+			fmt.Println("No attachments in this document")
+			return
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", "File name", "Size", "Uploaded"})
+		for _, attachment := range attachments.Records {
+			table.Append([]string{strconv.Itoa(attachment.Id), attachment.FileName, formatBytes(attachment.FileSize), attachment.TimeUploaded})
 		}
+		table.Render()
 	}
 }
 
-// Move all documents from a workspace to another
-func MoveAllDocs(fromWorkspaceId int, toWorkspaceId int) {
-	from_ws := gristapi.GetWorkspace(fromWorkspaceId)
-	to_ws := gristapi.GetWorkspace(toWorkspaceId)
+// Uploads files as attachments of a document and prints their IDs
+// Returns false if the upload failed
+func UploadAttachments(docId string, files []string) bool {
+	ids, status := gristapi.UploadAttachments(docId, files)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to upload attachments to document %s: HTTP %d ❗️\n", docId, status)
+		return false
+	}
+	if len(ids) != len(files) {
+		fmt.Printf("❗️ %d files uploaded to document %s, but %d attachment IDs returned: %v ❗️\n", len(files), docId, len(ids), ids)
+		return false
+	}
+	for i, id := range ids {
+		fmt.Printf("%s uploaded as attachment %d ✅\n", files[i], id)
+	}
+	return true
+}
 
-	if from_ws.Id == 0 || to_ws.Id == 0 {
-		fmt.Printf("❗️ Workspace %d or %d not found ❗️\n", fromWorkspaceId, toWorkspaceId)
-	} else {
-		gristapi.MoveAllDocs(fromWorkspaceId, toWorkspaceId)
+// Imports a .grist file as a new document of a workspace and prints its ID
+// Returns false if the import failed
+func ImportDocGrist(workspaceId int, path string) bool {
+	docId, status := gristapi.UploadDocGrist(workspaceId, path)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ %s ❗️\n", docId)
+		return false
 	}
+	fmt.Printf("%s imported as document %s ✅\n", path, docId)
+	return true
+}
 
+// Downloads an attachment of a document to destPath, or to its file name
+// in the current directory if destPath is empty
+// Returns false if the download failed
+func DownloadAttachment(docId string, attachmentId int, destPath string) bool {
+	if destPath == "" {
+		attachment, status := gristapi.GetAttachmentMetadata(docId, attachmentId)
+		if status != http.StatusOK {
+			fmt.Printf("❗️ Attachment %d not found: HTTP %d ❗️\n", attachmentId, status)
+			return false
+		}
+		destPath = gristapi.AttachmentFileName(attachmentId, attachment.FileName)
+	}
+	if err := gristapi.DownloadAttachmentToFile(docId, attachmentId, destPath); err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return false
+	}
+	fmt.Printf("Attachment %d saved to %s ✅\n", attachmentId, destPath)
+	return true
 }
 
-// Create a new organization
-func CreateOrg(orgName string, orgDomain string) {
-	org := gristapi.GetOrg(orgDomain)
+// Lists the attachments of a document not used by any cell, with the space
+// they take, then deletes them after confirmation unless dryRun is set
+// Returns false if an API call failed
+func PruneAttachments(docId string, dryRun bool) bool {
+	unused, status := gristapi.FindUnusedAttachments(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to find the unused attachments of document %s: HTTP %d ❗️\n", docId, status)
+		return false
+	}
+	if len(unused) == 0 {
+		fmt.Println("No unused attachments in this document ✅")
+		return true
+	}
 
-	if org.Id != 0 {
-		fmt.Printf("❗️ Organization %s already exists ❗️\n", org.Name)
-	} else {
-		orgId := gristapi.CreateOrg(orgName, orgDomain)
-		fmt.Printf("Organization %d : %s has been created\n", orgId, orgName)
+	var reclaimable int64
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "File name", "Size", "Uploaded"})
+	for _, attachment := range unused {
+		reclaimable += attachment.FileSize
+		table.Append([]string{strconv.Itoa(attachment.Id), attachment.FileName, formatBytes(attachment.FileSize), attachment.TimeUploaded})
 	}
+	common.DisplayTitle(fmt.Sprintf("Unused attachments of document %s", docId))
+	table.Render()
+	fmt.Printf("%d unused attachments, %s reclaimable\n", len(unused), formatBytes(reclaimable))
 
+	if dryRun || !common.Confirm(fmt.Sprintf("Do you really want to delete these %d attachments ?", len(unused))) {
+		return true
+	}
+	if _, status := gristapi.DeleteUnusedAttachments(docId); status != http.StatusOK {
+		fmt.Printf("❗️ Unable to delete the unused attachments: HTTP %d ❗️\n", status)
+		return false
+	}
+	fmt.Printf("%d attachments deleted, %s reclaimed ✅\n", len(unused), formatBytes(reclaimable))
+	return true
 }
 
-// Retrieve organization's usage
-func GetOrgUsageSummary(orgId string) {
-	org := gristapi.GetOrg(orgId)
+// Deletes all the rows of a table after confirmation
+// Returns false if the rows can't be counted or deleted
+func TruncateTable(docId string, tableId string) bool {
+	count, status := gristapi.CountRecords(docId, tableId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to count the rows of table %s: HTTP %d ❗️\n", tableId, status)
+		return false
+	}
+	if count == 0 {
+		fmt.Printf("Table %s is already empty ✅\n", tableId)
+		return true
+	}
+	if !common.Confirm(fmt.Sprintf("Do you really want to delete the %d rows of table %s ?", count, tableId)) {
+		return true
+	}
+	deleted, status := gristapi.TruncateTable(docId, tableId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to delete the rows of table %s: HTTP %d (%d rows deleted) ❗️\n", tableId, status, deleted)
+		return false
+	}
+	fmt.Printf("%d rows deleted from table %s ✅\n", deleted, tableId)
+	return true
+}
+
+// Backs up a document into a directory: its .grist file, its attachments
+// and a manifest with checksums
+// Returns false if the backup failed
+func BackupDoc(docId string, dir string) bool {
+	manifest, err := gristapi.BackupDoc(docId, dir)
+	if err != nil {
+		fmt.Printf("❗️ Backup of document %s failed: %s ❗️\n", docId, err)
+		return false
+	}
+	size := int64(0)
+	for _, file := range manifest.Files {
+		size += file.Size
+	}
+	fmt.Printf("Document %s backed up to %s: %d files, %s ✅\n", manifest.DocName, dir, len(manifest.Files), formatBytes(size))
+	return true
+}
+
+// Forces a document to be reloaded
+func ReloadDoc(docId string) {
+	response, status := gristapi.ForceReloadDoc(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to reload document %s: HTTP %d: %s ❗️\n", docId, status, response)
+		return
+	}
+	fmt.Printf("Document %s reloaded ✅\n", docId)
+}
+
+// Move all documents from a workspace to another, returning the outcome of
+// each move
+func MoveAllDocs(fromWorkspaceId int, toWorkspaceId int) ([]gristapi.MoveResult, error) {
+	results, err := gristapi.MoveAllDocs(fromWorkspaceId, toWorkspaceId)
+	if err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Success {
+			fmt.Printf("Document %s moved to workspace %d ✅\n", result.DocId, toWorkspaceId)
+		} else {
+			fmt.Printf("%s ❗️\n", result.Error)
+		}
+	}
+	return results, nil
+}
 
-	if org.Id == 0 {
+// MoveFailed reports whether a move did not complete for every document
+func MoveFailed(results []gristapi.MoveResult, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, result := range results {
+		if !result.Success {
+			return true
+		}
+	}
+	return false
+}
+
+// Prints why an organization couldn't be retrieved, telling a missing
+// organization apart from any other failure
+func printOrgError(orgId string, status int) {
+	if status == http.StatusNotFound {
 		fmt.Printf("❗️ Organization %s not found ❗️\n", orgId)
 	} else {
-		usage := gristapi.GetOrgUsageSummary(orgId)
-		jsonUsage, err := json.MarshalIndent(usage, "", "  ")
-		if err != nil {
+		fmt.Printf("❗️ Unable to get organization %s: HTTP %d ❗️\n", orgId, status)
+	}
+}
+
+// Create a new organization
+func CreateOrg(orgName string, orgDomain string) {
+	org, status := gristapi.GetOrg(orgDomain)
+
+	switch status {
+	case http.StatusOK:
+		fmt.Printf("❗️ Organization %s already exists ❗️\n", org.Name)
+	case http.StatusNotFound:
+		orgId, err := gristapi.CreateOrg(orgName, orgDomain)
+		if err != nil {
+			fmt.Printf("❗️ %s ❗️\n", err)
+			return
+		}
+		fmt.Printf("Organization %d : %s has been created\n", orgId, orgName)
+	default:
+		printOrgError(orgDomain, status)
+	}
+
+}
+
+// CreateDoc creates a document in a workspace, then the tables of template
+// if it isn't nil
+// Returns false if the document or one of the tables can't be created
+func CreateDoc(workspaceId int, docName string, template *gristapi.DocTemplate) bool {
+	docId, err := gristapi.CreateDoc(workspaceId, docName)
+	if err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return false
+	}
+	fmt.Printf("Document %s : %s has been created\n", docId, docName)
+	if template == nil {
+		return true
+	}
+
+	ok := true
+	for _, tableDef := range template.Tables {
+		table, status := gristapi.CreateTable(docId, tableDef)
+		if status != http.StatusOK {
+			fmt.Printf("❗️ Unable to create table %s: HTTP %d ❗️\n", tableDef.Id, status)
+			ok = false
+			continue
+		}
+		fmt.Printf("Table %s has been created with %d columns\n", table.Id, len(tableDef.Columns))
+	}
+	return ok
+}
+
+// Retrieve organization's usage
+func GetOrgUsageSummary(orgId string) {
+	if _, status := gristapi.GetOrg(orgId); status != http.StatusOK {
+		printOrgError(orgId, status)
+	} else {
+		usage := gristapi.GetOrgUsageSummary(orgId)
+		jsonUsage, err := json.MarshalIndent(usage, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonUsage))
+	}
+}
+
+// Displays the usage of each document of an organization, largest first
+func DisplayOrgDocsUsage(orgId string) {
+	org, status := gristapi.GetOrg(orgId)
+	if status != http.StatusOK {
+		printOrgError(orgId, status)
+		return
+	}
+
+	usages := gristapi.GetOrgDocsUsage(org.Id)
+
+	switch output {
+	case "json":
+		jsonUsage, err := json.MarshalIndent(usages, "", "  ")
+		if err != nil {
 			fmt.Println("ERROR :", err)
 		}
 		fmt.Println(string(jsonUsage))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("%s n°%d : %s", common.T("org.name"), org.Id, org.Name))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{common.T("col.ident"), common.T("col.name"), common.T("workspace.name"), common.T("col.nbRows"), "Data", "Attachments", "Total"})
+		for _, entry := range usages {
+			if entry.Status != http.StatusOK {
+				unknown := fmt.Sprintf("❗️ HTTP %d", entry.Status)
+				table.Append([]string{entry.DocId, entry.DocName, entry.WorkspaceName, unknown, unknown, unknown, unknown})
+				continue
+			}
+			table.Append([]string{
+				entry.DocId,
+				entry.DocName,
+				entry.WorkspaceName,
+				strconv.Itoa(entry.Usage.RowCount),
+				formatBytes(entry.Usage.DataSizeBytes),
+				formatBytes(entry.Usage.AttachmentsSizeBytes),
+				formatBytes(entry.Usage.TotalBytes()),
+			})
+		}
+		table.Render()
+	}
+}
+
+// formatBytes formats a size in bytes in a human readable way
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// ResolveOrg returns the ID of an organization given by ID, name or domain
+// Names and domains are matched case insensitively and must be unique
+// A reference matching no listed organization is looked up as a domain,
+// which the API also resolves, e.g. "docs" or "current"
+func ResolveOrg(ref string) (int, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return id, nil
+	}
+	matches := []string{}
+	id := 0
+	for _, org := range gristapi.GetOrgs() {
+		if strings.EqualFold(org.Name, ref) || strings.EqualFold(org.Domain, ref) {
+			matches = append(matches, fmt.Sprintf("%s (%d)", org.Name, org.Id))
+			id = org.Id
+		}
+	}
+	if len(matches) == 0 {
+		if org, status := gristapi.GetOrg(ref); status == http.StatusOK {
+			return org.Id, nil
+		}
+	}
+	if err := checkUniqueMatch("organization", ref, matches); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ResolveWorkspace returns the ID of a workspace given by ID or name
+// Names are matched case insensitively in all organizations and must be unique
+func ResolveWorkspace(ref string) (int, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return id, nil
+	}
+	matches := []string{}
+	id := 0
+	gristapi.WalkWorkspaces(func(org gristapi.Org, ws gristapi.Workspace) {
+		if strings.EqualFold(ws.Name, ref) {
+			matches = append(matches, fmt.Sprintf("%s (%d) in %s", ws.Name, ws.Id, org.Name))
+			id = ws.Id
+		}
+	})
+	if err := checkUniqueMatch("workspace", ref, matches); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// docIdPattern matches the references that may be document IDs: Grist's
+// generated IDs and custom URL IDs only hold letters, digits, - and _
+var docIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ResolveDoc returns the ID of a document given by ID or name
+// Names are matched case insensitively in all workspaces and must be unique.
+// A reference that may be an ID is first fetched as one: names are only
+// searched when it isn't found, other failures being returned
+func ResolveDoc(ref string) (string, error) {
+	if docIdPattern.MatchString(ref) {
+		_, status := gristapi.GetDoc(ref)
+		switch status {
+		case http.StatusOK:
+			return ref, nil
+		case http.StatusNotFound:
+			// Maybe a name
+		default:
+			return "", fmt.Errorf("unable to get document %s: HTTP %d", ref, status)
+		}
+	}
+	matches := []string{}
+	id := ""
+	for _, doc := range gristapi.FindDocsByName(ref) {
+		if strings.EqualFold(doc.Name, ref) {
+			matches = append(matches, fmt.Sprintf("%s (%s) in %s", doc.Name, doc.Id, doc.Workspace.Name))
+			id = doc.Id
+		}
+	}
+	if err := checkUniqueMatch("document", ref, matches); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// checkUniqueMatch reports a name matching nothing or several resources
+func checkUniqueMatch(kind string, ref string, matches []string) error {
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no %s with ID or name %q", kind, ref)
+	case 1:
+		return nil
+	}
+	return fmt.Errorf("%q matches %d %ss, use an ID instead: %s", ref, len(matches), kind, strings.Join(matches, ", "))
+}
+
+// Displays the documents whose name contains name, in any organization
+// and workspace
+func DisplayFoundDocs(name string) {
+	docs := gristapi.FindDocsByName(name)
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(docs, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		if len(docs) == 0 {
+			fmt.Printf("No document matching '%s'\n", name)
+			return
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{common.T("col.ident"), common.T("col.name"), "Workspace", "Organization"})
+		for _, doc := range docs {
+			table.Append([]string{
+				doc.Id,
+				doc.Name,
+				fmt.Sprintf("%s (%d)", doc.Workspace.Name, doc.Workspace.Id),
+				fmt.Sprintf("%s (%d)", doc.Workspace.Org.Name, doc.Workspace.Org.Id),
+			})
+		}
+		table.Render()
+	}
+}
+
+// Displays the list of tables of a document with their number of rows
+func DisplayDocTables(docId string) {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return
+	}
+	tables := gristapi.GetDocTables(docId)
+	countsById := make(map[string]gristapi.TableRowCount)
+	for _, count := range gristapi.GetTablesRowCounts(docId) {
+		countsById[count.TableId] = count
+	}
+	// Tables are listed in API order
+	counts := make([]gristapi.TableRowCount, len(tables.Tables))
+	for i, t := range tables.Tables {
+		counts[i] = countsById[t.Id]
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(counts, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Document '%s' (%s)", doc.Name, doc.Id))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Table", common.T("col.nbRows")})
+		for _, count := range counts {
+			table.Append([]string{count.TableId, rowCountCell(count)})
+		}
+		table.Render()
+	}
+}
+
+// rowCountCell formats the row count of a table, or why it is unknown
+func rowCountCell(count gristapi.TableRowCount) string {
+	if count.Status != http.StatusOK {
+		return fmt.Sprintf("❗️ HTTP %d", count.Status)
+	}
+	return strconv.Itoa(count.RowCount)
+}
+
+// Displays the columns of a table with their label, type and formula
+// Grist's internal columns (manualSort, gristHelper_...) are only shown
+// with allColumns
+func DisplayTableColumns(docId string, tableId string, allColumns bool) {
+	if !gristapi.DocExists(docId) {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return
+	}
+	columns := gristapi.GetTableColumns(docId, tableId)
+	if allColumns {
+		columns = gristapi.GetAllTableColumns(docId, tableId)
+	}
+	if len(columns.Columns) == 0 {
+		fmt.Printf("❗️ Table %s not found ❗️\n", tableId)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(columns.Columns, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Table %s", tableId))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{common.T("col.ident"), "Label", "Type", "Formula"})
+		for _, column := range columns.Columns {
+			table.Append([]string{column.Id, column.Fields.Label, column.Fields.Type, column.Fields.Formula})
+		}
+		table.Render()
+	}
+}
+
+// Displays a profile of a table: its columns with their type, its number
+// of rows and a sample of its first records
+func DisplayTableInspect(docId string, tableId string, sampleSize int) {
+	columns, status := gristapi.GetTableColumnsWithStatus(docId, tableId)
+	if status == http.StatusNotFound {
+		fmt.Printf("❗️ Table %s not found ❗️\n", tableId)
+		return
+	}
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the columns of table %s: HTTP %d ❗️\n", tableId, status)
+		return
+	}
+	rowCount, status := gristapi.CountRecords(docId, tableId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to count the rows of table %s: HTTP %d ❗️\n", tableId, status)
+		return
+	}
+	sample := gristapi.RecordsList{Records: []gristapi.Record{}}
+	if sampleSize > 0 {
+		sample, status = gristapi.GetRecords(docId, tableId, &gristapi.GetRecordsOptions{Limit: sampleSize})
+		if status != http.StatusOK {
+			fmt.Printf("❗️ Unable to read the records of table %s: HTTP %d ❗️\n", tableId, status)
+			return
+		}
+	}
+
+	switch output {
+	case "json":
+		type tableProfile struct {
+			TableId  string                 `json:"tableId"`
+			RowCount int                    `json:"rowCount"`
+			Columns  []gristapi.TableColumn `json:"columns"`
+			Sample   []gristapi.Record      `json:"sample"`
+		}
+		jsonData, err := json.MarshalIndent(tableProfile{tableId, rowCount, columns.Columns, sample.Records}, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Table %s: %d rows, %d columns", tableId, rowCount, len(columns.Columns)))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{common.T("col.ident"), "Label", "Type", "Formula"})
+		for _, column := range columns.Columns {
+			table.Append([]string{column.Id, column.Fields.Label, column.Fields.Type, column.Fields.Formula})
+		}
+		table.Render()
+
+		if len(sample.Records) == 0 {
+			return
+		}
+		fmt.Printf("\nFirst %d rows:\n", len(sample.Records))
+		header := []string{"id"}
+		for _, column := range columns.Columns {
+			header = append(header, column.Id)
+		}
+		table = tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(header)
+		table.SetAutoFormatHeaders(false)
+		for _, record := range sample.Records {
+			row := []string{strconv.Itoa(record.Id)}
+			for _, column := range columns.Columns {
+				row = append(row, sampleCell(record.Fields[column.Id]))
+			}
+			table.Append(row)
+		}
+		table.Render()
+	}
+}
+
+// DisplayRecordsSince displays the records of a table added or updated since
+// a cursor, with the cursor of the next sync
+// Returns false if the records can't be retrieved
+func DisplayRecordsSince(docId string, tableId string, since gristapi.RecordsSince) bool {
+	result, status, err := gristapi.GetRecordsSince(docId, tableId, since)
+	if err != nil {
+		fmt.Printf("❗️ Unable to get the records of table %s: %s ❗️\n", tableId, err)
+		return false
+	}
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the records of table %s: HTTP %d ❗️\n", tableId, status)
+		return false
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+			return false
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Table %s: %d records since row %d (selected by %s)", tableId, len(result.Records), since.LastRowId, result.Method))
+		if len(result.Records) > 0 {
+			columns := gristapi.GetTableColumns(docId, tableId).Columns
+			header := []string{"id"}
+			for _, column := range columns {
+				header = append(header, column.Id)
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader(header)
+			table.SetAutoFormatHeaders(false)
+			for _, record := range result.Records {
+				row := []string{strconv.Itoa(record.Id)}
+				for _, column := range columns {
+					row = append(row, sampleCell(record.Fields[column.Id]))
+				}
+				table.Append(row)
+			}
+			table.Render()
+		}
+		next := fmt.Sprintf("Next sync: %d", result.Next.LastRowId)
+		if since.UpdatedColumn != "" {
+			next += fmt.Sprintf(" --updated-column %s --updated-after %s", since.UpdatedColumn,
+				strconv.FormatFloat(result.Next.UpdatedAfter, 'f', -1, 64))
+		}
+		fmt.Println(next)
+	}
+	return true
+}
+
+// sampleCell formats a cell value on one line, shortened to 30 characters
+func sampleCell(value interface{}) string {
+	text := strings.Join(strings.Fields(cellText(value)), " ")
+	if runes := []rune(text); len(runes) > 30 {
+		text = string(runes[:29]) + "…"
+	}
+	return text
+}
+
+// cellText formats a cell value: strings as is, numbers without exponent,
+// other values as JSON
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// DisplayRecord displays the fields of a record, one per row, in the order
+// of the table columns
+// Returns false if the record can't be retrieved
+func DisplayRecord(docId string, tableId string, id int) bool {
+	record, status := gristapi.GetRecord(docId, tableId, id)
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		fmt.Printf("❗️ Record %d not found in table %s ❗️\n", id, tableId)
+		return false
+	default:
+		fmt.Printf("❗️ Unable to get record %d of table %s: HTTP %d ❗️\n", id, tableId, status)
+		return false
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+			return false
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		// Fields missing from the columns (hidden ones) are listed last
+		fieldIds := []string{}
+		for _, column := range gristapi.GetTableColumns(docId, tableId).Columns {
+			if _, found := record.Fields[column.Id]; found {
+				fieldIds = append(fieldIds, column.Id)
+			}
+		}
+		for _, fieldId := range slices.Sorted(maps.Keys(record.Fields)) {
+			if !slices.Contains(fieldIds, fieldId) {
+				fieldIds = append(fieldIds, fieldId)
+			}
+		}
+
+		common.DisplayTitle(fmt.Sprintf("Record %d of table %s", record.Id, tableId))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Field", "Value"})
+		table.SetAutoFormatHeaders(false)
+		for _, fieldId := range fieldIds {
+			table.Append([]string{fieldId, cellText(record.Fields[fieldId])})
+		}
+		table.Render()
+	}
+	return true
+}
+
+// Displays the tables of a document sorted by number of rows, largest first
+func DisplayBiggestTables(docId string) {
+	doc, status := gristapi.GetDoc(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+		return
+	}
+
+	counts := gristapi.GetTablesRowCounts(docId)
+	total, failed := 0, 0
+	for _, count := range counts {
+		total += count.RowCount
+		if count.Status != http.StatusOK {
+			failed++
+		}
+	}
+
+	switch output {
+	case "json":
+		type biggestTables struct {
+			DocId     string                   `json:"docId"`
+			DocName   string                   `json:"docName"`
+			TotalRows int                      `json:"totalRows"`
+			Tables    []gristapi.TableRowCount `json:"tables"`
+		}
+		jsonData, err := json.MarshalIndent(biggestTables{doc.Id, doc.Name, total, counts}, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Document '%s' (%s)", doc.Name, doc.Id))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Table", common.T("col.nbRows")})
+		for _, count := range counts {
+			table.Append([]string{count.TableId, rowCountCell(count)})
+		}
+		table.SetFooter([]string{"Total", strconv.Itoa(total)})
+		table.Render()
+		if failed > 0 {
+			fmt.Printf("❗️ %d tables could not be counted and are left out of the total ❗️\n", failed)
+		}
+	}
+}
+
+// Displays the schema differences between two documents
+// docA is the reference: tables and columns only in docB are reported as added
+func DisplayDocDiff(docA string, docB string) {
+	schemaA, statusA := gristapi.GetDocSchema(docA)
+	if statusA != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the schema of document %s: HTTP %d ❗️\n", docA, statusA)
+		return
+	}
+	schemaB, statusB := gristapi.GetDocSchema(docB)
+	if statusB != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the schema of document %s: HTTP %d ❗️\n", docB, statusB)
+		return
+	}
+
+	diff := gristapi.DiffSchemas(schemaA, schemaB)
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		fmt.Printf("--- %s\n+++ %s\n", docA, docB)
+		if diff.IsEmpty() {
+			fmt.Println("Schemas are identical")
+			return
+		}
+		for _, tableId := range diff.AddedTables {
+			fmt.Printf("+ table %s\n", tableId)
+		}
+		for _, tableId := range diff.RemovedTables {
+			fmt.Printf("- table %s\n", tableId)
+		}
+		for _, table := range diff.ChangedTables {
+			fmt.Printf("~ table %s\n", table.TableId)
+			for _, columnId := range table.AddedColumns {
+				fmt.Printf("  + column %s\n", columnId)
+			}
+			for _, columnId := range table.RemovedColumns {
+				fmt.Printf("  - column %s\n", columnId)
+			}
+			for _, change := range table.ChangedColumns {
+				fmt.Printf("  ~ column %s: %s %q -> %q\n", change.ColumnId, change.Property, change.Before, change.After)
+			}
+		}
+	}
+}
+
+// Compares the data of two documents, table by table, and reports the
+// mismatches; with deep, the content of the tables is compared too
+// Returns false if the documents differ or couldn't be compared
+func DisplayDocDataComparison(docA string, docB string, deep bool) bool {
+	comparison, err := gristapi.CompareDocsData(docA, docB, deep)
+	if err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return false
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Data of %s compared with %s", docB, docA))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Table", "Rows A", "Rows B", "Content", "Match"})
+		for _, t := range comparison.Tables {
+			content := "-"
+			if t.HashA != "" {
+				content = "same"
+				if t.HashA != t.HashB {
+					content = "differs"
+				}
+			}
+			match := "✅"
+			if !t.Match {
+				match = "❌"
+			}
+			table.Append([]string{t.TableId, strconv.Itoa(t.RowsA), strconv.Itoa(t.RowsB), content, match})
+		}
+		table.Render()
+		for _, tableId := range comparison.OnlyInA {
+			fmt.Printf("❌ Table %s only in %s\n", tableId, docA)
+		}
+		for _, tableId := range comparison.OnlyInB {
+			fmt.Printf("❌ Table %s only in %s\n", tableId, docB)
+		}
+		if comparison.Matches() {
+			fmt.Println("Documents hold the same data ✅")
+		}
+	}
+	return comparison.Matches()
+}
+
+// Imports a CSV file into a table
+// Returns false if the file can't be read or a batch failed
+func ImportCSV(docId string, tableId string, csvPath string, opts gristapi.ImportCSVOptions) bool {
+	result, status, err := gristapi.ImportCSV(docId, tableId, csvPath, opts)
+	nbRecords := len(result.Records)
+	switch {
+	case err != nil:
+		fmt.Printf("❗️ %s (%d records imported) ❗️\n", err, nbRecords)
+	case status != http.StatusOK:
+		fmt.Printf("❗️ Import into table %s failed: HTTP %d (%d records imported) ❗️\n", tableId, status, nbRecords)
+	case opts.KeyColumn != "":
+		fmt.Printf("%s upserted into table %s on column %s ✅\n", csvPath, tableId, opts.KeyColumn)
+	default:
+		fmt.Printf("%d records imported into table %s ✅\n", nbRecords, tableId)
+	}
+	return err == nil && status == http.StatusOK
+}
+
+// Displays what importing a CSV file into a table would change: the
+// number of records added, updated and unchanged, and the changed fields
+func DisplayImportPlan(docId string, tableId string, csvPath string, opts gristapi.ImportCSVOptions) {
+	plan, status := gristapi.PlanImportCSV(docId, tableId, csvPath, opts)
+	switch {
+	case status == -1:
+		fmt.Printf("❗️ Unable to read %s ❗️\n", csvPath)
+		return
+	case status != http.StatusOK:
+		fmt.Printf("❗️ Unable to read the records of table %s: HTTP %d ❗️\n", tableId, status)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Import of %s into table %s (dry run)", csvPath, tableId))
+		fmt.Printf("%d records to add, %d to update, %d unchanged\n", len(plan.Added), len(plan.Updated), len(plan.Unchanged))
+		if len(plan.Updated) == 0 {
+			return
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", opts.KeyColumn, "Field", "Before", "After"})
+		for _, update := range plan.Updated {
+			for _, change := range update.Changes {
+				table.Append([]string{
+					strconv.Itoa(update.Id),
+					fmt.Sprint(update.Require[opts.KeyColumn]),
+					change.Field,
+					fmt.Sprint(change.Before),
+					fmt.Sprint(change.After),
+				})
+			}
+		}
+		table.Render()
+	}
+}
+
+// Imports a JSON array or newline-delimited JSON file into a table
+// A path of "-" reads from standard input
+// Returns false if the input can't be read or a batch failed
+func ImportJSON(docId string, tableId string, path string, concurrency int) bool {
+	input := os.Stdin
+	if path != "-" {
+		// #nosec G304 - path is user-provided CLI argument for import source
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("❗️ Unable to open %s: %s ❗️\n", path, err)
+			return false
+		}
+		defer f.Close()
+		input = f
+	}
+
+	result, status, err := gristapi.ImportJSON(docId, tableId, input, concurrency)
+	nbRecords := len(result.Records)
+	switch {
+	case err != nil:
+		fmt.Printf("❗️ Unable to import %s: %s (%d records imported) ❗️\n", path, err, nbRecords)
+	case status != http.StatusOK:
+		fmt.Printf("❗️ Import into table %s failed: HTTP %d (%d records imported) ❗️\n", tableId, status, nbRecords)
+	default:
+		fmt.Printf("%d records imported into table %s ✅\n", nbRecords, tableId)
+	}
+	return err == nil && status == http.StatusOK
+}
+
+// Displays the SCIM groups
+func DisplaySCIMGroups() {
+	groups, status := gristapi.SCIMListGroups()
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to list SCIM groups: HTTP %d ❗️\n", status)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(groups.Resources, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle("SCIM groups")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Id", "Name", "Members"})
+		for _, group := range groups.Resources {
+			table.Append([]string{group.Id, group.DisplayName, strconv.Itoa(len(group.Members))})
+		}
+		table.Render()
+	}
+}
+
+// Displays a SCIM group with its members
+func DisplaySCIMGroup(groupId string) {
+	group, status := gristapi.SCIMGetGroup(groupId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ SCIM group %s not found ❗️\n", groupId)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(group, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("SCIM group '%s' (%s)", group.DisplayName, group.Id))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Id", "Name"})
+		for _, member := range group.Members {
+			table.Append([]string{member.Value, member.Display})
+		}
+		table.Render()
+	}
+}
+
+// Runs the SCIM bulk request of a JSON file and prints a summary of the
+// operations, with the bulkId and status of each failure
+// failOnErrors overrides the value of the file when not negative
+// Returns false if the request or one of its operations failed
+func SCIMBulkFromFile(path string, failOnErrors int) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("❗️ %s ❗️\n", err)
+		return false
+	}
+	var request gristapi.SCIMBulkRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		fmt.Printf("❗️ Invalid SCIM bulk request in %s: %s ❗️\n", path, err)
+		return false
+	}
+	if failOnErrors >= 0 {
+		request.FailOnErrors = failOnErrors
+	}
+
+	response, status := gristapi.SCIMBulk(request)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ SCIM bulk request failed: HTTP %d ❗️\n", status)
+		return false
+	}
+	summary := gristapi.SummarizeSCIMBulk(request, response)
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		fmt.Printf("%d operations: %d succeeded, %d failed, %d skipped\n",
+			summary.Total, summary.Succeeded, len(summary.Failures), summary.Skipped)
+		if len(summary.Failures) > 0 {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Bulk Id", "Method", "Status", "Detail"})
+			for _, failure := range summary.Failures {
+				table.Append([]string{failure.BulkId, failure.Method, failure.Status, scimErrorDetail(failure.Response)})
+			}
+			table.Render()
+		}
+	}
+	return len(summary.Failures) == 0 && summary.Skipped == 0
+}
+
+// scimErrorDetail returns the detail message of the response of a failed
+// SCIM operation
+func scimErrorDetail(response interface{}) string {
+	switch r := response.(type) {
+	case gristapi.SCIMError:
+		return r.Detail
+	case map[string]interface{}:
+		if detail, ok := r["detail"].(string); ok {
+			return detail
+		}
+	case nil:
+		return ""
+	}
+	data, _ := json.Marshal(response)
+	return string(data)
+}
+
+// Creates a SCIM group
+func CreateSCIMGroup(name string) {
+	group, status := gristapi.SCIMCreateGroup(gristapi.SCIMGroup{DisplayName: name})
+	if status != http.StatusCreated && status != http.StatusOK {
+		fmt.Printf("❗️ Unable to create SCIM group %s: HTTP %d ❗️\n", name, status)
+		return
+	}
+	fmt.Printf("SCIM group %s created with id %s ✅\n", name, group.Id)
+}
+
+// Adds or removes members of a SCIM group
+// Users are given by SCIM id or by user name (email)
+func PatchSCIMGroupMembers(groupId string, add []string, remove []string) {
+	addIds, okAdd := resolveSCIMUserIds(add)
+	removeIds, okRemove := resolveSCIMUserIds(remove)
+	if !okAdd || !okRemove {
+		return
+	}
+
+	response, status := gristapi.SCIMPatchGroupMembers(groupId, addIds, removeIds)
+	if status != http.StatusOK && status != http.StatusNoContent {
+		fmt.Printf("❗️ Unable to update members of SCIM group %s: HTTP %d %s ❗️\n", groupId, status, response)
+		return
+	}
+	if len(addIds) > 0 {
+		fmt.Printf("%d members added to SCIM group %s ✅\n", len(addIds), groupId)
+	}
+	if len(removeIds) > 0 {
+		fmt.Printf("%d members removed from SCIM group %s ✅\n", len(removeIds), groupId)
+	}
+}
+
+// resolveSCIMUserIds converts user names (containing "@") into SCIM ids
+// Returns false if a user name can't be resolved
+func resolveSCIMUserIds(users []string) ([]string, bool) {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		if !strings.Contains(user, "@") {
+			ids = append(ids, user)
+			continue
+		}
+		found, status := gristapi.SCIMListUsers("userName eq " + gristapi.SCIMFilterValue(user))
+		if status != http.StatusOK || len(found.Resources) == 0 {
+			fmt.Printf("❗️ SCIM user %s not found ❗️\n", user)
+			return nil, false
+		}
+		ids = append(ids, found.Resources[0].Id)
+	}
+	return ids, true
+}
+
+// Displays the capabilities and limits of the SCIM server
+func DisplaySCIMServiceProviderConfig() {
+	config, status := gristapi.SCIMServiceProviderConfig()
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the SCIM configuration: HTTP %d ❗️\n", status)
+		return
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle("SCIM service provider configuration")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Feature", "Supported", "Limits"})
+		table.Append([]string{"bulk", strconv.FormatBool(config.Bulk.Supported),
+			fmt.Sprintf("maxOperations=%d maxPayloadSize=%s", config.Bulk.MaxOperations, formatBytes(int64(config.Bulk.MaxPayloadSize)))})
+		table.Append([]string{"filter", strconv.FormatBool(config.Filter.Supported),
+			fmt.Sprintf("maxResults=%d", config.Filter.MaxResults)})
+		table.Append([]string{"patch", strconv.FormatBool(config.Patch.Supported), ""})
+		table.Append([]string{"sort", strconv.FormatBool(config.Sort.Supported), ""})
+		table.Append([]string{"etag", strconv.FormatBool(config.Etag.Supported), ""})
+		table.Append([]string{"changePassword", strconv.FormatBool(config.ChangePassword.Supported), ""})
+		table.Render()
+	}
+}
+
+// Webhook statuses reported as unhealthy
+var unhealthyWebhookStatuses = []string{"error", "retrying"}
+
+// Displays the health of the webhooks of a document: status, queue depth
+// and last delivery error. Unhealthy webhooks are highlighted in red
+func DisplayWebhookStatus(docId string) {
+	type WebhookStatus struct {
+		Id               string  `json:"id"`
+		Name             string  `json:"name"`
+		TableId          string  `json:"tableId"`
+		Status           string  `json:"status"`
+		NumWaiting       int     `json:"numWaiting"`
+		LastHttpStatus   *int    `json:"lastHttpStatus,omitempty"`
+		LastErrorMessage *string `json:"lastErrorMessage,omitempty"`
+		Attempts         int     `json:"attempts"`
+	}
+
+	webhooks, status := gristapi.GetWebhooks(docId)
+	if status != http.StatusOK {
+		fmt.Printf("❗️ Unable to get the webhooks of document %s: HTTP %d ❗️\n", docId, status)
+		return
+	}
+
+	statuses := []WebhookStatus{}
+	for _, wh := range webhooks.Webhooks {
+		whStatus := WebhookStatus{Id: wh.Id, Name: wh.Fields.Name, TableId: wh.Fields.TableId}
+		if wh.Usage != nil {
+			whStatus.Status = wh.Usage.Status
+			whStatus.NumWaiting = wh.Usage.NumWaiting
+			whStatus.LastHttpStatus = wh.Usage.LastHttpStatus
+			whStatus.LastErrorMessage = wh.Usage.LastErrorMessage
+			if wh.Usage.LastEventBatch != nil {
+				whStatus.Attempts = wh.Usage.LastEventBatch.Attempts
+			}
+		}
+		statuses = append(statuses, whStatus)
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		common.DisplayTitle(fmt.Sprintf("Webhooks of document %s", docId))
+		if len(statuses) == 0 {
+			fmt.Println("No webhooks configured for this document")
+			return
+		}
+		colored := termenv.ColorProfile() != termenv.Ascii
+		header := []string{"ID", "Name", "Table", "Status", "Waiting", "Last HTTP", "Last error", "Attempts"}
+		red := make([]tablewriter.Colors, len(header))
+		for i := range red {
+			red[i] = tablewriter.Colors{tablewriter.FgRedColor}
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(header)
+		for _, wh := range statuses {
+			lastHttp := ""
+			if wh.LastHttpStatus != nil {
+				lastHttp = strconv.Itoa(*wh.LastHttpStatus)
+			}
+			lastError := ""
+			if wh.LastErrorMessage != nil {
+				lastError = *wh.LastErrorMessage
+			}
+			row := []string{wh.Id, wh.Name, wh.TableId, wh.Status, strconv.Itoa(wh.NumWaiting), lastHttp, lastError, strconv.Itoa(wh.Attempts)}
+			if slices.Contains(unhealthyWebhookStatuses, wh.Status) {
+				if colored {
+					table.Rich(row, red)
+					continue
+				}
+				row[3] = "❗️ " + row[3]
+			}
+			table.Append(row)
+		}
+		table.Render()
+	}
+}
+
+// Displays the health of the webhooks of a document every interval, until
+// interrupted
+func WatchWebhookStatus(docId string, interval time.Duration) {
+	for {
+		if output == "table" {
+			// Clear the terminal before refreshing
+			fmt.Print("\033[H\033[2J")
+		}
+		DisplayWebhookStatus(docId)
+		if output == "table" {
+			fmt.Printf("\nRefreshed at %s, every %s (Ctrl+C to stop)\n", time.Now().Format(time.TimeOnly), interval)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Tests the delivery of a webhook and displays the outcome
+// Returns true only if the event was delivered
+func TestWebhook(docId string, webhookId string, wait time.Duration) bool {
+	result, err := gristapi.TriggerWebhookTest(docId, webhookId, wait)
+	if err != nil {
+		fmt.Printf("❗️ Unable to test webhook %s: %s ❗️\n", webhookId, err)
+		return false
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR :", err)
+		}
+		fmt.Println(string(jsonData))
+	case "table":
+		switch {
+		case result.Delivered:
+			fmt.Printf("✅ Webhook %s delivered the event of table %s\n", webhookId, result.TableId)
+		case result.Failed:
+			message := ""
+			if result.LastErrorMessage != nil {
+				message = *result.LastErrorMessage
+			}
+			if result.LastHttpStatus != nil {
+				message = fmt.Sprintf("HTTP %d %s", *result.LastHttpStatus, message)
+			}
+			fmt.Printf("❌ Webhook %s failed to deliver the event: %s\n", webhookId, strings.TrimSpace(message))
+		default:
+			fmt.Printf("⏳ Webhook %s delivered nothing within %s\n", webhookId, wait)
+		}
+	}
+	return result.Delivered
+}
+
+// Displays the records of a table as delimited text (csv, tsv or dsv) written
+// by gristle rather than by Grist, options giving the date format, the
+// columns and the text of null cells
+func DisplayTableRecordsCSV(docId string, tableId string, format string, options gristapi.RecordsCSVOptions) bool {
+	delimiter, found := gristapi.ExportDelimiter(format)
+	if !found {
+		fmt.Fprintf(os.Stderr, "❗️ Format %s can't be written locally, expected csv, tsv or dsv ❗️\n", format)
+		return false
+	}
+	options.Delimiter = delimiter
+	content, status := gristapi.GetTableRecordsCSV(docId, tableId, &options)
+	if status != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "❗️ Unable to export table %s: %s ❗️\n", tableId, content)
+		return false
+	}
+	fmt.Print(content)
+	return true
+}
+
+// Displays the content of a table in one of gristapi.TableExportFormats
+func DisplayTableExport(docId string, tableId string, format string, options *gristapi.ExportOptions) {
+	content, status := gristapi.GetTableExport(docId, tableId, format, options)
+	if status != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "❗️ Unable to export table %s: HTTP %d ❗️\n", tableId, status)
+		return
 	}
+	fmt.Println(content)
 }
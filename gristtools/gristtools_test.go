@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bdmorin/gristle/gristapi"
+)
+
+func TestDocExportPath(t *testing.T) {
+	doc := gristapi.Doc{Name: "Q1/Q2 budget", Workspace: gristapi.Workspace{Name: "Finance"}}
+
+	tests := []struct {
+		tableId   string
+		extension string
+		dir       string
+		expected  string
+	}{
+		{"", "xlsx", "", "Finance_Q1_Q2 budget.xlsx"},
+		{"", "grist", "", "Finance_Q1_Q2 budget.grist"},
+		{"Expenses", "csv", "", "Finance_Q1_Q2 budget_Expenses.csv"},
+		{"Expenses", "parquet", "exports", filepath.Join("exports", "Finance_Q1_Q2 budget_Expenses.parquet")},
+	}
+
+	defer SetExportDir("")
+	for _, tt := range tests {
+		SetExportDir(tt.dir)
+		if got := docExportPath(doc, tt.tableId, tt.extension); got != tt.expected {
+			t.Errorf("docExportPath(%q, %q) in %q = %q, expected %q", tt.tableId, tt.extension, tt.dir, got, tt.expected)
+		}
+	}
+}
+
+func TestResolveDoc(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/docs/k3Ad8qPz2Xm":
+			w.Write([]byte(`{"id": "k3Ad8qPz2Xm", "name": "Budget"}`))
+		case "/api/docs/Locked":
+			w.WriteHeader(http.StatusForbidden)
+		case "/api/orgs":
+			w.Write([]byte(`[{"id": 1, "name": "Org"}]`))
+		case "/api/orgs/1/workspaces":
+			w.Write([]byte(`[{"id": 10, "name": "Finance", "docs": [
+				{"id": "k3Ad8qPz2Xm", "name": "Budget"},
+				{"id": "p9Wn4rT6yUe", "name": "Sales report"}
+			]}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GRIST_URL", server.URL)
+
+	tests := []struct {
+		ref      string
+		expected string
+		requests int // Requests sent to resolve it
+		err      bool
+	}{
+		{"k3Ad8qPz2Xm", "k3Ad8qPz2Xm", 1, false},
+		// Found by name once the ID lookup returns 404
+		{"budget", "k3Ad8qPz2Xm", 3, false},
+		// Not an ID: only the names are searched
+		{"Sales report", "p9Wn4rT6yUe", 2, false},
+		{"Locked", "", 1, true},
+		{"Missing doc", "", 2, true},
+	}
+	for _, tt := range tests {
+		requests = nil
+		id, err := ResolveDoc(tt.ref)
+		if id != tt.expected || (err != nil) != tt.err {
+			t.Errorf("ResolveDoc(%q) = %q, %v, expected %q", tt.ref, id, err, tt.expected)
+		}
+		if len(requests) != tt.requests {
+			t.Errorf("ResolveDoc(%q) sent %v, expected %d requests", tt.ref, requests, tt.requests)
+		}
+	}
+}
+
+func TestResolveSCIMUserIds(t *testing.T) {
+	var filters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("filter"))
+		w.Write([]byte(`{"Resources": [{"id": "12"}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("GRIST_URL", server.URL)
+
+	ids, ok := resolveSCIMUserIds([]string{"5", `ann"@example.com`})
+	if !ok || !slices.Equal(ids, []string{"5", "12"}) {
+		t.Errorf("Unexpected ids %v (%v)", ids, ok)
+	}
+	// The quote of the email can't end the filter value
+	if !slices.Equal(filters, []string{`userName eq "ann\"@example.com"`}) {
+		t.Errorf("Unexpected filters %q", filters)
+	}
+}
+
+func TestUploadAttachments_IdMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// More IDs than uploaded files
+		w.Write([]byte(`[1, 2, 3]`))
+	}))
+	defer server.Close()
+	t.Setenv("GRIST_URL", server.URL)
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	for _, file := range files {
+		if err := os.WriteFile(file, []byte("content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if UploadAttachments("doc123", files) {
+		t.Error("Expected the upload to be reported as failed when the IDs don't match the files")
+	}
+}
+
+func TestFilterOrgs(t *testing.T) {
+	orgs := []gristapi.Org{
+		{Id: 1, Name: "Personal", Domain: "docs"},
+		{Id: 2, Name: "Finance Team", Domain: "finance"},
+		{Id: 3, Name: "Marketing", Domain: "mkt-team"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected []int
+	}{
+		{"no filter", "", []int{1, 2, 3}},
+		{"name, any case", "FINANCE", []int{2}},
+		{"name or domain", "team", []int{2, 3}},
+		{"domain only", "docs", []int{1}},
+		{"no match", "sales", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []int{}
+			for _, org := range filterOrgs(orgs, tt.filter) {
+				ids = append(ids, org.Id)
+			}
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("filterOrgs(%q) = %v, expected %v", tt.filter, ids, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSortOrgs(t *testing.T) {
+	orgs := []gristapi.Org{
+		{Id: 3, Name: "beta", Domain: "Zeta", CreatedAt: "2024-03-01T00:00:00Z"},
+		{Id: 1, Name: "Gamma", Domain: "alpha", CreatedAt: "2024-01-01T00:00:00Z"},
+		{Id: 2, Name: "Alpha", Domain: "beta", CreatedAt: "2024-02-01T00:00:00Z"},
+	}
+
+	tests := []struct {
+		key      string
+		expected []int
+	}{
+		{"id", []int{1, 2, 3}},
+		{"name", []int{2, 3, 1}},
+		{"domain", []int{1, 2, 3}},
+		{"created", []int{1, 2, 3}},
+		// The API order is kept
+		{"", []int{3, 1, 2}},
+		{"unknown", []int{3, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			sorted := slices.Clone(orgs)
+			sortOrgs(sorted, tt.key)
+			ids := []int{}
+			for _, org := range sorted {
+				ids = append(ids, org.Id)
+			}
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("sortOrgs(%q) = %v, expected %v", tt.key, ids, tt.expected)
+			}
+		})
+	}
+}
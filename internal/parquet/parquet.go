@@ -0,0 +1,432 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+// Package parquet writes the records of a Grist table as a Parquet file
+//
+// Rows are written in row groups of a bounded size, each column chunk being
+// a single uncompressed, PLAIN encoded data page, so that only one row group
+// is held in memory. The file metadata is encoded with the Thrift compact
+// protocol, as required by the format: https://github.com/apache/parquet-format
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// Physical types
+const (
+	physicalBoolean   = 0
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+)
+
+// Converted types, for readers ignoring logical types
+const (
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedDate            = 6
+	convertedTimestampMillis = 9
+	convertedInt64           = 18
+	convertedJSON            = 19
+)
+
+// Logical types (ids of the LogicalType union)
+const (
+	logicalNone      = 0
+	logicalString    = 1
+	logicalDate      = 6
+	logicalTimestamp = 8
+	logicalInteger   = 10
+	logicalJSON      = 12
+)
+
+// Encodings
+const (
+	encodingPlain = 0
+	encodingRLE   = 3
+)
+
+// Rows per row group
+const defaultRowGroupSize = 10000
+
+// Column of a Grist table, Type being its Grist type, e.g. "Int" or
+// "DateTime:Europe/Paris"
+type Column struct {
+	Name string
+	Type string
+}
+
+// column is a column of the row group being written
+// Values are PLAIN encoded as they are added; a nil value is a null
+type column struct {
+	name      string
+	physical  int32
+	converted int32
+	logical   int16
+	required  bool
+	convert   func(interface{}) (interface{}, bool)
+	present   []bool
+	bools     []bool
+	values    bytes.Buffer
+}
+
+// newColumn maps a Grist column type to a Parquet column
+// Ref columns hold row IDs, list columns are written as JSON arrays and
+// columns of any other type as strings
+func newColumn(name string, gristType string) *column {
+	c := &column{name: name}
+	baseType, _, _ := strings.Cut(gristType, ":")
+	switch baseType {
+	case "Int", "Ref":
+		c.physical, c.converted, c.logical = physicalInt64, convertedInt64, logicalInteger
+		c.convert = convertInt
+	case "Numeric":
+		c.physical, c.converted = physicalDouble, convertedNone
+		c.convert = convertFloat
+	case "Bool":
+		c.physical, c.converted = physicalBoolean, convertedNone
+		c.convert = convertBool
+	case "Date":
+		c.physical, c.converted, c.logical = physicalInt32, convertedDate, logicalDate
+		c.convert = convertDate
+	case "DateTime":
+		c.physical, c.converted, c.logical = physicalInt64, convertedTimestampMillis, logicalTimestamp
+		c.convert = convertTimestamp
+	case "ChoiceList", "RefList", "Attachments":
+		c.physical, c.converted, c.logical = physicalByteArray, convertedJSON, logicalJSON
+		c.convert = convertList
+	default:
+		c.physical, c.converted, c.logical = physicalByteArray, convertedUTF8, logicalString
+		c.convert = convertString
+	}
+	return c
+}
+
+// Conversions of the JSON values of Grist records
+// A value of the wrong type, e.g. an error or a text in a numeric
+// column, is written as a null
+
+func convertInt(value interface{}) (interface{}, bool) {
+	number, ok := value.(float64)
+	return int64(number), ok
+}
+
+func convertFloat(value interface{}) (interface{}, bool) {
+	number, ok := value.(float64)
+	return number, ok
+}
+
+func convertBool(value interface{}) (interface{}, bool) {
+	b, ok := value.(bool)
+	return b, ok
+}
+
+// Dates are stored by Grist as the timestamp of midnight UTC
+func convertDate(value interface{}) (interface{}, bool) {
+	seconds, ok := value.(float64)
+	return int32(math.Floor(seconds / 86400)), ok
+}
+
+// Datetimes are stored by Grist as seconds since the epoch
+func convertTimestamp(value interface{}) (interface{}, bool) {
+	seconds, ok := value.(float64)
+	return int64(math.Round(seconds * 1000)), ok
+}
+
+// Lists are encoded by Grist as ["L", items...]
+func convertList(value interface{}) (interface{}, bool) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 || list[0] != "L" {
+		return nil, false
+	}
+	items, err := json.Marshal(list[1:])
+	return string(items), err == nil
+}
+
+func convertString(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return v, true
+	case []interface{}:
+		if len(v) > 0 && v[0] == "E" {
+			return nil, false
+		}
+	}
+	text, err := json.Marshal(value)
+	return string(text), err == nil
+}
+
+// add appends a value to the column
+func (c *column) add(value interface{}) {
+	converted, ok := c.convert(value)
+	if !ok {
+		c.present = append(c.present, false)
+		return
+	}
+	c.present = append(c.present, true)
+	switch v := converted.(type) {
+	case bool:
+		c.bools = append(c.bools, v)
+	case int32:
+		_ = binary.Write(&c.values, binary.LittleEndian, v)
+	case int64:
+		_ = binary.Write(&c.values, binary.LittleEndian, v)
+	case float64:
+		_ = binary.Write(&c.values, binary.LittleEndian, v)
+	case string:
+		_ = binary.Write(&c.values, binary.LittleEndian, uint32(len(v)))
+		c.values.WriteString(v)
+	}
+}
+
+// pageData returns the content of the column's data page: the definition
+// levels of an optional column, followed by the non-null values
+func (c *column) pageData() []byte {
+	var data bytes.Buffer
+	if !c.required {
+		levels := rleBooleans(c.present)
+		_ = binary.Write(&data, binary.LittleEndian, uint32(len(levels)))
+		data.Write(levels)
+	}
+	if c.physical == physicalBoolean {
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, b := range c.bools {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		data.Write(packed)
+	} else {
+		data.Write(c.values.Bytes())
+	}
+	return data.Bytes()
+}
+
+// reset empties the column for the next row group
+func (c *column) reset() {
+	c.present = c.present[:0]
+	c.bools = c.bools[:0]
+	c.values.Reset()
+}
+
+// rleBooleans encodes levels of bit width 1 as RLE runs of the Parquet
+// RLE/bit-packing hybrid encoding
+func rleBooleans(levels []bool) []byte {
+	var encoded []byte
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		encoded = binary.AppendUvarint(encoded, uint64(end-start)<<1)
+		if levels[start] {
+			encoded = append(encoded, 1)
+		} else {
+			encoded = append(encoded, 0)
+		}
+		start = end
+	}
+	return encoded
+}
+
+// chunk locates a column chunk in the file
+type chunk struct {
+	offset int64
+	size   int64
+}
+
+// rowGroup is a row group already written, described in the footer
+type rowGroup struct {
+	rows   int
+	chunks []chunk
+}
+
+// Writer writes rows, the record ID followed by the given columns, to a
+// Parquet file
+type Writer struct {
+	w            io.Writer
+	offset       int64
+	columns      []*column
+	rows         int
+	rowGroupSize int
+	rowGroups    []rowGroup
+	closed       bool
+}
+
+// NewWriter starts a Parquet file on w
+// Grist types are mapped to Parquet logical types: Int and Ref to INT64,
+// Numeric to DOUBLE, Bool to BOOLEAN, Date to DATE, DateTime to TIMESTAMP
+// (milliseconds, UTC), lists to JSON and other columns to STRING.
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+	writer := &Writer{w: w, rowGroupSize: defaultRowGroupSize}
+	writer.columns = []*column{newColumn("id", "Int")}
+	writer.columns[0].required = true
+	for _, c := range columns {
+		writer.columns = append(writer.columns, newColumn(c.Name, c.Type))
+	}
+	return writer, writer.write([]byte("PAR1"))
+}
+
+// write writes data, keeping track of the offset in the file
+func (w *Writer) write(data []byte) error {
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+// Write adds a row, fields holding the values of the columns by name
+// Cells holding an error or a value of the wrong type are written as nulls
+func (w *Writer) Write(id int, fields map[string]interface{}) error {
+	if w.closed {
+		return errors.New("parquet: write to a closed writer")
+	}
+	w.columns[0].add(float64(id))
+	for _, c := range w.columns[1:] {
+		c.add(fields[c.name])
+	}
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the pending rows as a row group
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	group := rowGroup{rows: w.rows, chunks: make([]chunk, len(w.columns))}
+	for i, c := range w.columns {
+		data := c.pageData()
+		header := newThriftWriter()
+		header.i32Field(1, 0) // DATA_PAGE
+		header.i32Field(2, int32(len(data)))
+		header.i32Field(3, int32(len(data)))
+		header.structBegin(5)
+		header.i32Field(1, int32(w.rows))
+		header.i32Field(2, encodingPlain)
+		header.i32Field(3, encodingRLE)
+		header.i32Field(4, encodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		group.chunks[i] = chunk{w.offset, int64(header.buf.Len() + len(data))}
+		if err := w.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		c.reset()
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.rows = 0
+	return nil
+}
+
+// Close writes the pending rows and the file metadata
+// It doesn't close the underlying writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	totalRows := int64(0)
+	for _, group := range w.rowGroups {
+		totalRows += int64(group.rows)
+	}
+
+	footer := newThriftWriter()
+	footer.i32Field(1, 1)
+	footer.listBegin(2, thriftStruct, len(w.columns)+1)
+	footer.listStructBegin()
+	footer.stringField(4, "schema")
+	footer.i32Field(5, int32(len(w.columns)))
+	footer.structEnd()
+	for _, c := range w.columns {
+		footer.listStructBegin()
+		footer.i32Field(1, c.physical)
+		if c.required {
+			footer.i32Field(3, 0)
+		} else {
+			footer.i32Field(3, 1)
+		}
+		footer.stringField(4, c.name)
+		if c.converted != convertedNone {
+			footer.i32Field(6, c.converted)
+		}
+		if c.logical != logicalNone {
+			footer.structBegin(10)
+			footer.structBegin(c.logical)
+			switch c.logical {
+			case logicalTimestamp:
+				footer.boolField(1, true)
+				footer.structBegin(2)
+				footer.structBegin(1) // MILLIS
+				footer.structEnd()
+				footer.structEnd()
+			case logicalInteger:
+				footer.byteField(1, 64)
+				footer.boolField(2, true)
+			}
+			footer.structEnd()
+			footer.structEnd()
+		}
+		footer.structEnd()
+	}
+	footer.i64Field(3, totalRows)
+	footer.listBegin(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		footer.listStructBegin()
+		footer.listBegin(1, thriftStruct, len(w.columns))
+		totalSize := int64(0)
+		for i, c := range w.columns {
+			footer.listStructBegin()
+			footer.i64Field(2, group.chunks[i].offset)
+			footer.structBegin(3)
+			footer.i32Field(1, c.physical)
+			footer.listBegin(2, thriftI32, 2)
+			footer.listI32(encodingPlain)
+			footer.listI32(encodingRLE)
+			footer.listBegin(3, thriftBinary, 1)
+			footer.listString(c.name)
+			footer.i32Field(4, 0) // UNCOMPRESSED
+			footer.i64Field(5, int64(group.rows))
+			footer.i64Field(6, group.chunks[i].size)
+			footer.i64Field(7, group.chunks[i].size)
+			footer.i64Field(9, group.chunks[i].offset)
+			footer.structEnd()
+			footer.structEnd()
+			totalSize += group.chunks[i].size
+		}
+		footer.i64Field(2, totalSize)
+		footer.i64Field(3, int64(group.rows))
+		footer.structEnd()
+	}
+	footer.stringField(6, "gristle")
+	footer.structEnd()
+
+	if err := w.write(footer.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(footer.buf.Len()))); err != nil {
+		return err
+	}
+	return w.write([]byte("PAR1"))
+}
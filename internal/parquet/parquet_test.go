@@ -0,0 +1,287 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact structs into maps of field id to value
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (tr thriftReader) zigzag() int64 {
+	v, _ := binary.ReadUvarint(tr.r)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (tr thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftBoolTrue:
+		return true
+	case thriftBoolFalse:
+		return false
+	case thriftByte:
+		b, _ := tr.r.ReadByte()
+		return int8(b)
+	case thriftI32, thriftI64:
+		return tr.zigzag()
+	case thriftBinary:
+		size, _ := binary.ReadUvarint(tr.r)
+		data := make([]byte, size)
+		tr.r.Read(data)
+		return string(data)
+	case thriftList:
+		header, _ := tr.r.ReadByte()
+		size := int(header >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(tr.r)
+			size = int(n)
+		}
+		list := []interface{}{}
+		for i := 0; i < size; i++ {
+			list = append(list, tr.value(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return tr.readStruct()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d", fieldType))
+}
+
+func (tr thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	last := int16(0)
+	for {
+		header, _ := tr.r.ReadByte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(tr.zigzag())
+		}
+		fields[id] = tr.value(header & 0x0f)
+		last = id
+	}
+}
+
+// readParquetFooter checks the magic numbers of a Parquet file and decodes
+// its metadata
+func readParquetFooter(t *testing.T, data []byte) map[int16]interface{} {
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("Expected PAR1 magic numbers")
+	}
+	size := binary.LittleEndian.Uint32(data[len(data)-8:])
+	footer := data[len(data)-8-int(size) : len(data)-8]
+	return thriftReader{bytes.NewReader(footer)}.readStruct()
+}
+
+var testColumns = []Column{
+	{"Name", "Text"}, {"Age", "Int"}, {"Score", "Numeric"}, {"Active", "Bool"},
+	{"Born", "Date"}, {"Seen", "DateTime:Europe/Paris"}, {"Tags", "ChoiceList"},
+}
+
+var testRecords = []struct {
+	id     int
+	fields map[string]interface{}
+}{
+	{1, map[string]interface{}{"Name": "Alice", "Age": float64(30), "Score": 1.5, "Active": true,
+		"Born": float64(86400 * 2), "Seen": 1.5, "Tags": []interface{}{"L", "a", "b"}}},
+	{2, map[string]interface{}{"Name": nil, "Age": "n/a", "Score": []interface{}{"E", "TypeError"}, "Active": false,
+		"Born": nil, "Seen": nil, "Tags": nil}},
+	{3, map[string]interface{}{"Name": "Carol", "Age": float64(41), "Score": 2.0, "Active": true,
+		"Born": float64(0), "Seen": float64(0), "Tags": []interface{}{"L"}}},
+}
+
+// writeTestFile writes the test records in row groups of rowGroupSize rows
+func writeTestFile(t *testing.T, rowGroupSize int) []byte {
+	var file bytes.Buffer
+	writer, err := NewWriter(&file, testColumns)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	writer.rowGroupSize = rowGroupSize
+	for _, record := range testRecords {
+		if err := writer.Write(record.id, record.fields); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return file.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	data := writeTestFile(t, defaultRowGroupSize)
+	footer := readParquetFooter(t, data)
+
+	if footer[3] != int64(3) {
+		t.Errorf("Expected 3 rows, got %v", footer[3])
+	}
+	schema := footer[2].([]interface{})
+	if len(schema) != 9 {
+		t.Fatalf("Expected a root and 8 columns in the schema, got %d", len(schema))
+	}
+	expectedTypes := map[string]int64{
+		"id": physicalInt64, "Name": physicalByteArray, "Age": physicalInt64, "Score": physicalDouble,
+		"Active": physicalBoolean, "Born": physicalInt32, "Seen": physicalInt64, "Tags": physicalByteArray,
+	}
+	for _, element := range schema[1:] {
+		fields := element.(map[int16]interface{})
+		name := fields[4].(string)
+		if fields[1] != expectedTypes[name] {
+			t.Errorf("Column %s: expected type %d, got %v", name, expectedTypes[name], fields[1])
+		}
+	}
+	if logical := schema[7].(map[int16]interface{})[10].(map[int16]interface{}); logical[logicalTimestamp] == nil {
+		t.Errorf("Expected a timestamp logical type for Seen, got %v", logical)
+	}
+
+	// Read the Age column chunk: definition levels, then the 2 non-null values
+	rowGroup := footer[4].([]interface{})[0].(map[int16]interface{})
+	chunk := rowGroup[1].([]interface{})[2].(map[int16]interface{})
+	metadata := chunk[3].(map[int16]interface{})
+	if metadata[3].([]interface{})[0] != "Age" || metadata[5] != int64(3) {
+		t.Fatalf("Unexpected column chunk metadata: %v", metadata)
+	}
+	page := bytes.NewReader(data[metadata[9].(int64):])
+	header := thriftReader{page}.readStruct()
+	pageData := make([]byte, header[3].(int64))
+	page.Read(pageData)
+
+	levelsSize := binary.LittleEndian.Uint32(pageData)
+	levels := pageData[4 : 4+levelsSize]
+	if !bytes.Equal(levels, []byte{2, 1, 2, 0, 2, 1}) {
+		t.Errorf("Expected RLE runs present/absent/present, got %v", levels)
+	}
+	values := pageData[4+levelsSize:]
+	if len(values) != 16 || binary.LittleEndian.Uint64(values) != 30 || binary.LittleEndian.Uint64(values[8:]) != 41 {
+		t.Errorf("Expected values 30 and 41, got %v", values)
+	}
+}
+
+func TestWriter_RowGroups(t *testing.T) {
+	data := writeTestFile(t, 2)
+	footer := readParquetFooter(t, data)
+	if footer[3] != int64(3) {
+		t.Errorf("Expected 3 rows, got %v", footer[3])
+	}
+	rowGroups := footer[4].([]interface{})
+	if len(rowGroups) != 2 {
+		t.Fatalf("Expected 2 row groups, got %d", len(rowGroups))
+	}
+	offset := int64(4)
+	for i, expectedRows := range []int64{2, 1} {
+		group := rowGroups[i].(map[int16]interface{})
+		if group[3] != expectedRows {
+			t.Errorf("Row group %d: expected %d rows, got %v", i, expectedRows, group[3])
+		}
+		// Column chunks follow each other from the magic number
+		for _, element := range group[1].([]interface{}) {
+			metadata := element.(map[int16]interface{})[3].(map[int16]interface{})
+			if metadata[9] != offset {
+				t.Errorf("Row group %d: expected a chunk at %d, got %v", i, offset, metadata[9])
+			}
+			offset += metadata[7].(int64)
+		}
+	}
+
+	var file bytes.Buffer
+	writer, _ := NewWriter(&file, testColumns)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if footer := readParquetFooter(t, file.Bytes()); footer[3] != int64(0) {
+		t.Errorf("Expected an empty file, got %v rows", footer[3])
+	}
+	if err := writer.Write(1, nil); err == nil {
+		t.Error("Expected an error writing to a closed writer")
+	}
+}
+
+// interopReaders read a Parquet file given as argument and print its rows as
+// JSON, id by id
+var interopReaders = []struct {
+	name    string
+	command []string
+}{
+	{"pyarrow", []string{"python3", "-c", `
+import json, sys
+import pyarrow.parquet as pq
+rows = pq.read_table(sys.argv[1]).to_pylist()
+print(json.dumps(rows, default=str))`}},
+	{"duckdb", []string{"duckdb", "-json", "-c"}},
+}
+
+// TestInterop reads files back with real Parquet readers, pyarrow or the
+// DuckDB CLI, skipping those that aren't installed
+func TestInterop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.parquet")
+	if err := os.WriteFile(path, writeTestFile(t, 2), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tested := false
+	for _, reader := range interopReaders {
+		args := append([]string{}, reader.command[1:]...)
+		if reader.name == "duckdb" {
+			args = append(args, fmt.Sprintf("SELECT * FROM '%s' ORDER BY id", path))
+		} else {
+			args = append(args, path)
+		}
+		if _, err := exec.LookPath(reader.command[0]); err != nil {
+			continue
+		}
+		output, err := exec.Command(reader.command[0], args...).Output()
+		if err != nil {
+			// e.g. python3 without pyarrow
+			t.Logf("%s unavailable: %v", reader.name, err)
+			continue
+		}
+		tested = true
+		t.Run(reader.name, func(t *testing.T) {
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(output, &rows); err != nil {
+				t.Fatalf("Unexpected output %s: %v", output, err)
+			}
+			if len(rows) != 3 {
+				t.Fatalf("Expected 3 rows, got %d", len(rows))
+			}
+			ids, names, ages := []interface{}{}, []interface{}{}, []interface{}{}
+			for _, row := range rows {
+				ids = append(ids, row["id"])
+				names = append(names, row["Name"])
+				ages = append(ages, row["Age"])
+			}
+			if !reflect.DeepEqual(ids, []interface{}{1.0, 2.0, 3.0}) ||
+				!reflect.DeepEqual(names, []interface{}{"Alice", nil, "Carol"}) ||
+				!reflect.DeepEqual(ages, []interface{}{30.0, nil, 41.0}) {
+				t.Errorf("Unexpected rows %v", rows)
+			}
+			// JSON columns are read as text by pyarrow, as JSON values by DuckDB
+			tags, ok := rows[0]["Tags"].(string)
+			if !ok {
+				encoded, _ := json.Marshal(rows[0]["Tags"])
+				tags = string(encoded)
+			}
+			if rows[0]["Active"] != true || tags != `["a","b"]` || fmt.Sprint(rows[0]["Born"]) != "1970-01-03" {
+				t.Errorf("Unexpected first row %v", rows[0])
+			}
+		})
+	}
+	if !tested {
+		t.Skip("no Parquet reader installed (pyarrow or duckdb)")
+	}
+}
@@ -0,0 +1,408 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+// Package sqlite writes the records of a Grist table as a SQLite database
+//
+// The database holds a single table, written once as a rowid b-tree: leaf
+// pages filled in rowid order, interior pages above them, and overflow pages
+// for large values. There are no indexes and no free pages. The layout of
+// pages, cells and records follows https://www.sqlite.org/fileformat.html
+package sqlite
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const pageSize = 4096
+
+// Page types of table b-trees
+const (
+	interiorPage = 0x05
+	leafPage     = 0x0d
+)
+
+// column is a column of the exported table
+// Values are converted from the JSON values of Grist records
+type column struct {
+	name     string
+	declType string
+	convert  func(interface{}) interface{}
+}
+
+// newColumn maps a Grist column type to a SQLite column
+// Dates are written as ISO 8601 text, lists as JSON arrays
+func newColumn(name string, gristType string) column {
+	column := column{name: name}
+	baseType, _, _ := strings.Cut(gristType, ":")
+	switch baseType {
+	case "Int", "Ref":
+		column.declType, column.convert = "INTEGER", convertInt
+	case "Numeric":
+		column.declType, column.convert = "REAL", convertFloat
+	case "Bool":
+		column.declType, column.convert = "BOOLEAN", convertBool
+	case "Date":
+		column.declType, column.convert = "DATE", convertTime("2006-01-02")
+	case "DateTime":
+		column.declType, column.convert = "DATETIME", convertTime("2006-01-02T15:04:05.000Z")
+	case "ChoiceList", "RefList", "Attachments":
+		column.declType, column.convert = "TEXT", convertList
+	default:
+		column.declType, column.convert = "TEXT", convertText
+	}
+	return column
+}
+
+// Conversions of the JSON values of Grist records
+// A value of the wrong type, e.g. an error or a text in a numeric
+// column, is written as a NULL
+
+func convertInt(value interface{}) interface{} {
+	if number, ok := value.(float64); ok {
+		return int64(number)
+	}
+	return nil
+}
+
+func convertFloat(value interface{}) interface{} {
+	if number, ok := value.(float64); ok {
+		return number
+	}
+	return nil
+}
+
+func convertBool(value interface{}) interface{} {
+	if b, ok := value.(bool); ok {
+		if b {
+			return int64(1)
+		}
+		return int64(0)
+	}
+	return nil
+}
+
+// Dates and datetimes are stored by Grist as seconds since the epoch
+func convertTime(layout string) func(interface{}) interface{} {
+	return func(value interface{}) interface{} {
+		if seconds, ok := value.(float64); ok {
+			return time.UnixMilli(int64(math.Round(seconds * 1000))).UTC().Format(layout)
+		}
+		return nil
+	}
+}
+
+// Lists are encoded by Grist as ["L", items...]
+func convertList(value interface{}) interface{} {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 || list[0] != "L" {
+		return nil
+	}
+	items, err := json.Marshal(list[1:])
+	if err != nil {
+		return nil
+	}
+	return string(items)
+}
+
+func convertText(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case []interface{}:
+		if len(v) > 0 && v[0] == "E" {
+			return nil
+		}
+	}
+	text, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return string(text)
+}
+
+// varint encodes v as a SQLite varint: big-endian groups of 7 bits,
+// the ninth byte holding 8 bits
+func varint(v uint64) []byte {
+	if v > 0x00ffffffffffffff {
+		encoded := make([]byte, 9)
+		encoded[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			encoded[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return encoded
+	}
+	encoded := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		encoded = append([]byte{byte(v&0x7f) | 0x80}, encoded...)
+	}
+	return encoded
+}
+
+// encodeRecord serializes values in the SQLite record format
+// Values are nil, int64, float64 or string
+func encodeRecord(values []interface{}) []byte {
+	var header, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = append(header, 0)
+		case int64:
+			switch {
+			case v == 0:
+				header = append(header, 8)
+			case v == 1:
+				header = append(header, 9)
+			case v >= math.MinInt8 && v <= math.MaxInt8:
+				header = append(header, 1)
+				body = append(body, byte(v))
+			case v >= math.MinInt16 && v <= math.MaxInt16:
+				header = append(header, 2)
+				body = binary.BigEndian.AppendUint16(body, uint16(v))
+			case v >= math.MinInt32 && v <= math.MaxInt32:
+				header = append(header, 4)
+				body = binary.BigEndian.AppendUint32(body, uint32(v))
+			default:
+				header = append(header, 6)
+				body = binary.BigEndian.AppendUint64(body, uint64(v))
+			}
+		case float64:
+			header = append(header, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			header = append(header, varint(uint64(2*len(v)+13))...)
+			body = append(body, v...)
+		}
+	}
+	// The header size includes its own varint
+	size := len(header) + 1
+	if len(varint(uint64(size))) > 1 {
+		size++
+	}
+	record := append(varint(uint64(size)), header...)
+	return append(record, body...)
+}
+
+// database collects the pages of a database being written
+// Page 1 is reserved for the schema; the other pages are added in order
+type database struct {
+	pages [][]byte
+}
+
+// newPage adds an empty page, returning its number
+func (f *database) newPage() (int, []byte) {
+	page := make([]byte, pageSize)
+	f.pages = append(f.pages, page)
+	return len(f.pages), page
+}
+
+// leafCell builds the cell of a row, moving the end of a payload too large
+// to fit in a leaf page to overflow pages
+func (f *database) leafCell(rowid int64, payload []byte) []byte {
+	cell := varint(uint64(len(payload)))
+	cell = append(cell, varint(uint64(rowid))...)
+
+	usable := pageSize
+	maxLocal := usable - 35
+	if len(payload) <= maxLocal {
+		return append(cell, payload...)
+	}
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+
+	// Overflow pages are chained: each starts with the number of the next
+	rest := payload[local:]
+	first, page := f.newPage()
+	for {
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		if len(rest) == 0 {
+			break
+		}
+		next, nextPage := f.newPage()
+		binary.BigEndian.PutUint32(page, uint32(next))
+		page = nextPage
+	}
+	return binary.BigEndian.AppendUint32(cell, uint32(first))
+}
+
+// btreePage fills a b-tree page with cells, headerOffset being 100 on page 1
+// rightChild is the right-most pointer of interior pages
+func btreePage(page []byte, headerOffset int, pageType byte, cells [][]byte, rightChild int) {
+	page[headerOffset] = pageType
+	headerSize := 8
+	if pageType == interiorPage {
+		headerSize = 12
+		binary.BigEndian.PutUint32(page[headerOffset+8:], uint32(rightChild))
+	}
+	binary.BigEndian.PutUint16(page[headerOffset+3:], uint16(len(cells)))
+
+	content := len(page)
+	pointers := headerOffset + headerSize
+	for i, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[pointers+2*i:], uint16(content))
+	}
+	binary.BigEndian.PutUint16(page[headerOffset+5:], uint16(content))
+}
+
+// btreeChild is a page of a b-tree level, with the largest rowid it holds
+type btreeChild struct {
+	page     int
+	maxRowid int64
+}
+
+// Maximum number of children of an interior page: a cell holds a 4 bytes
+// page number and a rowid varint of up to 9 bytes, plus its 2 bytes pointer,
+// and one more child is the right-most pointer
+const maxChildren = (pageSize-12)/15 + 1
+
+// writeTable writes the rows as a table b-tree, returning its root page
+func (f *database) writeTable(rowids []int64, payloads [][]byte) int {
+	// Leaf pages, filled in rowid order
+	level := []btreeChild{}
+	cells := [][]byte{}
+	used := 8
+	for i, payload := range payloads {
+		cell := f.leafCell(rowids[i], payload)
+		if len(cells) > 0 && used+len(cell)+2 > pageSize {
+			number, page := f.newPage()
+			btreePage(page, 0, leafPage, cells, 0)
+			level = append(level, btreeChild{number, rowids[i-1]})
+			cells, used = [][]byte{}, 8
+		}
+		cells = append(cells, cell)
+		used += len(cell) + 2
+	}
+	if len(cells) > 0 || len(level) == 0 {
+		number, page := f.newPage()
+		btreePage(page, 0, leafPage, cells, 0)
+		maxRowid := int64(0)
+		if len(rowids) > 0 {
+			maxRowid = rowids[len(rowids)-1]
+		}
+		level = append(level, btreeChild{number, maxRowid})
+	}
+
+	// Interior levels, until a single root page remains
+	// Children are spread evenly so that no page is left with a single one
+	for len(level) > 1 {
+		nbPages := (len(level) + maxChildren - 1) / maxChildren
+		perPage := (len(level) + nbPages - 1) / nbPages
+		parents := []btreeChild{}
+		for start := 0; start < len(level); start += perPage {
+			children := level[start:min(start+perPage, len(level))]
+			last := children[len(children)-1]
+			cells := make([][]byte, 0, len(children)-1)
+			for _, child := range children[:len(children)-1] {
+				cell := binary.BigEndian.AppendUint32(nil, uint32(child.page))
+				cells = append(cells, append(cell, varint(uint64(child.maxRowid))...))
+			}
+			number, page := f.newPage()
+			btreePage(page, 0, interiorPage, cells, last.page)
+			parents = append(parents, btreeChild{number, last.maxRowid})
+		}
+		level = parents
+	}
+	return level[0].page
+}
+
+// Column of a Grist table, Type being its Grist type, e.g. "Int" or "Date"
+type Column struct {
+	Name string
+	Type string
+}
+
+// Row of a table: its record ID and the values of its columns by name
+type Row struct {
+	Id     int
+	Fields map[string]interface{}
+}
+
+// quoteIdentifier quotes a table or column name for the schema
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Write writes to w a SQLite database holding the rows in a table, with the
+// record ID as INTEGER PRIMARY KEY followed by the given columns
+// Grist types are mapped to SQLite column types: Int and Ref to INTEGER,
+// Numeric to REAL, Bool to BOOLEAN (0 or 1), Date and DateTime to ISO 8601
+// text, lists to JSON text and other columns to TEXT. Cells holding an error
+// or a value of the wrong type are written as NULLs.
+func Write(w io.Writer, table string, columns []Column, rows []Row) error {
+	sqliteColumns := make([]column, len(columns))
+	definitions := []string{`"id" INTEGER PRIMARY KEY`}
+	for i, c := range columns {
+		sqliteColumns[i] = newColumn(c.Name, c.Type)
+		definitions = append(definitions, quoteIdentifier(c.Name)+" "+sqliteColumns[i].declType)
+	}
+	createTable := fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(table), strings.Join(definitions, ", "))
+
+	sorted := make([]Row, len(rows))
+	copy(sorted, rows)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	rowids := make([]int64, len(sorted))
+	payloads := make([][]byte, len(sorted))
+	for i, row := range sorted {
+		// The INTEGER PRIMARY KEY is the rowid, stored as NULL in the record
+		values := []interface{}{nil}
+		for _, c := range sqliteColumns {
+			values = append(values, c.convert(row.Fields[c.name]))
+		}
+		rowids[i] = int64(row.Id)
+		payloads[i] = encodeRecord(values)
+	}
+
+	db := &database{}
+	_, schemaPage := db.newPage()
+	root := db.writeTable(rowids, payloads)
+
+	schema := encodeRecord([]interface{}{"table", table, table, int64(root), createTable})
+	schemaCell := db.leafCell(1, schema)
+	if 100+8+2+len(schemaCell) <= pageSize {
+		btreePage(schemaPage, 100, leafPage, [][]byte{schemaCell}, 0)
+	} else {
+		// The row doesn't fit after the file header: page 1 then only points
+		// to a leaf holding it
+		number, page := db.newPage()
+		btreePage(page, 0, leafPage, [][]byte{schemaCell}, 0)
+		btreePage(schemaPage, 100, interiorPage, nil, number)
+	}
+
+	header := schemaPage[:100]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], pageSize)
+	header[18], header[19] = 1, 1 // legacy journal mode
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1)                     // change counter
+	binary.BigEndian.PutUint32(header[28:], uint32(len(db.pages))) // database size
+	binary.BigEndian.PutUint32(header[40:], 1)                     // schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4)                     // schema format
+	binary.BigEndian.PutUint32(header[56:], 1)                     // UTF-8
+	binary.BigEndian.PutUint32(header[92:], 1)                     // version-valid-for
+	binary.BigEndian.PutUint32(header[96:], 3045000)
+
+	for _, page := range db.pages {
+		if _, err := w.Write(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	tests := []struct {
+		value    uint64
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{16383, []byte{0xff, 0x7f}},
+		{1 << 56, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+	}
+	for _, tt := range tests {
+		if encoded := varint(tt.value); !bytes.Equal(encoded, tt.expected) {
+			t.Errorf("varint(%d) = %x, expected %x", tt.value, encoded, tt.expected)
+		}
+	}
+}
+
+func TestEncodeRecord(t *testing.T) {
+	record := encodeRecord([]interface{}{nil, int64(1), int64(-2), int64(300), 1.5, "ab"})
+	expected := []byte{
+		7, 0, 9, 1, 2, 7, 17, // header: size, then serial types
+		0xfe,       // -2
+		0x01, 0x2c, // 300
+		0x3f, 0xf8, 0, 0, 0, 0, 0, 0, // 1.5
+		'a', 'b',
+	}
+	if !bytes.Equal(record, expected) {
+		t.Errorf("Unexpected record %x, expected %x", record, expected)
+	}
+}
+
+var testColumns = []Column{
+	{Name: "Name", Type: "Text"},
+	{Name: "Age", Type: "Int"},
+	{Name: "Born", Type: "Date"},
+	{Name: "Score", Type: "Numeric"},
+	{Name: "Active", Type: "Bool"},
+	{Name: "Tags", Type: "ChoiceList"},
+}
+
+// testRows returns enough rows, in reverse order, for several leaf pages and
+// an interior root, some of them with overflow pages
+func testRows() []Row {
+	rows := []Row{}
+	for id := 2000; id >= 1; id-- {
+		name := "name"
+		if id%100 == 0 {
+			name = strings.Repeat("x", 10000)
+		}
+		rows = append(rows, Row{Id: id, Fields: map[string]interface{}{
+			"Name":   name,
+			"Age":    float64(id),
+			"Born":   float64(86400),
+			"Score":  1.5,
+			"Active": id%2 == 0,
+			"Tags":   []interface{}{"L", "a", "b"},
+		}})
+	}
+	// A value of the wrong type is written as a NULL
+	rows[0].Fields["Age"] = []interface{}{"E", "TypeError"}
+	return rows
+}
+
+func TestWrite(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := Write(&buffer, "People", testColumns, testRows()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data := buffer.Bytes()
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		t.Fatal("Expected the SQLite header string")
+	}
+	if len(data)%pageSize != 0 || binary.BigEndian.Uint32(data[28:]) != uint32(len(data)/pageSize) {
+		t.Errorf("Database size in header doesn't match the %d bytes file", len(data))
+	}
+	if data[100] != leafPage {
+		t.Errorf("Expected the schema on a leaf page 1, got page type %d", data[100])
+	}
+	createTable := `CREATE TABLE "People" ("id" INTEGER PRIMARY KEY, "Name" TEXT, "Age" INTEGER, "Born" DATE, "Score" REAL, "Active" BOOLEAN, "Tags" TEXT)`
+	if !bytes.Contains(data[:pageSize], []byte(createTable)) {
+		t.Error("Expected the CREATE TABLE statement in the schema")
+	}
+
+	interiorPages := 0
+	for offset := pageSize; offset < len(data); offset += pageSize {
+		if data[offset] == interiorPage {
+			interiorPages++
+		}
+	}
+	if interiorPages != 1 {
+		t.Errorf("Expected an interior root page above the leaves, got %d interior pages", interiorPages)
+	}
+}
+
+// TestReadBack checks the database with the sqlite3 command line tool, when
+// it is installed
+func TestReadBack(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	path := filepath.Join(t.TempDir(), "people.sqlite")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(file, "People", testColumns, testRows()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []struct {
+		query    string
+		expected string
+	}{
+		{"PRAGMA integrity_check", "ok"},
+		{"SELECT count(*), min(id), max(id), sum(Age IS NULL) FROM People", "2000|1|2000|1"},
+		{"SELECT Name, Age, Born, Score, Active, Tags FROM People WHERE id = 3", `name|3|1970-01-02|1.5|0|["a","b"]`},
+		{"SELECT length(Name), Active FROM People WHERE id = 1000", "10000|1"},
+		{"SELECT typeof(Age), typeof(Score) FROM People WHERE id = 1", "integer|real"},
+	}
+	for _, tt := range queries {
+		// #nosec G204 - test queries are constants
+		output, err := exec.Command("sqlite3", path, tt.query).CombinedOutput()
+		if err != nil {
+			t.Fatalf("sqlite3 %q failed: %v: %s", tt.query, err, output)
+		}
+		if got := strings.TrimSpace(string(output)); got != tt.expected {
+			t.Errorf("sqlite3 %q = %q, expected %q", tt.query, got, tt.expected)
+		}
+	}
+}
@@ -2,31 +2,174 @@ package mcp
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bdmorin/gristle/gristapi"
+	"github.com/bdmorin/gristle/gristtools"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// concurrency bounds the number of simultaneous requests a tool sends to Grist
+var concurrency = 8
+
+// SetConcurrency sets the maximum number of simultaneous requests per tool call
+func SetConcurrency(n int) {
+	if n > 0 {
+		concurrency = n
+	}
+}
+
+// toolTimeout bounds the time a tool call waits for Grist, 0 for the
+// timeout of API requests (see gristapi.SetTimeout)
+var toolTimeout time.Duration
+
+// SetToolTimeout sets the time after which a tool call is abandoned, 0 to
+// use the timeout of API requests
+func SetToolTimeout(timeout time.Duration) {
+	if timeout >= 0 {
+		toolTimeout = timeout
+	}
+}
+
+// withTimeout ends a tool call when toolTimeout elapses or the client
+// cancels it, returning a tool error instead of leaving the assistant
+// waiting. The context given to the handler cancels its pending requests
+// to Grist, and handlers sending several requests check it between them
+func withTimeout(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		timeout := toolTimeout
+		if timeout == 0 {
+			timeout = gristapi.Timeout()
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		result, err := next(ctx, req)
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return mcp.NewToolResultError(fmt.Sprintf("%s timed out after %s", req.Params.Name, timeout)), nil
+		case ctx.Err() != nil:
+			return mcp.NewToolResultError(fmt.Sprintf("%s cancelled", req.Params.Name)), nil
+		}
+		return result, err
+	}
+}
+
+// Whether the tools modifying documents are left out (see SetReadOnly)
+var readOnly bool
+
+// Tools offered by the server, in registration order
+// Mutating tools modify documents or write files, and are left out in
+// read-only mode
+var tools = []struct {
+	name     string
+	register func(s *server.MCPServer)
+	mutating bool
+}{
+	{"list_orgs", registerListOrgs, false},
+	{"list_workspaces", registerListWorkspaces, false},
+	{"list_docs", registerListDocs, false},
+	{"get_doc", registerGetDoc, false},
+	{"export_doc", registerExportDoc, true},
+	{"get_doc_tables", registerGetDocTables, false},
+	{"query_sql", registerQuerySQL, false},
+	{"get_doc_webhooks", registerGetDocWebhooks, false},
+	{"get_org_usage", registerGetOrgUsage, false},
+	{"list_attachments", registerListAttachments, false},
+	{"get_attachment_metadata", registerGetAttachmentMetadata, false},
+	{"create_table", registerCreateTable, true},
+	{"rename_table", registerRenameTable, true},
+	{"delete_records", registerDeleteRecords, true},
+	{"create_webhook", registerCreateWebhook, true},
+	{"delete_webhook", registerDeleteWebhook, true},
+}
+
+// isMutatingTool tells whether a tool is left out in read-only mode
+func isMutatingTool(name string) bool {
+	for _, tool := range tools {
+		if tool.name == name {
+			return tool.mutating
+		}
+	}
+	return false
+}
+
+// MutatingTools returns the names of the tools left out in read-only mode
+func MutatingTools() []string {
+	names := []string{}
+	for _, tool := range tools {
+		if tool.mutating {
+			names = append(names, tool.name)
+		}
+	}
+	return names
+}
+
+// SetReadOnly leaves out the tools modifying documents or writing files, so
+// that only listing, reading and querying tools are offered
+func SetReadOnly(enabled bool) {
+	readOnly = enabled
+}
+
+// refuseMutatingTools rejects calls to the tools left out in read-only mode
+// with an explicit error, rather than the generic one of unknown tools
+func refuseMutatingTools(ctx context.Context, id any, message any) error {
+	raw, ok := message.(json.RawMessage)
+	if !ok {
+		return nil
+	}
+	var request struct {
+		Method string `json:"method"`
+		Params struct {
+			Name string `json:"name"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil || request.Method != string(mcp.MethodToolsCall) {
+		return nil
+	}
+	if isMutatingTool(request.Params.Name) {
+		return fmt.Errorf("tool %s modifies data or files and is disabled: the server runs in read-only mode", request.Params.Name)
+	}
+	return nil
+}
+
 // NewServer creates a new MCP server for Grist operations
 func NewServer() *server.MCPServer {
-	s := server.NewMCPServer(
-		"gristle",
-		"1.0.0",
+	options := []server.ServerOption{
 		server.WithToolCapabilities(true),
-	)
+		server.WithToolHandlerMiddleware(withTimeout),
+	}
+	if readOnly {
+		hooks := &server.Hooks{}
+		hooks.AddOnRequestInitialization(refuseMutatingTools)
+		options = append(options, server.WithHooks(hooks),
+			server.WithInstructions("Read-only access: the tools modifying documents or writing files are disabled."))
+	}
+	s := server.NewMCPServer("gristle", "1.0.0", options...)
 
 	// Register tools
-	registerListOrgs(s)
-	registerListWorkspaces(s)
-	registerListDocs(s)
-	registerGetDoc(s)
-	registerExportDoc(s)
-	registerGetDocTables(s)
-	registerDeleteRecords(s)
-	registerGetDocWebhooks(s)
+	for _, tool := range tools {
+		if readOnly && tool.mutating {
+			continue
+		}
+		tool.register(s)
+	}
+
+	// Register resources
+	registerAttachmentResource(s)
 
 	return s
 }
@@ -44,7 +187,7 @@ func registerListOrgs(s *server.MCPServer) {
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		orgs := gristapi.GetOrgs()
+		orgs := gristapi.GetOrgsContext(ctx)
 
 		type orgInfo struct {
 			ID     int    `json:"id"`
@@ -86,7 +229,7 @@ func registerListWorkspaces(s *server.MCPServer) {
 			return mcp.NewToolResultError("org_id is required"), nil
 		}
 
-		workspaces := gristapi.GetOrgWorkspaces(orgID)
+		workspaces := gristapi.GetOrgWorkspacesContext(ctx, orgID)
 
 		type wsInfo struct {
 			ID       int    `json:"id"`
@@ -128,7 +271,13 @@ func registerListDocs(s *server.MCPServer) {
 			return mcp.NewToolResultError("workspace_id is required"), nil
 		}
 
-		workspace := gristapi.GetWorkspace(wsID)
+		workspace, status := gristapi.GetWorkspaceContext(ctx, wsID)
+		if status == http.StatusNotFound {
+			return mcp.NewToolResultError(fmt.Sprintf("workspace not found: %d", wsID)), nil
+		}
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get workspace, status code: %d", status)), nil
+		}
 
 		type docInfo struct {
 			ID       string `json:"id"`
@@ -170,34 +319,12 @@ func registerGetDoc(s *server.MCPServer) {
 			return mcp.NewToolResultError("doc_id is required"), nil
 		}
 
-		doc := gristapi.GetDoc(docID)
-		tables := gristapi.GetDocTables(docID)
-
-		type tableInfo struct {
-			ID string `json:"id"`
+		result, status := gristtools.GetDocDetails(ctx, docID)
+		if status == http.StatusNotFound {
+			return mcp.NewToolResultError(fmt.Sprintf("document not found: %s", docID)), nil
 		}
-
-		type docDetail struct {
-			ID        string      `json:"id"`
-			Name      string      `json:"name"`
-			IsPinned  bool        `json:"is_pinned"`
-			Workspace string      `json:"workspace"`
-			Org       string      `json:"org"`
-			Tables    []tableInfo `json:"tables"`
-		}
-
-		tableList := make([]tableInfo, len(tables.Tables))
-		for i, t := range tables.Tables {
-			tableList[i] = tableInfo{ID: t.Id}
-		}
-
-		result := docDetail{
-			ID:        doc.Id,
-			Name:      doc.Name,
-			IsPinned:  doc.IsPinned,
-			Workspace: doc.Workspace.Name,
-			Org:       doc.Workspace.Org.Name,
-			Tables:    tableList,
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get document, status code: %d", status)), nil
 		}
 
 		jsonBytes, err := json.MarshalIndent(result, "", "  ")
@@ -239,28 +366,59 @@ func registerExportDoc(s *server.MCPServer) {
 		}
 
 		// Get doc name for default filename
-		doc := gristapi.GetDoc(docID)
-		filename := req.GetString("filename", doc.Name)
+		doc, status := gristapi.GetDocContext(ctx, docID)
+		if status == http.StatusNotFound {
+			return mcp.NewToolResultError(fmt.Sprintf("document not found: %s", docID)), nil
+		}
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get document, status code: %d", status)), nil
+		}
 
 		switch format {
 		case "excel":
-			if filename[len(filename)-5:] != ".xlsx" {
-				filename += ".xlsx"
+			filename := exportFileName(req.GetString("filename", ""), doc, "xlsx")
+			if err := gristapi.ExportDocExcelContext(ctx, docID, filename, nil); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
 			}
-			gristapi.ExportDocExcel(docID, filename)
+			return exportResult(filename), nil
 		case "grist":
-			if filename[len(filename)-6:] != ".grist" {
-				filename += ".grist"
+			filename := exportFileName(req.GetString("filename", ""), doc, "grist")
+			if err := gristapi.ExportDocGristContext(ctx, docID, filename); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
 			}
-			gristapi.ExportDocGrist(docID, filename)
+			return exportResult(filename), nil
 		default:
 			return mcp.NewToolResultError("invalid format: " + format), nil
 		}
-
-		return mcp.NewToolResultText(fmt.Sprintf("Document exported to %s", filename)), nil
 	})
 }
 
+// exportFileName returns the file a document is exported to: the given
+// filename, else the document name made safe for a file name, else its ID
+// if the document has no name. The extension is added if missing
+func exportFileName(filename string, doc gristapi.Doc, extension string) string {
+	if strings.TrimSpace(filename) == "" {
+		name := doc.Name
+		if strings.TrimSpace(name) == "" {
+			name = doc.Id
+		}
+		return gristapi.ExportFileName(extension, name)
+	}
+	if !strings.HasSuffix(strings.ToLower(filename), "."+extension) {
+		filename += "." + extension
+	}
+	return filename
+}
+
+// exportResult reports the absolute path of an exported file
+func exportResult(filename string) *mcp.CallToolResult {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		path = filename
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Document exported to %s", path))
+}
+
 // registerGetDocTables adds the get_doc_tables tool
 func registerGetDocTables(s *server.MCPServer) {
 	tool := mcp.NewTool("get_doc_tables",
@@ -277,7 +435,7 @@ func registerGetDocTables(s *server.MCPServer) {
 			return mcp.NewToolResultError("doc_id is required"), nil
 		}
 
-		tables := gristapi.GetDocTables(docID)
+		tables := gristapi.GetDocTablesContext(ctx, docID)
 
 		type colInfo struct {
 			ID string `json:"id"`
@@ -288,18 +446,34 @@ func registerGetDocTables(s *server.MCPServer) {
 			Columns []colInfo `json:"columns"`
 		}
 
+		// Fetch columns concurrently, bounded by the concurrency limit,
+		// writing each result at its table's index to preserve order
 		result := make([]tableDetail, len(tables.Tables))
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
 		for i, t := range tables.Tables {
-			cols := gristapi.GetTableColumns(docID, t.Id)
-			colList := make([]colInfo, len(cols.Columns))
-			for j, c := range cols.Columns {
-				colList[j] = colInfo{ID: c.Id}
-			}
-			result[i] = tableDetail{
-				ID:      t.Id,
-				Columns: colList,
-			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				// The call was abandoned: don't send the queued requests
+				if ctx.Err() != nil {
+					return
+				}
+
+				cols := gristapi.GetTableColumnsContext(ctx, docID, t.Id)
+				colList := make([]colInfo, len(cols.Columns))
+				for j, c := range cols.Columns {
+					colList[j] = colInfo{ID: c.Id}
+				}
+				result[i] = tableDetail{
+					ID:      t.Id,
+					Columns: colList,
+				}
+			}()
 		}
+		wg.Wait()
 
 		jsonBytes, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
@@ -310,6 +484,178 @@ func registerGetDocTables(s *server.MCPServer) {
 	})
 }
 
+// registerCreateTable adds the create_table tool
+func registerCreateTable(s *server.MCPServer) {
+	tool := mcp.NewTool("create_table",
+		mcp.WithDescription("Create a table with its columns in a document"),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+		mcp.WithString("table_id",
+			mcp.Required(),
+			mcp.Description("The table ID (e.g. People)"),
+		),
+		mcp.WithArray("columns",
+			mcp.Required(),
+			mcp.Description("Columns to create, each with an id, an optional type (Text, Numeric, Int, Bool, Date, DateTime, Choice, Ref:Table...) and an optional label"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":    map[string]any{"type": "string"},
+					"type":  map[string]any{"type": "string"},
+					"label": map[string]any{"type": "string"},
+				},
+				"required": []string{"id"},
+			}),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		tableID, err := req.RequireString("table_id")
+		if err != nil {
+			return mcp.NewToolResultError("table_id is required"), nil
+		}
+
+		columns, err := parseColumnDefs(req.GetArguments()["columns"])
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		table, status := gristapi.CreateTableContext(ctx, docID, gristapi.TableDef{Id: tableID, Columns: columns})
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to create table, status code: %d", status)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Table %s created with %d column(s)", table.Id, len(columns))), nil
+	})
+}
+
+// parseColumnDefs converts the columns argument of create_table into column definitions
+func parseColumnDefs(arg any) ([]gristapi.ColumnDef, error) {
+	items, ok := arg.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("columns must be a non-empty array")
+	}
+
+	columns := make([]gristapi.ColumnDef, 0, len(items))
+	for i, item := range items {
+		col, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("column %d must be an object", i)
+		}
+		id, _ := col["id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("column %d requires an id", i)
+		}
+		fields := map[string]interface{}{}
+		if colType, ok := col["type"].(string); ok && colType != "" {
+			fields["type"] = colType
+		}
+		if label, ok := col["label"].(string); ok && label != "" {
+			fields["label"] = label
+		}
+		columns = append(columns, gristapi.ColumnDef{Id: id, Fields: fields})
+	}
+	return columns, nil
+}
+
+// registerRenameTable adds the rename_table tool
+func registerRenameTable(s *server.MCPServer) {
+	tool := mcp.NewTool("rename_table",
+		mcp.WithDescription("Rename a table in a document"),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+		mcp.WithString("table_id",
+			mcp.Required(),
+			mcp.Description("The current table ID"),
+		),
+		mcp.WithString("new_table_id",
+			mcp.Required(),
+			mcp.Description("The new table ID"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		tableID, err := req.RequireString("table_id")
+		if err != nil {
+			return mcp.NewToolResultError("table_id is required"), nil
+		}
+
+		newTableID, err := req.RequireString("new_table_id")
+		if err != nil {
+			return mcp.NewToolResultError("new_table_id is required"), nil
+		}
+
+		_, status := gristapi.UpdateTableContext(ctx, docID, tableID, map[string]interface{}{"tableId": newTableID})
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to rename table, status code: %d", status)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Table %s renamed to %s", tableID, newTableID)), nil
+	})
+}
+
+// registerQuerySQL adds the query_sql tool
+func registerQuerySQL(s *server.MCPServer) {
+	tool := mcp.NewTool("query_sql",
+		mcp.WithDescription("Run a read-only SQL SELECT query against a document and return the result rows. Tables are named by their table ID."),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("A single SELECT statement (e.g. SELECT COUNT(*) FROM Orders)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		sql, err := req.RequireString("sql")
+		if err != nil {
+			return mcp.NewToolResultError("sql is required"), nil
+		}
+
+		if err := gristapi.ValidateReadOnlySQL(sql); err != nil {
+			return mcp.NewToolResultError("query rejected: " + err.Error()), nil
+		}
+
+		result, status := gristapi.RunSQLContext(ctx, docID, sql, nil)
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to run query, status code: %d", status)), nil
+		}
+
+		rows := make([]map[string]interface{}, len(result.Records))
+		for i, record := range result.Records {
+			rows[i] = record.Fields
+		}
+
+		jsonBytes, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	})
+}
+
 // registerDeleteRecords adds the delete_records tool
 func registerDeleteRecords(s *server.MCPServer) {
 	tool := mcp.NewTool("delete_records",
@@ -348,7 +694,7 @@ func registerDeleteRecords(s *server.MCPServer) {
 			return mcp.NewToolResultError("row_ids cannot be empty"), nil
 		}
 
-		_, status := gristapi.DeleteRecords(docID, tableID, rowIDs)
+		_, status := gristapi.DeleteRecordsContext(ctx, docID, tableID, rowIDs)
 
 		if status == 200 {
 			return mcp.NewToolResultText(fmt.Sprintf("Successfully deleted %d record(s)", len(rowIDs))), nil
@@ -374,7 +720,7 @@ func registerGetDocWebhooks(s *server.MCPServer) {
 			return mcp.NewToolResultError("doc_id is required"), nil
 		}
 
-		webhooks := gristapi.GetDocWebhooks(docID)
+		webhooks := gristapi.GetDocWebhooksContext(ctx, docID)
 
 		type webhookInfo struct {
 			ID         string   `json:"id"`
@@ -414,3 +760,334 @@ func registerGetDocWebhooks(s *server.MCPServer) {
 		return mcp.NewToolResultText(string(jsonBytes)), nil
 	})
 }
+
+// registerGetOrgUsage adds the get_org_usage tool
+func registerGetOrgUsage(s *server.MCPServer) {
+	tool := mcp.NewTool("get_org_usage",
+		mcp.WithDescription("Get the storage usage of an organization: number of documents approaching or over their data limits and size of attachments, optionally with the usage of each document"),
+		mcp.WithString("org_id",
+			mcp.Required(),
+			mcp.Description("The organization ID or domain"),
+		),
+		mcp.WithBoolean("include_docs",
+			mcp.Description("Include the usage of each document, largest first (one request per document)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		orgID, err := req.RequireString("org_id")
+		if err != nil {
+			return mcp.NewToolResultError("org_id is required"), nil
+		}
+
+		org, status := gristapi.GetOrgContext(ctx, orgID)
+		if status == http.StatusNotFound {
+			return mcp.NewToolResultError(fmt.Sprintf("organization not found: %s", orgID)), nil
+		}
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get organization, status code: %d", status)), nil
+		}
+		usage := gristapi.GetOrgUsageSummaryContext(ctx, strconv.Itoa(org.Id))
+
+		type docUsage struct {
+			ID               string `json:"id"`
+			Name             string `json:"name"`
+			Workspace        string `json:"workspace"`
+			RowCount         int    `json:"row_count"`
+			DataBytes        int64  `json:"data_bytes"`
+			AttachmentsBytes int64  `json:"attachments_bytes"`
+			Error            string `json:"error,omitempty"`
+		}
+
+		type orgUsage struct {
+			ID               int        `json:"id"`
+			Name             string     `json:"name"`
+			ApproachingLimit int        `json:"docs_approaching_limit"`
+			GracePeriod      int        `json:"docs_in_grace_period"`
+			DeleteOnly       int        `json:"docs_delete_only"`
+			AttachmentsBytes int        `json:"attachments_bytes"`
+			Docs             []docUsage `json:"docs,omitempty"`
+		}
+
+		result := orgUsage{
+			ID:               org.Id,
+			Name:             org.Name,
+			ApproachingLimit: usage.CountsByDataLimitStatus.ApproachingLimit,
+			GracePeriod:      usage.CountsByDataLimitStatus.GracePeriod,
+			DeleteOnly:       usage.CountsByDataLimitStatus.DeleteOnly,
+			AttachmentsBytes: usage.Attachments.TotalBytes,
+		}
+		if req.GetBool("include_docs", false) {
+			for _, entry := range gristapi.GetOrgDocsUsageContext(ctx, org.Id) {
+				doc := docUsage{
+					ID:               entry.DocId,
+					Name:             entry.DocName,
+					Workspace:        entry.WorkspaceName,
+					RowCount:         entry.Usage.RowCount,
+					DataBytes:        entry.Usage.DataSizeBytes,
+					AttachmentsBytes: entry.Usage.AttachmentsSizeBytes,
+				}
+				if entry.Status != http.StatusOK {
+					doc.Error = fmt.Sprintf("usage unavailable, status code: %d", entry.Status)
+				}
+				result.Docs = append(result.Docs, doc)
+			}
+		}
+
+		jsonBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	})
+}
+
+// registerCreateWebhook adds the create_webhook tool
+func registerCreateWebhook(s *server.MCPServer) {
+	tool := mcp.NewTool("create_webhook",
+		mcp.WithDescription("Create a webhook calling a URL when records of a table are added or updated"),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("The http or https URL called with the records"),
+		),
+		mcp.WithString("table_id",
+			mcp.Required(),
+			mcp.Description("The table whose records trigger the webhook"),
+		),
+		mcp.WithArray("event_types",
+			mcp.Required(),
+			mcp.Description("Events triggering the webhook"),
+			mcp.WithStringItems(mcp.Enum(gristapi.WebhookEventTypes...)),
+		),
+		mcp.WithString("name",
+			mcp.Description("Name of the webhook (optional)"),
+		),
+		mcp.WithString("is_ready_column",
+			mcp.Description("Bool column that must be true for a record to be sent (optional)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		url := req.GetString("url", "")
+		tableID := req.GetString("table_id", "")
+		eventTypes := req.GetStringSlice("event_types", nil)
+		enabled := true
+		fields := gristapi.WebhookPartialFields{
+			URL:        &url,
+			TableId:    &tableID,
+			EventTypes: &eventTypes,
+			Enabled:    &enabled,
+		}
+		if name := req.GetString("name", ""); name != "" {
+			fields.Name = &name
+		}
+		if column := req.GetString("is_ready_column", ""); column != "" {
+			fields.IsReadyColumn = &column
+		}
+		if err := gristapi.ValidateWebhooksCreate([]gristapi.WebhookPartialFields{fields}); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		created, status := gristapi.CreateWebhooksContext(ctx, docID, []gristapi.WebhookPartialFields{fields})
+		if status != http.StatusOK || len(created.Webhooks) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to create webhook, status code: %d", status)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Webhook %s created on table %s", created.Webhooks[0].Id, tableID)), nil
+	})
+}
+
+// registerDeleteWebhook adds the delete_webhook tool
+func registerDeleteWebhook(s *server.MCPServer) {
+	tool := mcp.NewTool("delete_webhook",
+		mcp.WithDescription("Delete a webhook of a document"),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+		mcp.WithString("webhook_id",
+			mcp.Required(),
+			mcp.Description("The webhook ID, as listed by get_doc_webhooks"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		webhookID, err := req.RequireString("webhook_id")
+		if err != nil {
+			return mcp.NewToolResultError("webhook_id is required"), nil
+		}
+
+		_, status := gristapi.DeleteWebhookContext(ctx, docID, webhookID)
+		if status == http.StatusNotFound {
+			return mcp.NewToolResultError(fmt.Sprintf("webhook not found: %s", webhookID)), nil
+		}
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to delete webhook, status code: %d", status)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Webhook %s deleted", webhookID)), nil
+	})
+}
+
+// URI template of the attachment resources, read to download their content
+const attachmentURITemplate = "grist://docs/{doc_id}/attachments/{attachment_id}"
+
+// attachmentURI returns the URI of the resource holding an attachment
+func attachmentURI(docID string, attachmentID int) string {
+	return fmt.Sprintf("grist://docs/%s/attachments/%d", docID, attachmentID)
+}
+
+// attachmentInfo is an attachment's metadata with the URI of its content
+type attachmentInfo struct {
+	ID           int    `json:"id"`
+	FileName     string `json:"file_name"`
+	FileSize     int64  `json:"file_size"`
+	TimeUploaded string `json:"time_uploaded"`
+	ImageHeight  int    `json:"image_height,omitempty"`
+	ImageWidth   int    `json:"image_width,omitempty"`
+	URI          string `json:"uri"`
+}
+
+func newAttachmentInfo(docID string, attachment gristapi.AttachmentMetadata) attachmentInfo {
+	return attachmentInfo{
+		ID:           attachment.Id,
+		FileName:     attachment.FileName,
+		FileSize:     attachment.FileSize,
+		TimeUploaded: attachment.TimeUploaded,
+		ImageHeight:  attachment.ImageHeight,
+		ImageWidth:   attachment.ImageWidth,
+		URI:          attachmentURI(docID, attachment.Id),
+	}
+}
+
+// registerListAttachments adds the list_attachments tool
+func registerListAttachments(s *server.MCPServer) {
+	tool := mcp.NewTool("list_attachments",
+		mcp.WithDescription("List the attachments of a document with their metadata and the URI of the resource to read to download their content"),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		attachments, status := gristapi.ListAttachmentsContext(ctx, docID, nil)
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list attachments, status code: %d", status)), nil
+		}
+
+		result := make([]attachmentInfo, len(attachments.Records))
+		for i, attachment := range attachments.Records {
+			result[i] = newAttachmentInfo(docID, attachment)
+		}
+
+		jsonBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	})
+}
+
+// registerGetAttachmentMetadata adds the get_attachment_metadata tool
+func registerGetAttachmentMetadata(s *server.MCPServer) {
+	tool := mcp.NewTool("get_attachment_metadata",
+		mcp.WithDescription("Get the metadata of an attachment and the URI of the resource to read to download its content"),
+		mcp.WithString("doc_id",
+			mcp.Required(),
+			mcp.Description("The document ID"),
+		),
+		mcp.WithNumber("attachment_id",
+			mcp.Required(),
+			mcp.Description("The attachment ID"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		docID, err := req.RequireString("doc_id")
+		if err != nil {
+			return mcp.NewToolResultError("doc_id is required"), nil
+		}
+
+		attachmentID, err := req.RequireInt("attachment_id")
+		if err != nil {
+			return mcp.NewToolResultError("attachment_id is required"), nil
+		}
+
+		attachment, status := gristapi.GetAttachmentMetadataContext(ctx, docID, attachmentID)
+		if status == http.StatusNotFound {
+			return mcp.NewToolResultError(fmt.Sprintf("attachment not found: %d", attachmentID)), nil
+		}
+		if status != http.StatusOK {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get attachment, status code: %d", status)), nil
+		}
+
+		jsonBytes, err := json.MarshalIndent(newAttachmentInfo(docID, attachment), "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	})
+}
+
+// registerAttachmentResource adds the resources holding the content of
+// attachments, rather than inlining bytes in tool results
+func registerAttachmentResource(s *server.MCPServer) {
+	template := mcp.NewResourceTemplate(attachmentURITemplate, "attachment",
+		mcp.WithTemplateDescription("Content of a document attachment"),
+	)
+
+	s.AddResourceTemplate(template, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		docID := templateArgument(req.Params.Arguments, "doc_id")
+		attachmentID, err := strconv.Atoi(templateArgument(req.Params.Arguments, "attachment_id"))
+		if docID == "" || err != nil {
+			return nil, fmt.Errorf("invalid attachment URI %s", req.Params.URI)
+		}
+
+		content, contentType, status := gristapi.DownloadAttachmentContext(ctx, docID, attachmentID)
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("failed to download attachment %d, status code: %d", attachmentID, status)
+		}
+
+		return []mcp.ResourceContents{mcp.BlobResourceContents{
+			URI:      req.Params.URI,
+			MIMEType: contentType,
+			Blob:     base64.StdEncoding.EncodeToString(content),
+		}}, nil
+	})
+}
+
+// templateArgument returns a variable of a resource URI template
+func templateArgument(arguments map[string]any, name string) string {
+	switch value := arguments[name].(type) {
+	case string:
+		return value
+	case []string:
+		if len(value) > 0 {
+			return value[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bdmorin/gristle/gristapi"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestExportFileName(t *testing.T) {
+	tests := []struct {
+		name      string
+		filename  string
+		doc       gristapi.Doc
+		extension string
+		expected  string
+	}{
+		{"one-character doc name", "", gristapi.Doc{Id: "doc123", Name: "A"}, "xlsx", "A.xlsx"},
+		{"empty doc name", "", gristapi.Doc{Id: "doc123"}, "grist", "doc123.grist"},
+		{"unsafe doc name", "", gristapi.Doc{Id: "doc123", Name: "Q1/Q2: sales"}, "xlsx", "Q1_Q2_ sales.xlsx"},
+		{"short filename", "x", gristapi.Doc{Id: "doc123", Name: "Sales"}, "grist", "x.grist"},
+		{"filename with extension", "out/Report.XLSX", gristapi.Doc{Id: "doc123"}, "xlsx", "out/Report.XLSX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exportFileName(tt.filename, tt.doc, tt.extension); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A server slower than the tool timeout
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GRIST_URL", server.URL)
+
+	defer SetToolTimeout(toolTimeout)
+	SetToolTimeout(50 * time.Millisecond)
+	handler := withTimeout(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		gristapi.GetDocContext(ctx, "doc123")
+		return mcp.NewToolResultText("done"), nil
+	})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "get_doc"
+	start := time.Now()
+	result, err := handler(context.Background(), req)
+	if err != nil || !result.IsError {
+		t.Fatalf("Expected a tool error, got %+v, %v", result, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the call to end after the tool timeout, took %s", elapsed)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the request to Grist to be cancelled")
+	}
+}
+
+func TestNewServer_ReadOnly(t *testing.T) {
+	defer SetReadOnly(readOnly)
+
+	SetReadOnly(false)
+	if got := len(NewServer().ListTools()); got != len(tools) {
+		t.Errorf("Expected all %d tools, got %d", len(tools), got)
+	}
+
+	SetReadOnly(true)
+	names := []string{}
+	for name := range NewServer().ListTools() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	expected := []string{
+		"get_attachment_metadata", "get_doc", "get_doc_tables", "get_doc_webhooks", "get_org_usage",
+		"list_attachments", "list_docs", "list_orgs", "list_workspaces", "query_sql",
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("Expected read-only tools %v, got %v", expected, names)
+	}
+}
+
+func TestRefuseMutatingTools(t *testing.T) {
+	call := func(name string) error {
+		message := json.RawMessage(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "` + name + `"}}`)
+		return refuseMutatingTools(context.Background(), 1, message)
+	}
+	if err := call("export_doc"); err == nil {
+		t.Error("Expected export_doc to be refused in read-only mode")
+	}
+	if err := call("delete_records"); err == nil {
+		t.Error("Expected delete_records to be refused in read-only mode")
+	}
+	if err := call("query_sql"); err != nil {
+		t.Errorf("Expected query_sql to be allowed, got %v", err)
+	}
+}
+
+// Every tool of the table must register under its own name, which is the
+// one checked by refuseMutatingTools
+func TestToolNames(t *testing.T) {
+	for _, tool := range tools {
+		s := server.NewMCPServer("test", "1.0.0")
+		tool.register(s)
+		if s.GetTool(tool.name) == nil {
+			t.Errorf("Tool %s registered under another name: %v", tool.name, s.ListTools())
+		}
+	}
+}
+
+func TestMutatingTools(t *testing.T) {
+	names := MutatingTools()
+	if !slices.Contains(names, "export_doc") || !slices.Contains(names, "delete_records") {
+		t.Errorf("Expected export_doc and delete_records to be mutating, got %v", names)
+	}
+	if slices.Contains(names, "query_sql") {
+		t.Errorf("Expected query_sql not to be mutating, got %v", names)
+	}
+}
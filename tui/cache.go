@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdmorin/gristle/gristapi"
+)
+
+// Lifetime of cached tables and columns
+const cacheTTL = 30 * time.Second
+
+// Whether tables and columns are cached (see SetCache)
+var cacheEnabled = true
+
+// SetCache enables or disables the cache of tables and columns
+func SetCache(enabled bool) {
+	cacheEnabled = enabled
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// ttlCache keeps values for cacheTTL, so that navigating back and forth
+// doesn't fetch them again
+type ttlCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry[T]
+	now     func() time.Time
+}
+
+func newTTLCache[T any]() *ttlCache[T] {
+	return &ttlCache[T]{entries: map[string]cacheEntry[T]{}, now: time.Now}
+}
+
+// get returns the cached value of key, calling fetch if it is missing or
+// expired. Values for which keep returns false (failed fetches) aren't cached
+func (c *ttlCache[T]) get(key string, fetch func() T, keep func(T) bool) T {
+	if !cacheEnabled {
+		return fetch()
+	}
+
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	c.mu.Unlock()
+	if found && c.now().Before(entry.expires) {
+		return entry.value
+	}
+
+	value := fetch()
+	if keep(value) {
+		c.mu.Lock()
+		c.entries[key] = cacheEntry[T]{value: value, expires: c.now().Add(cacheTTL)}
+		c.mu.Unlock()
+	}
+	return value
+}
+
+// invalidate removes the entries whose key starts with prefix
+func (c *ttlCache[T]) invalidate(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+var (
+	tablesCache  = newTTLCache[gristapi.Tables]()
+	columnsCache = newTTLCache[gristapi.TableColumns]()
+)
+
+// getDocTables returns the tables of a document, from the cache if possible
+func getDocTables(docID string) gristapi.Tables {
+	return tablesCache.get(docID+"/",
+		func() gristapi.Tables { return gristapi.GetDocTables(docID) },
+		func(tables gristapi.Tables) bool { return len(tables.Tables) > 0 })
+}
+
+// getTableColumns returns the columns of a table, from the cache if possible
+func getTableColumns(docID, tableID string) gristapi.TableColumns {
+	return columnsCache.get(docID+"/"+tableID,
+		func() gristapi.TableColumns { return gristapi.GetTableColumns(docID, tableID) },
+		func(columns gristapi.TableColumns) bool { return len(columns.Columns) > 0 })
+}
+
+// invalidateDoc forgets the cached tables and columns of a document,
+// to be called after an action modifying them
+func invalidateDoc(docID string) {
+	tablesCache.invalidate(docID + "/")
+	columnsCache.invalidate(docID + "/")
+}
@@ -91,7 +91,12 @@ var (
 				BorderForeground(ColorMuted)
 
 	TableCellStyle = lipgloss.NewStyle().
-			Padding(0, 1)
+			Foreground(ColorFg)
+
+	TableSelectedCellStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(ColorFg).
+				Background(ColorPrimary)
 
 	// Badge styles (for counts, status)
 	BadgeStyle = lipgloss.NewStyle().
@@ -2,11 +2,18 @@ package tui
 
 import (
 	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/bdmorin/gristle/gristapi"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -24,6 +31,10 @@ const (
 	ViewTableActions
 	ViewDocAccess
 	ViewConfirmDelete
+	ViewWebhooks
+	ViewConfirmDeleteWebhook
+	ViewMoveDoc
+	ViewNewTable
 )
 
 // DocAction represents an action that can be performed on a document
@@ -34,6 +45,8 @@ const (
 	ActionExportExcel
 	ActionExportGrist
 	ActionViewAccess
+	ActionViewWebhooks
+	ActionMove
 	ActionDelete
 )
 
@@ -42,6 +55,8 @@ var docActionLabels = []string{
 	"Export as Excel (.xlsx)",
 	"Export as Grist (.grist)",
 	"View Access",
+	"View Webhooks",
+	"Move to Workspace",
 	"Delete Document",
 }
 
@@ -70,6 +85,9 @@ type Model struct {
 	docs       []gristapi.Doc
 	tables     []gristapi.Table
 
+	// Number of rows of each table
+	tableRowCounts map[string]int
+
 	// Table data
 	tableColumns []gristapi.TableColumn
 	tableData    map[string][]interface{} // column ID -> values
@@ -78,16 +96,21 @@ type Model struct {
 	// Access data
 	docAccess gristapi.EntityAccess
 
+	// Webhooks data
+	webhooks []gristapi.Webhook
+
 	// Selection context
 	selectedOrg       *gristapi.Org
 	selectedWorkspace *gristapi.Workspace
 	selectedDoc       *gristapi.Doc
 	selectedTable     *gristapi.Table
+	selectedWebhook   *gristapi.Webhook
 
 	// List state
 	cursor  int
+	offset  int // index of the first visible item
 	items   []string
-	itemIDs []interface{} // stores the actual items for selection
+	itemIDs []interface{} // stores the actual items for selection, in the order of items
 
 	// UI state
 	loading bool
@@ -95,10 +118,18 @@ type Model struct {
 	err     error
 	message string // success/info message
 
-	// Scroll state for table data
+	// Selected cell of table data (column and row indexes)
 	scrollX int
 	scrollY int
 
+	// Cell editing
+	editing   bool
+	editInput textinput.Model
+
+	// Table creation form: table ID, then column specs
+	newTableInputs []textinput.Model
+	newTableFocus  int
+
 	// Keybindings
 	keys KeyMap
 
@@ -109,19 +140,27 @@ type Model struct {
 // Messages
 type orgsLoadedMsg []gristapi.Org
 type workspacesLoadedMsg []gristapi.Workspace
-type docsLoadedMsg struct {
-	docs      []gristapi.Doc
-	workspace gristapi.Workspace
+type docsLoadedMsg []gristapi.Doc
+type tablesLoadedMsg struct {
+	tables    []gristapi.Table
+	rowCounts map[string]int
 }
-type tablesLoadedMsg []gristapi.Table
 type tableDataLoadedMsg struct {
 	columns []gristapi.TableColumn
 	data    map[string][]interface{}
 	rowIDs  []uint
 }
+type recordUpdatedMsg struct {
+	row    int
+	column string
+	value  interface{}
+}
 type docAccessLoadedMsg gristapi.EntityAccess
 type docDeletedMsg struct{}
-type csvExportedMsg string
+type docMovedMsg gristapi.Workspace
+type tableCreatedMsg gristapi.Table
+type webhooksLoadedMsg []gristapi.Webhook
+type webhookChangedMsg string
 type errMsg error
 type successMsg string
 
@@ -140,46 +179,108 @@ func loadWorkspaces(orgID int) tea.Cmd {
 
 func loadDocs(workspaceID int) tea.Cmd {
 	return func() tea.Msg {
-		workspace := gristapi.GetWorkspace(workspaceID)
-		return docsLoadedMsg{docs: workspace.Docs, workspace: workspace}
+		docs, status := gristapi.GetWorkspaceDocs(workspaceID)
+		if status != http.StatusOK {
+			return errMsg(fmt.Errorf("unable to load documents of workspace %d: HTTP %d", workspaceID, status))
+		}
+		return docsLoadedMsg(docs)
 	}
 }
 
 func loadTables(docID string) tea.Cmd {
 	return func() tea.Msg {
-		tables := gristapi.GetDocTables(docID)
-		return tablesLoadedMsg(tables.Tables)
+		tables := getDocTables(docID)
+		rowCounts := make(map[string]int)
+		for _, count := range gristapi.GetTablesRowCounts(docID) {
+			if count.Status == http.StatusOK {
+				rowCounts[count.TableId] = count.RowCount
+			}
+		}
+		return tablesLoadedMsg{tables: tables.Tables, rowCounts: rowCounts}
 	}
 }
 
 func exportExcel(docID, filename string) tea.Cmd {
 	return func() tea.Msg {
-		gristapi.ExportDocExcel(docID, filename)
-		return successMsg(fmt.Sprintf("Exported to %s", filename))
+		return exportResult(filename, gristapi.ExportDocExcel(docID, filename, nil))
 	}
 }
 
 func exportGrist(docID, filename string) tea.Cmd {
 	return func() tea.Msg {
-		gristapi.ExportDocGrist(docID, filename)
-		return successMsg(fmt.Sprintf("Exported to %s", filename))
+		return exportResult(filename, gristapi.ExportDocGrist(docID, filename))
+	}
+}
+
+// Directory export files are written to, the current directory if empty
+var exportDir string
+
+// SetExportDir sets the directory export files are written to
+func SetExportDir(dir string) {
+	exportDir = dir
+}
+
+// exportPath returns the path of an export file in the export directory
+func exportPath(fileName string) string {
+	return filepath.Join(exportDir, fileName)
+}
+
+// exportResult turns the outcome of an export into a message,
+// reporting the absolute path of the written file on success
+func exportResult(filename string, err error) tea.Msg {
+	if err != nil {
+		return errMsg(fmt.Errorf("export to %s failed: %w", filename, err))
+	}
+	path, absErr := filepath.Abs(filename)
+	if absErr != nil {
+		path = filename
+	}
+	return successMsg(fmt.Sprintf("Exported to %s", path))
+}
+
+func createTable(docID string, table gristapi.TableDef) tea.Cmd {
+	return func() tea.Msg {
+		created, status := gristapi.CreateTable(docID, table)
+		if status != http.StatusOK {
+			return errMsg(fmt.Errorf("unable to create table %s: HTTP %d", table.Id, status))
+		}
+		invalidateDoc(docID)
+		return tableCreatedMsg(created)
 	}
 }
 
 func loadTableData(docID, tableID string) tea.Cmd {
 	return func() tea.Msg {
-		columns := gristapi.GetTableColumns(docID, tableID)
-		rows := gristapi.GetTableRows(docID, tableID)
+		columns := getTableColumns(docID, tableID)
+		records, status := gristapi.GetRecords(docID, tableID, nil)
+		if status != http.StatusOK {
+			return errMsg(fmt.Errorf("unable to load records of table %s: HTTP %d", tableID, status))
+		}
 
-		// Fetch actual data using the records endpoint
 		data := make(map[string][]interface{})
-		// For now, we'll use the row IDs and column info
-		// The actual data would need a GetTableRecords function
+		rowIDs := make([]uint, 0, len(records.Records))
+		for _, record := range records.Records {
+			rowIDs = append(rowIDs, uint(record.Id))
+			for _, col := range columns.Columns {
+				data[col.Id] = append(data[col.Id], record.Fields[col.Id])
+			}
+		}
 		return tableDataLoadedMsg{
 			columns: columns.Columns,
 			data:    data,
-			rowIDs:  rows.Id,
+			rowIDs:  rowIDs,
+		}
+	}
+}
+
+func updateRecord(docID, tableID string, rowID uint, row int, column string, value interface{}) tea.Cmd {
+	return func() tea.Msg {
+		records := []gristapi.Record{{Id: int(rowID), Fields: map[string]interface{}{column: value}}}
+		response, status := gristapi.UpdateRecords(docID, tableID, records, nil)
+		if status != http.StatusOK {
+			return errMsg(fmt.Errorf("update of %s rejected: HTTP %d %s", column, status, strings.TrimSpace(response)))
 		}
+		return recordUpdatedMsg{row: row, column: column, value: value}
 	}
 }
 
@@ -192,15 +293,59 @@ func loadDocAccess(docID string) tea.Cmd {
 
 func deleteDoc(docID string) tea.Cmd {
 	return func() tea.Msg {
-		gristapi.DeleteDoc(docID)
+		if err := gristapi.DeleteDoc(docID); err != nil {
+			return errMsg(err)
+		}
+		invalidateDoc(docID)
 		return docDeletedMsg{}
 	}
 }
 
+func moveDoc(docID string, workspace gristapi.Workspace) tea.Cmd {
+	return func() tea.Msg {
+		if err := gristapi.MoveDoc(docID, workspace.Id); err != nil {
+			return errMsg(err)
+		}
+		return docMovedMsg(workspace)
+	}
+}
+
+func loadWebhooks(docID string) tea.Cmd {
+	return func() tea.Msg {
+		webhooks, status := gristapi.GetWebhooks(docID)
+		if status != http.StatusOK {
+			return errMsg(fmt.Errorf("unable to load webhooks of document %s: HTTP %d", docID, status))
+		}
+		return webhooksLoadedMsg(webhooks.Webhooks)
+	}
+}
+
+func toggleWebhook(docID string, webhook gristapi.Webhook) tea.Cmd {
+	return func() tea.Msg {
+		enabled := !webhook.Fields.Enabled
+		fields := gristapi.WebhookPartialFields{Enabled: &enabled}
+		if _, status := gristapi.UpdateWebhook(docID, webhook.Id, fields); status != http.StatusOK {
+			return errMsg(fmt.Errorf("unable to update webhook %s: HTTP %d", webhook.Id, status))
+		}
+		if enabled {
+			return webhookChangedMsg(fmt.Sprintf("Webhook %s enabled", webhookName(webhook)))
+		}
+		return webhookChangedMsg(fmt.Sprintf("Webhook %s disabled", webhookName(webhook)))
+	}
+}
+
+func deleteWebhook(docID string, webhook gristapi.Webhook) tea.Cmd {
+	return func() tea.Msg {
+		if _, status := gristapi.DeleteWebhook(docID, webhook.Id); status != http.StatusOK {
+			return errMsg(fmt.Errorf("unable to delete webhook %s: HTTP %d", webhook.Id, status))
+		}
+		return webhookChangedMsg(fmt.Sprintf("Webhook %s deleted", webhookName(webhook)))
+	}
+}
+
 func exportTableCSV(docID, tableID, filename string) tea.Cmd {
 	return func() tea.Msg {
-		gristapi.GetTableContent(docID, tableID)
-		return csvExportedMsg(fmt.Sprintf("Exported %s to CSV", tableID))
+		return exportResult(filename, gristapi.ExportTableCSV(docID, tableID, filename, nil))
 	}
 }
 
@@ -234,25 +379,82 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.message = ""
 		m.err = nil
 
+		if m.editing {
+			return m.handleEditKey(msg)
+		}
+		if m.view == ViewNewTable && !m.loading {
+			return m.handleNewTableKey(msg)
+		}
+
 		switch {
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 
 		case key.Matches(msg, m.keys.Up):
+			if m.view == ViewTableData {
+				m.scrollY = max(m.scrollY-1, 0)
+				break
+			}
 			if m.cursor > 0 {
 				m.cursor--
 			}
+			m.offset = viewportStart(m.offset, m.cursor, m.listHeight(), len(m.items))
 
 		case key.Matches(msg, m.keys.Down):
+			if m.view == ViewTableData {
+				m.scrollY = max(min(m.scrollY+1, len(m.tableRowIDs)-1), 0)
+				break
+			}
 			if m.cursor < len(m.items)-1 {
 				m.cursor++
 			}
+			m.offset = viewportStart(m.offset, m.cursor, m.listHeight(), len(m.items))
+
+		case key.Matches(msg, m.keys.Left) && m.view == ViewTableData:
+			m.scrollX = max(m.scrollX-1, 0)
+
+		case key.Matches(msg, m.keys.Right) && m.view == ViewTableData:
+			m.scrollX = max(min(m.scrollX+1, len(m.tableColumns)-1), 0)
+
+		case key.Matches(msg, m.keys.Edit) && m.view == ViewTableData:
+			return m.startEdit()
+
+		case key.Matches(msg, m.keys.New) && m.view == ViewTables:
+			return m.startNewTable()
+
+		case key.Matches(msg, m.keys.Yank) && !m.loading:
+			id := m.selectedID()
+			if id == "" {
+				break
+			}
+			if err := clipboard.WriteAll(id); err != nil {
+				m.err = fmt.Errorf("unable to copy %s to the clipboard: %w", id, err)
+				break
+			}
+			m.message = fmt.Sprintf("Copied %s", id)
 
 		case key.Matches(msg, m.keys.Select):
 			return m.handleSelect()
 
 		case key.Matches(msg, m.keys.Back):
 			return m.handleBack()
+
+		case key.Matches(msg, m.keys.Toggle) && m.view == ViewWebhooks:
+			webhook, ok := selectedItem[gristapi.Webhook](m)
+			if !ok || m.loading || m.selectedDoc == nil {
+				break
+			}
+			m.loading = true
+			return m, tea.Batch(m.spinner.Tick, toggleWebhook(m.selectedDoc.Id, webhook))
+
+		case key.Matches(msg, m.keys.Delete) && m.view == ViewWebhooks:
+			webhook, ok := selectedItem[gristapi.Webhook](m)
+			if !ok || m.loading {
+				break
+			}
+			m.selectedWebhook = &webhook
+			m.view = ViewConfirmDeleteWebhook
+			m.setConfirmItems("Yes, delete this webhook")
 		}
 
 	case tea.WindowSizeMsg:
@@ -276,17 +478,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case docsLoadedMsg:
 		m.loading = false
-		m.docs = msg.docs
-		// Update workspace info if we got more detail
+		m.docs = msg
 		if m.selectedWorkspace != nil {
-			ws := msg.workspace
-			m.selectedWorkspace = &ws
+			m.selectedWorkspace.Docs = msg
 		}
 		m.updateDocsList()
 
 	case tablesLoadedMsg:
 		m.loading = false
-		m.tables = msg
+		m.tables = msg.tables
+		m.tableRowCounts = msg.rowCounts
 		m.updateTablesList()
 
 	case tableDataLoadedMsg:
@@ -297,6 +498,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.scrollX = 0
 		m.scrollY = 0
 
+	case recordUpdatedMsg:
+		m.loading = false
+		if values := m.tableData[msg.column]; msg.row < len(values) {
+			values[msg.row] = msg.value
+		}
+		m.message = "Record updated"
+
 	case docAccessLoadedMsg:
 		m.loading = false
 		m.docAccess = gristapi.EntityAccess(msg)
@@ -314,9 +522,45 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(m.spinner.Tick, loadDocs(m.selectedWorkspace.Id))
 		}
 
-	case csvExportedMsg:
+	case docMovedMsg:
 		m.loading = false
+		m.message = fmt.Sprintf("Document moved to workspace %s", msg.Name)
+		if m.selectedDoc != nil {
+			m.moveWorkspaceDoc(*m.selectedDoc, msg.Id)
+		}
+		// Go back to docs list and refresh
+		m.view = ViewDocs
+		m.selectedDoc = nil
+		m.breadcrumb = m.breadcrumb[:2]
+		m.cursor = 0
+		if m.selectedWorkspace != nil {
+			return m, tea.Batch(m.spinner.Tick, loadDocs(m.selectedWorkspace.Id))
+		}
+
+	case tableCreatedMsg:
+		m.message = fmt.Sprintf("Table %s created", msg.Id)
+		m.view = ViewTables
+		m.newTableInputs = nil
+		m.cursor = 0
+		if m.selectedDoc != nil {
+			return m, tea.Batch(m.spinner.Tick, loadTables(m.selectedDoc.Id))
+		}
+		m.loading = false
+
+	case webhooksLoadedMsg:
+		m.loading = false
+		m.webhooks = msg
+		m.cursor = min(m.cursor, max(len(msg)-1, 0))
+		m.updateWebhooksList()
+
+	case webhookChangedMsg:
 		m.message = string(msg)
+		m.view = ViewWebhooks
+		m.selectedWebhook = nil
+		if m.selectedDoc != nil {
+			return m, tea.Batch(m.spinner.Tick, loadWebhooks(m.selectedDoc.Id))
+		}
+		m.loading = false
 
 	case successMsg:
 		m.loading = false
@@ -324,12 +568,381 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case errMsg:
 		m.loading = false
+		m.message = ""
 		m.err = msg
+
+	default:
+		// Cursor blinking of the cell being edited
+		if m.editing {
+			var cmd tea.Cmd
+			m.editInput, cmd = m.editInput.Update(msg)
+			return m, cmd
+		}
+		if m.view == ViewNewTable && len(m.newTableInputs) > 0 {
+			var cmd tea.Cmd
+			m.newTableInputs[m.newTableFocus], cmd = m.newTableInputs[m.newTableFocus].Update(msg)
+			return m, cmd
+		}
 	}
 
 	return m, nil
 }
 
+// startEdit opens the selected cell of table data for editing
+func (m Model) startEdit() (tea.Model, tea.Cmd) {
+	if m.loading || len(m.tableRowIDs) == 0 || len(m.tableColumns) == 0 {
+		return m, nil
+	}
+	col := m.tableColumns[m.scrollX]
+	if col.Fields.IsFormula && col.Fields.Formula != "" {
+		m.err = fmt.Errorf("%s is a formula column and can't be edited", col.Id)
+		return m, nil
+	}
+	m.editInput = textinput.New()
+	m.editInput.Prompt = col.Id + ": "
+	m.editInput.SetValue(formatCell(col.Fields.Type, m.cellValue(m.scrollY, col.Id)))
+	m.editInput.CursorEnd()
+	m.editing = true
+	return m, tea.Batch(m.editInput.Focus(), textinput.Blink)
+}
+
+// handleEditKey processes keys while a cell is being edited
+// enter saves the value, esc cancels
+func (m Model) handleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.editing = false
+		return m, nil
+
+	case tea.KeyEnter:
+		if m.selectedDoc == nil || m.selectedTable == nil {
+			m.editing = false
+			return m, nil
+		}
+		col := m.tableColumns[m.scrollX]
+		value, err := coerceCellValue(col.Fields.Type, m.editInput.Value())
+		if err != nil {
+			// Keep editing so that the value can be fixed
+			m.err = err
+			return m, nil
+		}
+		m.editing = false
+		m.loading = true
+		return m, tea.Batch(m.spinner.Tick, updateRecord(m.selectedDoc.Id, m.selectedTable.Id,
+			m.tableRowIDs[m.scrollY], m.scrollY, col.Id, value))
+	}
+
+	var cmd tea.Cmd
+	m.editInput, cmd = m.editInput.Update(msg)
+	return m, cmd
+}
+
+// Layouts of Date and DateTime cells, for display and editing
+const (
+	cellDateLayout     = "2006-01-02"
+	cellDateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// columnLocation returns the timezone of a DateTime column, given after its
+// type, e.g. DateTime:Europe/Paris
+func columnLocation(columnType string) *time.Location {
+	_, timezone, _ := strings.Cut(columnType, ":")
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		return time.UTC
+	}
+	return location
+}
+
+// coerceCellValue converts an edited value to the type of its column
+// Numeric, Int and Bool values are sent as JSON numbers and booleans, Date
+// and DateTime values as seconds since the epoch, Ref values as row IDs and
+// RefList values as lists of row IDs, other types as text for Grist to parse
+func coerceCellValue(columnType, input string) (interface{}, error) {
+	input = strings.TrimSpace(input)
+	baseType, _, _ := strings.Cut(columnType, ":")
+	switch baseType {
+	case "Date":
+		if input == "" {
+			return nil, nil
+		}
+		date, err := time.Parse(cellDateLayout, input)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date (YYYY-MM-DD)", input)
+		}
+		return float64(date.Unix()), nil
+	case "DateTime":
+		if input == "" {
+			return nil, nil
+		}
+		for _, layout := range []string{cellDateTimeLayout, "2006-01-02 15:04", cellDateLayout} {
+			if date, err := time.ParseInLocation(layout, input, columnLocation(columnType)); err == nil {
+				return float64(date.Unix()), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a date and time (YYYY-MM-DD HH:MM:SS)", input)
+	case "Ref":
+		if input == "" {
+			return float64(0), nil
+		}
+		id, err := strconv.Atoi(input)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("%q is not a row ID", input)
+		}
+		// Stored like the values decoded from JSON
+		return float64(id), nil
+	case "RefList":
+		if input == "" {
+			return nil, nil
+		}
+		list := []interface{}{"L"}
+		for _, item := range strings.Split(input, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("%q is not a list of row IDs separated by commas", input)
+			}
+			list = append(list, float64(id))
+		}
+		return list, nil
+	}
+	switch columnType {
+	case "Numeric":
+		if input == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(input, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", input)
+		}
+		return value, nil
+	case "Int":
+		if input == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", input)
+		}
+		return value, nil
+	case "Bool":
+		if input == "" {
+			return false, nil
+		}
+		value, err := strconv.ParseBool(input)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean (true or false)", input)
+		}
+		return value, nil
+	}
+	return input, nil
+}
+
+// selectedID returns the ID of the selected org, workspace, document, table
+// or webhook, to be copied to the clipboard. In action views, it is the ID
+// of the document or table the actions apply to
+func (m Model) selectedID() string {
+	switch m.view {
+	case ViewOrgs:
+		if org, ok := selectedItem[gristapi.Org](m); ok {
+			return strconv.Itoa(org.Id)
+		}
+	case ViewWorkspaces, ViewMoveDoc:
+		if ws, ok := selectedItem[gristapi.Workspace](m); ok {
+			return strconv.Itoa(ws.Id)
+		}
+	case ViewDocs:
+		if doc, ok := selectedItem[gristapi.Doc](m); ok {
+			return doc.Id
+		}
+	case ViewDocActions, ViewDocAccess:
+		if m.selectedDoc != nil {
+			return m.selectedDoc.Id
+		}
+	case ViewTables:
+		if table, ok := selectedItem[gristapi.Table](m); ok {
+			return table.Id
+		}
+	case ViewTableActions, ViewTableData:
+		if m.selectedTable != nil {
+			return m.selectedTable.Id
+		}
+	case ViewWebhooks:
+		if webhook, ok := selectedItem[gristapi.Webhook](m); ok {
+			return webhook.Id
+		}
+	}
+	return ""
+}
+
+// startNewTable opens the form creating a table in the selected document
+func (m Model) startNewTable() (tea.Model, tea.Cmd) {
+	if m.loading || m.selectedDoc == nil {
+		return m, nil
+	}
+	name := textinput.New()
+	name.Prompt = "Table:   "
+	name.Placeholder = "People"
+	columns := textinput.New()
+	columns.Prompt = "Columns: "
+	columns.Placeholder = "Name:Text, Age:Int, Born:Date"
+	m.newTableInputs = []textinput.Model{name, columns}
+	m.newTableFocus = 0
+	m.view = ViewNewTable
+	return m, tea.Batch(m.newTableInputs[0].Focus(), textinput.Blink)
+}
+
+// handleNewTableKey processes keys of the table creation form
+// tab and shift+tab move between fields, enter moves to the next field or
+// creates the table from the last one, esc cancels
+func (m Model) handleNewTableKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.view = ViewTables
+		m.newTableInputs = nil
+		m.updateTablesList()
+		return m, nil
+
+	case tea.KeyTab, tea.KeyShiftTab, tea.KeyUp, tea.KeyDown:
+		next := (m.newTableFocus + 1) % len(m.newTableInputs)
+		if msg.Type == tea.KeyShiftTab || msg.Type == tea.KeyUp {
+			next = (m.newTableFocus + len(m.newTableInputs) - 1) % len(m.newTableInputs)
+		}
+		return m, m.focusNewTableInput(next)
+
+	case tea.KeyEnter:
+		if m.newTableFocus < len(m.newTableInputs)-1 {
+			return m, m.focusNewTableInput(m.newTableFocus + 1)
+		}
+		table, err := m.newTableDef()
+		if err != nil {
+			// Keep the form open so that it can be fixed
+			m.err = err
+			return m, nil
+		}
+		m.loading = true
+		return m, tea.Batch(m.spinner.Tick, createTable(m.selectedDoc.Id, table))
+	}
+
+	var cmd tea.Cmd
+	m.newTableInputs[m.newTableFocus], cmd = m.newTableInputs[m.newTableFocus].Update(msg)
+	return m, cmd
+}
+
+// focusNewTableInput moves the focus of the table creation form to a field
+func (m *Model) focusNewTableInput(index int) tea.Cmd {
+	m.newTableInputs[m.newTableFocus].Blur()
+	m.newTableFocus = index
+	return m.newTableInputs[index].Focus()
+}
+
+// newTableDef builds the definition of the table entered in the creation
+// form, rejecting the IDs of existing tables
+func (m Model) newTableDef() (gristapi.TableDef, error) {
+	tableID := strings.TrimSpace(m.newTableInputs[0].Value())
+	if tableID == "" {
+		return gristapi.TableDef{}, fmt.Errorf("the table name is required")
+	}
+	// Table IDs are unique regardless of case
+	for _, table := range m.tables {
+		if strings.EqualFold(table.Id, tableID) {
+			return gristapi.TableDef{}, fmt.Errorf("table %s already exists", table.Id)
+		}
+	}
+	columns, err := parseColumnSpecs(m.newTableInputs[1].Value())
+	if err != nil {
+		return gristapi.TableDef{}, err
+	}
+	return gristapi.TableDef{Id: tableID, Columns: columns}, nil
+}
+
+// Column types offered by the table creation form, besides Ref:<table>
+// and RefList:<table>
+var newTableColumnTypes = []string{
+	"Text", "Numeric", "Int", "Bool", "Date", "DateTime", "Choice", "ChoiceList", "Any",
+}
+
+// parseColumnSpecs parses comma separated column specs such as
+// "Name:Text, Age:Int", the type being Text when omitted
+func parseColumnSpecs(specs string) ([]gristapi.ColumnDef, error) {
+	columns := []gristapi.ColumnDef{}
+	seen := map[string]bool{}
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		id, columnType, found := strings.Cut(spec, ":")
+		id = strings.TrimSpace(id)
+		columnType = strings.TrimSpace(columnType)
+		if !found {
+			columnType = "Text"
+		}
+		if id == "" {
+			return nil, fmt.Errorf("column %q has no name", spec)
+		}
+		if seen[strings.ToLower(id)] {
+			return nil, fmt.Errorf("column %s is defined twice", id)
+		}
+		seen[strings.ToLower(id)] = true
+
+		baseType, refTable, isRef := strings.Cut(columnType, ":")
+		switch {
+		case isRef && (baseType == "Ref" || baseType == "RefList") && refTable != "":
+		case !slices.Contains(newTableColumnTypes, columnType):
+			return nil, fmt.Errorf("column %s: unknown type %q (expected one of %s, Ref:<table> or RefList:<table>)",
+				id, columnType, strings.Join(newTableColumnTypes, ", "))
+		}
+		columns = append(columns, gristapi.ColumnDef{Id: id, Fields: map[string]interface{}{"type": columnType}})
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one column is required")
+	}
+	return columns, nil
+}
+
+// cellValue returns the value of a column in a row of table data
+func (m Model) cellValue(row int, column string) interface{} {
+	values := m.tableData[column]
+	if row >= len(values) {
+		return nil
+	}
+	return values[row]
+}
+
+// formatCell formats a cell value for display and editing, according to
+// the type of its column: dates as YYYY-MM-DD (followed by the time in the
+// column timezone for DateTime), references as row IDs, separated by commas
+// for RefList, 0 being an empty reference
+func formatCell(columnType string, value interface{}) string {
+	baseType, _, _ := strings.Cut(columnType, ":")
+	number, isNumber := value.(float64)
+	switch {
+	case baseType == "Date" && isNumber:
+		return time.Unix(int64(number), 0).UTC().Format(cellDateLayout)
+	case baseType == "DateTime" && isNumber:
+		return time.Unix(int64(number), 0).In(columnLocation(columnType)).Format(cellDateTimeLayout)
+	case baseType == "Ref" && isNumber && number == 0:
+		return ""
+	case baseType == "RefList":
+		if list, ok := value.([]interface{}); ok && len(list) > 0 && list[0] == "L" {
+			ids := make([]string, len(list)-1)
+			for i, id := range list[1:] {
+				ids[i] = formatCell("", id)
+			}
+			return strings.Join(ids, ", ")
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
 // handleSelect processes enter/select action
 func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 	if len(m.items) == 0 || m.loading {
@@ -338,7 +951,10 @@ func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 
 	switch m.view {
 	case ViewOrgs:
-		org := m.orgs[m.cursor]
+		org, ok := selectedItem[gristapi.Org](m)
+		if !ok {
+			return m, nil
+		}
 		m.selectedOrg = &org
 		m.breadcrumb = []string{org.Name}
 		m.view = ViewWorkspaces
@@ -347,7 +963,10 @@ func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 		return m, tea.Batch(m.spinner.Tick, loadWorkspaces(org.Id))
 
 	case ViewWorkspaces:
-		ws := m.workspaces[m.cursor]
+		ws, ok := selectedItem[gristapi.Workspace](m)
+		if !ok {
+			return m, nil
+		}
 		m.selectedWorkspace = &ws
 		m.breadcrumb = append(m.breadcrumb, ws.Name)
 		m.view = ViewDocs
@@ -356,10 +975,10 @@ func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 		return m, tea.Batch(m.spinner.Tick, loadDocs(ws.Id))
 
 	case ViewDocs:
-		if len(m.docs) == 0 {
+		doc, ok := selectedItem[gristapi.Doc](m)
+		if !ok {
 			return m, nil
 		}
-		doc := m.docs[m.cursor]
 		m.selectedDoc = &doc
 		m.breadcrumb = append(m.breadcrumb, doc.Name)
 		m.view = ViewDocActions
@@ -367,13 +986,15 @@ func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 		m.updateActionsList()
 
 	case ViewDocActions:
-		return m.handleDocAction(DocAction(m.cursor))
+		if action, ok := selectedItem[DocAction](m); ok {
+			return m.handleDocAction(action)
+		}
 
 	case ViewTables:
-		if len(m.tables) == 0 {
+		table, ok := selectedItem[gristapi.Table](m)
+		if !ok {
 			return m, nil
 		}
-		table := m.tables[m.cursor]
 		m.selectedTable = &table
 		m.breadcrumb = append(m.breadcrumb, table.Id)
 		m.view = ViewTableActions
@@ -381,11 +1002,12 @@ func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 		m.updateTableActionsList()
 
 	case ViewTableActions:
-		return m.handleTableAction(TableAction(m.cursor))
+		if action, ok := selectedItem[TableAction](m); ok {
+			return m.handleTableAction(action)
+		}
 
 	case ViewConfirmDelete:
-		// Yes/No confirmation - cursor 0 = Yes, cursor 1 = No
-		if m.cursor == 0 && m.selectedDoc != nil {
+		if confirmed, _ := selectedItem[bool](m); confirmed && m.selectedDoc != nil {
 			m.loading = true
 			return m, tea.Batch(m.spinner.Tick, deleteDoc(m.selectedDoc.Id))
 		}
@@ -393,6 +1015,35 @@ func (m Model) handleSelect() (tea.Model, tea.Cmd) {
 		m.view = ViewDocActions
 		m.cursor = 0
 		m.updateActionsList()
+
+	case ViewMoveDoc:
+		if m.selectedDoc == nil || m.selectedWorkspace == nil {
+			return m, nil
+		}
+		ws, ok := selectedItem[gristapi.Workspace](m)
+		if !ok {
+			return m, nil
+		}
+		if ws.Id == m.selectedWorkspace.Id {
+			m.message = fmt.Sprintf("Document is already in workspace %s", ws.Name)
+			m.view = ViewDocActions
+			m.cursor = int(ActionMove)
+			m.updateActionsList()
+			return m, nil
+		}
+		m.loading = true
+		return m, tea.Batch(m.spinner.Tick, moveDoc(m.selectedDoc.Id, ws))
+
+	case ViewConfirmDeleteWebhook:
+		if confirmed, _ := selectedItem[bool](m); confirmed && m.selectedDoc != nil && m.selectedWebhook != nil {
+			m.loading = true
+			return m, tea.Batch(m.spinner.Tick, deleteWebhook(m.selectedDoc.Id, *m.selectedWebhook))
+		}
+		// Cancel - go back to webhooks
+		m.view = ViewWebhooks
+		m.selectedWebhook = nil
+		m.cursor = 0
+		m.updateWebhooksList()
 	}
 
 	return m, nil
@@ -415,15 +1066,15 @@ func (m Model) handleDocAction(action DocAction) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(m.spinner.Tick, loadTables(docID))
 
 	case ActionExportExcel:
-		filename := sanitizeFilename(docName) + ".xlsx"
+		filename := exportPath(gristapi.ExportFileName("xlsx", docName))
 		m.loading = true
-		m.message = "Exporting..."
+		m.message = fmt.Sprintf("Exporting %s...", filename)
 		return m, tea.Batch(m.spinner.Tick, exportExcel(docID, filename))
 
 	case ActionExportGrist:
-		filename := sanitizeFilename(docName) + ".grist"
+		filename := exportPath(gristapi.ExportFileName("grist", docName))
 		m.loading = true
-		m.message = "Exporting..."
+		m.message = fmt.Sprintf("Exporting %s...", filename)
 		return m, tea.Batch(m.spinner.Tick, exportGrist(docID, filename))
 
 	case ActionViewAccess:
@@ -432,10 +1083,30 @@ func (m Model) handleDocAction(action DocAction) (tea.Model, tea.Cmd) {
 		m.loading = true
 		return m, tea.Batch(m.spinner.Tick, loadDocAccess(docID))
 
+	case ActionViewWebhooks:
+		m.view = ViewWebhooks
+		m.cursor = 0
+		m.loading = true
+		return m, tea.Batch(m.spinner.Tick, loadWebhooks(docID))
+
+	case ActionMove:
+		if len(m.workspaces) == 0 {
+			m.err = fmt.Errorf("no workspace to move %s to", docName)
+			return m, nil
+		}
+		m.view = ViewMoveDoc
+		m.updateMoveTargetsList()
+		m.cursor = 0
+		for i, item := range m.itemIDs {
+			if ws, ok := item.(gristapi.Workspace); ok && m.selectedWorkspace != nil && ws.Id == m.selectedWorkspace.Id {
+				m.cursor = i
+			}
+		}
+		m.offset = viewportStart(0, m.cursor, m.listHeight(), len(m.items))
+
 	case ActionDelete:
 		m.view = ViewConfirmDelete
-		m.cursor = 1 // Default to "No" for safety
-		m.items = []string{"Yes, delete this document", "No, cancel"}
+		m.setConfirmItems("Yes, delete this document")
 	}
 
 	return m, nil
@@ -457,7 +1128,7 @@ func (m Model) handleTableAction(action TableAction) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(m.spinner.Tick, loadTableData(docID, tableID))
 
 	case TableActionExportCSV:
-		filename := sanitizeFilename(tableID) + ".csv"
+		filename := exportPath(gristapi.ExportFileName("csv", tableID))
 		m.loading = true
 		m.message = "Exporting CSV..."
 		return m, tea.Batch(m.spinner.Tick, exportTableCSV(docID, tableID, filename))
@@ -518,61 +1189,142 @@ func (m Model) handleBack() (tea.Model, tea.Cmd) {
 		m.cursor = 0
 		m.updateActionsList()
 
-	case ViewConfirmDelete:
+	case ViewConfirmDelete, ViewMoveDoc:
+		m.view = ViewDocActions
+		m.cursor = 0
+		m.updateActionsList()
+
+	case ViewWebhooks:
 		m.view = ViewDocActions
+		m.breadcrumb = m.breadcrumb[:3]
 		m.cursor = 0
 		m.updateActionsList()
+
+	case ViewConfirmDeleteWebhook:
+		m.view = ViewWebhooks
+		m.selectedWebhook = nil
+		m.cursor = 0
+		m.updateWebhooksList()
 	}
 
 	return m, nil
 }
 
-// Update item lists for each view
+// selectedItem returns the item under the cursor, as stored in itemIDs
+func selectedItem[T any](m Model) (T, bool) {
+	var item T
+	if m.cursor < 0 || m.cursor >= len(m.itemIDs) {
+		return item, false
+	}
+	item, ok := m.itemIDs[m.cursor].(T)
+	return item, ok
+}
+
+// setConfirmItems lists the Yes/No choices of a confirmation
+func (m *Model) setConfirmItems(yes string) {
+	m.items = []string{yes, "No, cancel"}
+	m.itemIDs = []interface{}{true, false}
+	m.cursor = 1 // Default to "No" for safety
+}
+
+// Update item lists for each view, along with the items they display
 func (m *Model) updateOrgsList() {
 	m.items = make([]string, len(m.orgs))
+	m.itemIDs = make([]interface{}, len(m.orgs))
 	for i, org := range m.orgs {
 		m.items[i] = org.Name
+		m.itemIDs[i] = org
 	}
 }
 
 func (m *Model) updateWorkspacesList() {
 	m.items = make([]string, len(m.workspaces))
+	m.itemIDs = make([]interface{}, len(m.workspaces))
 	for i, ws := range m.workspaces {
 		docCount := len(ws.Docs)
 		m.items[i] = fmt.Sprintf("%s (%d docs)", ws.Name, docCount)
+		m.itemIDs[i] = ws
 	}
 }
 
 func (m *Model) updateDocsList() {
 	m.items = make([]string, len(m.docs))
+	m.itemIDs = make([]interface{}, len(m.docs))
 	for i, doc := range m.docs {
 		name := doc.Name
 		if doc.IsPinned {
 			name += " [pinned]"
 		}
+		if modified := doc.LastModified(); !modified.IsZero() {
+			name += fmt.Sprintf(" (modified %s)", modified.Local().Format("2006-01-02 15:04"))
+		}
 		m.items[i] = name
+		m.itemIDs[i] = doc
+	}
+}
+
+// updateMoveTargetsList lists the workspaces of the organization a document
+// can be moved to, marking the current one
+func (m *Model) updateMoveTargetsList() {
+	m.updateWorkspacesList()
+	for i, item := range m.itemIDs {
+		if ws := item.(gristapi.Workspace); m.selectedWorkspace != nil && ws.Id == m.selectedWorkspace.Id {
+			m.items[i] += " (current)"
+		}
+	}
+}
+
+// moveWorkspaceDoc moves a document between the loaded workspaces, to keep
+// their document counts right without reloading them
+func (m *Model) moveWorkspaceDoc(doc gristapi.Doc, workspaceID int) {
+	for i := range m.workspaces {
+		ws := &m.workspaces[i]
+		for j, wsDoc := range ws.Docs {
+			if wsDoc.Id == doc.Id {
+				ws.Docs = append(ws.Docs[:j:j], ws.Docs[j+1:]...)
+				break
+			}
+		}
+		if ws.Id == workspaceID {
+			ws.Docs = append(ws.Docs, doc)
+		}
 	}
 }
 
 func (m *Model) updateActionsList() {
 	m.items = make([]string, len(docActionLabels))
-	copy(m.items, docActionLabels)
+	m.itemIDs = make([]interface{}, len(docActionLabels))
+	for i, label := range docActionLabels {
+		m.items[i] = label
+		m.itemIDs[i] = DocAction(i)
+	}
 }
 
 func (m *Model) updateTablesList() {
 	m.items = make([]string, len(m.tables))
+	m.itemIDs = make([]interface{}, len(m.tables))
 	for i, t := range m.tables {
-		m.items[i] = t.Id
+		if nbRows, ok := m.tableRowCounts[t.Id]; ok {
+			m.items[i] = fmt.Sprintf("%s (%d rows)", t.Id, nbRows)
+		} else {
+			m.items[i] = fmt.Sprintf("%s (? rows)", t.Id)
+		}
+		m.itemIDs[i] = t
 	}
 }
 
 func (m *Model) updateTableActionsList() {
 	m.items = make([]string, len(tableActionLabels))
-	copy(m.items, tableActionLabels)
+	m.itemIDs = make([]interface{}, len(tableActionLabels))
+	for i, label := range tableActionLabels {
+		m.items[i] = label
+		m.itemIDs[i] = TableAction(i)
+	}
 }
 
 func (m *Model) updateAccessList() {
 	m.items = make([]string, len(m.docAccess.Users))
+	m.itemIDs = make([]interface{}, len(m.docAccess.Users))
 	for i, user := range m.docAccess.Users {
 		access := user.Access
 		if access == "" {
@@ -582,9 +1334,37 @@ func (m *Model) updateAccessList() {
 			}
 		}
 		m.items[i] = fmt.Sprintf("%s <%s> - %s", user.Name, user.Email, access)
+		m.itemIDs[i] = user
+	}
+}
+
+func (m *Model) updateWebhooksList() {
+	m.items = make([]string, len(m.webhooks))
+	m.itemIDs = make([]interface{}, len(m.webhooks))
+	for i, wh := range m.webhooks {
+		state := "disabled"
+		if wh.Fields.Enabled {
+			state = "enabled"
+		}
+		if wh.Usage != nil && wh.Usage.Status != "" {
+			state += ", " + wh.Usage.Status
+			if wh.Usage.NumWaiting > 0 {
+				state += fmt.Sprintf(", %d waiting", wh.Usage.NumWaiting)
+			}
+		}
+		m.items[i] = fmt.Sprintf("%s on %s [%s]", webhookName(wh), wh.Fields.TableId, state)
+		m.itemIDs[i] = wh
 	}
 }
 
+// webhookName returns the name of a webhook, or its URL if it has none
+func webhookName(webhook gristapi.Webhook) string {
+	if webhook.Fields.Name != "" {
+		return webhook.Fields.Name
+	}
+	return webhook.Fields.URL
+}
+
 // View implements tea.Model
 func (m Model) View() string {
 	var b strings.Builder
@@ -614,6 +1394,14 @@ func (m Model) View() string {
 		title = "Document Access"
 	case ViewConfirmDelete:
 		title = "Confirm Delete"
+	case ViewWebhooks:
+		title = "Webhooks"
+	case ViewConfirmDeleteWebhook:
+		title = "Confirm Delete Webhook"
+	case ViewMoveDoc:
+		title = "Move to Workspace"
+	case ViewNewTable:
+		title = "New Table"
 	}
 	b.WriteString(TitleStyle.Render(title))
 	b.WriteString("\n")
@@ -621,6 +1409,8 @@ func (m Model) View() string {
 	// Special view for table data
 	if m.view == ViewTableData && !m.loading {
 		b.WriteString(m.renderTableData())
+	} else if m.view == ViewNewTable && !m.loading {
+		b.WriteString(m.renderNewTable())
 	} else if m.view == ViewConfirmDelete && !m.loading {
 		// Show warning for delete confirmation
 		if m.selectedDoc != nil {
@@ -639,6 +1429,22 @@ func (m Model) View() string {
 			}
 			b.WriteString(cursor + style.Render(item) + "\n")
 		}
+	} else if m.view == ViewConfirmDeleteWebhook && !m.loading {
+		if m.selectedWebhook != nil {
+			b.WriteString(ErrorStyle.Render(fmt.Sprintf("Are you sure you want to delete webhook '%s'?", webhookName(*m.selectedWebhook))))
+			b.WriteString("\n")
+			b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render("This action cannot be undone."))
+			b.WriteString("\n\n")
+		}
+		for i, item := range m.items {
+			cursor := "  "
+			style := ItemStyle
+			if i == m.cursor {
+				cursor = CursorStyle.Render()
+				style = SelectedItemStyle
+			}
+			b.WriteString(cursor + style.Render(item) + "\n")
+		}
 	} else if m.loading {
 		// Loading state
 		b.WriteString(m.spinner.View() + " Loading...\n")
@@ -649,15 +1455,23 @@ func (m Model) View() string {
 		b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render("(empty)"))
 		b.WriteString("\n")
 	} else {
-		// Render list items
-		for i, item := range m.items {
+		// Render only the visible window of list items
+		height := m.listHeight()
+		start := viewportStart(m.offset, m.cursor, height, len(m.items))
+		end := min(start+height, len(m.items))
+		for i := start; i < end; i++ {
 			cursor := "  "
 			style := ItemStyle
 			if i == m.cursor {
 				cursor = CursorStyle.Render()
 				style = SelectedItemStyle
 			}
-			b.WriteString(cursor + style.Render(item) + "\n")
+			b.WriteString(cursor + style.Render(m.items[i]) + "\n")
+		}
+		if len(m.items) > height {
+			b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render(
+				fmt.Sprintf("%d-%d of %d", start+1, end, len(m.items))))
+			b.WriteString("\n")
 		}
 	}
 
@@ -671,20 +1485,46 @@ func (m Model) View() string {
 	// Footer with help
 	b.WriteString("\n")
 	help := []string{}
-	help = append(help, HelpKeyStyle.Render("enter")+" select")
-	if m.view != ViewOrgs {
+	switch {
+	case m.editing:
+		help = append(help, HelpKeyStyle.Render("enter")+" save", HelpKeyStyle.Render("esc")+" cancel")
+	case m.view == ViewNewTable:
+		help = append(help, HelpKeyStyle.Render("tab")+" next field", HelpKeyStyle.Render("enter")+" create",
+			HelpKeyStyle.Render("esc")+" cancel")
+	case m.view == ViewTableData:
+		help = append(help, HelpKeyStyle.Render("←↑↓→")+" move", HelpKeyStyle.Render("e")+" edit")
+	default:
+		help = append(help, HelpKeyStyle.Render("enter")+" select")
+	}
+	if m.view == ViewWebhooks {
+		help = append(help, HelpKeyStyle.Render("t")+" toggle", HelpKeyStyle.Render("d")+" delete")
+	}
+	if m.view == ViewTables {
+		help = append(help, HelpKeyStyle.Render("n")+" new table")
+	}
+	if m.selectedID() != "" && !m.editing {
+		help = append(help, HelpKeyStyle.Render("y")+" copy ID")
+	}
+	if m.view != ViewOrgs && m.view != ViewNewTable && !m.editing {
 		help = append(help, HelpKeyStyle.Render("esc")+" back")
 	}
-	help = append(help, HelpKeyStyle.Render("q")+" quit")
+	if !m.editing && m.view != ViewNewTable {
+		help = append(help, HelpKeyStyle.Render("q")+" quit")
+	}
 	b.WriteString(HelpStyle.Render(strings.Join(help, "  ")))
 
 	return AppStyle.Render(b.String())
 }
 
-// renderTableData renders the table data view
+// renderTableData renders the table data view, highlighting the selected cell
 func (m Model) renderTableData() string {
 	var b strings.Builder
 
+	if m.err != nil {
+		b.WriteString(ErrorStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+		b.WriteString("\n")
+	}
+
 	if len(m.tableColumns) == 0 {
 		b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render("No columns found"))
 		b.WriteString("\n")
@@ -697,58 +1537,107 @@ func (m Model) renderTableData() string {
 	}
 	b.WriteString(fmt.Sprintf("Columns: %d | Rows: %d\n\n", len(m.tableColumns), len(m.tableRowIDs)))
 
-	// Render column headers
-	headers := make([]string, len(m.tableColumns))
-	for i, col := range m.tableColumns {
-		headers[i] = TableHeaderStyle.Render(fmt.Sprintf(" %-15s ", col.Id))
+	// Only the columns and rows fitting on screen are shown, scrolling
+	// to keep the selected cell visible
+	const cellWidth = 15
+	colCount := len(m.tableColumns)
+	if m.width > 0 {
+		colCount = max((m.width-12)/(cellWidth+3), 1)
 	}
-	b.WriteString(strings.Join(headers, "|"))
-	b.WriteString("\n")
+	colStart := max(m.scrollX-colCount+1, 0)
+	colEnd := min(colStart+colCount, len(m.tableColumns))
+	rowCount := max(m.listHeight()-4, 1)
+	rowStart := max(m.scrollY-rowCount+1, 0)
+	rowEnd := min(rowStart+rowCount, len(m.tableRowIDs))
 
-	// Separator line
-	sep := strings.Repeat("-", len(m.tableColumns)*18)
-	b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render(sep))
-	b.WriteString("\n")
-
-	// Show row IDs (we don't have full data yet, but we can show row count)
-	maxRows := 10
-	if len(m.tableRowIDs) < maxRows {
-		maxRows = len(m.tableRowIDs)
+	// Render column headers
+	headers := []string{fmt.Sprintf(" %-6s ", "id")}
+	for _, col := range m.tableColumns[colStart:colEnd] {
+		headers = append(headers, fmt.Sprintf(" %-*s ", cellWidth, truncate(col.Id, cellWidth)))
 	}
+	b.WriteString(TableHeaderStyle.Render(strings.Join(headers, "|")))
+	b.WriteString("\n")
 
-	for i := 0; i < maxRows; i++ {
-		rowID := m.tableRowIDs[i]
-		// Show row ID in first "column" position
-		b.WriteString(TableCellStyle.Render(fmt.Sprintf(" Row %-10d ", rowID)))
-		for j := 1; j < len(m.tableColumns); j++ {
-			b.WriteString(TableCellStyle.Render(fmt.Sprintf(" %-15s ", "-")))
+	for row := rowStart; row < rowEnd; row++ {
+		b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render(fmt.Sprintf(" %-6d ", m.tableRowIDs[row])))
+		for i := colStart; i < colEnd; i++ {
+			col := m.tableColumns[i]
+			cell := fmt.Sprintf(" %-*s ", cellWidth, truncate(formatCell(col.Fields.Type, m.cellValue(row, col.Id)), cellWidth))
+			style := TableCellStyle
+			if row == m.scrollY && i == m.scrollX {
+				style = TableSelectedCellStyle
+			}
+			b.WriteString("|" + style.Render(cell))
 		}
 		b.WriteString("\n")
 	}
 
-	if len(m.tableRowIDs) > maxRows {
+	if len(m.tableRowIDs) > rowCount {
 		b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render(
-			fmt.Sprintf("\n... and %d more rows", len(m.tableRowIDs)-maxRows)))
+			fmt.Sprintf("\nRow %d of %d", m.scrollY+1, len(m.tableRowIDs))))
+		b.WriteString("\n")
+	}
+
+	if m.editing {
+		b.WriteString("\n")
+		b.WriteString(m.editInput.View())
 		b.WriteString("\n")
 	}
 
 	return b.String()
 }
 
-// sanitizeFilename makes a string safe for use as a filename
-func sanitizeFilename(s string) string {
-	replacer := strings.NewReplacer(
-		"/", "_",
-		"\\", "_",
-		":", "_",
-		"*", "_",
-		"?", "_",
-		"\"", "_",
-		"<", "_",
-		">", "_",
-		"|", "_",
-	)
-	return replacer.Replace(s)
+// renderNewTable renders the table creation form
+func (m Model) renderNewTable() string {
+	var b strings.Builder
+	for _, input := range m.newTableInputs {
+		b.WriteString(input.View())
+		b.WriteString("\n")
+	}
+	b.WriteString(lipgloss.NewStyle().Foreground(ColorMuted).Render(
+		"Columns are comma separated name:type pairs, Text if the type is omitted"))
+	b.WriteString("\n")
+	if m.err != nil {
+		b.WriteString("\n")
+		b.WriteString(ErrorStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// truncate shortens a string to width runes, on a single line
+func truncate(s string, width int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// listHeight returns the number of list items that fit on screen
+func (m Model) listHeight() int {
+	// Breadcrumb, title, messages and footer take roughly 12 lines
+	const reserved = 12
+	if m.height == 0 {
+		return 20
+	}
+	return max(m.height-reserved, 3)
+}
+
+// viewportStart returns the index of the first visible item so that
+// the cursor stays within a window of height items
+func viewportStart(offset, cursor, height, total int) int {
+	if total <= height {
+		return 0
+	}
+	if cursor < offset {
+		offset = cursor
+	}
+	if cursor >= offset+height {
+		offset = cursor - height + 1
+	}
+	return min(max(offset, 0), total-height)
 }
 
 // Run starts the TUI
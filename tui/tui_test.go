@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdmorin/gristle/gristapi"
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+func TestColumnLocation(t *testing.T) {
+	tests := []struct {
+		columnType string
+		expected   string
+	}{
+		{"DateTime:Europe/Paris", "Europe/Paris"},
+		{"DateTime", "UTC"},
+		{"DateTime:", "UTC"},
+		{"DateTime:Nowhere/City", "UTC"},
+	}
+	for _, tt := range tests {
+		if got := columnLocation(tt.columnType).String(); got != tt.expected {
+			t.Errorf("columnLocation(%q) = %s, expected %s", tt.columnType, got, tt.expected)
+		}
+	}
+}
+
+func TestCoerceCellValue(t *testing.T) {
+	tests := []struct {
+		columnType string
+		input      string
+		expected   interface{}
+	}{
+		{"Text", "  hello ", "hello"},
+		{"Choice", "Red", "Red"},
+		{"Numeric", "3.5", 3.5},
+		{"Numeric", "", nil},
+		{"Int", "42", int64(42)},
+		{"Bool", "true", true},
+		{"Bool", "", false},
+		{"Date", "2024-03-15", float64(1710460800)},
+		{"Date", "", nil},
+		{"DateTime", "2024-03-15 14:30:00", float64(1710513000)},
+		{"DateTime", "2024-03-15 14:30", float64(1710513000)},
+		// Entered in the timezone of the column, 1 hour ahead of UTC in March
+		{"DateTime:Europe/Paris", "2024-03-15 14:30:00", float64(1710509400)},
+		{"DateTime:Europe/Paris", "2024-03-15", float64(1710457200)},
+		{"Ref:People", "12", float64(12)},
+		{"Ref:People", "", float64(0)},
+		{"RefList:People", "1, 2,3", []interface{}{"L", float64(1), float64(2), float64(3)}},
+		{"RefList:People", "", nil},
+	}
+	for _, tt := range tests {
+		got, err := coerceCellValue(tt.columnType, tt.input)
+		if err != nil {
+			t.Errorf("coerceCellValue(%q, %q) failed: %v", tt.columnType, tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("coerceCellValue(%q, %q) = %#v, expected %#v", tt.columnType, tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestCoerceCellValue_Invalid(t *testing.T) {
+	tests := []struct {
+		columnType string
+		input      string
+	}{
+		{"Numeric", "abc"},
+		{"Int", "4.2"},
+		{"Bool", "maybe"},
+		{"Date", "15/03/2024"},
+		{"DateTime", "2024-03-15T14:30"},
+		{"Ref:People", "-1"},
+		{"Ref:People", "Alice"},
+		{"RefList:People", "1, x"},
+		{"RefList:People", "0"},
+	}
+	for _, tt := range tests {
+		if got, err := coerceCellValue(tt.columnType, tt.input); err == nil {
+			t.Errorf("coerceCellValue(%q, %q) = %#v, expected an error", tt.columnType, tt.input, got)
+		}
+	}
+}
+
+func TestFormatCell(t *testing.T) {
+	tests := []struct {
+		columnType string
+		value      interface{}
+		expected   string
+	}{
+		{"Text", "hello", "hello"},
+		{"Text", nil, ""},
+		{"Numeric", 3.5, "3.5"},
+		{"Int", float64(42), "42"},
+		{"Bool", true, "true"},
+		{"Date", float64(1710460800), "2024-03-15"},
+		{"Date", nil, ""},
+		{"DateTime", float64(1710513000), "2024-03-15 14:30:00"},
+		{"DateTime:Europe/Paris", float64(1710509400), "2024-03-15 14:30:00"},
+		{"Ref:People", float64(0), ""},
+		{"Ref:People", float64(12), "12"},
+		{"RefList:People", []interface{}{"L", float64(1), float64(2)}, "1, 2"},
+		{"RefList:People", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := formatCell(tt.columnType, tt.value); got != tt.expected {
+			t.Errorf("formatCell(%q, %#v) = %q, expected %q", tt.columnType, tt.value, got, tt.expected)
+		}
+	}
+}
+
+// A cell edited without change keeps its value
+func TestFormatCell_RoundTrip(t *testing.T) {
+	newYork := time.Date(2024, 7, 4, 9, 15, 0, 0, time.FixedZone("EDT", -4*3600))
+	tests := []struct {
+		columnType string
+		value      interface{}
+	}{
+		{"Text", "hello"},
+		{"Numeric", 3.5},
+		{"Bool", true},
+		{"Date", float64(1710460800)},
+		{"DateTime", float64(1710513000)},
+		{"DateTime:Europe/Paris", float64(1710509400)},
+		{"DateTime:America/New_York", float64(newYork.Unix())},
+		{"Ref:People", float64(0)},
+		{"Ref:People", float64(7)},
+		{"RefList:People", []interface{}{"L", float64(3), float64(5)}},
+	}
+	for _, tt := range tests {
+		text := formatCell(tt.columnType, tt.value)
+		got, err := coerceCellValue(tt.columnType, text)
+		if err != nil || !reflect.DeepEqual(got, tt.value) {
+			t.Errorf("%s %#v formatted as %q read back as %#v (%v)", tt.columnType, tt.value, text, got, err)
+		}
+	}
+}
+
+func TestParseColumnSpecs(t *testing.T) {
+	specs := "Name, Age:Int , Born: Date, Manager:Ref:People, Tags:RefList:Tags"
+	columns, err := parseColumnSpecs(specs)
+	if err != nil {
+		t.Fatalf("parseColumnSpecs(%q) failed: %v", specs, err)
+	}
+	expected := []gristapi.ColumnDef{
+		{Id: "Name", Fields: map[string]interface{}{"type": "Text"}},
+		{Id: "Age", Fields: map[string]interface{}{"type": "Int"}},
+		{Id: "Born", Fields: map[string]interface{}{"type": "Date"}},
+		{Id: "Manager", Fields: map[string]interface{}{"type": "Ref:People"}},
+		{Id: "Tags", Fields: map[string]interface{}{"type": "RefList:Tags"}},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("parseColumnSpecs(%q) = %+v, expected %+v", specs, columns, expected)
+	}
+}
+
+func TestParseColumnSpecs_Invalid(t *testing.T) {
+	tests := []struct {
+		specs string
+		error string
+	}{
+		{"", "at least one column"},
+		{" , ", "at least one column"},
+		{":Int", "has no name"},
+		{"Name, name:Text", "defined twice"},
+		{"Age:Integer", "unknown type"},
+		{"Manager:Ref", "unknown type"},
+		{"Manager:Ref:", "unknown type"},
+		{"Manager:Link:People", "unknown type"},
+	}
+	for _, tt := range tests {
+		_, err := parseColumnSpecs(tt.specs)
+		if err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("parseColumnSpecs(%q) returned %v, expected an error with %q", tt.specs, err, tt.error)
+		}
+	}
+}
+
+func TestNewTableDef(t *testing.T) {
+	form := func(tableID string, specs string) Model {
+		name := textinput.New()
+		name.SetValue(tableID)
+		columns := textinput.New()
+		columns.SetValue(specs)
+		return Model{
+			tables:         []gristapi.Table{{Id: "People"}},
+			newTableInputs: []textinput.Model{name, columns},
+		}
+	}
+
+	table, err := form(" Projects ", "Title, Lead:Ref:People").newTableDef()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if table.Id != "Projects" || len(table.Columns) != 2 || table.Columns[1].Fields["type"] != "Ref:People" {
+		t.Errorf("Unexpected table definition: %+v", table)
+	}
+
+	tests := []struct {
+		tableID string
+		specs   string
+		error   string
+	}{
+		{"", "Name", "table name is required"},
+		{"People", "Name", "table People already exists"},
+		// Table IDs are unique regardless of case
+		{"people", "Name", "table People already exists"},
+		{"Projects", "Title, title", "defined twice"},
+		{"Projects", "Title:Varchar", "unknown type"},
+	}
+	for _, tt := range tests {
+		_, err := form(tt.tableID, tt.specs).newTableDef()
+		if err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("newTableDef(%q, %q) returned %v, expected an error with %q", tt.tableID, tt.specs, err, tt.error)
+		}
+	}
+}